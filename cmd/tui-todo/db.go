@@ -0,0 +1,403 @@
+package main
+
+import (
+	"cmp"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/config"
+	"github.com/martijnspitter/tui-todo/internal/encryption"
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+const dbUsage = `usage: todo db <command>
+
+Commands:
+  status               show the applied and pending migrations
+  migrate [--dry-run]  apply the pending migrations, --dry-run tries them without saving
+  rollback --to <id>   undo the migrations after <id>, so an older version of todo can
+                       open the database
+  encrypt              encrypt the titles and descriptions with a passphrase
+  decrypt              store the titles and descriptions in plain text again`
+
+// runDB implements `todo db`, which inspects and migrates the database schema
+func runDB(args []string, appVersion string, cfg *config.Config) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, dbUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "status":
+		return runDBStatus(args[1:], appVersion, cfg)
+	case "migrate":
+		return runDBMigrate(args[1:], appVersion, cfg)
+	case "rollback":
+		return runDBRollback(args[1:], appVersion, cfg)
+	case "encrypt":
+		return runDBEncrypt(args[1:], appVersion, cfg)
+	case "decrypt":
+		return runDBDecrypt(args[1:], appVersion, cfg)
+	default:
+		fmt.Fprintln(os.Stderr, dbUsage)
+		return 2
+	}
+}
+
+func runDBStatus(args []string, appVersion string, cfg *config.Config) int {
+	fs := flag.NewFlagSet("db status", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	db, manager, err := openMigrations(cfg, appVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	migrations := repository.GetAllMigrations()
+	statuses, err := manager.Status(migrations)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to read migrations:", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tAPPLIED AT\tROLLBACK")
+	for _, status := range statuses {
+		state, appliedAt, rollback := "pending", "", "no"
+		switch {
+		case status.Unknown:
+			state = "newer version"
+		case status.Applied:
+			state = "applied"
+		}
+		if status.Applied {
+			appliedAt = status.AppliedAt.Local().Format(time.DateTime)
+		}
+		if status.Reversible {
+			rollback = "yes"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", status.ID, status.Name, state, appliedAt, rollback)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	header, err := repository.ReadEncryptionHeader(db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to read encryption settings:", err)
+		return 1
+	}
+	if header != nil {
+		fmt.Println("\nTitles and descriptions are encrypted")
+	} else {
+		fmt.Println("\nTitles and descriptions are stored in plain text")
+	}
+
+	if err := manager.CheckVersion(migrations); err != nil {
+		fmt.Fprintln(os.Stderr, "\n"+err.Error())
+		return 1
+	}
+	return 0
+}
+
+func runDBMigrate(args []string, appVersion string, cfg *config.Config) int {
+	fs := flag.NewFlagSet("db migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "try the pending migrations without saving them")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	db, manager, err := openMigrations(cfg, appVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	migrations := repository.GetAllMigrations()
+	if *dryRun {
+		pending, err := manager.DryRun(migrations)
+		if err == nil || len(pending) > 0 {
+			printMigrations("Would apply", pending)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(pending) > 0 {
+			fmt.Println("The migrations apply cleanly, nothing was saved")
+		}
+		return 0
+	}
+
+	pending, err := manager.Pending(migrations)
+	if err == nil {
+		err = manager.ApplyMigrations(migrations)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	printMigrations("Applied", pending)
+	return 0
+}
+
+func runDBRollback(args []string, appVersion string, cfg *config.Config) int {
+	fs := flag.NewFlagSet("db rollback", flag.ContinueOnError)
+	to := fs.Int("to", -1, "the migration to roll back to, it stays applied")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *to < 0 || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: todo db rollback --to <id>")
+		return 2
+	}
+
+	// A running instance would write with the schema it was started on
+	if instanceRunning(appVersion) {
+		fmt.Fprintln(os.Stderr, "todo is running, close it before rolling back the database")
+		return 1
+	}
+
+	db, migrationManager, err := openMigrations(cfg, appVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	migrations := repository.GetAllMigrations()
+	undo, err := migrationManager.Rollbacks(migrations, *to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(undo) == 0 {
+		printMigrations("Rolled back", nil)
+		return 0
+	}
+
+	// Rolling back drops columns and tables, keep what they held
+	backup, err := backupDatabase(db, appVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to back up the database:", err)
+		return 1
+	}
+	fmt.Printf("Backed up the database to %s\n", backup)
+
+	undone, err := migrationManager.RollbackTo(migrations, *to)
+	if len(undone) > 0 {
+		printMigrations("Rolled back", undone)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func runDBEncrypt(args []string, appVersion string, cfg *config.Config) int {
+	fs := flag.NewFlagSet("db encrypt", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// A running instance would keep writing plain text
+	if instanceRunning(appVersion) {
+		fmt.Fprintln(os.Stderr, "todo is running, close it before encrypting the database")
+		return 1
+	}
+	// The relay gets the titles in plain text, see AppService.CheckRemote
+	if cfg.Sync.Remote != "" {
+		fmt.Fprintln(os.Stderr, "sync.remote is set, the relay would keep the titles in plain text, remove it first")
+		return 1
+	}
+
+	db, manager, err := openMigrations(cfg, appVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+	if err := requireMigrated(manager); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if header, err := repository.ReadEncryptionHeader(db); err != nil || header != nil {
+		fmt.Fprintln(os.Stderr, cmp.Or(err, repository.ErrAlreadyEncrypted))
+		return 1
+	}
+
+	path := databasePath(appVersion)
+	passphrase, err := newPassphrase(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	header, key, err := repository.EncryptDatabase(db, passphrase, encryption.DefaultKDFParams)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to encrypt the database:", err)
+		return 1
+	}
+	if keyring := openKeyring(cfg); keyring != nil {
+		if err := keyring.Put(header.Salt, key); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to cache the key in the keyring:", err)
+		}
+	}
+	fmt.Printf("Encrypted the titles and descriptions in %s\n", path)
+	fmt.Println("There is no way to read them without the passphrase, keep it somewhere safe")
+
+	// Backups made by rollback and the files of an earlier sync with a relay were written
+	// before the encryption
+	leftovers, _ := filepath.Glob(path + ".*.bak")
+	for _, name := range []string{"sync-remote.json", "relay.log"} {
+		if file := osoperations.GetFilePath(name, appVersion); fileExists(file) {
+			leftovers = append(leftovers, file)
+		}
+	}
+	if len(leftovers) > 0 {
+		fmt.Println("These files still hold them in plain text, delete the ones you don't need:")
+		for _, leftover := range leftovers {
+			fmt.Println("  " + leftover)
+		}
+	}
+	return 0
+}
+
+func runDBDecrypt(args []string, appVersion string, cfg *config.Config) int {
+	fs := flag.NewFlagSet("db decrypt", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	// A running instance would keep writing ciphertext
+	if instanceRunning(appVersion) {
+		fmt.Fprintln(os.Stderr, "todo is running, close it before decrypting the database")
+		return 1
+	}
+
+	db, manager, err := openMigrations(cfg, appVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+	if err := requireMigrated(manager); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	header, err := repository.ReadEncryptionHeader(db)
+	if err != nil || header == nil {
+		fmt.Fprintln(os.Stderr, cmp.Or(err, repository.ErrNotEncrypted))
+		return 1
+	}
+
+	path := databasePath(appVersion)
+	key, err := databaseKey(header, path, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := repository.DecryptDatabase(db, key); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to decrypt the database:", err)
+		return 1
+	}
+	if keyring := openKeyring(cfg); keyring != nil {
+		if err := keyring.Delete(header.Salt); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to remove the key from the keyring:", err)
+		}
+	}
+	fmt.Printf("Decrypted the titles and descriptions in %s\n", path)
+	return 0
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// instanceRunning reports whether todo is running on the database of the workspace
+func instanceRunning(appVersion string) bool {
+	manager, err := socket_sync.NewManager(appVersion, nil)
+	if err != nil {
+		return false
+	}
+	_, err = socket_sync.QueryStatus(manager.GetSocketPath())
+	var incompatible *socket_sync.IncompatibleError
+	return err == nil || errors.As(err, &incompatible)
+}
+
+// requireMigrated refuses a database that is behind or ahead of this version of todo
+func requireMigrated(manager *repository.MigrationManager) error {
+	migrations := repository.GetAllMigrations()
+	if err := manager.CheckVersion(migrations); err != nil {
+		return err
+	}
+	pending, err := manager.Pending(migrations)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return errors.New("the database has pending migrations, run `todo db migrate` first")
+	}
+	return nil
+}
+
+// openMigrations opens the database of the workspace without migrating it
+func openMigrations(cfg *config.Config, appVersion string) (*sql.DB, *repository.MigrationManager, error) {
+	if cfg.Storage.Backend == config.VaultBackend {
+		return nil, nil, errors.New("the vault backend has no database to migrate")
+	}
+
+	path := databasePath(appVersion)
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("no database at %s", path)
+	}
+	db, err := repository.OpenSQLiteDB(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manager := repository.NewMigrationManager(db)
+	if err := manager.Initialize(); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("couldn't initialize migration manager: %w", err)
+	}
+	return db, manager, nil
+}
+
+func databasePath(appVersion string) string {
+	return filepath.Join(osoperations.GetDataDir(appVersion), "todo.sql")
+}
+
+// backupDatabase copies the database next to itself and returns the path of the copy
+func backupDatabase(db *sql.DB, appVersion string) (string, error) {
+	backup := fmt.Sprintf("%s.%s.bak", databasePath(appVersion), time.Now().Format("20060102-150405"))
+	if _, err := db.Exec("VACUUM INTO ?", backup); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+func printMigrations(action string, migrations []repository.Migration) {
+	if len(migrations) == 0 {
+		fmt.Println("Nothing to do")
+		return
+	}
+	for _, migration := range migrations {
+		fmt.Printf("%s migration %d: %s\n", action, migration.ID, migration.Name)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/config"
+	"github.com/martijnspitter/tui-todo/internal/doctor"
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/workspace"
+)
+
+// runDoctor implements `todo doctor`, which diagnoses the data dir, the database, the sync
+// socket and the log, and repairs what can be repaired safely
+func runDoctor(args []string, appVersion string, cfg *config.Config, ws workspace.Workspace) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "repair the problems that can be repaired without losing data")
+	bundle := fs.String("bundle", "", "write a redacted report for a bug report to this zip file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: todo doctor [--fix] [--bundle <file.zip>]")
+		return 2
+	}
+
+	dataDir := osoperations.GetDataDir(appVersion)
+	report := &doctor.Report{}
+	report.Add(doctor.CheckDataDir(dataDir))
+	closeDB := checkStorage(report, appVersion, cfg)
+	defer closeDB()
+
+	socketPath, err := filepath.Abs(filepath.Join(dataDir, "todo.sock"))
+	if err == nil {
+		report.Add(doctor.CheckSocket(socketPath))
+	}
+	logFinding, logTail := doctor.CheckLog(filepath.Join(dataDir, "debug.log"))
+	report.Add(logFinding)
+
+	if err := report.Write(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Problems that were repaired no longer count for the exit status
+	unresolved := make(map[string]bool)
+	for _, finding := range report.Findings {
+		if finding.Status == doctor.Problem {
+			unresolved[finding.Check] = true
+		}
+	}
+
+	fixable := report.Fixable()
+	var repairs []string
+	switch {
+	case *fix && len(fixable) > 0:
+		fmt.Println()
+		for _, finding := range fixable {
+			if err := finding.Repair(); err != nil {
+				repairs = append(repairs, fmt.Sprintf("Failed to %s: %v", finding.Fix, err))
+				fmt.Fprintln(os.Stderr, repairs[len(repairs)-1])
+				continue
+			}
+			delete(unresolved, finding.Check)
+			repairs = append(repairs, fmt.Sprintf("Fixed %s: %s", finding.Check, finding.Fix))
+			fmt.Println(repairs[len(repairs)-1])
+		}
+	case len(fixable) > 0:
+		fmt.Printf("\nRun `todo doctor --fix` to repair %d of these\n", len(fixable))
+	}
+
+	if *bundle != "" {
+		if err := writeDoctorBundle(*bundle, report, repairs, logTail, appVersion, cfg, ws); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to write the bundle:", err)
+			return 1
+		}
+		fmt.Printf("\nWrote %s, attach it to the bug report\n", *bundle)
+	}
+
+	if len(unresolved) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkStorage adds the findings about the database and the todos in it. It returns a
+// function that closes what the repairs need open.
+func checkStorage(report *doctor.Report, appVersion string, cfg *config.Config) func() {
+	if cfg.Storage.Backend == config.VaultBackend {
+		return checkTodos(report, appVersion, cfg)
+	}
+
+	path := databasePath(appVersion)
+	if _, err := os.Stat(path); err != nil {
+		report.Add(doctor.Finding{
+			Check:   "todos",
+			Status:  doctor.Warning,
+			Summary: fmt.Sprintf("there is no database at %s, is this the workspace and data dir you meant?", path),
+		})
+		return func() {}
+	}
+
+	db, manager, err := openMigrations(cfg, appVersion)
+	if err != nil {
+		report.Add(doctor.Finding{Check: "database", Status: doctor.Problem, Summary: err.Error()})
+		return func() {}
+	}
+	migrations := doctor.CheckMigrations(manager, repository.GetAllMigrations())
+	report.Add(doctor.CheckIntegrity(db), doctor.CheckForeignKeys(db), migrations)
+
+	// Opening the repository would migrate the database
+	if migrations.Status != doctor.OK {
+		report.Add(doctor.Finding{Check: "todos", Status: doctor.Warning, Summary: "not checked until the schema is current"})
+		return func() { db.Close() }
+	}
+	closeRepo := checkTodos(report, appVersion, cfg)
+	return func() {
+		closeRepo()
+		db.Close()
+	}
+}
+
+func checkTodos(report *doctor.Report, appVersion string, cfg *config.Config) func() {
+	todoRepo, err := openRepository(cfg, appVersion)
+	if err != nil {
+		report.Add(doctor.Finding{Check: "todos", Status: doctor.Problem, Summary: fmt.Sprintf("failed to open storage: %v", err)})
+		return func() {}
+	}
+	tracking := doctor.CheckTimeTracking(todoRepo, time.Now())
+	if repair := tracking.Repair; repair != nil {
+		tracking.Repair = func() error {
+			// A running instance holds the todos in memory and would start the timers again
+			if instanceRunning(appVersion) {
+				return errors.New("todo is running, close it first")
+			}
+			return repair()
+		}
+	}
+	report.Add(doctor.CheckTodos(todoRepo), tracking)
+	return func() { todoRepo.Close() }
+}
+
+// writeDoctorBundle writes the report, the repairs that were made, the end of the log and
+// the configuration, all redacted, to a zip file
+func writeDoctorBundle(path string, report *doctor.Report, repairs, logTail []string, appVersion string, cfg *config.Config, ws workspace.Workspace) error {
+	var text bytes.Buffer
+	fmt.Fprintf(&text, "todo %s on %s/%s, built with %s\n", appVersion, runtime.GOOS, runtime.GOARCH, runtime.Version())
+	fmt.Fprintf(&text, "workspace %s, backend %s, data dir %s\n", ws.Name, cfg.Storage.Backend, osoperations.GetDataDir(appVersion))
+	fmt.Fprintf(&text, "generated %s\n\n", time.Now().Format(time.RFC3339))
+	if err := report.Write(&text); err != nil {
+		return err
+	}
+	if len(repairs) > 0 {
+		fmt.Fprintf(&text, "\n%s\n", strings.Join(repairs, "\n"))
+	}
+
+	files := []doctor.BundleFile{
+		{Name: "report.txt", Content: text.Bytes()},
+		{Name: "debug.log", Content: []byte(strings.Join(logTail, "\n") + "\n")},
+	}
+	if cfg.Path != "" {
+		content, err := os.ReadFile(cfg.Path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err == nil {
+			files = append(files, doctor.BundleFile{Name: "config.toml", Content: content})
+		}
+	}
+
+	return doctor.WriteBundle(path, doctor.NewRedactor(cfg.Sync.Secret, cfg.HTTP.Token), files)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+	"github.com/martijnspitter/tui-todo/internal/config"
+	"github.com/martijnspitter/tui-todo/internal/encryption"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"golang.org/x/term"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// passphraseAttempts is how often the passphrase is asked before todo gives up
+	passphraseAttempts = 3
+	// minPassphraseLength keeps the passphrase of a new database from being guessed quickly,
+	// argon2 only slows the guessing down
+	minPassphraseLength = 8
+)
+
+// errNoPassphrase is returned when the database is encrypted and there is no terminal to
+// ask the passphrase on
+var errNoPassphrase = errors.New("the database is encrypted, set TODO_PASSPHRASE to open it without a terminal")
+
+// unlocker is the part of the database that is unlocked with the key
+type unlocker interface {
+	EncryptionHeader() *encryption.Header
+	Unlock(key []byte) error
+}
+
+// unlockDatabase unlocks an encrypted database before it is used, with the key from the
+// keyring, the TODO_PASSPHRASE environment variable or the passphrase typed on the terminal,
+// in that order. A database in plain text is left alone.
+func unlockDatabase(db unlocker, path string, cfg *config.Config) error {
+	header := db.EncryptionHeader()
+	if header == nil {
+		return nil
+	}
+	key, err := databaseKey(header, path, cfg)
+	if err != nil {
+		return err
+	}
+	return db.Unlock(key)
+}
+
+// databaseKey returns the key of an encrypted database and caches it in the keyring
+func databaseKey(header *encryption.Header, path string, cfg *config.Config) ([]byte, error) {
+	keyring := openKeyring(cfg)
+	if keyring != nil {
+		key, ok, err := keyring.Get(header.Salt)
+		if err != nil {
+			log.Warn("Failed to read keyring", "error", err)
+		}
+		if ok {
+			if _, err := header.Check(key); err == nil {
+				return key, nil
+			}
+			// The database was encrypted again with another passphrase
+			log.Warn("Removing outdated key from keyring")
+			if err := keyring.Delete(header.Salt); err != nil {
+				log.Warn("Failed to update keyring", "error", err)
+			}
+		}
+	}
+
+	var key []byte
+	if passphrase, ok := os.LookupEnv("TODO_PASSPHRASE"); ok {
+		_, derived, err := header.Unlock(normalizePassphrase(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("TODO_PASSPHRASE: %w", err)
+		}
+		key = derived
+	} else {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, errNoPassphrase
+		}
+		for attempt := 1; ; attempt++ {
+			passphrase, err := readPassphrase(fmt.Sprintf("Passphrase for %s: ", path))
+			if err != nil {
+				return nil, err
+			}
+			_, derived, err := header.Unlock(passphrase)
+			if err == nil {
+				key = derived
+				break
+			}
+			if !errors.Is(err, encryption.ErrWrongPassphrase) || attempt == passphraseAttempts {
+				return nil, err
+			}
+			fmt.Fprintln(os.Stderr, "Wrong passphrase, try again")
+		}
+	}
+
+	if keyring != nil {
+		if err := keyring.Put(header.Salt, key); err != nil {
+			log.Warn("Failed to cache key in keyring", "error", err)
+		}
+	}
+	return key, nil
+}
+
+// newPassphrase returns the passphrase to encrypt a database with, from TODO_PASSPHRASE or
+// typed twice on the terminal
+func newPassphrase(path string) (string, error) {
+	passphrase, ok := os.LookupEnv("TODO_PASSPHRASE")
+	if ok {
+		passphrase = normalizePassphrase(passphrase)
+	} else {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return "", errors.New("no terminal to ask the passphrase on, set TODO_PASSPHRASE")
+		}
+		var err error
+		passphrase, err = readPassphrase(fmt.Sprintf("New passphrase for %s: ", path))
+		if err != nil {
+			return "", err
+		}
+		if len([]rune(passphrase)) >= minPassphraseLength {
+			again, err := readPassphrase("Repeat the passphrase: ")
+			if err != nil {
+				return "", err
+			}
+			if again != passphrase {
+				return "", errors.New("the passphrases don't match")
+			}
+		}
+	}
+
+	if len([]rune(passphrase)) < minPassphraseLength {
+		return "", fmt.Errorf("the passphrase needs at least %d characters", minPassphraseLength)
+	}
+	return passphrase, nil
+}
+
+// readPassphrase asks for a passphrase on the terminal without echoing it
+func readPassphrase(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return normalizePassphrase(string(passphrase)), nil
+}
+
+// normalizePassphrase makes a passphrase with accents give the same key wherever it is
+// typed, keyboards and terminals don't agree on how to compose them
+func normalizePassphrase(passphrase string) string {
+	return norm.NFC.String(passphrase)
+}
+
+// openKeyring returns the keyring of the config, nil when there is none
+func openKeyring(cfg *config.Config) *encryption.Keyring {
+	path := cfg.KeyringPath()
+	if path == "" {
+		return nil
+	}
+	return encryption.NewKeyring(filepath.Clean(path))
+}
+
+// databaseEncrypted reports whether the database at path exists and is encrypted
+func databaseEncrypted(path string) (bool, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	db, err := repository.OpenSQLiteDB(path)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+	header, err := repository.ReadEncryptionHeader(db)
+	return header != nil, err
+}
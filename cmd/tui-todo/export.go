@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/martijnspitter/tui-todo/internal/config"
+	"github.com/martijnspitter/tui-todo/internal/export"
+	"github.com/martijnspitter/tui-todo/internal/i18n"
+	"github.com/martijnspitter/tui-todo/internal/service"
+)
+
+// runExport implements `todo export`, writing the todos of a pane as Markdown or CSV
+func runExport(args []string, appVersion string, cfg *config.Config) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := fs.String("format", "md", "output format: md or csv")
+	viewName := fs.String("view", "today", "pane to export: today, open, doing, done, blocked or all")
+	archived := fs.Bool("archived", false, "show archived todos instead of active ones (all view only)")
+	tagName := fs.String("tag", "", "only export todos with this tag")
+	output := fs.String("output", "", "file to write to (defaults to stdout)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	view, err := service.ParseViewName(*viewName)
+	if err != nil || view == service.TagsPane {
+		fmt.Fprintf(os.Stderr, "unknown view %q\n", *viewName)
+		return 2
+	}
+
+	todoRepo, err := openRepository(cfg, appVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to open database:", err)
+		return 1
+	}
+	defer todoRepo.Close()
+
+	translator, err := i18n.NewTranslationService(cfg.Language)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	appService := service.NewAppService(todoRepo)
+	appService.SetConfig(cfg)
+	todos, err := appService.GetTodosForExport(view, *archived, *tagName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, translator.T(err.Error()))
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to create output file:", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	title := translator.T("filter." + view.Name())
+	if view != service.TodayPane && view != service.AllPane {
+		title = translator.T("status." + view.Name())
+	}
+	if err := export.Write(w, format, title, todos, translator); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to export todos:", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/martijnspitter/tui-todo/internal/config"
+	"github.com/martijnspitter/tui-todo/internal/i18n"
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
+	"github.com/martijnspitter/tui-todo/internal/theme"
+	"github.com/martijnspitter/tui-todo/internal/workspace"
+)
+
+// options holds the global command line flags, which override the configuration file
+type options struct {
+	showVersion   bool
+	configPath    string
+	language      string
+	dataDir       string
+	workspace     string
+	noUpdateCheck bool
+	noConfirmQuit bool
+}
+
+// parseFlags parses the global flags and returns the remaining arguments (the subcommand)
+func parseFlags(args []string) (*options, []string, error) {
+	opts := &options{}
+
+	fs := flag.NewFlagSet("todo", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: todo [flags] [command]\n\nCommands:\n  db          show, apply and roll back migrations, encrypt the database\n  doctor      diagnose and repair the data dir, database and sync socket\n  export      write the todos of a pane as Markdown or CSV\n  git         link todos to git branches and commits\n  serve       run a relay that syncs todos between machines\n  serve-http  serve todos, tags and the Today dashboard as a JSON API\n  sync        show the running instances and their lag (todo sync status)\n  workspace   list, create and move todos between workspaces\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&opts.showVersion, "version", false, "print the version and exit")
+	fs.BoolVar(&opts.showVersion, "v", false, "print the version and exit (shorthand)")
+	fs.StringVar(&opts.configPath, "config", "", "path to the config file (default "+config.DefaultPath()+")")
+	fs.StringVar(&opts.language, "lang", "", "language of the interface, e.g. en")
+	fs.StringVar(&opts.dataDir, "data-dir", "", "directory holding the database, socket and log")
+	fs.StringVar(&opts.workspace, "workspace", "", "workspace to open, a name or . for the .todo directory of the project")
+	fs.BoolVar(&opts.noUpdateCheck, "no-update-check", false, "don't check GitHub for new releases")
+	fs.BoolVar(&opts.noConfirmQuit, "no-confirm-quit", false, "quit without asking for confirmation")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	return opts, fs.Args(), nil
+}
+
+// loadConfig reads the config file and the settings of the workspace, applies flag overrides
+// and validates the result
+func loadConfig(opts *options, appVersion string) (*config.Config, workspace.Workspace, error) {
+	cfg, err := config.Load(opts.configPath)
+	if err != nil {
+		return nil, workspace.Workspace{}, err
+	}
+
+	if opts.dataDir != "" {
+		dir, err := filepath.Abs(opts.dataDir)
+		if err != nil {
+			return nil, workspace.Workspace{}, err
+		}
+		cfg.DataDir = dir
+	}
+
+	ws, err := resolveWorkspace(opts, cfg, appVersion)
+	if err != nil {
+		return nil, workspace.Workspace{}, err
+	}
+	// Flags still win over the settings of the workspace
+	if path := ws.ConfigPath(); path != "" {
+		if err := cfg.Overlay(path); err != nil {
+			return nil, workspace.Workspace{}, err
+		}
+	}
+
+	if opts.language != "" {
+		cfg.Language = opts.language
+	}
+	if opts.noUpdateCheck {
+		cfg.CheckForUpdates = false
+	}
+	if opts.noConfirmQuit {
+		cfg.ConfirmQuit = false
+	}
+	// Keeps the secret out of the config file, which is often shared between machines
+	if secret := os.Getenv("TODO_SYNC_SECRET"); secret != "" {
+		cfg.Sync.Secret = secret
+	}
+	if token := os.Getenv("TODO_HTTP_TOKEN"); token != "" {
+		cfg.HTTP.Token = token
+	}
+	if cfg.Language == "" {
+		cfg.Language = i18n.DetectLanguage()
+	}
+	// https://no-color.org
+	if theme.NoColorRequested() {
+		cfg.Theme = theme.NoColorName
+	}
+
+	// Custom palettes have to be known before the theme option is validated
+	if err := theme.LoadDir(cfg.ThemesDir()); err != nil {
+		return nil, workspace.Workspace{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, workspace.Workspace{}, err
+	}
+
+	return cfg, ws, nil
+}
+
+// resolveWorkspace picks the workspace from --workspace, $TODO_WORKSPACE or the working directory
+func resolveWorkspace(opts *options, cfg *config.Config, appVersion string) (workspace.Workspace, error) {
+	name := opts.workspace
+	if name == "" {
+		name = os.Getenv("TODO_WORKSPACE")
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return workspace.Workspace{}, err
+	}
+	return workspace.Resolve(workspaceRoot(cfg, appVersion), name, cwd)
+}
+
+// workspaceRoot returns the data directory the default and named workspaces live in
+func workspaceRoot(cfg *config.Config, appVersion string) string {
+	if dir := cfg.ResolvedDataDir(); dir != "" {
+		return dir
+	}
+	return osoperations.GetDataDir(appVersion)
+}
+
+func exitWithConfigError(err error) {
+	fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+	os.Exit(2)
+}
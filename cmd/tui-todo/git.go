@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/martijnspitter/tui-todo/internal/config"
+	"github.com/martijnspitter/tui-todo/internal/gitlink"
+	"github.com/martijnspitter/tui-todo/internal/hooks"
+	"github.com/martijnspitter/tui-todo/internal/service"
+)
+
+const gitUsage = `usage: todo git <command>
+
+Commands:
+  branch <id>      create and check out a branch for a todo and move it to Doing
+  install-hooks    install the post-checkout and commit-msg hooks in this repository
+  post-checkout    called by the post-checkout hook
+  commit-msg       called by the commit-msg hook`
+
+// runGit implements `todo git`, which links todos to the branches and commits of the git
+// repository in the working directory
+func runGit(args []string, appVersion string, cfg *config.Config) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, gitUsage)
+		return 2
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch args[0] {
+	case "branch":
+		return runGitBranch(args[1:], dir, appVersion, cfg)
+	case "install-hooks":
+		return runGitInstallHooks(args[1:], dir)
+	case "post-checkout":
+		return runGitPostCheckout(args[1:], dir, appVersion, cfg)
+	case "commit-msg":
+		return runGitCommitMsg(args[1:], dir, appVersion, cfg)
+	default:
+		fmt.Fprintln(os.Stderr, gitUsage)
+		return 2
+	}
+}
+
+func runGitBranch(args []string, dir, appVersion string, cfg *config.Config) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: todo git branch <id>")
+		return 2
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%q is not a todo ID\n", args[0])
+		return 2
+	}
+
+	return withService(appVersion, cfg, func(appService *service.AppService) int {
+		todo, err := appService.GetTodo(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "todo %d not found\n", id)
+			return 1
+		}
+
+		branch := gitlink.BranchName(todo.ID, todo.Title)
+		if err := gitlink.CreateBranch(dir, branch); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		// The post-checkout hook does this as well when it is installed
+		if _, err := appService.StartLinkedTodo(branch); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to move the todo to Doing:", err)
+			return 1
+		}
+
+		fmt.Printf("Switched to %s\n", branch)
+		return 0
+	})
+}
+
+func runGitInstallHooks(args []string, dir string) int {
+	fs := flag.NewFlagSet("git install-hooks", flag.ContinueOnError)
+	force := fs.Bool("force", false, "replace existing hooks")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	installed, err := gitlink.InstallHooks(dir, executable, *force)
+	for _, path := range installed {
+		fmt.Println("Installed", path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// runGitPostCheckout moves the todo of the checked out branch to Doing. Git passes the
+// previous and new HEAD and whether a branch was checked out rather than files.
+func runGitPostCheckout(args []string, dir, appVersion string, cfg *config.Config) int {
+	if len(args) == 3 && args[2] != "1" {
+		return 0
+	}
+
+	branch, err := gitlink.CurrentBranch(dir)
+	if err != nil || branch == "" {
+		return 0
+	}
+	if _, ok := gitlink.ParseBranch(branch); !ok {
+		return 0
+	}
+
+	return withService(appVersion, cfg, func(appService *service.AppService) int {
+		todo, err := appService.StartLinkedTodo(branch)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "todo: failed to move the todo to Doing:", err)
+			return 1
+		}
+		if todo != nil {
+			fmt.Printf("todo: TODO-%d %s is in progress\n", todo.ID, todo.Title)
+		}
+		return 0
+	})
+}
+
+// runGitCommitMsg applies the Closes and Refs trailers of the message git is about to commit
+func runGitCommitMsg(args []string, dir, appVersion string, cfg *config.Config) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: todo git commit-msg <file>")
+		return 2
+	}
+	message, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(gitlink.ParseReferences(string(message))) == 0 {
+		return 0
+	}
+
+	branch, _ := gitlink.CurrentBranch(dir)
+
+	return withService(appVersion, cfg, func(appService *service.AppService) int {
+		applied, err := appService.ApplyCommitMessage(string(message), branch)
+		for _, ref := range applied {
+			if ref.Closes {
+				fmt.Printf("todo: TODO-%d is done\n", ref.ID)
+			} else {
+				fmt.Printf("todo: noted the commit on TODO-%d\n", ref.ID)
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "todo: failed to update todos:", err)
+			return 1
+		}
+		return 0
+	})
+}
+
+// withService runs fn on a service that tells the running instances about its changes
+func withService(appVersion string, cfg *config.Config, fn func(*service.AppService) int) int {
+	todoRepo, err := openRepository(cfg, appVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to open database:", err)
+		return 1
+	}
+	defer todoRepo.Close()
+
+	appService := service.NewAppService(todoRepo)
+	appService.SetConfig(cfg)
+	hookRunner := hooks.NewRunner(cfg.HooksDir(), cfg.HookTimeout())
+	appService.SetHooks(hookRunner)
+	defer hookRunner.Wait()
+
+	syncManager, _ := startSync(appVersion, appService, todoRepo)
+	if syncManager != nil {
+		defer syncManager.Stop()
+	}
+
+	return fn(appService)
+}
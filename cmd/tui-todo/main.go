@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -12,54 +14,160 @@ import (
 	"github.com/charmbracelet/log"
 	_ "modernc.org/sqlite"
 
+	"github.com/martijnspitter/tui-todo/internal/config"
+	"github.com/martijnspitter/tui-todo/internal/encryption"
+	"github.com/martijnspitter/tui-todo/internal/hooks"
+	"github.com/martijnspitter/tui-todo/internal/httpapi"
 	"github.com/martijnspitter/tui-todo/internal/i18n"
 	"github.com/martijnspitter/tui-todo/internal/logger"
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
 	"github.com/martijnspitter/tui-todo/internal/repository"
 	"github.com/martijnspitter/tui-todo/internal/service"
 	"github.com/martijnspitter/tui-todo/internal/socket_sync"
 	"github.com/martijnspitter/tui-todo/internal/ui"
 	"github.com/martijnspitter/tui-todo/internal/version"
+	"github.com/martijnspitter/tui-todo/internal/workspace"
 )
 
 func main() {
 	appVersion := version.GetVersion()
-	if len(os.Args) > 1 && (os.Args[1] == "--version" || os.Args[1] == "-v") {
+
+	opts, args, err := parseFlags(os.Args[1:])
+	if err != nil {
+		os.Exit(2)
+	}
+	if opts.showVersion {
 		fmt.Printf("todo version %s\n", appVersion)
 		os.Exit(0)
 	}
 
+	cfg, ws, err := loadConfig(opts, appVersion)
+	if err != nil {
+		exitWithConfigError(err)
+	}
+	root := workspaceRoot(cfg, appVersion)
+	osoperations.SetDataDir(ws.Dir)
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "db":
+			log.SetLevel(log.WarnLevel)
+			os.Exit(runDB(args[1:], appVersion, cfg))
+		case "doctor":
+			log.SetLevel(log.WarnLevel)
+			os.Exit(runDoctor(args[1:], appVersion, cfg, ws))
+		case "export":
+			log.SetLevel(log.WarnLevel)
+			os.Exit(runExport(args[1:], appVersion, cfg))
+		case "git":
+			log.SetLevel(log.WarnLevel)
+			os.Exit(runGit(args[1:], appVersion, cfg))
+		case "serve":
+			os.Exit(runServe(args[1:], appVersion, cfg))
+		case "serve-http":
+			os.Exit(runServeHTTP(args[1:], appVersion, cfg))
+		case "sync":
+			log.SetLevel(log.WarnLevel)
+			os.Exit(runSync(args[1:], appVersion, cfg))
+		case "workspace":
+			log.SetLevel(log.WarnLevel)
+			os.Exit(runWorkspace(args[1:], appVersion, cfg, ws, root))
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+			os.Exit(2)
+		}
+	}
+
+	// Switching workspaces quits the interface, it is reopened on the new one
+	for {
+		next, ok := runTUI(appVersion, cfg, ws, root)
+		if !ok {
+			return
+		}
+
+		opts.workspace = next
+		cfg, ws, err = loadConfig(opts, appVersion)
+		if err != nil {
+			exitWithConfigError(err)
+		}
+		osoperations.SetDataDir(ws.Dir)
+	}
+}
+
+// runTUI runs the interface on a workspace until it quits. It returns the workspace to open
+// next when the user switched to another one.
+func runTUI(appVersion string, cfg *config.Config, ws workspace.Workspace, root string) (string, bool) {
 	logger := logger.InitLogger(appVersion)
 	if logger != nil {
 		defer logger.Close()
 	}
+	if cfg.Path != "" {
+		log.Info("Loaded configuration", "path", cfg.Path)
+	}
+	log.Info("Opening workspace", "name", ws.Name, "dir", ws.Dir)
 
-	todoRepo, err := repository.NewSQLiteTodoRepository(appVersion)
+	todoRepo, err := openRepository(cfg, appVersion)
 	if err != nil {
 		log.Error("Failed to start db", err)
+		// The log goes to a file, this one needs the user's attention
+		var tooNew *repository.SchemaTooNewError
+		if errors.As(err, &tooNew) {
+			fmt.Fprintln(os.Stderr, tooNew)
+		} else if errors.Is(err, encryption.ErrWrongPassphrase) || errors.Is(err, errNoPassphrase) {
+			fmt.Fprintln(os.Stderr, err)
+		}
 		os.Exit(1)
 	}
 	defer todoRepo.Close()
 
-	translationService, err := i18n.NewTranslationService("en")
+	translationService, err := i18n.NewTranslationService(cfg.Language)
 	if err != nil {
 		log.Fatal(err)
 	}
-	service := service.NewAppService(todoRepo)
-	baseModel := ui.NewBaseModel(service, translationService)
-	syncManager, err := socket_sync.NewManager(appVersion, service)
+	appService := service.NewAppService(todoRepo)
+	appService.SetConfig(cfg)
+	appService.SetWorkspace(ws, root)
+	hookRunner := hooks.NewRunner(cfg.HooksDir(), cfg.HookTimeout())
+	appService.SetHooks(hookRunner)
+	// Hooks started by the last changes finish before the program exits
+	defer hookRunner.Wait()
+	baseModel := ui.NewBaseModel(appService, translationService)
+	// Only the primary talks to the relay, the other instances get its changes through it.
+	// A secondary connects when it takes over from a primary that quit.
+	var remote *socket_sync.RemoteClient
+	var remoteMutex sync.Mutex
+	connectRemote := func() {
+		remoteMutex.Lock()
+		defer remoteMutex.Unlock()
+		if remote != nil || cfg.Sync.Remote == "" {
+			return
+		}
+		var err error
+		remote, err = startRemote(cfg, appVersion, appService)
+		if err != nil {
+			log.Warn("Failed to start sync with other machines", "error", err)
+		}
+	}
+	syncManager, err := startSync(appVersion, appService, todoRepo)
 	if err == nil {
-		// Store the sync manager in the service
-		service.SetSyncManager(syncManager)
+		syncManager.OnPromote(connectRemote)
+		if syncManager.IsPrimary() {
+			connectRemote()
+		}
+	}
 
-		// Start the sync system
-		err = syncManager.Start()
-		if err != nil {
-			log.Warn("Failed to start sync manager", "error", err)
-		} else {
-			log.Info("Sync system initialized", "primary", syncManager.IsPrimary())
+	// Edits made to a vault in an editor show up like those of another instance
+	stopWatching := appService.WatchExternalChanges(service.ExternalPollInterval)
+	defer stopWatching()
+
+	// The JSON API runs on the same service, so it sees the changes made in the TUI
+	var api *httpapi.Server
+	if cfg.HTTP.Addr != "" {
+		api = httpapi.New(appService, cfg.HTTP.Addr, cfg.HTTP.Token)
+		if err := api.Start(); err != nil {
+			log.Warn("Failed to start HTTP API", "error", err)
+			api = nil
 		}
-	} else {
-		log.Warn("Failed to create sync manager", "error", err)
 	}
 
 	// Initialize TUI with endpoints as options
@@ -69,26 +177,63 @@ func main() {
 		tea.WithMouseCellMotion(), // turn on mouse support so we can track the mouse wheel
 	)
 
-	service.RegisterNotificationCallback(func(notificationType string, todoID int64) {
-		// This will be called when notifications arrive
-		// Send a message to the program to trigger a refresh
-		p.Send(ui.LoadTodosMsg{})
+	appService.RegisterNotificationCallback(func(change service.Change) {
+		// This will be called when notifications arrive. The panes patch the change in
+		// place and fall back to a reload when the sender speaks another protocol version.
+		p.Send(ui.RemoteChangeMsg{Change: change})
+	})
+	appService.OnIncompatiblePeer(func(err *socket_sync.IncompatibleError) {
+		// Refusals during startup come in before the program runs, Send would wait for it
+		go p.Send(ui.IncompatiblePeerMsg{Err: err})
+	})
+	appService.OnPresenceChange(func() {
+		// The status bar counts the windows and the edit modal warns about shared todos
+		p.Send(ui.PresenceChangedMsg{})
 	})
 
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-sigCh
-		log.Info("Shutting down gracefully...")
-		// Clean shutdown
+	// Clean shutdown, also before the interface is reopened on another workspace
+	shutdown := func() {
+		stopWatching()
+		if api != nil {
+			if err := api.Stop(); err != nil {
+				log.Error("Error stopping HTTP API", "error", err)
+			}
+		}
+		remoteMutex.Lock()
+		if remote != nil {
+			if err := remote.Stop(); err != nil {
+				log.Error("Error stopping relay connection", "error", err)
+			}
+		}
+		remoteMutex.Unlock()
 		if syncManager != nil {
 			if err := syncManager.Stop(); err != nil {
 				log.Error("Error stopping sync manager", "error", err)
 			}
 		}
+		hookRunner.Wait()
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	defer close(done)
+	defer signal.Stop(sigCh)
+	go func() {
+		select {
+		case <-sigCh:
+		case <-done:
+			return
+		}
+		log.Info("Shutting down gracefully...")
+		shutdown()
 		os.Exit(0)
 	}()
 	go func() {
+		if !cfg.CheckForUpdates {
+			return
+		}
+
 		// Wait a short period to let the UI initialize
 		time.Sleep(1 * time.Second)
 
@@ -101,7 +246,7 @@ func main() {
 
 		if updateInfo != nil {
 			// Store the update info in the service
-			service.SetUpdateInfo(
+			appService.SetUpdateInfo(
 				updateInfo.Version,
 				updateInfo.ReleaseURL,
 				updateInfo.ReleaseNotes,
@@ -119,4 +264,48 @@ func main() {
 		fmt.Printf("Alas, there's been an error: %v", err)
 		os.Exit(1)
 	}
+
+	next, ok := appService.RequestedWorkspace()
+	if !ok {
+		return "", false
+	}
+	shutdown()
+	if next.Local {
+		return workspace.LocalName, true
+	}
+	return next.Name, true
+}
+
+// startSync joins the instances on this machine, the first one started becomes the primary.
+// A sync manager that fails to start is still returned, the instance then runs on its own.
+func startSync(appVersion string, appService *service.AppService, todoRepo repository.TodoRepository) (*socket_sync.Manager, error) {
+	return startSyncAt(osoperations.GetFilePath("todo.sock", appVersion), appService, todoRepo)
+}
+
+// startSyncAt joins the instances that share the socket at socketPath
+func startSyncAt(socketPath string, appService *service.AppService, todoRepo repository.TodoRepository) (*socket_sync.Manager, error) {
+	syncManager, err := socket_sync.NewManagerAt(socketPath, appService)
+	if err != nil {
+		log.Warn("Failed to create sync manager", "error", err)
+		return nil, err
+	}
+	// Store the sync manager in the service
+	appService.SetSyncManager(syncManager)
+
+	// Lets instances that were disconnected catch up on the changes they missed
+	if changeLog, ok := service.NewChangeLog(todoRepo); ok {
+		syncManager.SetChangeLog(changeLog)
+	}
+	// Tools talk JSON-RPC to the primary over the same socket
+	syncManager.SetRPCHandler(appService)
+	syncManager.OnIncompatible(appService.IncompatiblePeer)
+
+	// Start the sync system
+	if err := syncManager.Start(); err != nil {
+		log.Warn("Failed to start sync manager", "error", err)
+		return syncManager, err
+	}
+	log.Info("Sync system initialized", "primary", syncManager.IsPrimary())
+
+	return syncManager, nil
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/martijnspitter/tui-todo/internal/config"
+	"github.com/martijnspitter/tui-todo/internal/hooks"
+	"github.com/martijnspitter/tui-todo/internal/httpapi"
+	"github.com/martijnspitter/tui-todo/internal/logger"
+	"github.com/martijnspitter/tui-todo/internal/service"
+)
+
+// DefaultHTTPAddr is where `todo serve-http` listens when neither --addr nor http.addr is set
+const DefaultHTTPAddr = "127.0.0.1:8080"
+
+// runServeHTTP implements `todo serve-http`, the JSON API without the TUI. It joins the
+// other instances on this machine, so changes made in a TUI show up in its event stream.
+func runServeHTTP(args []string, appVersion string, cfg *config.Config) int {
+	defaultAddr := cfg.HTTP.Addr
+	if defaultAddr == "" {
+		defaultAddr = DefaultHTTPAddr
+	}
+
+	fs := flag.NewFlagSet("serve-http", flag.ContinueOnError)
+	addr := fs.String("addr", defaultAddr, "address to listen on")
+	token := fs.String("token", cfg.HTTP.Token, "token clients send as bearer token")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := config.ValidateHTTPAddr(*addr, *token); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	logger := logger.InitLogger(appVersion)
+	if logger != nil {
+		defer logger.Close()
+	}
+
+	todoRepo, err := openRepository(cfg, appVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to open database:", err)
+		return 1
+	}
+	defer todoRepo.Close()
+
+	appService := service.NewAppService(todoRepo)
+	appService.SetConfig(cfg)
+	// Tools may change todos over the sync socket when this instance is the primary
+	hookRunner := hooks.NewRunner(cfg.HooksDir(), cfg.HookTimeout())
+	appService.SetHooks(hookRunner)
+	defer hookRunner.Wait()
+
+	syncManager, _ := startSync(appVersion, appService, todoRepo)
+	stopWatching := appService.WatchExternalChanges(service.ExternalPollInterval)
+	defer stopWatching()
+
+	api := httpapi.New(appService, *addr, *token)
+	if err := api.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Serving the API on http://%s/api\n", api.Addr())
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	<-sigCh
+
+	log.Info("Stopping HTTP API")
+	exitCode := 0
+	if err := api.Stop(); err != nil {
+		log.Error("Error stopping HTTP API", "error", err)
+		exitCode = 1
+	}
+	if syncManager != nil {
+		if err := syncManager.Stop(); err != nil {
+			log.Error("Error stopping sync manager", "error", err)
+		}
+	}
+	return exitCode
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/martijnspitter/tui-todo/internal/config"
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
+	"github.com/martijnspitter/tui-todo/internal/service"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+// runServe implements `todo serve`, a relay passing changes between the machines that
+// have sync.remote pointing at it
+func runServe(args []string, appVersion string, cfg *config.Config) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", cfg.Sync.Listen, "address to listen on")
+	certFile := fs.String("tls-cert", cfg.Sync.TLSCert, "certificate for TLS connections")
+	keyFile := fs.String("tls-key", cfg.Sync.TLSKey, "private key for TLS connections")
+	logPath := fs.String("log", osoperations.GetFilePath("relay.log", appVersion), "file keeping changes for machines that are offline")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if cfg.Sync.Secret == "" {
+		fmt.Fprintln(os.Stderr, "a shared secret is required, set sync.secret or TODO_SYNC_SECRET")
+		return 2
+	}
+	if (*certFile == "") != (*keyFile == "") {
+		fmt.Fprintln(os.Stderr, "--tls-cert and --tls-key must be used together")
+		return 2
+	}
+
+	// The relay log holds the titles of every machine in plain text
+	if *logPath != "" && filepath.Dir(filepath.Clean(*logPath)) == filepath.Clean(osoperations.GetDataDir(appVersion)) {
+		encrypted, err := databaseEncrypted(databasePath(appVersion))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if encrypted {
+			fmt.Fprintln(os.Stderr, "the database next to the relay log is encrypted, keep the log elsewhere with --log <file> or in memory with --log \"\"")
+			return 2
+		}
+	}
+
+	relayCfg := socket_sync.RelayConfig{
+		Listen:  *listen,
+		Secret:  cfg.Sync.Secret,
+		LogPath: *logPath,
+	}
+	if *certFile != "" {
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to load certificate:", err)
+			return 1
+		}
+		relayCfg.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	relay, err := socket_sync.NewRelay(relayCfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := relay.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	<-sigCh
+
+	log.Info("Stopping relay")
+	if err := relay.Stop(); err != nil {
+		log.Error("Error stopping relay", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// startRemote connects the primary instance to the relay configured in sync.remote
+func startRemote(cfg *config.Config, appVersion string, appService *service.AppService) (*socket_sync.RemoteClient, error) {
+	if err := appService.CheckRemote(); err != nil {
+		return nil, err
+	}
+	remoteCfg := socket_sync.RemoteConfig{
+		Address:   cfg.Sync.Remote,
+		Secret:    cfg.Sync.Secret,
+		StatePath: osoperations.GetFilePath("sync-remote.json", appVersion),
+	}
+
+	if cfg.Sync.TLS || cfg.Sync.CAFile != "" {
+		tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.Sync.CAFile != "" {
+			pem, err := os.ReadFile(cfg.Sync.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.New("no certificates found in " + cfg.Sync.CAFile)
+			}
+			tlsCfg.RootCAs = pool
+		}
+		remoteCfg.TLS = tlsCfg
+	}
+
+	remote, err := socket_sync.NewRemoteClient(remoteCfg, appService)
+	if err != nil {
+		return nil, err
+	}
+	appService.SetRemote(remote)
+	remote.Start()
+
+	return remote, nil
+}
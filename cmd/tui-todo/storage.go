@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/martijnspitter/tui-todo/internal/config"
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+)
+
+// openRepository opens the storage backend of the current workspace
+func openRepository(cfg *config.Config, appVersion string) (repository.Store, error) {
+	return openRepositoryAt(cfg, osoperations.GetDataDir(appVersion))
+}
+
+// openRepositoryAt opens the storage backend the config selects for the workspace in dataDir
+func openRepositoryAt(cfg *config.Config, dataDir string) (repository.Store, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Storage.Backend {
+	case config.VaultBackend:
+		vault, err := repository.NewVaultTodoRepository(cfg.VaultDir(dataDir))
+		if err != nil {
+			return nil, err
+		}
+		return vault, nil
+	default:
+		path := filepath.Join(dataDir, "todo.sql")
+		db, err := repository.OpenSQLiteTodoRepository(path)
+		if err != nil {
+			return nil, err
+		}
+		// Asked before the TUI takes over the terminal
+		if err := unlockDatabase(db, path, cfg); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return db, nil
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/config"
+	"github.com/martijnspitter/tui-todo/internal/service"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+// runSync implements `todo sync`, which inspects the sync between the instances on this machine
+func runSync(args []string, appVersion string, cfg *config.Config) int {
+	if len(args) == 0 || args[0] != "status" {
+		fmt.Fprintln(os.Stderr, "usage: todo sync status")
+		return 2
+	}
+
+	return runSyncStatus(args[1:], appVersion, cfg)
+}
+
+// runSyncStatus implements `todo sync status`, listing every instance and how far it lags
+// behind the change log of the primary
+func runSyncStatus(args []string, appVersion string, cfg *config.Config) int {
+	fs := flag.NewFlagSet("sync status", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	manager, err := socket_sync.NewManager(appVersion, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	status, err := socket_sync.QueryStatus(manager.GetSocketPath())
+	var incompatible *socket_sync.IncompatibleError
+	if errors.As(err, &incompatible) {
+		fmt.Fprintln(os.Stderr, "the running instances can't be queried:", err)
+		return 1
+	}
+	if err != nil {
+		// Without a primary the database is all there is to report on
+		head, err := changeLogHead(appVersion, cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to read change log:", err)
+			return 1
+		}
+		fmt.Printf("No instances running, change log is at %d\n", head)
+		return 0
+	}
+
+	fmt.Printf("Change log is at %d\n\n", status.Head)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PID\tROLE\tVERSION\tSEEN\tLAG\tCONNECTED\tLAST SEEN")
+	for _, peer := range status.Peers {
+		role := "secondary"
+		if peer.Primary {
+			role = "primary"
+		}
+		// Instances from before the change log don't say who they are
+		pid := "?"
+		if peer.PID != 0 {
+			pid = strconv.Itoa(peer.PID)
+		}
+		app := peer.App
+		if app == "" {
+			app = "?"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n", pid, role, app, peer.Seq, peer.Lag,
+			peer.ConnectedAt.Format(time.DateTime), ago(peer.LastSeen))
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func changeLogHead(appVersion string, cfg *config.Config) (int64, error) {
+	todoRepo, err := openRepository(cfg, appVersion)
+	if err != nil {
+		return 0, err
+	}
+	defer todoRepo.Close()
+
+	changeLog, ok := service.NewChangeLog(todoRepo)
+	if !ok {
+		return 0, nil
+	}
+	return changeLog.Head()
+}
+
+func ago(t time.Time) string {
+	elapsed := time.Since(t).Round(time.Second)
+	if elapsed < time.Second {
+		return "now"
+	}
+	return elapsed.String() + " ago"
+}
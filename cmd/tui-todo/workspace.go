@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/martijnspitter/tui-todo/internal/config"
+	"github.com/martijnspitter/tui-todo/internal/hooks"
+	"github.com/martijnspitter/tui-todo/internal/service"
+	"github.com/martijnspitter/tui-todo/internal/workspace"
+)
+
+const workspaceUsage = `usage: todo workspace <command>
+
+Commands:
+  list                      show the workspaces, * marks the current one
+  create <name>             create a named workspace
+  init [dir]                create a .todo workspace for the project in dir (default .)
+  move --to <name> <id>...  move todos from the current workspace to another one`
+
+// runWorkspace implements `todo workspace`. ws is the workspace selected by --workspace or
+// the working directory, root the data directory the named workspaces live in.
+func runWorkspace(args []string, appVersion string, cfg *config.Config, ws workspace.Workspace, root string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, workspaceUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "list":
+		return runWorkspaceList(ws, root)
+	case "create":
+		return runWorkspaceCreate(args[1:], root)
+	case "init":
+		return runWorkspaceInit(args[1:])
+	case "move":
+		return runWorkspaceMove(args[1:], appVersion, cfg, ws, root)
+	default:
+		fmt.Fprintln(os.Stderr, workspaceUsage)
+		return 2
+	}
+}
+
+func runWorkspaceList(current workspace.Workspace, root string) int {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	workspaces, err := workspace.List(root, cwd)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to list workspaces:", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, ws := range workspaces {
+		marker := " "
+		if ws.Dir == current.Dir {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\n", marker, ws.Label(), ws.Dir)
+	}
+	w.Flush()
+	return 0
+}
+
+func runWorkspaceCreate(args []string, root string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: todo workspace create <name>")
+		return 2
+	}
+
+	ws, err := workspace.Create(root, args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Created workspace %s in %s\n", ws.Name, ws.Dir)
+	fmt.Printf("Open it with todo --workspace %s, settings go in %s\n", ws.Name, ws.ConfigPath())
+	return 0
+}
+
+func runWorkspaceInit(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: todo workspace init [dir]")
+		return 2
+	}
+	dir := "."
+	if len(args) == 1 {
+		dir = args[0]
+	}
+
+	ws, err := workspace.Init(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Created workspace %s in %s\n", ws.Name, ws.Dir)
+	fmt.Println("It is opened whenever todo runs in this directory or below it")
+	return 0
+}
+
+// runWorkspaceMove moves todos from the current workspace to another one. Instances running
+// on either workspace pick up the changes through their own sync socket.
+func runWorkspaceMove(args []string, appVersion string, cfg *config.Config, current workspace.Workspace, root string) int {
+	fs := flag.NewFlagSet("workspace move", flag.ContinueOnError)
+	to := fs.String("to", "", "workspace to move the todos to, . for the project in the working directory")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *to == "" || fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: todo workspace move --to <name> <id>...")
+		return 2
+	}
+
+	ids := make([]int64, fs.NArg())
+	for i, arg := range fs.Args() {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%q is not a todo ID\n", arg)
+			return 2
+		}
+		ids[i] = id
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	target, err := workspace.Resolve(root, *to, cwd)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if target.Dir == current.Dir {
+		fmt.Fprintf(os.Stderr, "the todos are already in workspace %s\n", target.Label())
+		return 1
+	}
+
+	// The settings of the target decide where it keeps its todos
+	targetCfg, err := workspaceConfig(cfg, target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	targetRepo, err := openRepositoryAt(targetCfg, target.Dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to open database of workspace", target.Label()+":", err)
+		return 1
+	}
+	defer targetRepo.Close()
+
+	targetService := service.NewAppService(targetRepo)
+	targetService.SetConfig(targetCfg)
+	hookRunner := hooks.NewRunner(targetCfg.HooksDir(), targetCfg.HookTimeout())
+	targetService.SetHooks(hookRunner)
+	defer hookRunner.Wait()
+
+	targetSync, _ := startSyncAt(filepath.Join(target.Dir, "todo.sock"), targetService, targetRepo)
+	if targetSync != nil {
+		defer targetSync.Stop()
+	}
+
+	return withService(appVersion, cfg, func(appService *service.AppService) int {
+		status := 0
+		for _, id := range ids {
+			moved, err := appService.MoveTodo(id, targetService)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to move todo %d: %v\n", id, err)
+				status = 1
+				continue
+			}
+			fmt.Printf("Moved %q to %s as TODO-%d\n", moved.Title, target.Label(), moved.ID)
+		}
+		return status
+	})
+}
+
+// workspaceConfig returns the config as it applies to another workspace than the current one
+func workspaceConfig(cfg *config.Config, ws workspace.Workspace) (*config.Config, error) {
+	wsCfg, err := config.Load(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	wsCfg.DataDir = cfg.DataDir
+	wsCfg.Language = cfg.Language
+	if path := ws.ConfigPath(); path != "" {
+		if err := wsCfg.Overlay(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := wsCfg.Validate(); err != nil {
+		return nil, err
+	}
+	return wsCfg, nil
+}
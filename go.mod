@@ -5,13 +5,18 @@ go 1.23.4
 toolchain go1.23.7
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/charmbracelet/bubbles v0.21.0
 	github.com/charmbracelet/bubbletea v1.3.5
 	github.com/charmbracelet/lipgloss v1.1.1-0.20250404203927-76690c660834
 	github.com/charmbracelet/log v0.4.2
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/nicksnyder/go-i18n/v2 v2.6.0
 	github.com/rmhubbert/bubbletea-overlay v0.3.2
+	golang.org/x/crypto v0.37.0
+	golang.org/x/term v0.31.0
 	golang.org/x/text v0.26.0
+	gopkg.in/yaml.v3 v3.0.1
 	modernc.org/sqlite v1.37.0
 	pgregory.net/rapid v1.2.0
 )
@@ -30,7 +35,6 @@ require (
 	github.com/yuin/goldmark v1.7.8 // indirect
 	github.com/yuin/goldmark-emoji v1.0.5 // indirect
 	golang.org/x/net v0.38.0 // indirect
-	golang.org/x/term v0.31.0 // indirect
 )
 
 require (
@@ -57,7 +61,7 @@ require (
 	github.com/sahilm/fuzzy v0.1.1 // indirect
 	golang.org/x/exp v0.0.0-20250305212735-054e65f0b394 // indirect
 	golang.org/x/sync v0.15.0 // indirect
-	golang.org/x/sys v0.32.0 // indirect
+	golang.org/x/sys v0.32.0
 	modernc.org/libc v1.62.1 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
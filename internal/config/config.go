@@ -0,0 +1,401 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/martijnspitter/tui-todo/internal/keys"
+	"github.com/martijnspitter/tui-todo/internal/models"
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
+	"github.com/martijnspitter/tui-todo/internal/theme"
+	"golang.org/x/text/language"
+)
+
+const FileName = "config.toml"
+
+// Config holds the user configurable behaviour and defaults of the application
+type Config struct {
+	// Language is detected from the locale when empty
+	Language        string         `toml:"language"`
+	Theme           string         `toml:"theme"`
+	DataDir         string         `toml:"data_dir"`
+	DateFormat      string         `toml:"date_format"`
+	ConfirmQuit     bool           `toml:"confirm_quit"`
+	CheckForUpdates bool           `toml:"check_for_updates"`
+	Defaults        DefaultsConfig `toml:"defaults"`
+	Today           TodayConfig    `toml:"today"`
+	Keys            KeysConfig     `toml:"keys"`
+	Sync            SyncConfig     `toml:"sync"`
+	HTTP            HTTPConfig     `toml:"http"`
+	Hooks           HooksConfig    `toml:"hooks"`
+	Storage         StorageConfig  `toml:"storage"`
+
+	// Path is the file the configuration was loaded from, empty when no file exists
+	Path string `toml:"-"`
+}
+
+// DefaultsConfig holds the values new todos start with
+type DefaultsConfig struct {
+	Priority string `toml:"priority"`
+	Status   string `toml:"status"`
+}
+
+// TodayConfig controls which todos show up on the Today dashboard
+type TodayConfig struct {
+	ComingUpDays int    `toml:"coming_up_days"`
+	HighPriority string `toml:"high_priority"`
+}
+
+// KeysConfig selects a key map preset and remaps single bindings on top of it
+type KeysConfig struct {
+	Preset   string              `toml:"preset"`
+	Bindings map[string][]string `toml:"bindings"`
+}
+
+// SyncConfig connects to a relay started with `todo serve` to share todos between machines
+type SyncConfig struct {
+	// Remote is the host:port of the relay, sync between machines is off when empty
+	Remote string `toml:"remote"`
+	// Secret is shared by the relay and all machines, TODO_SYNC_SECRET overrides it
+	Secret string `toml:"secret"`
+	TLS    bool   `toml:"tls"`
+	// CAFile verifies a relay with a self-signed certificate
+	CAFile string `toml:"ca_file"`
+
+	// LockEdits claims a todo while it is open for editing, the other instances on this
+	// machine ask before saving it
+	LockEdits bool `toml:"lock_edits"`
+
+	// Listen, TLSCert and TLSKey configure `todo serve`
+	Listen  string `toml:"listen"`
+	TLSCert string `toml:"tls_cert"`
+	TLSKey  string `toml:"tls_key"`
+}
+
+// HTTPConfig serves the JSON API next to the TUI, `todo serve-http` serves it on its own
+type HTTPConfig struct {
+	// Addr is the host:port to listen on, the API is off when empty
+	Addr string `toml:"addr"`
+	// Token is required in the Authorization header, TODO_HTTP_TOKEN overrides it
+	Token string `toml:"token"`
+}
+
+// HooksConfig controls the scripts in the hooks directory next to the config file
+type HooksConfig struct {
+	// Timeout is how many seconds a hook may run before it is killed
+	Timeout int `toml:"timeout"`
+}
+
+// Storage backends
+const (
+	SQLiteBackend = "sqlite"
+	VaultBackend  = "vault"
+)
+
+// StorageConfig selects where the todos of a workspace are kept
+type StorageConfig struct {
+	// Backend is sqlite for a database or vault for a directory of Markdown files
+	Backend string `toml:"backend"`
+	// Dir holds the Markdown files of the vault, the vault directory next to the database
+	// when empty
+	Dir string `toml:"dir"`
+	// Keyring is a file that caches the key of an encrypted database, so the passphrase
+	// isn't asked on every start. Empty asks every time.
+	Keyring string `toml:"keyring"`
+}
+
+// ValidateHTTPAddr checks the address of the HTTP API. Without a token it may only listen
+// on the loopback interface.
+func ValidateHTTPAddr(addr, token string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%q must be host:port", addr)
+	}
+	if token != "" {
+		return nil
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("%q is reachable from other machines, set http.token or TODO_HTTP_TOKEN", addr)
+	}
+	return nil
+}
+
+// Default returns the configuration used when no config file is present
+func Default() *Config {
+	return &Config{
+		Language:        "",
+		Theme:           theme.DarkName,
+		DateFormat:      time.Stamp,
+		ConfirmQuit:     true,
+		CheckForUpdates: true,
+		Defaults: DefaultsConfig{
+			Priority: "low",
+			Status:   "open",
+		},
+		Today: TodayConfig{
+			ComingUpDays: 3,
+			HighPriority: "major",
+		},
+		Keys: KeysConfig{
+			Preset: keys.DefaultPreset,
+		},
+		Sync: SyncConfig{
+			Listen: ":7420",
+		},
+		Hooks: HooksConfig{
+			Timeout: 10,
+		},
+		Storage: StorageConfig{
+			Backend: SQLiteBackend,
+		},
+	}
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/tui-todo/config.toml or the OS equivalent
+func DefaultPath() string {
+	return filepath.Join(osoperations.GetConfigDir(), FileName)
+}
+
+// Load reads the configuration file at path, or the default path when path is empty.
+// A missing file is not an error and yields the default configuration.
+func Load(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath()
+	}
+
+	cfg := Default()
+
+	if _, err := cfg.decodeFile(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	cfg.Path = path
+
+	return cfg, nil
+}
+
+// Overlay applies the settings file of a workspace on top of the configuration. Only the
+// options in the file change, a missing file changes nothing. The data directory can't be
+// set per workspace, the workspace decides where its data lives.
+func (c *Config) Overlay(path string) error {
+	dataDir := c.DataDir
+	meta, err := c.decodeFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if meta.IsDefined("data_dir") {
+		c.DataDir = dataDir
+		return fmt.Errorf("data_dir can't be set in workspace config %s", path)
+	}
+	return nil
+}
+
+// decodeFile reads path into c and rejects keys that don't match an option
+func (c *Config) decodeFile(path string) (toml.MetaData, error) {
+	meta, err := toml.DecodeFile(path, c)
+	if err != nil {
+		return meta, fmt.Errorf("couldn't read config %s: %w", path, err)
+	}
+
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return meta, fmt.Errorf("unknown config keys in %s: %s", path, strings.Join(keys, ", "))
+	}
+	return meta, nil
+}
+
+// Validate checks every option and returns all problems at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if _, err := language.Parse(c.Language); c.Language != "" && err != nil {
+		errs = append(errs, fmt.Errorf("language: %q is not a valid language tag", c.Language))
+	}
+
+	if _, ok := theme.Lookup(c.Theme); !ok {
+		errs = append(errs, fmt.Errorf("theme: %q is not one of %s", c.Theme, strings.Join(theme.Names(), ", ")))
+	}
+
+	if c.DataDir != "" {
+		dir, err := expandHome(c.DataDir)
+		if err != nil || !filepath.IsAbs(dir) {
+			errs = append(errs, fmt.Errorf("data_dir: %q must be an absolute path", c.DataDir))
+		}
+	}
+
+	if strings.TrimSpace(c.DateFormat) == "" {
+		errs = append(errs, errors.New("date_format: must not be empty"))
+	} else if sample := time.Date(1999, 11, 28, 23, 59, 58, 0, time.UTC); sample.Format(c.DateFormat) == c.DateFormat {
+		errs = append(errs, fmt.Errorf("date_format: %q contains no Go time layout elements", c.DateFormat))
+	}
+
+	if _, err := models.ParsePriority(c.Defaults.Priority); err != nil {
+		errs = append(errs, fmt.Errorf("defaults.priority: %w", err))
+	}
+
+	if _, err := models.ParseStatus(c.Defaults.Status); err != nil {
+		errs = append(errs, fmt.Errorf("defaults.status: %w", err))
+	}
+
+	if c.Today.ComingUpDays < 1 || c.Today.ComingUpDays > 31 {
+		errs = append(errs, fmt.Errorf("today.coming_up_days: %d must be between 1 and 31", c.Today.ComingUpDays))
+	}
+
+	if _, err := models.ParsePriority(c.Today.HighPriority); err != nil {
+		errs = append(errs, fmt.Errorf("today.high_priority: %w", err))
+	}
+
+	if _, err := keys.FromConfig(c.Keys.Preset, c.Keys.Bindings); err != nil {
+		errs = append(errs, fmt.Errorf("keys: %w", err))
+	}
+
+	if c.Sync.Remote != "" {
+		if _, _, err := net.SplitHostPort(c.Sync.Remote); err != nil {
+			errs = append(errs, fmt.Errorf("sync.remote: %q must be host:port", c.Sync.Remote))
+		}
+		if c.Sync.Secret == "" {
+			errs = append(errs, errors.New("sync.secret: required when sync.remote is set"))
+		}
+	}
+
+	if _, _, err := net.SplitHostPort(c.Sync.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("sync.listen: %q must be [host]:port", c.Sync.Listen))
+	}
+
+	if (c.Sync.TLSCert == "") != (c.Sync.TLSKey == "") {
+		errs = append(errs, errors.New("sync.tls_cert and sync.tls_key must be set together"))
+	}
+
+	if c.HTTP.Addr != "" {
+		if err := ValidateHTTPAddr(c.HTTP.Addr, c.HTTP.Token); err != nil {
+			errs = append(errs, fmt.Errorf("http.addr: %w", err))
+		}
+	}
+
+	if c.Hooks.Timeout < 1 || c.Hooks.Timeout > 300 {
+		errs = append(errs, fmt.Errorf("hooks.timeout: %d must be between 1 and 300 seconds", c.Hooks.Timeout))
+	}
+
+	if c.Storage.Backend != SQLiteBackend && c.Storage.Backend != VaultBackend {
+		errs = append(errs, fmt.Errorf("storage.backend: %q is not one of %s, %s", c.Storage.Backend, SQLiteBackend, VaultBackend))
+	}
+	if c.Storage.Dir != "" {
+		dir, err := expandHome(c.Storage.Dir)
+		if err != nil || !filepath.IsAbs(dir) {
+			errs = append(errs, fmt.Errorf("storage.dir: %q must be an absolute path", c.Storage.Dir))
+		}
+	}
+	if c.Storage.Keyring != "" {
+		path, err := expandHome(c.Storage.Keyring)
+		if err != nil || !filepath.IsAbs(path) {
+			errs = append(errs, fmt.Errorf("storage.keyring: %q must be an absolute path", c.Storage.Keyring))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ThemesDir returns the directory with custom palettes, next to the config file
+func (c *Config) ThemesDir() string {
+	path := c.Path
+	if path == "" {
+		path = DefaultPath()
+	}
+	return filepath.Join(filepath.Dir(path), "themes")
+}
+
+// HooksDir returns the directory with the event hooks, next to the config file
+func (c *Config) HooksDir() string {
+	path := c.Path
+	if path == "" {
+		path = DefaultPath()
+	}
+	return filepath.Join(filepath.Dir(path), "hooks")
+}
+
+// HookTimeout returns how long a hook may run
+func (c *Config) HookTimeout() time.Duration {
+	return time.Duration(c.Hooks.Timeout) * time.Second
+}
+
+// VaultDir returns the directory of the Markdown vault, dataDir/vault unless storage.dir is set
+func (c *Config) VaultDir(dataDir string) string {
+	if c.Storage.Dir == "" {
+		return filepath.Join(dataDir, "vault")
+	}
+	dir, err := expandHome(c.Storage.Dir)
+	if err != nil {
+		return c.Storage.Dir
+	}
+	return dir
+}
+
+// KeyringPath returns the keyring file with a leading ~ expanded, empty when there is none
+func (c *Config) KeyringPath() string {
+	path, err := expandHome(c.Storage.Keyring)
+	if err != nil {
+		return c.Storage.Keyring
+	}
+	return path
+}
+
+// ResolvedDataDir returns the data directory with a leading ~ expanded
+func (c *Config) ResolvedDataDir() string {
+	if c.DataDir == "" {
+		return ""
+	}
+	dir, err := expandHome(c.DataDir)
+	if err != nil {
+		return c.DataDir
+	}
+	return dir
+}
+
+// DefaultPriority returns the priority new todos start with
+func (c *Config) DefaultPriority() models.Priority {
+	p, _ := models.ParsePriority(c.Defaults.Priority)
+	return p
+}
+
+// DefaultStatus returns the status new todos start with
+func (c *Config) DefaultStatus() models.Status {
+	s, _ := models.ParseStatus(c.Defaults.Status)
+	return s
+}
+
+// HighPriority returns the lowest priority shown in the Today high priority section
+func (c *Config) HighPriority() models.Priority {
+	p, err := models.ParsePriority(c.Today.HighPriority)
+	if err != nil {
+		return models.Major
+	}
+	return p
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadMissingDefaultFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("expected defaults when no config file exists, got %v", err)
+	}
+	if cfg.Path != "" {
+		t.Errorf("expected empty path, got %q", cfg.Path)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("default config should be valid: %v", err)
+	}
+}
+
+func TestLoadMissingExplicitFile(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "nope.toml")); err == nil {
+		t.Error("expected an error for a missing explicit config file")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeConfig(t, `
+language = "nl"
+date_format = "2006-01-02"
+confirm_quit = false
+
+[defaults]
+priority = "high"
+status = "doing"
+
+[today]
+coming_up_days = 7
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Path != path || cfg.Language != "nl" || cfg.DateFormat != "2006-01-02" || cfg.ConfirmQuit {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if cfg.DefaultPriority() != models.High || cfg.DefaultStatus() != models.Doing {
+		t.Errorf("unexpected defaults: %v %v", cfg.DefaultPriority(), cfg.DefaultStatus())
+	}
+	if cfg.Today.ComingUpDays != 7 {
+		t.Errorf("expected coming_up_days 7, got %d", cfg.Today.ComingUpDays)
+	}
+	// Options missing from the file keep their defaults
+	if !cfg.CheckForUpdates || cfg.HighPriority() != models.Major {
+		t.Errorf("expected defaults for unset options: %+v", cfg)
+	}
+}
+
+func TestLoadUnknownKey(t *testing.T) {
+	path := writeConfig(t, "langauge = \"en\"\n")
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "langauge") {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Language = "not a language!"
+	cfg.DataDir = "relative/dir"
+	cfg.DateFormat = "no layout"
+	cfg.Defaults.Priority = "urgent"
+	cfg.Defaults.Status = "waiting"
+	cfg.Today.ComingUpDays = 0
+	cfg.Today.HighPriority = "huge"
+	cfg.Hooks.Timeout = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	for _, key := range []string{"language", "data_dir", "date_format", "defaults.priority", "defaults.status", "today.coming_up_days", "today.high_priority", "hooks.timeout"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("expected an error for %s, got:\n%v", key, err)
+		}
+	}
+}
+
+func TestResolvedDataDir(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	cfg := Default()
+	cfg.DataDir = "~/todos"
+	if got := cfg.ResolvedDataDir(); got != filepath.Join(home, "todos") {
+		t.Errorf("ResolvedDataDir() = %q", got)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("~ paths should be valid: %v", err)
+	}
+}
+
+func TestValidateTheme(t *testing.T) {
+	cfg := Default()
+	cfg.Theme = "sepia"
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "theme") {
+		t.Errorf("expected an unknown theme error, got %v", err)
+	}
+
+	cfg.Theme = "high-contrast"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("built-in themes should be valid: %v", err)
+	}
+}
+
+func TestValidateSync(t *testing.T) {
+	cfg := Default()
+	cfg.Sync.Remote = "laptop"
+	cfg.Sync.TLSCert = "relay.crt"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, key := range []string{"sync.remote", "sync.secret", "sync.tls_cert"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("expected an error for %s, got:\n%v", key, err)
+		}
+	}
+
+	cfg.Sync.Remote = "relay.example.com:7420"
+	cfg.Sync.Secret = "s3cret"
+	cfg.Sync.TLSKey = "relay.key"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid sync options rejected: %v", err)
+	}
+}
+
+func TestValidateHTTP(t *testing.T) {
+	tests := []struct {
+		addr, token string
+		valid       bool
+	}{
+		{"127.0.0.1:8080", "", true},
+		{"localhost:8080", "", true},
+		{"[::1]:8080", "", true},
+		{":8080", "", false},
+		{"0.0.0.0:8080", "", false},
+		{"0.0.0.0:8080", "s3cret", true},
+		{"8080", "s3cret", false},
+	}
+
+	for _, tt := range tests {
+		cfg := Default()
+		cfg.HTTP.Addr = tt.addr
+		cfg.HTTP.Token = tt.token
+
+		err := cfg.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("Validate(%q, token %q) error: %v", tt.addr, tt.token, err)
+		}
+		if !tt.valid && (err == nil || !strings.Contains(err.Error(), "http.addr")) {
+			t.Errorf("Validate(%q, token %q) = %v, want an http.addr error", tt.addr, tt.token, err)
+		}
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "language = \"nl\"\ntheme = \"light\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.Overlay(writeConfig(t, "theme = \"high-contrast\"\n\n[http]\naddr = \"127.0.0.1:8081\"\n")); err != nil {
+		t.Fatal(err)
+	}
+	// Options the workspace doesn't set keep the value of the config file
+	if cfg.Language != "nl" || cfg.Theme != "high-contrast" || cfg.HTTP.Addr != "127.0.0.1:8081" {
+		t.Errorf("unexpected config after overlay: %+v", cfg)
+	}
+
+	if err := cfg.Overlay(filepath.Join(t.TempDir(), "missing.toml")); err != nil {
+		t.Errorf("missing workspace config: %v", err)
+	}
+	if err := cfg.Overlay(writeConfig(t, "data_dir = \"/tmp/elsewhere\"\n")); err == nil || cfg.DataDir != "" {
+		t.Errorf("data_dir in workspace config: err %v, data_dir %q", err, cfg.DataDir)
+	}
+}
+
+func TestValidateStorage(t *testing.T) {
+	cfg := Default()
+	cfg.Storage.Backend = "postgres"
+	cfg.Storage.Dir = "notes/todos"
+	cfg.Storage.Keyring = "keyring"
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "storage.backend") || !strings.Contains(err.Error(), "storage.dir") ||
+		!strings.Contains(err.Error(), "storage.keyring") {
+		t.Errorf("expected storage errors, got %v", err)
+	}
+
+	cfg = Default()
+	if got := cfg.VaultDir("/data"); got != filepath.Join("/data", "vault") {
+		t.Errorf("VaultDir() = %q", got)
+	}
+	cfg.Storage.Backend = VaultBackend
+	cfg.Storage.Dir = "/notes/todos"
+	if err := cfg.Validate(); err != nil || cfg.VaultDir("/data") != "/notes/todos" {
+		t.Errorf("vault storage: %v, dir %q", err, cfg.VaultDir("/data"))
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	cfg.Storage.Keyring = "~/.config/tui-todo/keyring"
+	if err := cfg.Validate(); err != nil || cfg.KeyringPath() != filepath.Join(home, ".config/tui-todo/keyring") {
+		t.Errorf("keyring: %v, path %q", err, cfg.KeyringPath())
+	}
+}
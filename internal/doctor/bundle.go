@@ -0,0 +1,99 @@
+package doctor
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"os/user"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A bundle is a zip file users attach to a bug report. Everything in it is redacted: the
+// content of todos and tags, secrets, and the names of the user and the machine.
+
+const redacted = "[redacted]"
+
+var (
+	// logValuePattern matches the values of log fields that hold what the user wrote
+	logValuePattern = regexp.MustCompile(`\b(title|description|name|tag|tags|query|remote|secret|token)=("(?:[^"\\]|\\.)*"|\S+)`)
+	// configSecretPattern matches the secrets in the configuration file
+	configSecretPattern = regexp.MustCompile(`(?m)^(\s*(?:secret|token)\s*=\s*).*$`)
+)
+
+// Redactor removes personal data and secrets from text
+type Redactor struct {
+	replacer *strings.Replacer
+}
+
+// NewRedactor creates a redactor for the secrets, such as the sync secret and the HTTP
+// token. The home directory, user name and host name are always redacted.
+func NewRedactor(secrets ...string) *Redactor {
+	var pairs []string
+	for _, secret := range secrets {
+		if secret != "" {
+			pairs = append(pairs, secret, redacted)
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil && len(home) > 1 {
+		pairs = append(pairs, home, "~")
+	}
+	// Short names would also match inside other words
+	if current, err := user.Current(); err == nil && len(current.Username) >= 3 {
+		pairs = append(pairs, current.Username, "[user]")
+	}
+	if host, err := os.Hostname(); err == nil && len(host) >= 3 {
+		pairs = append(pairs, host, "[host]")
+	}
+	return newRedactor(pairs)
+}
+
+func newRedactor(pairs []string) *Redactor {
+	// The longest strings go first, so a home directory is replaced before the user name in it
+	type pair struct{ old, new string }
+	sorted := make([]pair, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		sorted = append(sorted, pair{pairs[i], pairs[i+1]})
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].old) > len(sorted[j].old) })
+
+	flat := make([]string, 0, len(pairs))
+	for _, p := range sorted {
+		flat = append(flat, p.old, p.new)
+	}
+	return &Redactor{replacer: strings.NewReplacer(flat...)}
+}
+
+// Redact returns text without the values of todo fields, the secrets and personal names
+func (r *Redactor) Redact(text string) string {
+	text = logValuePattern.ReplaceAllString(text, "${1}="+redacted)
+	text = configSecretPattern.ReplaceAllString(text, `${1}"`+redacted+`"`)
+	return r.replacer.Replace(text)
+}
+
+// BundleFile is a file in a bundle
+type BundleFile struct {
+	Name    string
+	Content []byte
+}
+
+// WriteBundle redacts the files and writes them to a zip file at path, which only the user
+// can read
+func WriteBundle(path string, redactor *Redactor, files []BundleFile) error {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := archive.Create(file.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(redactor.Redact(string(file.Content)))); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o600)
+}
@@ -0,0 +1,74 @@
+package doctor
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	redactor := newRedactor([]string{"s3cret", redacted, "/home/alice", "~", "alice", "[user]"})
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`ERRO Failed to create todo title="Buy a present" error="disk full"`, `ERRO Failed to create todo title=[redacted] error="disk full"`},
+		{`WARN Tag not found tag=birthday id=4`, `WARN Tag not found tag=[redacted] id=4`},
+		{`INFO Opening workspace dir=/home/alice/.local/share/tui-todo`, `INFO Opening workspace dir=~/.local/share/tui-todo`},
+		{`INFO Connected to relay as alice`, `INFO Connected to relay as [user]`},
+		{`relay refused secret s3cret`, `relay refused secret [redacted]`},
+		{"[sync]\nremote = \"relay:7000\"\nsecret = \"s3cret\"\n", "[sync]\nremote = \"relay:7000\"\nsecret = \"[redacted]\"\n"},
+		{`  token = "abc"`, `  token = "[redacted]"`},
+	}
+
+	for _, tt := range tests {
+		if got := redactor.Redact(tt.input); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.zip")
+	redactor := newRedactor([]string{"s3cret", redacted})
+	err := WriteBundle(path, redactor, []BundleFile{
+		{Name: "report.txt", Content: []byte("ok  todos  3 todos\n")},
+		{Name: "debug.log", Content: []byte(`ERRO Failed title="Secret plan" secret=s3cret` + "\n")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm()&0o077 != 0 {
+		t.Errorf("the bundle can be read by others: %v, %v", info.Mode(), err)
+	}
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	want := map[string]string{
+		"report.txt": "ok  todos  3 todos\n",
+		"debug.log":  "ERRO Failed title=[redacted] secret=[redacted]\n",
+	}
+	if len(archive.File) != len(want) {
+		t.Fatalf("the bundle holds %d files, want %d", len(archive.File), len(want))
+	}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != want[file.Name] {
+			t.Errorf("%s = %q, want %q", file.Name, content, want[file.Name])
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package doctor
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+// The doctor diagnoses why todo doesn't start or shows no todos. Every check returns a
+// finding, those that can be repaired without losing data carry the repair, which only runs
+// when the user asks for it.
+
+const (
+	// MaxTrackingSession is how long a todo can plausibly be tracked without a pause, a
+	// timer that runs longer was forgotten or started with a wrong clock
+	MaxTrackingSession = 7 * 24 * time.Hour
+	// clockSkew is how far in the future a start time may be, instances on other machines
+	// don't agree on the time to the second
+	clockSkew = time.Minute
+	// logTailLines is how much of the log is checked and put in a bug report
+	logTailLines = 200
+	// maxDetails limits the lines listed for one finding
+	maxDetails = 10
+)
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	OK Status = iota
+	Warning
+	Problem
+)
+
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Warning:
+		return "warning"
+	default:
+		return "problem"
+	}
+}
+
+// Finding is the outcome of one check
+type Finding struct {
+	Check   string
+	Status  Status
+	Summary string
+	Details []string
+	// Fix says what Repair does, Repair is nil when nothing can be fixed safely
+	Fix    string
+	Repair func() error
+}
+
+// Fixable reports whether the finding is a problem --fix can repair
+func (f Finding) Fixable() bool {
+	return f.Status != OK && f.Repair != nil
+}
+
+// Report collects the findings of all checks
+type Report struct {
+	Findings []Finding
+}
+
+func (r *Report) Add(findings ...Finding) {
+	r.Findings = append(r.Findings, findings...)
+}
+
+// Worst returns the most severe status among the findings
+func (r *Report) Worst() Status {
+	worst := OK
+	for _, finding := range r.Findings {
+		worst = max(worst, finding.Status)
+	}
+	return worst
+}
+
+// Fixable returns the findings --fix can repair
+func (r *Report) Fixable() []Finding {
+	var fixable []Finding
+	for _, finding := range r.Findings {
+		if finding.Fixable() {
+			fixable = append(fixable, finding)
+		}
+	}
+	return fixable
+}
+
+// Write prints the findings as a table, with their details and fixes below them
+func (r *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, finding := range r.Findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", finding.Status, finding.Check, finding.Summary)
+		for _, detail := range finding.Details {
+			fmt.Fprintf(tw, "\t\t  %s\n", detail)
+		}
+		if finding.Fixable() {
+			fmt.Fprintf(tw, "\t\t  fix: %s\n", finding.Fix)
+		}
+	}
+	return tw.Flush()
+}
+
+// limitDetails keeps the first maxDetails lines and says how many were left out
+func limitDetails(details []string) []string {
+	if len(details) <= maxDetails {
+		return details
+	}
+	return append(details[:maxDetails:maxDetails], fmt.Sprintf("and %d more", len(details)-maxDetails))
+}
+
+// ===========================================================================
+// Data directory
+// ===========================================================================
+
+// dataFiles are the files todo writes in its data directory
+var dataFiles = []string{"todo.sql", "todo.sql-wal", "todo.sql-shm", "debug.log"}
+
+// CheckDataDir checks that todo can write its data directory and the files in it
+func CheckDataDir(dir string) Finding {
+	finding := Finding{Check: "data dir"}
+	info, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		finding.Status = Warning
+		finding.Summary = fmt.Sprintf("%s doesn't exist, todo hasn't stored anything there yet", dir)
+		finding.Details = []string{"check data_dir in the configuration, --data-dir and the workspace"}
+		return finding
+	}
+	if err != nil {
+		finding.Status, finding.Summary = Problem, err.Error()
+		return finding
+	}
+	if !info.IsDir() {
+		finding.Status, finding.Summary = Problem, fmt.Sprintf("%s is not a directory", dir)
+		return finding
+	}
+
+	if probe, err := os.CreateTemp(dir, ".doctor-*"); err != nil {
+		finding.Status = Problem
+		finding.Summary = fmt.Sprintf("can't create files in %s", dir)
+		finding.Details = append(finding.Details, err.Error())
+	} else {
+		probe.Close()
+		os.Remove(probe.Name())
+	}
+
+	var readOnly []string
+	for _, name := range dataFiles {
+		path := filepath.Join(dir, name)
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			readOnly = append(readOnly, path)
+			finding.Details = append(finding.Details, err.Error())
+			continue
+		}
+		file.Close()
+	}
+
+	// Others that can write the directory can replace the database
+	openToOthers := runtime.GOOS != "windows" && info.Mode().Perm()&0o022 != 0
+	if openToOthers {
+		finding.Details = append(finding.Details, fmt.Sprintf("%s can be written by other users (%s)", dir, info.Mode().Perm()))
+	}
+
+	switch {
+	case len(readOnly) > 0:
+		finding.Status = Problem
+		finding.Summary = fmt.Sprintf("%d files in %s can't be written", len(readOnly), dir)
+	case finding.Status == Problem:
+	case openToOthers:
+		finding.Status = Warning
+		finding.Summary = fmt.Sprintf("%s is writable by other users", dir)
+	default:
+		finding.Summary = fmt.Sprintf("%s is writable", dir)
+		return finding
+	}
+
+	if len(readOnly) > 0 || openToOthers {
+		var fixes []string
+		if len(readOnly) > 0 {
+			fixes = append(fixes, "give the owner write access to the files")
+		}
+		if openToOthers {
+			fixes = append(fixes, "take write access to the directory away from other users")
+		}
+		finding.Fix = strings.Join(fixes, " and ")
+		finding.Repair = func() error {
+			for _, path := range readOnly {
+				info, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				if err := os.Chmod(path, info.Mode().Perm()|0o600); err != nil {
+					return err
+				}
+			}
+			if openToOthers {
+				return os.Chmod(dir, info.Mode().Perm()&^0o022)
+			}
+			return nil
+		}
+	}
+	return finding
+}
+
+// ===========================================================================
+// Database
+// ===========================================================================
+
+// CheckIntegrity runs SQLite's integrity check on the database
+func CheckIntegrity(db *sql.DB) Finding {
+	finding := Finding{Check: "integrity"}
+	problems, err := repository.IntegrityCheck(db)
+	switch {
+	case err != nil:
+		finding.Status, finding.Summary = Problem, fmt.Sprintf("the integrity check failed: %v", err)
+	case len(problems) > 0:
+		// Repairing a damaged file is guesswork, a backup is the safe way back
+		finding.Status = Problem
+		finding.Summary = "the database is damaged, restore a backup such as todo.sql.<date>.bak"
+		finding.Details = limitDetails(problems)
+	default:
+		finding.Summary = "the database is sound"
+	}
+	return finding
+}
+
+// CheckForeignKeys looks for rows that refer to todos or tags that don't exist
+func CheckForeignKeys(db *sql.DB) Finding {
+	finding := Finding{Check: "foreign keys"}
+	violations, err := repository.ForeignKeyCheck(db)
+	if err != nil {
+		finding.Status, finding.Summary = Problem, fmt.Sprintf("the foreign key check failed: %v", err)
+		return finding
+	}
+	if len(violations) == 0 {
+		finding.Summary = "every reference points to an existing row"
+		return finding
+	}
+
+	finding.Status = Warning
+	finding.Summary = fmt.Sprintf("%d rows refer to rows that don't exist", len(violations))
+	removable := 0
+	var details []string
+	for _, violation := range violations {
+		details = append(details, fmt.Sprintf("%s row %d refers to a missing row in %s", violation.Table, violation.RowID, violation.Parent))
+		if violation.Removable() {
+			removable++
+		}
+	}
+	finding.Details = limitDetails(details)
+
+	if removable > 0 {
+		finding.Fix = fmt.Sprintf("remove the %d tag links of deleted todos and tags", removable)
+		finding.Repair = func() error {
+			_, err := repository.RemoveViolations(db, violations)
+			return err
+		}
+	}
+	return finding
+}
+
+// CheckMigrations compares the migrations applied to the database with those of this version
+func CheckMigrations(manager *repository.MigrationManager, migrations []repository.Migration) Finding {
+	finding := Finding{Check: "migrations"}
+	if err := manager.CheckVersion(migrations); err != nil {
+		finding.Status, finding.Summary = Problem, err.Error()
+		return finding
+	}
+
+	statuses, err := manager.Status(migrations)
+	if err == nil {
+		var pending []repository.Migration
+		pending, err = manager.Pending(migrations)
+		if err == nil && len(pending) > 0 {
+			applied := make(map[int]bool)
+			for _, status := range statuses {
+				applied[status.ID] = status.Applied
+			}
+			for _, migration := range pending {
+				detail := fmt.Sprintf("migration %d: %s", migration.ID, migration.Name)
+				if applied[migration.ID] {
+					detail += " is recorded, but its changes are missing"
+				}
+				finding.Details = append(finding.Details, detail)
+			}
+			finding.Status = Warning
+			finding.Summary = fmt.Sprintf("%d migrations are pending, todo applies them when it starts", len(pending))
+			finding.Fix = "apply the pending migrations"
+			finding.Repair = func() error { return manager.ApplyMigrations(migrations) }
+			return finding
+		}
+	}
+	if err != nil {
+		finding.Status, finding.Summary = Problem, fmt.Sprintf("failed to read migrations: %v", err)
+		return finding
+	}
+
+	finding.Summary = fmt.Sprintf("the schema is at version %d", repository.LatestMigration(migrations))
+	return finding
+}
+
+// ===========================================================================
+// Sync socket
+// ===========================================================================
+
+// CheckSocket reports the instances connected to the sync socket, or a socket that was left
+// behind by an instance that crashed
+func CheckSocket(socketPath string) Finding {
+	finding := Finding{Check: "sync socket"}
+	if _, err := os.Stat(socketPath); errors.Is(err, os.ErrNotExist) {
+		finding.Summary = "no instances are running"
+		return finding
+	}
+
+	status, err := socket_sync.QueryStatus(socketPath)
+	if err == nil {
+		finding.Summary = fmt.Sprintf("%d instances are running", len(status.Peers))
+		for _, peer := range status.Peers {
+			role := "secondary"
+			if peer.Primary {
+				role = "primary"
+			}
+			finding.Details = append(finding.Details, fmt.Sprintf("pid %d, %s, version %s, last seen %s",
+				peer.PID, role, peer.App, peer.LastSeen.Format(time.DateTime)))
+		}
+		return finding
+	}
+
+	var incompatible *socket_sync.IncompatibleError
+	if errors.As(err, &incompatible) {
+		finding.Status = Warning
+		finding.Summary = "instances of another version are running, they don't sync with this one"
+		finding.Details = []string{err.Error()}
+		return finding
+	}
+
+	conn, dialErr := net.DialTimeout(socket_sync.DefaultProtocol, socketPath, 2*time.Second)
+	if dialErr == nil {
+		conn.Close()
+		finding.Status = Warning
+		finding.Summary = "an instance is listening, but doesn't answer"
+		finding.Details = []string{err.Error()}
+		return finding
+	}
+
+	finding.Status = Warning
+	finding.Summary = fmt.Sprintf("%s was left behind by an instance that is gone", filepath.Base(socketPath))
+	finding.Fix = "remove the stale socket"
+	finding.Repair = func() error { return socket_sync.CleanupSocket(socketPath) }
+	return finding
+}
+
+// ===========================================================================
+// Todos
+// ===========================================================================
+
+// CheckTodos counts the todos, an empty workspace is the usual reason the app looks empty
+func CheckTodos(repo repository.TodoRepository) Finding {
+	finding := Finding{Check: "todos"}
+	todos, err := repo.GetAll()
+	if err != nil {
+		finding.Status, finding.Summary = Problem, fmt.Sprintf("failed to read todos: %v", err)
+		return finding
+	}
+
+	archived := 0
+	for _, todo := range todos {
+		if todo.Archived {
+			archived++
+		}
+	}
+	switch {
+	case len(todos) == 0:
+		finding.Status = Warning
+		finding.Summary = "there are no todos, is this the workspace and data dir you meant?"
+	case archived == len(todos):
+		finding.Status = Warning
+		finding.Summary = fmt.Sprintf("all %d todos are archived, they are only shown in the archive", archived)
+	default:
+		finding.Summary = fmt.Sprintf("%d todos, %d of them archived", len(todos), archived)
+	}
+	return finding
+}
+
+// CheckTimeTracking finds todos in Doing whose timer started in the future or so long ago
+// that it was forgotten
+func CheckTimeTracking(repo repository.TodoRepository, now time.Time) Finding {
+	finding := Finding{Check: "time tracking"}
+	doing, err := repo.GetAll(repository.StatusFilter(models.Doing))
+	if err != nil {
+		finding.Status, finding.Summary = Problem, fmt.Sprintf("failed to read todos: %v", err)
+		return finding
+	}
+
+	var stuck []*models.Todo
+	var details []string
+	for _, todo := range doing {
+		if todo.TimeStarted == nil {
+			continue
+		}
+		started := *todo.TimeStarted
+		switch {
+		case started.After(now.Add(clockSkew)):
+			details = append(details, fmt.Sprintf("todo %d started in the future, at %s", todo.ID, started.Local().Format(time.DateTime)))
+		case now.Sub(started) > MaxTrackingSession:
+			details = append(details, fmt.Sprintf("todo %d has been tracked since %s", todo.ID, started.Local().Format(time.DateTime)))
+		default:
+			continue
+		}
+		stuck = append(stuck, todo)
+	}
+	if len(stuck) == 0 {
+		finding.Summary = fmt.Sprintf("%d todos in progress, their timers look right", len(doing))
+		return finding
+	}
+
+	finding.Status = Warning
+	finding.Summary = fmt.Sprintf("%d todos have a timer that can't be right", len(stuck))
+	finding.Details = limitDetails(details)
+	finding.Fix = "pause their timers without counting the time since they started"
+	finding.Repair = func() error {
+		for _, todo := range stuck {
+			todo.TimeStarted = nil
+			if err := repo.Update(todo); err != nil {
+				return fmt.Errorf("todo %d: %w", todo.ID, err)
+			}
+		}
+		return nil
+	}
+	return finding
+}
+
+// ===========================================================================
+// Log
+// ===========================================================================
+
+// CheckLog looks for errors in the end of the log of the last run and returns that end for
+// a bug report
+func CheckLog(path string) (Finding, []string) {
+	finding := Finding{Check: "log"}
+	tail, err := tailFile(path, logTailLines)
+	if errors.Is(err, os.ErrNotExist) {
+		finding.Summary = "there is no log yet"
+		return finding, nil
+	}
+	if err != nil {
+		finding.Status, finding.Summary = Warning, fmt.Sprintf("failed to read the log: %v", err)
+		return finding, nil
+	}
+
+	var errorLines []string
+	warnings := 0
+	for _, line := range tail {
+		switch logLevel(line) {
+		case "ERRO", "FATA":
+			errorLines = append(errorLines, line)
+		case "WARN":
+			warnings++
+		}
+	}
+	if len(errorLines) == 0 {
+		finding.Summary = fmt.Sprintf("no errors in the last %d lines, %d warnings", len(tail), warnings)
+		return finding, tail
+	}
+
+	finding.Status = Warning
+	finding.Summary = fmt.Sprintf("%d errors in the last %d lines of %s", len(errorLines), len(tail), filepath.Base(path))
+	// The latest errors are the ones that matter
+	finding.Details = errorLines[max(0, len(errorLines)-5):]
+	return finding, tail
+}
+
+// logLevel returns the level of a line of the log, such as ERRO, or "" for lines that
+// continue a message
+func logLevel(line string) string {
+	for _, field := range strings.Fields(line) {
+		switch field {
+		case "DEBU", "INFO", "WARN", "ERRO", "FATA":
+			return field
+		}
+	}
+	return ""
+}
+
+// tailFile returns the last lines of a file, reading at most its last 256 KiB
+func tailFile(path string, lines int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	const maxRead = 256 << 10
+	offset := max(0, info.Size()-maxRead)
+	data := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(data, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	text := strings.TrimRight(string(data), "\n")
+	if text == "" {
+		return []string{}, nil
+	}
+	all := strings.Split(text, "\n")
+	if offset > 0 {
+		// The first line was cut off
+		all = all[1:]
+	}
+	return all[max(0, len(all)-lines):], nil
+}
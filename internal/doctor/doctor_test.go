@@ -0,0 +1,267 @@
+package doctor
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+)
+
+func openTestDB(t *testing.T) (*repository.SQLiteTodoRepository, *sql.DB) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "todo.sql")
+	repo, err := repository.OpenSQLiteTodoRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	db, err := repository.OpenSQLiteDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return repo, db
+}
+
+func repair(t *testing.T, finding Finding) {
+	t.Helper()
+	if !finding.Fixable() {
+		t.Fatalf("%s can't be repaired: %+v", finding.Check, finding)
+	}
+	if err := finding.Repair(); err != nil {
+		t.Fatalf("repairing %s: %v", finding.Check, err)
+	}
+}
+
+func TestCheckDataDir(t *testing.T) {
+	dir := t.TempDir()
+	if finding := CheckDataDir(dir); finding.Status != OK {
+		t.Errorf("CheckDataDir() = %+v, want ok", finding)
+	}
+	if finding := CheckDataDir(filepath.Join(dir, "missing")); finding.Status != Warning || finding.Fixable() {
+		t.Errorf("CheckDataDir() of a missing dir = %+v, want a warning without a fix", finding)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if err := os.Chmod(dir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	finding := CheckDataDir(dir)
+	if finding.Status != Warning {
+		t.Fatalf("CheckDataDir() of a world-writable dir = %+v, want a warning", finding)
+	}
+	repair(t, finding)
+	if info, _ := os.Stat(dir); info.Mode().Perm() != 0o755 {
+		t.Errorf("mode after the repair = %s, want -rwxr-xr-x", info.Mode().Perm())
+	}
+}
+
+func TestCheckForeignKeys(t *testing.T) {
+	repo, db := openTestDB(t)
+	todo := &models.Todo{Title: "Tagged", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := repo.Create(todo); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddTagToTodo(todo.ID, "work"); err != nil {
+		t.Fatal(err)
+	}
+	if finding := CheckForeignKeys(db); finding.Status != OK {
+		t.Fatalf("CheckForeignKeys() = %+v, want ok", finding)
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		"PRAGMA foreign_keys = OFF",
+		"INSERT INTO todo_tags (todo_id, tag_id) VALUES (9999, 1)",
+		"PRAGMA foreign_keys = ON",
+	} {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close()
+
+	finding := CheckForeignKeys(db)
+	if finding.Status != Warning || len(finding.Details) != 1 {
+		t.Fatalf("CheckForeignKeys() = %+v, want the orphaned link", finding)
+	}
+	repair(t, finding)
+	if finding := CheckForeignKeys(db); finding.Status != OK {
+		t.Errorf("CheckForeignKeys() after the repair = %+v", finding)
+	}
+	if got, err := repo.GetByID(todo.ID); err != nil || len(got.Tags) != 1 {
+		t.Errorf("the repair touched the valid link: %+v, %v", got, err)
+	}
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	_, db := openTestDB(t)
+	if finding := CheckIntegrity(db); finding.Status != OK {
+		t.Errorf("CheckIntegrity() = %+v, want ok", finding)
+	}
+}
+
+func TestCheckMigrations(t *testing.T) {
+	_, db := openTestDB(t)
+	migrations := repository.GetAllMigrations()
+	manager := repository.NewMigrationManager(db)
+	if finding := CheckMigrations(manager, migrations); finding.Status != OK {
+		t.Fatalf("CheckMigrations() = %+v, want ok", finding)
+	}
+
+	if _, err := manager.RollbackTo(migrations, 5); err != nil {
+		t.Fatal(err)
+	}
+	finding := CheckMigrations(manager, migrations)
+	if finding.Status != Warning || len(finding.Details) != len(migrations)-5 {
+		t.Fatalf("CheckMigrations() = %+v, want the migrations after 5 pending", finding)
+	}
+	repair(t, finding)
+	if finding := CheckMigrations(manager, migrations); finding.Status != OK {
+		t.Errorf("CheckMigrations() after the repair = %+v", finding)
+	}
+
+	_, err := db.Exec("INSERT INTO schema_migrations (id, name, applied_at) VALUES (?, 'From the future', CURRENT_TIMESTAMP)",
+		repository.LatestMigration(migrations)+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if finding := CheckMigrations(manager, migrations); finding.Status != Problem || finding.Fixable() {
+		t.Errorf("CheckMigrations() of a newer schema = %+v, want a problem without a fix", finding)
+	}
+}
+
+func TestCheckSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets")
+	}
+	// Short, socket paths are limited to about 100 characters
+	dir, err := os.MkdirTemp("", "doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "todo.sock")
+
+	if finding := CheckSocket(path); finding.Status != OK {
+		t.Errorf("CheckSocket() without a socket = %+v, want ok", finding)
+	}
+
+	// A crashed instance doesn't remove its socket
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+
+	finding := CheckSocket(path)
+	if finding.Status != Warning {
+		t.Fatalf("CheckSocket() of a stale socket = %+v, want a warning", finding)
+	}
+	repair(t, finding)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the stale socket is still there: %v", err)
+	}
+}
+
+func TestCheckTodos(t *testing.T) {
+	repo := repository.NewInMemoryTodoRepository()
+	if finding := CheckTodos(repo); finding.Status != Warning {
+		t.Errorf("CheckTodos() without todos = %+v, want a warning", finding)
+	}
+	if err := repo.Create(&models.Todo{Title: "Archived", Archived: true}); err != nil {
+		t.Fatal(err)
+	}
+	if finding := CheckTodos(repo); finding.Status != Warning {
+		t.Errorf("CheckTodos() with only archived todos = %+v, want a warning", finding)
+	}
+	if err := repo.Create(&models.Todo{Title: "Open"}); err != nil {
+		t.Fatal(err)
+	}
+	if finding := CheckTodos(repo); finding.Status != OK {
+		t.Errorf("CheckTodos() = %+v, want ok", finding)
+	}
+}
+
+func TestCheckTimeTracking(t *testing.T) {
+	repo := repository.NewInMemoryTodoRepository()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	forgotten := now.Add(-MaxTrackingSession - time.Hour)
+	future := now.Add(time.Hour)
+	recent := now.Add(-2 * time.Hour)
+	todos := []*models.Todo{
+		{Title: "Forgotten", Status: models.Doing, TimeStarted: &forgotten, TimeSpent: 60},
+		{Title: "Wrong clock", Status: models.Doing, TimeStarted: &future},
+		{Title: "Working on it", Status: models.Doing, TimeStarted: &recent},
+		{Title: "Paused", Status: models.Doing},
+	}
+	for _, todo := range todos {
+		if err := repo.Create(todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	finding := CheckTimeTracking(repo, now)
+	if finding.Status != Warning || len(finding.Details) != 2 {
+		t.Fatalf("CheckTimeTracking() = %+v, want the forgotten and future timers", finding)
+	}
+	repair(t, finding)
+
+	for _, todo := range todos {
+		got, err := repo.GetByID(todo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		running := got.TimeStarted != nil
+		if wantRunning := todo.Title == "Working on it"; running != wantRunning {
+			t.Errorf("%s: timer running = %v, want %v", todo.Title, running, wantRunning)
+		}
+		if got.Status != models.Doing || got.TimeSpent != todo.TimeSpent {
+			t.Errorf("%s: status %v, time spent %d, the repair only stops the timer", todo.Title, got.Status, got.TimeSpent)
+		}
+	}
+	if finding := CheckTimeTracking(repo, now); finding.Status != OK {
+		t.Errorf("CheckTimeTracking() after the repair = %+v", finding)
+	}
+}
+
+func TestCheckLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "debug.log")
+	if finding, tail := CheckLog(path); finding.Status != OK || tail != nil {
+		t.Errorf("CheckLog() without a log = %+v, %v", finding, tail)
+	}
+
+	var log strings.Builder
+	for i := range 300 {
+		log.WriteString("May  1 12:00:00.000 INFO <main.go:10> Line\n")
+		if i == 250 {
+			log.WriteString("May  1 12:00:00.000 ERRO <service.go:20> Failed to update todo error=\"disk I/O error\"\n")
+		}
+	}
+	if err := os.WriteFile(path, []byte(log.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	finding, tail := CheckLog(path)
+	if finding.Status != Warning || len(finding.Details) != 1 || !strings.Contains(finding.Details[0], "disk I/O error") {
+		t.Errorf("CheckLog() = %+v, want the error", finding)
+	}
+	if len(tail) != logTailLines {
+		t.Errorf("CheckLog() returned %d lines, want the last %d", len(tail), logTailLines)
+	}
+}
@@ -0,0 +1,149 @@
+package encryption
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The titles and descriptions of an encrypted database are sealed with XChaCha20-Poly1305
+// under a key derived from the passphrase with argon2id. Every value gets a random nonce, so
+// equal titles don't look equal in the database. The name of the column is authenticated
+// with the value, so a title can't be passed off as a description.
+
+const (
+	// KeySize is the length of a derived key
+	KeySize = chacha20poly1305.KeySize
+	// SaltSize is the length of the random salt of a database
+	SaltSize = 16
+	// prefix marks a sealed value and the version of its format
+	prefix = "enc1:"
+	// verifierText is sealed in the header, a key that opens it is the right one
+	verifierText  = "todo"
+	verifierField = "verifier"
+)
+
+// ErrWrongPassphrase is returned when a passphrase or key doesn't open the database
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// KDFParams are the argon2id costs, they are stored with the database so they can be raised
+// for new databases without locking out the existing ones
+type KDFParams struct {
+	Time    uint32
+	Memory  uint32 // in KiB
+	Threads uint8
+}
+
+// DefaultKDFParams follow the second recommendation of RFC 9106, for machines that can't
+// spare 2 GiB
+var DefaultKDFParams = KDFParams{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// DeriveKey derives the key of a database from its passphrase
+func DeriveKey(passphrase string, salt []byte, params KDFParams) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, params.Time, params.Memory, params.Threads, KeySize)
+}
+
+// Cipher seals and opens the values of one database
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher for a derived key
+func NewCipher(key []byte) (*Cipher, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts the value of a field
+func (c *Cipher) Seal(field, plaintext string) string {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		// crypto/rand doesn't fail on the platforms Go supports
+		panic(err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(field))
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed)
+}
+
+// Open decrypts the value of a field. Values that aren't sealed are returned as they are,
+// so a database can be read while it is being encrypted.
+func (c *Cipher) Open(field, value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted %s", field)
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", field, err)
+	}
+	return string(plaintext), nil
+}
+
+// IsSealed reports whether a value was encrypted by Seal
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Header is what an encrypted database keeps to derive and check its key
+type Header struct {
+	Salt   []byte
+	Params KDFParams
+	// Verifier is a known text sealed with the key
+	Verifier string
+}
+
+// NewHeader creates the header of a database encrypted with passphrase and returns it with
+// the derived key
+func NewHeader(passphrase string, params KDFParams) (*Header, []byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	key := DeriveKey(passphrase, salt, params)
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Header{Salt: salt, Params: params, Verifier: c.Seal(verifierField, verifierText)}, key, nil
+}
+
+// Unlock derives the key from the passphrase and checks it
+func (h *Header) Unlock(passphrase string) (*Cipher, []byte, error) {
+	key := DeriveKey(passphrase, h.Salt, h.Params)
+	c, err := h.Check(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, key, nil
+}
+
+// Check returns the cipher for a key, e.g. one from the keyring, if it is the key of the
+// database
+func (h *Header) Check(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, ErrWrongPassphrase
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	text, err := c.Open(verifierField, h.Verifier)
+	if err != nil || !IsSealed(h.Verifier) || subtle.ConstantTimeCompare([]byte(text), []byte(verifierText)) != 1 {
+		return nil, ErrWrongPassphrase
+	}
+	return c, nil
+}
@@ -0,0 +1,100 @@
+package encryption
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testParams keep the tests fast, real databases use DefaultKDFParams
+var testParams = KDFParams{Time: 1, Memory: 64, Threads: 1}
+
+func testCipher(t *testing.T) *Cipher {
+	t.Helper()
+	c, err := NewCipher(DeriveKey("passphrase", make([]byte, SaltSize), testParams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSealAndOpen(t *testing.T) {
+	c := testCipher(t)
+	for _, text := range []string{"", "Buy a present", "Ünïcode ✓\nand lines"} {
+		sealed := c.Seal("todos.title", text)
+		if !IsSealed(sealed) || (text != "" && strings.Contains(sealed, text)) {
+			t.Errorf("Seal(%q) = %q", text, sealed)
+		}
+		opened, err := c.Open("todos.title", sealed)
+		if err != nil || opened != text {
+			t.Errorf("Open(Seal(%q)) = %q, %v", text, opened, err)
+		}
+	}
+}
+
+func TestSealUsesFreshNonces(t *testing.T) {
+	c := testCipher(t)
+	if c.Seal("todos.title", "same") == c.Seal("todos.title", "same") {
+		t.Error("sealing the same text twice gave the same value")
+	}
+}
+
+func TestOpenRejectsTamperedValues(t *testing.T) {
+	c := testCipher(t)
+	sealed := c.Seal("todos.title", "Buy a present")
+
+	if _, err := c.Open("todos.description", sealed); err == nil {
+		t.Error("a title opened as a description")
+	}
+	other, err := NewCipher(DeriveKey("other", make([]byte, SaltSize), testParams))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open("todos.title", sealed); err == nil {
+		t.Error("a value opened with the wrong key")
+	}
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if tampered != sealed {
+		if _, err := c.Open("todos.title", tampered); err == nil {
+			t.Error("a tampered value opened")
+		}
+	}
+	if _, err := c.Open("todos.title", prefix+"!!"); err == nil {
+		t.Error("a malformed value opened")
+	}
+}
+
+func TestOpenPassesPlainText(t *testing.T) {
+	if got, err := testCipher(t).Open("todos.title", "plain"); err != nil || got != "plain" {
+		t.Errorf("Open(plain) = %q, %v", got, err)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	header, key, err := NewHeader("correct horse", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(header.Salt) != SaltSize || len(key) != KeySize {
+		t.Fatalf("salt of %d bytes and key of %d bytes", len(header.Salt), len(key))
+	}
+
+	if _, got, err := header.Unlock("correct horse"); err != nil || string(got) != string(key) {
+		t.Errorf("Unlock() with the passphrase = %x, %v", got, err)
+	}
+	if _, _, err := header.Unlock("wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Unlock() with the wrong passphrase: %v", err)
+	}
+	if _, err := header.Check(key[:8]); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Check() with a short key: %v", err)
+	}
+
+	// The same passphrase gives another key for another database
+	other, otherKey, err := NewHeader("correct horse", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(other.Salt) == string(header.Salt) || string(otherKey) == string(key) {
+		t.Error("two databases share a salt or key")
+	}
+}
@@ -0,0 +1,121 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Keyring caches the derived keys of encrypted databases in a file, so the passphrase isn't
+// asked every time todo starts. Every line holds the salt of a database and its key, in hex.
+// The file is as sensitive as the passphrase, only the user may read it.
+type Keyring struct {
+	path string
+}
+
+// NewKeyring returns the keyring kept in the file at path, which is created when a key is
+// first put in it
+func NewKeyring(path string) *Keyring {
+	return &Keyring{path: path}
+}
+
+// Get returns the key of the database with the given salt
+func (k *Keyring) Get(salt []byte) ([]byte, bool, error) {
+	entries, err := k.read()
+	if err != nil {
+		return nil, false, err
+	}
+	key, ok := entries[hex.EncodeToString(salt)]
+	return key, ok, nil
+}
+
+// Put stores the key of the database with the given salt
+func (k *Keyring) Put(salt, key []byte) error {
+	entries, err := k.read()
+	if err != nil {
+		return err
+	}
+	entries[hex.EncodeToString(salt)] = key
+	return k.write(entries)
+}
+
+// Delete forgets the key of the database with the given salt, e.g. after it was decrypted
+func (k *Keyring) Delete(salt []byte) error {
+	entries, err := k.read()
+	if err != nil {
+		return err
+	}
+	id := hex.EncodeToString(salt)
+	if _, ok := entries[id]; !ok {
+		return nil
+	}
+	delete(entries, id)
+	return k.write(entries)
+}
+
+func (k *Keyring) read() (map[string][]byte, error) {
+	entries := make(map[string][]byte)
+	file, err := os.Open(k.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("keyring %s can be read by other users, chmod 600 it", k.path)
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		key, err := hex.DecodeString(fields[1])
+		if err != nil || len(key) != KeySize {
+			continue
+		}
+		entries[fields[0]] = key
+	}
+	return entries, scanner.Err()
+}
+
+// write replaces the file, so a crash can't leave half a keyring behind
+func (k *Keyring) write(entries map[string][]byte) error {
+	if err := os.MkdirAll(filepath.Dir(k.path), 0o700); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for id, key := range entries {
+		fmt.Fprintf(&buf, "%s %s\n", id, hex.EncodeToString(key))
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(k.path), ".keyring-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(buf.Bytes()); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	// CreateTemp already makes the file readable by the user only
+	return os.Rename(temp.Name(), k.path)
+}
@@ -0,0 +1,52 @@
+package encryption
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "keyring")
+	keyring := NewKeyring(path)
+	salt, key := bytes.Repeat([]byte{1}, SaltSize), bytes.Repeat([]byte{2}, KeySize)
+	otherSalt, otherKey := bytes.Repeat([]byte{3}, SaltSize), bytes.Repeat([]byte{4}, KeySize)
+
+	if _, ok, err := keyring.Get(salt); ok || err != nil {
+		t.Fatalf("Get() from a missing keyring = %v, %v", ok, err)
+	}
+	if err := keyring.Put(salt, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := keyring.Put(otherSalt, otherKey); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := keyring.Get(salt); !ok || err != nil || !bytes.Equal(got, key) {
+		t.Errorf("Get() = %x, %v, %v", got, ok, err)
+	}
+
+	if err := keyring.Delete(salt); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := keyring.Get(salt); ok {
+		t.Error("Get() found a deleted key")
+	}
+	if got, ok, _ := keyring.Get(otherSalt); !ok || !bytes.Equal(got, otherKey) {
+		t.Error("Delete() removed the key of another database")
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("keyring mode = %v, %v, want 0600", info.Mode(), err)
+	}
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := keyring.Get(otherSalt); err == nil {
+		t.Error("Get() read a keyring that others can read")
+	}
+}
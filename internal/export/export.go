@@ -0,0 +1,159 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/i18n"
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+type Format string
+
+const (
+	Markdown Format = "md"
+	CSV      Format = "csv"
+)
+
+const timeLayout = "2006-01-02 15:04"
+
+// ParseFormat converts a user supplied format name into a Format
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "md", "markdown":
+		return Markdown, nil
+	case "csv":
+		return CSV, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q (expected md or csv)", s)
+	}
+}
+
+// Extension returns the file extension used for the format
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// Write renders the todos in the given format
+func Write(w io.Writer, format Format, title string, todos []*models.Todo, translator *i18n.TranslationService) error {
+	switch format {
+	case Markdown:
+		return WriteMarkdown(w, title, todos, translator)
+	case CSV:
+		return WriteCSV(w, todos)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// WriteMarkdown writes the todos as a Markdown checklist grouped by status
+func WriteMarkdown(w io.Writer, title string, todos []*models.Todo, translator *i18n.TranslationService) error {
+	var sb strings.Builder
+
+	if title != "" {
+		fmt.Fprintf(&sb, "# %s\n\n", title)
+	}
+
+	for status := models.Open; status <= models.Blocked; status++ {
+		var group []*models.Todo
+		for _, todo := range todos {
+			if todo.Status == status {
+				group = append(group, todo)
+			}
+		}
+		if len(group) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "## %s (%d)\n\n", translator.T(status.String()), len(group))
+		for _, todo := range group {
+			check := " "
+			if todo.Status == models.Done {
+				check = "x"
+			}
+			fmt.Fprintf(&sb, "- [%s] %s", check, escapeMarkdown(todo.Title))
+
+			details := []string{translator.T(todo.Priority.String())}
+			if todo.DueDate != nil {
+				details = append(details, translator.Tf("ui.due", map[string]interface{}{"Time": todo.DueDate.Format(timeLayout)}))
+			}
+			if seconds := todo.GetTotalSeconds(); seconds > 0 {
+				details = append(details, translator.Tf("ui.time_spent", map[string]interface{}{"Time": todo.FormatTimeSpent()}))
+			}
+			if todo.Archived {
+				details = append(details, translator.T("filter.archived"))
+			}
+			fmt.Fprintf(&sb, " — %s", strings.Join(details, ", "))
+
+			for _, tag := range todo.Tags {
+				fmt.Fprintf(&sb, " `#%s`", tag)
+			}
+			sb.WriteString("\n")
+
+			if desc := strings.TrimSpace(todo.Description); desc != "" {
+				for _, line := range strings.Split(desc, "\n") {
+					fmt.Fprintf(&sb, "  > %s\n", strings.TrimRight(line, "\r"))
+				}
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteCSV writes the todos as CSV including every column, tags and time spent
+func WriteCSV(w io.Writer, todos []*models.Todo) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{
+		"id", "title", "description", "status", "priority", "tags", "due_date",
+		"created_at", "updated_at", "archived", "time_spent_seconds", "time_spent",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, todo := range todos {
+		dueDate := ""
+		if todo.DueDate != nil {
+			dueDate = todo.DueDate.Format(time.RFC3339)
+		}
+
+		record := []string{
+			strconv.FormatInt(todo.ID, 10),
+			todo.Title,
+			todo.Description,
+			strings.TrimPrefix(todo.Status.String(), "status."),
+			strings.TrimPrefix(todo.Priority.String(), "priority."),
+			strings.Join(todo.Tags, ";"),
+			dueDate,
+			todo.CreatedAt.Format(time.RFC3339),
+			todo.UpdatedAt.Format(time.RFC3339),
+			strconv.FormatBool(todo.Archived),
+			strconv.FormatInt(todo.GetTotalSeconds(), 10),
+			todo.FormatTimeSpent(),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// FileName builds a default export file name such as todos-open-20240101-150405.md
+func FileName(view string, format Format, now time.Time) string {
+	return fmt.Sprintf("todos-%s-%s.%s", view, now.Format("20060102-150405"), format.Extension())
+}
+
+func escapeMarkdown(s string) string {
+	replacer := strings.NewReplacer("\n", " ", "\r", "", "[", "\\[", "]", "\\]")
+	return replacer.Replace(s)
+}
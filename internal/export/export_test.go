@@ -0,0 +1,135 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/i18n"
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+func testTodos() []*models.Todo {
+	due := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	created := time.Date(2024, 4, 1, 8, 0, 0, 0, time.UTC)
+	return []*models.Todo{
+		{ID: 1, Title: "Write report", Description: "first line\nsecond line", Status: models.Open, Priority: models.High, Tags: []string{"work"}, DueDate: &due, CreatedAt: created, UpdatedAt: created},
+		{ID: 2, Title: "Ship release", Status: models.Done, Priority: models.Critical, TimeSpent: 3725, CreatedAt: created, UpdatedAt: created},
+		{ID: 3, Title: "Wait for review", Status: models.Blocked, Priority: models.Low, Tags: []string{"work", "review"}, CreatedAt: created, UpdatedAt: created},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Format
+		wantErr bool
+	}{
+		{"md", Markdown, false},
+		{"Markdown", Markdown, false},
+		{"csv", CSV, false},
+		{" CSV ", CSV, false},
+		{"pdf", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseFormat(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFormat(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFormat(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	translator, err := i18n.NewTranslationService("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, "Status meeting", testTodos(), translator); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	expected := []string{
+		"# Status meeting",
+		"## Open (1)",
+		"- [ ] Write report",
+		"`#work`",
+		"  > first line",
+		"  > second line",
+		"## Done (1)",
+		"- [x] Ship release",
+		"1h 2m 5s",
+		"## Blocked (1)",
+		"`#review`",
+	}
+	for _, want := range expected {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown output missing %q\n%s", want, out)
+		}
+	}
+
+	if strings.Contains(out, "## Doing") {
+		t.Errorf("empty status groups should be omitted\n%s", out)
+	}
+
+	// Groups follow the status order, not the input order
+	if strings.Index(out, "## Done") > strings.Index(out, "## Blocked") {
+		t.Errorf("expected Done group before Blocked group\n%s", out)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, testTodos()); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+
+	if len(records) != 4 {
+		t.Fatalf("expected header and 3 rows, got %d records", len(records))
+	}
+
+	header := records[0]
+	if header[0] != "id" || header[5] != "tags" || header[10] != "time_spent_seconds" {
+		t.Errorf("unexpected header: %v", header)
+	}
+
+	first := records[1]
+	if first[2] != "first line\nsecond line" {
+		t.Errorf("description should survive CSV quoting, got %q", first[2])
+	}
+	if first[3] != "open" || first[4] != "high" {
+		t.Errorf("expected untranslated status/priority, got %q/%q", first[3], first[4])
+	}
+	if first[6] != "2024-05-01T09:30:00Z" {
+		t.Errorf("unexpected due date %q", first[6])
+	}
+
+	if records[2][10] != "3725" || records[2][11] != "1h 2m 5s" {
+		t.Errorf("unexpected time spent columns: %v", records[2][10:])
+	}
+	if records[3][5] != "work;review" {
+		t.Errorf("unexpected tags column %q", records[3][5])
+	}
+}
+
+func TestFileName(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	if got := FileName("open", CSV, now); got != "todos-open-20240102-150405.csv" {
+		t.Errorf("FileName() = %q", got)
+	}
+}
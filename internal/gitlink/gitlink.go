@@ -0,0 +1,194 @@
+package gitlink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Todos are linked to git through their ID: branches are named todo-42-write-report and
+// commits refer to TODO-42 in a trailer. Only the local repository is involved, nothing is
+// fetched or pushed.
+
+const (
+	// BranchPrefix starts the name of a branch created for a todo
+	BranchPrefix = "todo-"
+	// maxSlugLength keeps branch names readable for todos with a long title
+	maxSlugLength = 40
+	// hookMarker identifies the hooks written by InstallHooks, which may be overwritten
+	hookMarker = "# Installed by todo git install-hooks"
+)
+
+var (
+	branchPattern    = regexp.MustCompile(`(?i)(?:^|/)todo-(\d+)(?:-|$)`)
+	referencePattern = regexp.MustCompile(`(?i)^\s*(close[sd]?|fix(?:e[sd])?|resolve[sd]?|refs?|references)\s*:?\s+(.+)$`)
+	todoIDPattern    = regexp.MustCompile(`(?i)\btodo-(\d+)\b`)
+)
+
+// ErrNotRepository is returned when the directory is not inside a git work tree
+var ErrNotRepository = errors.New("not a git repository")
+
+// Reference is a todo mentioned in a commit message
+type Reference struct {
+	ID int64
+	// Closes is set for Closes, Fixes and Resolves, Refs only notes the commit on the todo
+	Closes bool
+}
+
+// BranchName returns the branch for a todo, e.g. todo-42-write-annual-report
+func BranchName(id int64, title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return r >= unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+
+	// Long titles are cut between words
+	slug := strings.Join(words, "-")
+	if len(slug) > maxSlugLength {
+		slug = slug[:maxSlugLength]
+		if i := strings.LastIndexByte(slug, '-'); i > 0 {
+			slug = slug[:i]
+		}
+	}
+
+	name := BranchPrefix + strconv.FormatInt(id, 10)
+	if slug != "" {
+		name += "-" + slug
+	}
+	return name
+}
+
+// ParseBranch returns the ID of the todo a branch was created for. Prefixes such as
+// feature/todo-42-… are allowed.
+func ParseBranch(branch string) (int64, bool) {
+	match := branchPattern.FindStringSubmatch(branch)
+	if match == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(match[1], 10, 64)
+	return id, err == nil
+}
+
+// ParseReferences returns the todos a commit message closes or refers to, in lines like
+// "Closes TODO-42", "Refs: TODO-7, TODO-8". Comment lines as in the commit-msg file are
+// skipped, a todo that is both closed and referenced is closed.
+func ParseReferences(message string) []Reference {
+	var refs []Reference
+	index := make(map[int64]int)
+
+	for _, line := range strings.Split(message, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		match := referencePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		closes := !strings.HasPrefix(strings.ToLower(match[1]), "ref")
+
+		for _, id := range todoIDPattern.FindAllStringSubmatch(match[2], -1) {
+			todoID, err := strconv.ParseInt(id[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			if i, seen := index[todoID]; seen {
+				refs[i].Closes = refs[i].Closes || closes
+				continue
+			}
+			index[todoID] = len(refs)
+			refs = append(refs, Reference{ID: todoID, Closes: closes})
+		}
+	}
+	return refs
+}
+
+// Subject returns the first line of a commit message that is not a comment
+func Subject(message string) string {
+	for _, line := range strings.Split(message, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// CurrentBranch returns the branch checked out in the repository containing dir, empty for
+// a detached HEAD
+func CurrentBranch(dir string) (string, error) {
+	branch, err := run(dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		// symbolic-ref fails for a detached HEAD as well, that is not an error
+		if _, repoErr := run(dir, "rev-parse", "--git-dir"); repoErr != nil {
+			return "", ErrNotRepository
+		}
+		return "", nil
+	}
+	return branch, nil
+}
+
+// CreateBranch creates a branch from HEAD and checks it out
+func CreateBranch(dir, name string) error {
+	_, err := run(dir, "checkout", "-b", name)
+	return err
+}
+
+// InstallHooks writes the post-checkout and commit-msg hooks that call back into the todo
+// executable. Hooks written by someone else are left alone unless force is set. It returns
+// the paths of the installed hooks.
+func InstallHooks(dir, executable string, force bool) ([]string, error) {
+	hooksDir, err := run(dir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return nil, ErrNotRepository
+	}
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(dir, hooksDir)
+	}
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	hooks := map[string]string{
+		"post-checkout": fmt.Sprintf("%q git post-checkout \"$@\"", executable),
+		"commit-msg":    fmt.Sprintf("%q git commit-msg \"$1\"", executable),
+	}
+
+	var installed []string
+	for _, name := range []string{"post-checkout", "commit-msg"} {
+		path := filepath.Join(hooksDir, name)
+		if existing, err := os.ReadFile(path); err == nil && !force && !bytes.Contains(existing, []byte(hookMarker)) {
+			return installed, fmt.Errorf("%s already exists, use --force to replace it", path)
+		}
+
+		// A failing commit-msg hook aborts the commit, updating the todo is a side effect
+		// that must never block it
+		script := "#!/bin/sh\n" + hookMarker + "\n" + hooks[name] + " || true\n"
+		if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+			return installed, err
+		}
+		installed = append(installed, path)
+	}
+	return installed, nil
+}
+
+// run executes git in dir and returns its trimmed output
+func run(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,123 @@
+package gitlink
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBranchName(t *testing.T) {
+	tests := []struct {
+		id    int64
+		title string
+		want  string
+	}{
+		{42, "Write annual report", "todo-42-write-annual-report"},
+		{7, "  Fix: crash on   empty list!  ", "todo-7-fix-crash-on-empty-list"},
+		{3, "Überprüfung der API", "todo-3-berpr-fung-der-api"},
+		{9, "", "todo-9"},
+		{1, strings.Repeat("long title ", 10), "todo-1-long-title-long-title-long-title-long"},
+	}
+	for _, tt := range tests {
+		if got := BranchName(tt.id, tt.title); got != tt.want {
+			t.Errorf("BranchName(%d, %q) = %q, want %q", tt.id, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestParseBranch(t *testing.T) {
+	tests := []struct {
+		branch string
+		id     int64
+		ok     bool
+	}{
+		{"todo-42-write-report", 42, true},
+		{"todo-42", 42, true},
+		{"feature/TODO-7-login", 7, true},
+		{"main", 0, false},
+		{"mytodo-3", 0, false},
+		{"todo-12abc", 0, false},
+	}
+	for _, tt := range tests {
+		id, ok := ParseBranch(tt.branch)
+		if id != tt.id || ok != tt.ok {
+			t.Errorf("ParseBranch(%q) = %d, %v, want %d, %v", tt.branch, id, ok, tt.id, tt.ok)
+		}
+	}
+}
+
+func TestParseReferences(t *testing.T) {
+	message := `Add login form
+
+The form validates the email address.
+
+Refs TODO-3
+Closes: TODO-42, todo-43
+Fixes TODO-3
+# Refs TODO-99 is a comment
+Mentions TODO-5 without a keyword
+`
+	want := []Reference{{ID: 3, Closes: true}, {ID: 42, Closes: true}, {ID: 43, Closes: true}}
+	if got := ParseReferences(message); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseReferences() = %+v, want %+v", got, want)
+	}
+
+	if got := Subject("# comment\n\nAdd login form\n\nRefs TODO-3"); got != "Add login form" {
+		t.Errorf("Subject() = %q", got)
+	}
+}
+
+func TestRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	if _, err := CurrentBranch(dir); err != ErrNotRepository {
+		t.Fatalf("CurrentBranch() outside a repository = %v, want ErrNotRepository", err)
+	}
+
+	for _, args := range [][]string{
+		{"init", "--quiet", "--initial-branch", "main"},
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "--quiet", "--allow-empty", "-m", "Initial commit"},
+	} {
+		if _, err := run(dir, args...); err != nil {
+			t.Fatalf("git %v: %v", args, err)
+		}
+	}
+
+	if err := CreateBranch(dir, "todo-42-write-report"); err != nil {
+		t.Fatalf("CreateBranch() error: %v", err)
+	}
+	if branch, err := CurrentBranch(dir); err != nil || branch != "todo-42-write-report" {
+		t.Errorf("CurrentBranch() = %q, %v", branch, err)
+	}
+
+	t.Run("hooks", func(t *testing.T) {
+		installed, err := InstallHooks(dir, "/usr/local/bin/todo", false)
+		if err != nil || len(installed) != 2 {
+			t.Fatalf("InstallHooks() = %v, %v", installed, err)
+		}
+		script, err := os.ReadFile(filepath.Join(dir, ".git", "hooks", "commit-msg"))
+		if err != nil || !strings.Contains(string(script), `"/usr/local/bin/todo" git commit-msg "$1"`) {
+			t.Errorf("commit-msg hook = %q, %v", script, err)
+		}
+
+		// Reinstalling replaces our own hooks, but not someone else's
+		if _, err := InstallHooks(dir, "/usr/local/bin/todo", false); err != nil {
+			t.Errorf("reinstall error: %v", err)
+		}
+		if err := os.WriteFile(installed[0], []byte("#!/bin/sh\nmake lint\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := InstallHooks(dir, "/usr/local/bin/todo", false); err == nil {
+			t.Error("InstallHooks() replaced a foreign hook")
+		}
+		if _, err := InstallHooks(dir, "/usr/local/bin/todo", true); err != nil {
+			t.Errorf("InstallHooks(force) error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Event is a moment in the life of a todo that hooks can act on
+type Event string
+
+const (
+	Create       Event = "create"
+	StatusChange Event = "status-change"
+	Done         Event = "done"
+	Archive      Event = "archive"
+	Delete       Event = "delete"
+	TagAdded     Event = "tag-added"
+)
+
+// Hooks are executables in the hooks directory named after the event. A pre-hook runs before
+// the change and rejects it by exiting non-zero, an on-hook runs in the background after it.
+const (
+	PrePrefix = "pre-"
+	OnPrefix  = "on-"
+)
+
+// maxOutput bounds the output of a hook that is kept for the log
+const maxOutput = 4096
+
+// VetoError is returned when a pre-hook rejected a change
+type VetoError struct {
+	Hook   string
+	Output string
+}
+
+func (e *VetoError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("hook %s rejected the change", e.Hook)
+	}
+	return fmt.Sprintf("hook %s rejected the change: %s", e.Hook, e.Output)
+}
+
+// Runner runs the hooks in a directory, a directory that doesn't exist has no hooks
+type Runner struct {
+	dir     string
+	timeout time.Duration
+	wg      sync.WaitGroup
+}
+
+// NewRunner creates a runner for the hooks in dir that kills hooks running longer than timeout
+func NewRunner(dir string, timeout time.Duration) *Runner {
+	return &Runner{dir: dir, timeout: timeout}
+}
+
+// Dir returns the directory the hooks are read from
+func (r *Runner) Dir() string {
+	return r.dir
+}
+
+// Check runs the pre-hook of the event and returns a *VetoError when it exits non-zero or
+// doesn't finish in time. The payload is written to its stdin as JSON.
+func (r *Runner) Check(event Event, payload any) error {
+	name := PrePrefix + string(event)
+	path, ok := r.find(name)
+	if !ok {
+		return nil
+	}
+
+	output, err := r.run(path, event, payload)
+	if err != nil {
+		log.Warn("Hook rejected change", "hook", name, "error", err, "output", output)
+		return &VetoError{Hook: name, Output: output}
+	}
+	return nil
+}
+
+// Run starts the on-hook of the event in the background, failures are logged
+func (r *Runner) Run(event Event, payload any) {
+	name := OnPrefix + string(event)
+	path, ok := r.find(name)
+	if !ok {
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if output, err := r.run(path, event, payload); err != nil {
+			log.Error("Hook failed", "hook", name, "error", err, "output", output)
+		}
+	}()
+}
+
+// Wait blocks until the hooks started by Run have finished
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+// find returns the path of an executable hook
+func (r *Runner) find(name string) (string, bool) {
+	path := filepath.Join(r.dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Warn("Failed to read hook", "hook", name, "error", err)
+		}
+		return "", false
+	}
+	if info.IsDir() {
+		return "", false
+	}
+	// Windows has no executable bit, any file named after the event is run
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0 {
+		log.Warn("Hook is not executable, skipping it", "hook", path)
+		return "", false
+	}
+	return path, true
+}
+
+// run executes a hook with the payload on stdin and returns its combined output
+func (r *Runner) run(path string, event Event, payload any) (string, error) {
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(), "TODO_EVENT="+string(event))
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Don't wait for grandchildren holding on to the output after the hook was killed
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", r.timeout)
+	}
+
+	out := strings.TrimSpace(output.String())
+	if len(out) > maxOutput {
+		out = out[:maxOutput]
+	}
+	return out, err
+}
@@ -0,0 +1,86 @@
+package hooks
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeHook(t *testing.T, dir, name, script string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks in tests are shell scripts")
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+}
+
+func TestRunPassesPayloadOnStdin(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	writeHook(t, dir, "on-done", `echo "$TODO_EVENT $(cat)" > `+out+"\n")
+
+	runner := NewRunner(dir, time.Second)
+	runner.Run(Done, map[string]string{"title": "Write report"})
+	runner.Wait()
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook didn't run: %v", err)
+	}
+	if want := `done {"title":"Write report"}`; strings.TrimSpace(string(got)) != want {
+		t.Errorf("hook got %q, want %q", got, want)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	writeHook(t, dir, "pre-create", "exit 0\n")
+	writeHook(t, dir, "pre-delete", "echo 'todos are never deleted'\nexit 1\n")
+	writeHook(t, dir, "pre-archive", "sleep 5\n")
+	// Not executable, so not a hook
+	if err := os.WriteFile(filepath.Join(dir, "pre-done"), []byte("#!/bin/sh\nexit 1\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	runner := NewRunner(dir, 200*time.Millisecond)
+
+	tests := []struct {
+		event  Event
+		vetoed bool
+		output string
+	}{
+		{Create, false, ""},
+		{Delete, true, "todos are never deleted"},
+		{Archive, true, ""},
+		{Done, false, ""},
+		{TagAdded, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.event), func(t *testing.T) {
+			err := runner.Check(tt.event, nil)
+			var veto *VetoError
+			if vetoed := errors.As(err, &veto); vetoed != tt.vetoed {
+				t.Fatalf("Check() = %v, want vetoed %v", err, tt.vetoed)
+			}
+			if tt.vetoed && veto.Output != tt.output {
+				t.Errorf("output = %q, want %q", veto.Output, tt.output)
+			}
+		})
+	}
+}
+
+func TestMissingDirHasNoHooks(t *testing.T) {
+	runner := NewRunner(filepath.Join(t.TempDir(), "missing"), time.Second)
+	if err := runner.Check(Delete, nil); err != nil {
+		t.Errorf("Check() = %v, want nil", err)
+	}
+	runner.Run(Delete, nil)
+	runner.Wait()
+}
@@ -0,0 +1,109 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/martijnspitter/tui-todo/internal/service"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+const (
+	// KeepAliveInterval keeps idle event streams open through proxies
+	KeepAliveInterval = 15 * time.Second
+	// eventBuffer holds the changes of a subscriber that isn't reading, later changes are dropped
+	eventBuffer = 64
+)
+
+// changeEvent is the data of a change event, the same fields tools subscribed over the
+// socket receive
+type changeEvent struct {
+	Type   socket_sync.NotificationType `json:"type"`
+	Entity socket_sync.Entity           `json:"entity"`
+	ID     int64                        `json:"id"`
+	UID    string                       `json:"uid,omitempty"`
+	Seq    int64                        `json:"seq,omitempty"`
+}
+
+func newChangeEvent(change service.Change) changeEvent {
+	entity := socket_sync.EntityTodo
+	if change.IsTag() {
+		entity = socket_sync.EntityTag
+	}
+	return changeEvent{Type: change.Type, Entity: entity, ID: change.ID, UID: change.UID(), Seq: change.Seq()}
+}
+
+// publish passes a change to every open event stream
+func (s *Server) publish(change service.Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for events := range s.subscribers {
+		select {
+		case events <- change:
+		default:
+			log.Warn("Event stream is not keeping up, dropping change", "id", change.ID)
+		}
+	}
+}
+
+func (s *Server) subscribe() chan service.Change {
+	events := make(chan service.Change, eventBuffer)
+	s.mu.Lock()
+	s.subscribers[events] = struct{}{}
+	s.mu.Unlock()
+	return events
+}
+
+func (s *Server) unsubscribe(events chan service.Change) {
+	s.mu.Lock()
+	delete(s.subscribers, events)
+	s.mu.Unlock()
+}
+
+// handleEvents streams every change as a server-sent event named "change". The event ID is
+// the sequence number of the change when the primary logged it.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "error.unknown")
+		return
+	}
+
+	events := s.subscribe()
+	defer s.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(KeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case change := <-events:
+			data, err := json.Marshal(newChangeEvent(change))
+			if err != nil {
+				continue
+			}
+			if seq := change.Seq(); seq > 0 {
+				fmt.Fprintf(w, "id: %d\n", seq)
+			}
+			fmt.Fprintf(w, "event: change\ndata: %s\n\n", data)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,114 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+)
+
+// parseFilters maps the query parameters of /api/todos onto the repository filters:
+//
+//	archived=true|false|any  archived todos, default false
+//	status=doing             todos with the status
+//	min_priority=high        todos of at least the priority
+//	due_before=2025-01-31    todos due on or before the date (or an RFC 3339 time)
+//	q=report                 todos with the text in the title or description
+//	tag=work                 todos with the tag, may be repeated
+//	overdue, due_today, completed_today, high_priority=true
+//	coming_up=7              unfinished todos due within the next days
+//
+// Errors are translation keys, like the errors of the service.
+func parseFilters(query url.Values, highPriority models.Priority) ([]repository.Filter, error) {
+	var filters []repository.Filter
+
+	switch query.Get("archived") {
+	case "", "false":
+		filters = append(filters, repository.NotArchivedFilter())
+	case "true":
+		filters = append(filters, repository.ArchivedFilter())
+	case "any":
+	default:
+		return nil, fmt.Errorf("error.invalid_filter")
+	}
+
+	if value := query.Get("status"); value != "" {
+		status, err := models.ParseStatus(value)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, repository.StatusFilter(status))
+	}
+
+	if value := query.Get("min_priority"); value != "" {
+		priority, err := models.ParsePriority(value)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, repository.PriorityFilter(priority))
+	}
+
+	if value := query.Get("due_before"); value != "" {
+		before, err := parseDate(value)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, repository.DueDateFilter(before))
+	}
+
+	if value := query.Get("q"); value != "" {
+		filters = append(filters, repository.SearchFilter(value))
+	}
+
+	for _, tag := range query["tag"] {
+		filters = append(filters, repository.TagFilter(tag))
+	}
+
+	flags := []struct {
+		name   string
+		filter func() repository.Filter
+	}{
+		{"overdue", repository.OverDueFilter},
+		{"due_today", repository.DueTodayFilter},
+		{"completed_today", repository.CompletedTodayFilter},
+		{"high_priority", func() repository.Filter { return repository.PrioAboveHighFilter(highPriority) }},
+	}
+	for _, flag := range flags {
+		value := query.Get(flag.name)
+		if value == "" {
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("error.invalid_filter")
+		}
+		if enabled {
+			filters = append(filters, flag.filter())
+		}
+	}
+
+	if value := query.Get("coming_up"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 {
+			return nil, fmt.Errorf("error.invalid_filter")
+		}
+		filters = append(filters, repository.ComingUpFilter(days))
+	}
+
+	return filters, nil
+}
+
+// parseDate reads a date as the end of that day in local time, or an exact RFC 3339 time
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error.due_date_invalid")
+	}
+	return day.Add(24*time.Hour - time.Nanosecond), nil
+}
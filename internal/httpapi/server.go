@@ -0,0 +1,291 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/service"
+)
+
+// ShutdownTimeout bounds how long Stop waits for running requests
+const ShutdownTimeout = 5 * time.Second
+
+// Server serves todos, tags and the Today dashboard as JSON, and streams changes as
+// server-sent events. It runs on the AppService of the process, next to the TUI or alone.
+type Server struct {
+	service    *service.AppService
+	token      string
+	httpServer *http.Server
+	listener   net.Listener
+
+	// closing ends the event streams, which would otherwise keep Stop waiting
+	closing     chan struct{}
+	closeOnce   sync.Once
+	mu          sync.Mutex
+	subscribers map[chan service.Change]struct{}
+}
+
+// New creates a server for addr. Requests need the token as bearer token unless it is empty.
+func New(appService *service.AppService, addr, token string) *Server {
+	s := &Server{
+		service:     appService,
+		token:       token,
+		closing:     make(chan struct{}),
+		subscribers: make(map[chan service.Change]struct{}),
+	}
+	s.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	appService.RegisterNotificationCallback(s.publish)
+
+	return s
+}
+
+// Handler returns the routes of the API behind the token check
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/todos", s.handleTodos)
+	mux.HandleFunc("GET /api/todos/{id}", s.handleTodo)
+	mux.HandleFunc("GET /api/tags", s.handleTags)
+	mux.HandleFunc("GET /api/today", s.handleToday)
+	mux.HandleFunc("GET /api/stats", s.handleStats)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+
+	return s.authenticate(mux)
+}
+
+// Start listens on the address and serves requests in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("HTTP API stopped", "error", err)
+		}
+	}()
+
+	log.Info("HTTP API listening", "address", listener.Addr().String(), "auth", s.token != "")
+	return nil
+}
+
+// Addr returns the address the server listens on, useful when started on port 0
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop ends the event streams and waits for running requests
+func (s *Server) Stop() error {
+	s.closeOnce.Do(func() { close(s.closing) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+// ===========================================================================
+// Handlers
+// ===========================================================================
+
+// apiTag is a tag as the API shows it
+type apiTag struct {
+	ID          int64     `json:"id"`
+	UID         string    `json:"uid"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type todaySections struct {
+	HighPriority []service.APITodo `json:"high_priority"`
+	Overdue      []service.APITodo `json:"overdue"`
+	DueToday     []service.APITodo `json:"due_today"`
+	InProgress   []service.APITodo `json:"in_progress"`
+	Blocked      []service.APITodo `json:"blocked"`
+	ComingUp     []service.APITodo `json:"coming_up"`
+}
+
+type todayStats struct {
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+	TimeSpent string `json:"time_spent"`
+}
+
+func (s *Server) handleTodos(w http.ResponseWriter, r *http.Request) {
+	filters, err := parseFilters(r.URL.Query(), s.service.GetConfig().HighPriority())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	todos, err := s.service.GetTodosMatching(filters...)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, r, apiTodos(todos))
+}
+
+func (s *Server) handleTodo(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "error.todo_not_found")
+		return
+	}
+
+	todo, err := s.service.GetTodo(id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, r, service.NewAPITodo(todo))
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := s.service.GetAllTags()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	result := make([]apiTag, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, apiTag{
+			ID:          tag.ID,
+			UID:         tag.UID,
+			Name:        tag.Name,
+			Description: tag.Description,
+			CreatedAt:   tag.CreatedAt,
+			UpdatedAt:   tag.UpdatedAt,
+		})
+	}
+
+	writeJSON(w, r, result)
+}
+
+func (s *Server) handleToday(w http.ResponseWriter, r *http.Request) {
+	highPrio, dueToday, inProgress, blocked, overDue, comingUp, err := s.service.GetTodosForToday()
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, r, todaySections{
+		HighPriority: apiTodos(highPrio),
+		Overdue:      apiTodos(overDue),
+		DueToday:     apiTodos(dueToday),
+		InProgress:   apiTodos(inProgress),
+		Blocked:      apiTodos(blocked),
+		ComingUp:     apiTodos(comingUp),
+	})
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	completed, total, timeSpent := s.service.GetTodayCompletionStats()
+	writeJSON(w, r, map[string]todayStats{
+		"today": {Completed: completed, Total: total, TimeSpent: timeSpent},
+	})
+}
+
+func apiTodos(todos []*models.Todo) []service.APITodo {
+	result := make([]service.APITodo, 0, len(todos))
+	for _, todo := range todos {
+		result = append(result, service.NewAPITodo(todo))
+	}
+	return result
+}
+
+// ===========================================================================
+// Responses
+// ===========================================================================
+
+// authenticate requires the token as bearer token. Browsers can't set headers on an
+// EventSource, so the token is also accepted as access_token query parameter.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !found {
+				token = r.URL.Query().Get("access_token")
+			}
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="todo"`)
+				writeError(w, http.StatusUnauthorized, "error.permission")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes v with an ETag of its content, answering 304 when the client has it
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "error.unknown")
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(append(body, '\n'))
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeError writes an error with its translation key as message
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func writeServiceError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if err.Error() == "error.todo_not_found" {
+		status = http.StatusNotFound
+	}
+	writeError(w, status, err.Error())
+}
@@ -0,0 +1,248 @@
+package httpapi_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/httpapi"
+	"github.com/martijnspitter/tui-todo/internal/models"
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/service"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+const testToken = "secret"
+
+func newTestServer(t *testing.T) (*service.AppService, *httptest.Server) {
+	t.Helper()
+
+	osoperations.SetDataDir(t.TempDir())
+	t.Cleanup(func() { osoperations.SetDataDir("") })
+
+	repo, err := repository.NewSQLiteTodoRepository("test")
+	if err != nil {
+		t.Fatalf("NewSQLiteTodoRepository() error: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	appService := service.NewAppService(repo)
+
+	api := httpapi.New(appService, "127.0.0.1:0", testToken)
+	server := httptest.NewServer(api.Handler())
+	t.Cleanup(func() {
+		api.Stop()
+		server.Close()
+	})
+	return appService, server
+}
+
+func get(t *testing.T, server *httptest.Server, path string, header http.Header) *http.Response {
+	t.Helper()
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error: %v", err)
+	}
+	request.Header = header.Clone()
+	if request.Header == nil {
+		request.Header = http.Header{}
+	}
+	if request.Header.Get("Authorization") == "" {
+		request.Header.Set("Authorization", "Bearer "+testToken)
+	}
+
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatalf("GET %s error: %v", path, err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+	return response
+}
+
+func decode[T any](t *testing.T, response *http.Response) T {
+	t.Helper()
+
+	var v T
+	if err := json.NewDecoder(response.Body).Decode(&v); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	return v
+}
+
+func titles(todos []service.APITodo) []string {
+	var titles []string
+	for _, todo := range todos {
+		titles = append(titles, todo.Title)
+	}
+	return titles
+}
+
+func TestTodos(t *testing.T) {
+	appService, server := newTestServer(t)
+
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	for _, todo := range []struct {
+		title    string
+		priority models.Priority
+		tags     []string
+		dueDate  *time.Time
+	}{
+		{"Write report", models.Major, []string{"work"}, nil},
+		{"Buy milk", models.Low, []string{"home"}, &tomorrow},
+		{"Old task", models.Medium, nil, nil},
+	} {
+		if err := appService.CreateTodo(todo.title, "", todo.priority, todo.tags, todo.dueDate, models.Open); err != nil {
+			t.Fatalf("CreateTodo() error: %v", err)
+		}
+	}
+	all, err := appService.GetTodosMatching()
+	if err != nil {
+		t.Fatalf("GetTodosMatching() error: %v", err)
+	}
+	for _, todo := range all {
+		if todo.Title == "Old task" {
+			if err := appService.ArchiveTodo(todo.ID); err != nil {
+				t.Fatalf("ArchiveTodo() error: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Write report", "Buy milk"}},
+		{"?archived=true", []string{"Old task"}},
+		{"?min_priority=high", []string{"Write report"}},
+		{"?tag=home", []string{"Buy milk"}},
+		{"?q=report", []string{"Write report"}},
+		{"?coming_up=3", []string{"Buy milk"}},
+	}
+	for _, tt := range tests {
+		t.Run("filter "+tt.query, func(t *testing.T) {
+			response := get(t, server, "/api/todos"+tt.query, nil)
+			if response.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", response.StatusCode)
+			}
+			got := titles(decode[[]service.APITodo](t, response))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("todos = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("invalid filters are rejected", func(t *testing.T) {
+		for _, query := range []string{"?status=later", "?coming_up=soon", "?due_before=tomorrow", "?overdue=maybe"} {
+			if response := get(t, server, "/api/todos"+query, nil); response.StatusCode != http.StatusBadRequest {
+				t.Errorf("%s: status = %d, want 400", query, response.StatusCode)
+			}
+		}
+	})
+
+	t.Run("single todo", func(t *testing.T) {
+		response := get(t, server, "/api/todos/1", nil)
+		if todo := decode[service.APITodo](t, response); todo.Title != "Write report" {
+			t.Errorf("todo = %+v", todo)
+		}
+		if response := get(t, server, "/api/todos/999", nil); response.StatusCode != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", response.StatusCode)
+		}
+	})
+
+	t.Run("today sections", func(t *testing.T) {
+		sections := decode[map[string][]service.APITodo](t, get(t, server, "/api/today", nil))
+		if got := titles(sections["high_priority"]); len(got) != 1 || got[0] != "Write report" {
+			t.Errorf("high_priority = %v", got)
+		}
+		if got := titles(sections["coming_up"]); len(got) != 1 || got[0] != "Buy milk" {
+			t.Errorf("coming_up = %v", got)
+		}
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	_, server := newTestServer(t)
+
+	header := http.Header{"Authorization": {"Bearer wrong"}}
+	if response := get(t, server, "/api/tags", header); response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", response.StatusCode)
+	}
+
+	response, err := server.Client().Get(server.URL + "/api/tags?access_token=" + testToken)
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("access_token: status = %d, want 200", response.StatusCode)
+	}
+}
+
+func TestETag(t *testing.T) {
+	appService, server := newTestServer(t)
+
+	response := get(t, server, "/api/todos", nil)
+	etag := response.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("response has no ETag")
+	}
+
+	if response := get(t, server, "/api/todos", http.Header{"If-None-Match": {etag}}); response.StatusCode != http.StatusNotModified {
+		t.Errorf("unchanged: status = %d, want 304", response.StatusCode)
+	}
+
+	if err := appService.CreateTodo("New", "", models.Low, nil, nil, models.Open); err != nil {
+		t.Fatalf("CreateTodo() error: %v", err)
+	}
+	response = get(t, server, "/api/todos", http.Header{"If-None-Match": {etag}})
+	if response.StatusCode != http.StatusOK || response.Header.Get("ETag") == etag {
+		t.Errorf("changed: status = %d, ETag %s, want 200 and a new ETag", response.StatusCode, response.Header.Get("ETag"))
+	}
+}
+
+func TestEvents(t *testing.T) {
+	appService, server := newTestServer(t)
+
+	response := get(t, server, "/api/events", nil)
+	if got := response.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	reader := bufio.NewReader(response.Body)
+
+	// The comment sent on connect means the stream is subscribed
+	if line, err := reader.ReadString('\n'); err != nil || !strings.HasPrefix(line, ":") {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+
+	change := socket_sync.NewNotification(socket_sync.TodoUpdated, socket_sync.EntityTodo, 7, nil, nil)
+	change.UID = "todo-7"
+	change.Seq = 12
+	appService.OnNotification(change)
+
+	var lines []string
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("ReadString() error: %v", err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if lines[0] != "id: 12" || lines[1] != "event: change" {
+		t.Errorf("event = %v", lines)
+	}
+	var event map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &event); err != nil {
+		t.Fatalf("data %q: %v", lines[2], err)
+	}
+	if event["type"] != string(socket_sync.TodoUpdated) || event["entity"] != "todo" || event["uid"] != "todo-7" || event["id"] != float64(7) {
+		t.Errorf("data = %v", event)
+	}
+}
@@ -3,6 +3,9 @@ package i18n
 import (
 	"embed"
 	"encoding/json"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/charmbracelet/log"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
@@ -19,7 +22,8 @@ type TranslationService struct {
 }
 
 func NewTranslationService(defaultLang string) (*TranslationService, error) {
-	bundle := i18n.NewBundle(language.MustParse(defaultLang))
+	// English is the source catalog, languages without a catalog fall back to it
+	bundle := i18n.NewBundle(language.English)
 	bundle.RegisterUnmarshalFunc("json", json.Unmarshal)
 
 	entries, err := translationFiles.ReadDir("translations")
@@ -81,3 +85,74 @@ func (t *TranslationService) T(id string) string {
 func (t *TranslationService) Tf(id string, data map[string]interface{}) string {
 	return t.Translate(id, data)
 }
+
+// Tn translates a message with plural forms, count picks the form and is available as {{.Count}}
+func (t *TranslationService) Tn(id string, count int, data map[string]interface{}) string {
+	templateData := map[string]interface{}{"Count": count}
+	for k, v := range data {
+		templateData[k] = v
+	}
+
+	message, err := t.localizer.Localize(&i18n.LocalizeConfig{
+		MessageID:    id,
+		TemplateData: templateData,
+		PluralCount:  count,
+	})
+
+	if err != nil {
+		log.Error("Translate error", err)
+		return id
+	}
+
+	return message
+}
+
+// Languages returns the codes of all languages with a catalog, sorted
+func (t *TranslationService) Languages() []string {
+	tags := t.bundle.LanguageTags()
+	codes := make([]string, len(tags))
+	for i, tag := range tags {
+		codes[i] = tag.String()
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// LanguageName returns the name of a language in that language, e.g. "Deutsch" for de
+func (t *TranslationService) LanguageName(lang string) string {
+	localizer := i18n.NewLocalizer(t.bundle, lang)
+	name, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: "language.name"})
+	if err != nil {
+		return lang
+	}
+	return name
+}
+
+// DetectLanguage returns the language from the POSIX locale variables in order of
+// precedence, e.g. "nl" for LANG=nl_NL.UTF-8, and English when none is set
+func DetectLanguage() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if lang := parseLocale(os.Getenv(env)); lang != "" {
+			return lang
+		}
+	}
+	return "en"
+}
+
+// parseLocale turns a locale like de_DE.UTF-8@euro into a language tag
+func parseLocale(locale string) string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	// C and POSIX are the untranslated default locale
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return ""
+	}
+
+	tag, err := language.Parse(strings.ReplaceAll(locale, "_", "-"))
+	if err != nil {
+		return ""
+	}
+	base, _ := tag.Base()
+	return base.String()
+}
@@ -0,0 +1,159 @@
+package i18n
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func readCatalog(t *testing.T, name string) map[string]any {
+	t.Helper()
+	data, err := translationFiles.ReadFile("translations/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var catalog map[string]any
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		t.Fatalf("%s is not valid JSON: %v", name, err)
+	}
+	return catalog
+}
+
+// TestCatalogsAreComplete fails when a catalog lacks keys that en.json has
+func TestCatalogsAreComplete(t *testing.T) {
+	source := readCatalog(t, "en.json")
+
+	entries, err := translationFiles.ReadDir("translations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if entry.Name() == "en.json" {
+			continue
+		}
+
+		t.Run(entry.Name(), func(t *testing.T) {
+			catalog := readCatalog(t, entry.Name())
+
+			for id, message := range source {
+				translated, ok := catalog[id]
+				if !ok {
+					t.Errorf("missing key %q", id)
+					continue
+				}
+
+				// Plural messages need at least the "other" form in every language
+				if _, plural := message.(map[string]any); plural {
+					forms, ok := translated.(map[string]any)
+					if !ok || forms["other"] == nil {
+						t.Errorf("key %q must have plural forms with at least \"other\"", id)
+					}
+				}
+			}
+
+			for id := range catalog {
+				if _, ok := source[id]; !ok {
+					t.Errorf("key %q does not exist in en.json", id)
+				}
+			}
+		})
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	ts, err := NewTranslationService("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	languages := ts.Languages()
+	for _, lang := range []string{"de", "en", "nl"} {
+		if !slices.Contains(languages, lang) {
+			t.Errorf("expected a %s catalog, got %v", lang, languages)
+		}
+	}
+
+	if name := ts.LanguageName("de"); name != "Deutsch" {
+		t.Errorf("LanguageName(de) = %q", name)
+	}
+}
+
+func TestSetLanguage(t *testing.T) {
+	ts, err := NewTranslationService("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ts.T("status.done"); got != "Done" {
+		t.Errorf("en: got %q", got)
+	}
+
+	ts.SetLanguage("nl")
+	if got := ts.T("status.done"); got != "Klaar" {
+		t.Errorf("nl: got %q", got)
+	}
+	if ts.GetCurrentLanguage() != "nl" {
+		t.Errorf("current language = %q", ts.GetCurrentLanguage())
+	}
+
+	// Languages without a catalog fall back to English
+	ts.SetLanguage("fr")
+	if got := ts.T("status.done"); got != "Done" {
+		t.Errorf("fr: got %q", got)
+	}
+}
+
+func TestPlural(t *testing.T) {
+	ts, err := NewTranslationService("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		lang  string
+		count int
+		want  string
+	}{
+		{"en", 1, "Exported 1 todo to out.md"},
+		{"en", 3, "Exported 3 todos to out.md"},
+		{"en", 0, "Exported 0 todos to out.md"},
+		{"nl", 1, "1 todo geëxporteerd naar out.md"},
+		{"nl", 2, "2 todo's geëxporteerd naar out.md"},
+		{"de", 5, "5 Todos nach out.md exportiert"},
+	}
+
+	for _, tt := range tests {
+		ts.SetLanguage(tt.lang)
+		if got := ts.Tn("toast.exported", tt.count, map[string]interface{}{"Path": "out.md"}); got != tt.want {
+			t.Errorf("%s/%d: got %q, want %q", tt.lang, tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name        string
+		lcAll, lang string
+		want        string
+	}{
+		{"LANG", "", "nl_NL.UTF-8", "nl"},
+		{"LC_ALL wins", "de_DE.UTF-8", "nl_NL.UTF-8", "de"},
+		{"modifier", "", "de_DE@euro", "de"},
+		{"C locale", "C", "", "en"},
+		{"unset", "", "", "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LC_ALL", tt.lcAll)
+			t.Setenv("LC_MESSAGES", "")
+			t.Setenv("LANG", tt.lang)
+
+			if got := DetectLanguage(); got != tt.want {
+				t.Errorf("DetectLanguage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
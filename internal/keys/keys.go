@@ -27,6 +27,11 @@ type KeyMap struct {
 	About          key.Binding
 	PageDown       key.Binding
 	PageUp         key.Binding
+	Export         key.Binding
+	ExportCSV      key.Binding
+	Theme          key.Binding
+	Language       key.Binding
+	Workspace      key.Binding
 }
 
 func DefaultKeyMap() KeyMap {
@@ -80,11 +85,11 @@ func DefaultKeyMap() KeyMap {
 			key.WithHelp("?", "help.toggle"),
 		),
 		Up: key.NewBinding(
-			key.WithKeys("k", "↑"),
+			key.WithKeys("up", "k"),
 			key.WithHelp("↑/k", "help.up"),
 		),
 		Down: key.NewBinding(
-			key.WithKeys("j", "↓"),
+			key.WithKeys("down", "j"),
 			key.WithHelp("↓/j", "help.down"),
 		),
 		PageDown: key.NewBinding(
@@ -127,5 +132,60 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("ctrl+t"),
 			key.WithHelp("ctrl+t", "help.ctrl_t"),
 		),
+		Export: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "help.export"),
+		),
+		ExportCSV: key.NewBinding(
+			key.WithKeys("E"),
+			key.WithHelp("E", "help.export_csv"),
+		),
+		Theme: key.NewBinding(
+			key.WithKeys("T"),
+			key.WithHelp("T", "help.theme"),
+		),
+		Language: key.NewBinding(
+			key.WithKeys("L"),
+			key.WithHelp("L", "help.language"),
+		),
+		Workspace: key.NewBinding(
+			key.WithKeys("w"),
+			key.WithHelp("w", "help.workspace"),
+		),
+	}
+}
+
+// Bindings returns every binding by the name used in the config file
+func (k *KeyMap) Bindings() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"next":            &k.Next,
+		"prev":            &k.Prev,
+		"switch_pane":     &k.SwitchPane,
+		"select":          &k.Select,
+		"quit":            &k.Quit,
+		"new":             &k.New,
+		"edit":            &k.Edit,
+		"delete":          &k.Delete,
+		"advance_status":  &k.AdvanceStatus,
+		"archive":         &k.Archive,
+		"block_todo":      &k.BlockTodo,
+		"toggle_archived": &k.ToggleArchived,
+		"help":            &k.Help,
+		"filter":          &k.Filter,
+		"up":              &k.Up,
+		"down":            &k.Down,
+		"cancel":          &k.Cancel,
+		"home":            &k.Home,
+		"end":             &k.End,
+		"tag_filter":      &k.TagFilter,
+		"save":            &k.Save,
+		"about":           &k.About,
+		"page_down":       &k.PageDown,
+		"page_up":         &k.PageUp,
+		"export":          &k.Export,
+		"export_csv":      &k.ExportCSV,
+		"theme":           &k.Theme,
+		"language":        &k.Language,
+		"workspace":       &k.Workspace,
 	}
 }
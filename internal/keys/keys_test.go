@@ -0,0 +1,130 @@
+package keys
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestDefaultKeyMapHasNoConflicts(t *testing.T) {
+	for _, preset := range Presets() {
+		if _, err := FromConfig(preset, nil); err != nil {
+			t.Errorf("preset %s: %v", preset, err)
+		}
+	}
+}
+
+func TestBindingsCoverKeyMap(t *testing.T) {
+	k := DefaultKeyMap()
+	for name, binding := range k.Bindings() {
+		if len(binding.Keys()) == 0 {
+			t.Errorf("binding %s has no keys", name)
+		}
+	}
+}
+
+func TestRemap(t *testing.T) {
+	k, err := FromConfig(DefaultPreset, map[string][]string{
+		"archive": {"A"},
+		"delete":  {"D", "ctrl+x"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(k.Archive.Keys(), ","); got != "A" {
+		t.Errorf("archive keys = %s", got)
+	}
+	if got := k.Delete.Help(); got.Key != "D/ctrl+x" || got.Desc != "help.ctrl_d" {
+		t.Errorf("help should follow the new keys and keep its description, got %+v", got)
+	}
+}
+
+func TestRemapErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		preset    string
+		overrides map[string][]string
+		want      string
+	}{
+		{"unknown preset", "emacs", nil, "unknown preset"},
+		{"unknown binding", DefaultPreset, map[string][]string{"teleport": {"T"}}, `unknown binding "teleport"`},
+		{"empty keys", DefaultPreset, map[string][]string{"new": {}}, "new: keys must not be empty"},
+		{"list conflict", DefaultPreset, map[string][]string{"archive": {"e"}}, `list: "e" is bound to archive and export`},
+		{"modal conflict", DefaultPreset, map[string][]string{"save": {"enter"}}, `modal: "enter" is bound to select and save`},
+		{"sequence prefix conflict", VimPreset, map[string][]string{"new": {"g"}}, `list: "g" is bound to new and home`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromConfig(tt.preset, tt.overrides)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestContextsAreIndependent(t *testing.T) {
+	// Save is only active in modals and advance status only in lists
+	if _, err := FromConfig(DefaultPreset, map[string][]string{"save": {"x"}, "advance_status": {"x"}}); err != nil {
+		t.Errorf("bindings in different contexts may share keys: %v", err)
+	}
+}
+
+func TestVimKeyMap(t *testing.T) {
+	k := VimKeyMap()
+	if k.Home.Help().Key != "gg/home" {
+		t.Errorf("unexpected home help %q", k.Home.Help().Key)
+	}
+	if k.Delete.Keys()[0] != "d d" || k.AdvanceStatus.Keys()[0] != "x" {
+		t.Errorf("unexpected vim bindings: %v %v", k.Delete.Keys(), k.AdvanceStatus.Keys())
+	}
+}
+
+func runes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func feed(s *Sequencer, presses ...string) []string {
+	var out []string
+	for _, p := range presses {
+		for _, msg := range s.Feed(runes(p)) {
+			out = append(out, msg.String())
+		}
+	}
+	return out
+}
+
+func TestSequencer(t *testing.T) {
+	tests := []struct {
+		name    string
+		presses []string
+		want    []string
+	}{
+		{"single key", []string{"j"}, []string{"j"}},
+		{"complete sequence", []string{"g", "g"}, []string{"g g"}},
+		{"pending sequence", []string{"d"}, nil},
+		{"broken sequence replays presses", []string{"d", "j"}, []string{"d", "j"}},
+		{"sequences in a row", []string{"d", "d", "g", "g"}, []string{"d d", "g g"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := feed(NewSequencer(VimKeyMap()), tt.presses...)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Feed(%v) = %v, want %v", tt.presses, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSequencerReset(t *testing.T) {
+	s := NewSequencer(VimKeyMap())
+	feed(s, "g")
+	s.Reset()
+	if got := feed(s, "j"); len(got) != 1 || got[0] != "j" {
+		t.Errorf("expected pending presses to be dropped, got %v", got)
+	}
+}
@@ -0,0 +1,192 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// Context is a part of the UI in which a set of bindings is active at the same time
+type Context string
+
+const (
+	ListContext   Context = "list"
+	ModalContext  Context = "modal"
+	FilterContext Context = "filter"
+)
+
+// contextBindings lists the bindings that are matched against the same key press
+var contextBindings = map[Context][]string{
+	ListContext: {
+		"switch_pane", "quit", "new", "edit", "delete", "advance_status", "archive", "block_todo",
+		"toggle_archived", "help", "filter", "up", "down", "home", "end", "tag_filter", "about",
+		"page_down", "page_up", "export", "export_csv", "workspace",
+	},
+	ModalContext:  {"next", "prev", "select", "save", "cancel", "quit", "theme", "language"},
+	FilterContext: {"select", "cancel", "quit"},
+}
+
+// sharedKeys are bindings that do the same thing in a context and may share keys
+var sharedKeys = [][2]string{
+	{"cancel", "quit"},
+}
+
+const (
+	DefaultPreset = "default"
+	VimPreset     = "vim"
+)
+
+// Presets returns the names of the built-in key maps
+func Presets() []string {
+	return []string{DefaultPreset, VimPreset}
+}
+
+// VimKeyMap returns the default key map with vim style list navigation and actions
+func VimKeyMap() KeyMap {
+	// j/k and G are already part of the default key map
+	k := DefaultKeyMap()
+	setKeys(&k.Home, []string{"g g", "home"})
+	setKeys(&k.Delete, []string{"d d", "ctrl+d"})
+	setKeys(&k.AdvanceStatus, []string{"x", "ctrl+s"})
+	return k
+}
+
+// FromConfig builds the key map of a preset with the user's overrides applied and validates it
+func FromConfig(preset string, overrides map[string][]string) (KeyMap, error) {
+	var k KeyMap
+	switch preset {
+	case "", DefaultPreset:
+		k = DefaultKeyMap()
+	case VimPreset:
+		k = VimKeyMap()
+	default:
+		return k, fmt.Errorf("unknown preset %q, expected one of %s", preset, strings.Join(Presets(), ", "))
+	}
+
+	if err := k.Remap(overrides); err != nil {
+		return k, err
+	}
+
+	return k, k.Validate()
+}
+
+// Remap replaces the keys of the named bindings, the help text follows the new keys
+func (k *KeyMap) Remap(overrides map[string][]string) error {
+	bindings := k.Bindings()
+
+	var errs []error
+	for _, name := range sortedNames(overrides) {
+		binding, ok := bindings[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown binding %q", name))
+			continue
+		}
+
+		keys := overrides[name]
+		if len(keys) == 0 || slices.Contains(keys, "") {
+			errs = append(errs, fmt.Errorf("%s: keys must not be empty", name))
+			continue
+		}
+		setKeys(binding, keys)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate reports keys that are bound to more than one action within a context. A key
+// that starts a sequence (the "g" of "g g") may not be bound on its own either.
+func (k *KeyMap) Validate() error {
+	bindings := k.Bindings()
+
+	var errs []error
+	for _, context := range []Context{ListContext, ModalContext, FilterContext} {
+		owners := map[string][]string{}
+		for _, name := range contextBindings[context] {
+			for _, pressed := range bindings[name].Keys() {
+				owners[pressed] = appendUnique(owners[pressed], name)
+				for _, prefix := range sequencePrefixes(pressed) {
+					owners[prefix] = appendUnique(owners[prefix], name)
+				}
+			}
+		}
+
+		for _, pressed := range sortedNames(owners) {
+			names := owners[pressed]
+			if len(names) > 1 && !isShared(names) {
+				errs = append(errs, fmt.Errorf("%s: %q is bound to %s", context, pressed, strings.Join(names, " and ")))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ===========================================================================
+// Helpers
+// ===========================================================================
+func setKeys(binding *key.Binding, keys []string) {
+	binding.SetKeys(keys...)
+	binding.SetHelp(HelpKey(keys), binding.Help().Desc)
+}
+
+// HelpKey formats keys for the help view, "g g" is shown as "gg"
+func HelpKey(keys []string) string {
+	labels := make([]string, len(keys))
+	for i, k := range keys {
+		switch {
+		case k == " ":
+			labels[i] = "space"
+		case IsSequence(k):
+			labels[i] = strings.Join(strings.Fields(k), "")
+		default:
+			labels[i] = k
+		}
+	}
+	return strings.Join(labels, "/")
+}
+
+// sequencePrefixes returns the key presses that lead up to the last key of a sequence
+func sequencePrefixes(k string) []string {
+	if !IsSequence(k) {
+		return nil
+	}
+
+	parts := strings.Fields(k)
+	prefixes := make([]string, 0, len(parts)-1)
+	for i := 1; i < len(parts); i++ {
+		prefixes = append(prefixes, strings.Join(parts[:i], " "))
+	}
+	return prefixes
+}
+
+func isShared(names []string) bool {
+	if len(names) != 2 {
+		return false
+	}
+	for _, pair := range sharedKeys {
+		if slices.Contains(names, pair[0]) && slices.Contains(names, pair[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(names []string, name string) []string {
+	if slices.Contains(names, name) {
+		return names
+	}
+	return append(names, name)
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
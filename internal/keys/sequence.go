@@ -0,0 +1,77 @@
+package keys
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// IsSequence reports whether a key is a sequence of presses, written as "g g"
+func IsSequence(k string) bool {
+	return len(strings.Fields(k)) > 1
+}
+
+// Sequencer combines the presses of a key sequence like "g g" into a single key message
+// so bindings can match sequences the same way they match single keys
+type Sequencer struct {
+	sequences map[string]bool
+	prefixes  map[string]bool
+	pending   []tea.KeyMsg
+}
+
+func NewSequencer(k KeyMap) *Sequencer {
+	s := &Sequencer{
+		sequences: map[string]bool{},
+		prefixes:  map[string]bool{},
+	}
+
+	for _, binding := range k.Bindings() {
+		for _, pressed := range binding.Keys() {
+			if !IsSequence(pressed) {
+				continue
+			}
+			s.sequences[pressed] = true
+			for _, prefix := range sequencePrefixes(pressed) {
+				s.prefixes[prefix] = true
+			}
+		}
+	}
+
+	return s
+}
+
+// Feed takes a key press and returns the key messages to handle: none while a sequence
+// is being typed, the whole sequence once it completes, or the buffered presses when
+// the sequence is broken off
+func (s *Sequencer) Feed(msg tea.KeyMsg) []tea.KeyMsg {
+	candidate := msg.String()
+	if len(s.pending) > 0 {
+		candidate = s.pendingKeys() + " " + candidate
+	}
+
+	switch {
+	case s.sequences[candidate]:
+		s.pending = nil
+		return []tea.KeyMsg{{Type: tea.KeyRunes, Runes: []rune(candidate)}}
+	case s.prefixes[candidate]:
+		s.pending = append(s.pending, msg)
+		return nil
+	}
+
+	pressed := append(s.pending, msg)
+	s.pending = nil
+	return pressed
+}
+
+// Reset drops a partially typed sequence
+func (s *Sequencer) Reset() {
+	s.pending = nil
+}
+
+func (s *Sequencer) pendingKeys() string {
+	keys := make([]string, len(s.pending))
+	for i, msg := range s.pending {
+		keys[i] = msg.String()
+	}
+	return strings.Join(keys, " ")
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// ChangeRecord is an entry of the change log, which orders the changes of all instances so
+// an instance that was disconnected can catch up
+type ChangeRecord struct {
+	Seq       int64
+	Key       string // Identifies the changed entity, older records for it are compacted away
+	Deleted   bool   // Records of deletes are dropped once they are old enough
+	Payload   []byte // The notification as it was sent to the other instances
+	CreatedAt time.Time
+}
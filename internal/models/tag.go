@@ -6,6 +6,7 @@ type Tag struct {
 	Name        string
 	Description string
 	ID          int64
+	UID         string // Identifies the tag across replicated databases
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
@@ -28,15 +30,15 @@ func (s Status) String() string {
 func (s Status) Color() lipgloss.Color {
 	switch s {
 	case Open:
-		return theme.OpenStatusColor
+		return theme.Current().OpenStatus
 	case Doing:
-		return theme.DoingStatusColor
+		return theme.Current().DoingStatus
 	case Done:
-		return theme.DoneStatusColor
+		return theme.Current().DoneStatus
 	case Blocked:
-		return theme.BlockedStatusColor
+		return theme.Current().BlockedStatus
 	default:
-		return theme.OpenStatusColor
+		return theme.Current().OpenStatus
 	}
 }
 
@@ -47,6 +49,16 @@ const (
 	Blocked
 )
 
+// ParseStatus converts a status name such as "doing" into a Status
+func ParseStatus(name string) (Status, error) {
+	for s := Open; s <= Blocked; s++ {
+		if strings.EqualFold(strings.TrimPrefix(s.String(), "status."), strings.TrimSpace(name)) {
+			return s, nil
+		}
+	}
+	return Open, fmt.Errorf("unknown status %q", name)
+}
+
 type Priority int
 
 func (p Priority) String() string {
@@ -69,17 +81,17 @@ func (p Priority) String() string {
 func (p Priority) Color() lipgloss.Color {
 	switch p {
 	case Low:
-		return theme.LowPriorityColor
+		return theme.Current().LowPriority
 	case Medium:
-		return theme.MediumPriorityColor
+		return theme.Current().MediumPriority
 	case High:
-		return theme.HighPriorityColor
+		return theme.Current().HighPriority
 	case Major:
-		return theme.MajorPriorityColor
+		return theme.Current().MajorPriority
 	case Critical:
-		return theme.CriticalPriorityColor
+		return theme.Current().CriticalPriority
 	default:
-		return theme.MediumPriorityColor
+		return theme.Current().MediumPriority
 	}
 }
 
@@ -91,8 +103,19 @@ const (
 	Critical
 )
 
+// ParsePriority converts a priority name such as "major" into a Priority
+func ParsePriority(name string) (Priority, error) {
+	for p := Low; p <= Critical; p++ {
+		if strings.EqualFold(strings.TrimPrefix(p.String(), "priority."), strings.TrimSpace(name)) {
+			return p, nil
+		}
+	}
+	return Medium, fmt.Errorf("unknown priority %q", name)
+}
+
 type Todo struct {
 	ID          int64
+	UID         string // Identifies the todo across replicated databases
 	Title       string
 	Description string
 	CreatedAt   time.Time
@@ -104,6 +127,7 @@ type Todo struct {
 	Archived    bool
 	TimeSpent   int64      // Total time spent in seconds
 	TimeStarted *time.Time // When the task was last set to Doing status
+	Revision    int64      // Incremented on every update to detect concurrent edits
 }
 
 // FormatTimeSpent returns a human-readable format of the time spent on this todo
@@ -34,9 +34,9 @@ func TestStatus_Color(t *testing.T) {
 		status   Status
 		expected lipgloss.Color
 	}{
-		{"Open status color", Open, theme.OpenStatusColor},
-		{"Doing status color", Doing, theme.DoingStatusColor},
-		{"Done status color", Done, theme.DoneStatusColor},
+		{"Open status color", Open, theme.Current().OpenStatus},
+		{"Doing status color", Doing, theme.Current().DoingStatus},
+		{"Done status color", Done, theme.Current().DoneStatus},
 	}
 
 	for _, tt := range tests {
@@ -77,12 +77,12 @@ func TestPriority_Color(t *testing.T) {
 		priority Priority
 		expected lipgloss.Color
 	}{
-		{"Low priority color", Low, theme.LowPriorityColor},
-		{"Medium priority color", Medium, theme.MediumPriorityColor},
-		{"High priority color", High, theme.HighPriorityColor},
-		{"Major priority color", Major, theme.MajorPriorityColor},
-		{"Critical priority color", Critical, theme.CriticalPriorityColor},
-		{"Invalid priority color", Priority(999), theme.MediumPriorityColor},
+		{"Low priority color", Low, theme.Current().LowPriority},
+		{"Medium priority color", Medium, theme.Current().MediumPriority},
+		{"High priority color", High, theme.Current().HighPriority},
+		{"Major priority color", Major, theme.Current().MajorPriority},
+		{"Critical priority color", Critical, theme.Current().CriticalPriority},
+		{"Invalid priority color", Priority(999), theme.Current().MediumPriority},
 	}
 
 	for _, tt := range tests {
@@ -94,6 +94,60 @@ func TestPriority_Color(t *testing.T) {
 	}
 }
 
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Status
+		wantErr bool
+	}{
+		{"open", Open, false},
+		{"Doing", Doing, false},
+		{" done ", Done, false},
+		{"blocked", Blocked, false},
+		{"status.open", Open, true},
+		{"finished", Open, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseStatus(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStatus(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseStatus(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Priority
+		wantErr bool
+	}{
+		{"low", Low, false},
+		{"MEDIUM", Medium, false},
+		{"high", High, false},
+		{"major", Major, false},
+		{"critical", Critical, false},
+		{"urgent", Medium, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParsePriority(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePriority(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePriority(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
 // Test completeness - verify we've covered all enum values
 func TestCompleteness(t *testing.T) {
 	// Check that we have tests for all Status values
@@ -119,7 +173,7 @@ func TestCompleteness(t *testing.T) {
 func TestDoneStatus_HasCorrectColor(t *testing.T) {
 	// This test will fail with your current implementation
 	// because Done is using DoingStatusColor instead of DoneStatusColor
-	if Done.Color() != theme.DoneStatusColor {
-		t.Errorf("Done status color = %q, want %q", Done.Color(), theme.DoneStatusColor)
+	if Done.Color() != theme.Current().DoneStatus {
+		t.Errorf("Done status color = %q, want %q", Done.Color(), theme.Current().DoneStatus)
 	}
 }
@@ -5,15 +5,40 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
-func GetFilePath(fileName, version string) string {
+const appName = "tui-todo"
+
+// dataDirOverride is set from the configuration file or the --data-dir flag
+var dataDirOverride string
+
+// SetDataDir overrides the OS-specific data directory, an empty dir restores the default
+func SetDataDir(dir string) {
+	dataDirOverride = dir
+}
+
+// GetDataDir returns the directory GetFilePath puts its files in, without creating it
+func GetDataDir(version string) string {
+	if dataDirOverride != "" {
+		return dataDirOverride
+	}
 	if version == "dev" {
-		return fmt.Sprintf("./%s", fileName)
+		return "."
 	}
+	return getAppDataDir()
+}
+
+func GetFilePath(fileName, version string) string {
+	appDir := dataDirOverride
+	if appDir == "" {
+		if version == "dev" {
+			return fmt.Sprintf("./%s", fileName)
+		}
 
-	// Get the app data directory based on OS conventions
-	appDir := getAppDataDir()
+		// Get the app data directory based on OS conventions
+		appDir = getAppDataDir()
+	}
 
 	// Create the directory if it doesn't exist
 	os.MkdirAll(appDir, 0755)
@@ -23,8 +48,6 @@ func GetFilePath(fileName, version string) string {
 
 // getAppDataDir returns the OS-specific directory for application data
 func getAppDataDir() string {
-	const appName = "tui-todo"
-
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		// Fallback to current directory if we can't get home directory
@@ -55,3 +78,28 @@ func getAppDataDir() string {
 		return filepath.Join(homeDir, ".local", "share", appName)
 	}
 }
+
+// GetConfigDir returns the directory holding the configuration file.
+// $XDG_CONFIG_HOME is honoured on every OS, otherwise the OS default config directory is used.
+func GetConfigDir() string {
+	if xdgConfigHome := os.Getenv("XDG_CONFIG_HOME"); xdgConfigHome != "" {
+		return filepath.Join(xdgConfigHome, appName)
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "."
+	}
+
+	return filepath.Join(configDir, appName)
+}
+
+// TTYName returns the terminal the app runs in, e.g. /dev/pts/3, or "" when it isn't
+// known on this platform
+func TTYName() string {
+	target, err := os.Readlink("/proc/self/fd/0")
+	if err != nil || !strings.HasPrefix(target, "/dev/") {
+		return ""
+	}
+	return target
+}
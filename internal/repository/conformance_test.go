@@ -0,0 +1,492 @@
+package repository
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+// The conformance tests run against every backend, they all have to store todos and
+// evaluate filters the same way
+
+var backends = map[string]func(t *testing.T) TodoRepository{
+	"sqlite": func(t *testing.T) TodoRepository {
+		db, err := OpenSQLiteTodoRepository(filepath.Join(t.TempDir(), "todo.sql"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	},
+	"encrypted": func(t *testing.T) TodoRepository {
+		return newEncryptedTestDB(t)
+	},
+	"memory": func(t *testing.T) TodoRepository {
+		return NewInMemoryTodoRepository()
+	},
+	"vault": func(t *testing.T) TodoRepository {
+		return newTestVault(t)
+	},
+}
+
+func forEachBackend(t *testing.T, test func(t *testing.T, repo TodoRepository)) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			test(t, open(t))
+		})
+	}
+}
+
+func TestConformanceCRUD(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo TodoRepository) {
+		due := time.Date(2026, 3, 14, 9, 0, 0, 0, time.Local)
+		todo := newTestTodo("Write annual report")
+		todo.Description = "numbers and plans"
+		todo.DueDate = &due
+		todo.Priority = models.High
+		if err := repo.Create(todo); err != nil {
+			t.Fatal(err)
+		}
+		if todo.ID == 0 || todo.UID == "" {
+			t.Fatalf("expected an ID and a UID, got %d %q", todo.ID, todo.UID)
+		}
+
+		got, err := repo.GetByID(todo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Title != todo.Title || got.Description != todo.Description || got.Priority != models.High ||
+			!got.DueDate.Equal(due) || got.UID != todo.UID || got.Revision != 0 || got.Tags == nil {
+			t.Errorf("todo didn't survive the round trip: %+v", got)
+		}
+
+		got.Status = models.Doing
+		got.DueDate = nil
+		if err := repo.Update(got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Revision != 1 {
+			t.Errorf("expected revision 1 after update, got %d", got.Revision)
+		}
+		updated, _ := repo.GetByID(todo.ID)
+		if updated.Status != models.Doing || updated.DueDate != nil || updated.Revision != 1 {
+			t.Errorf("update wasn't stored: %+v", updated)
+		}
+
+		if err := repo.Delete(todo.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.GetByID(todo.ID); err == nil {
+			t.Error("expected deleted todo to be gone")
+		}
+		if err := repo.Delete(todo.ID); err != nil {
+			t.Errorf("deleting a missing todo: %v", err)
+		}
+	})
+}
+
+func TestConformanceUpdateConflict(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo TodoRepository) {
+		todo := newTestTodo("Shared")
+		if err := repo.Create(todo); err != nil {
+			t.Fatal(err)
+		}
+
+		first, _ := repo.GetByID(todo.ID)
+		second, _ := repo.GetByID(todo.ID)
+		if err := repo.Update(first); err != nil {
+			t.Fatal(err)
+		}
+
+		err := repo.Update(second)
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) || conflict.Current != 1 || !errors.Is(err, ErrConflict) {
+			t.Fatalf("expected a conflict at revision 1, got %v", err)
+		}
+
+		if err := repo.Delete(todo.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Update(first); !errors.As(err, &conflict) || !conflict.Deleted {
+			t.Errorf("expected a conflict for a deleted todo, got %v", err)
+		}
+	})
+}
+
+func TestConformanceTagChangeIsAConflict(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo TodoRepository) {
+		todo := newTestTodo("Shared")
+		if err := repo.Create(todo); err != nil {
+			t.Fatal(err)
+		}
+
+		stale, _ := repo.GetByID(todo.ID)
+		if err := repo.AddTagToTodo(todo.ID, "work"); err != nil {
+			t.Fatal(err)
+		}
+		var conflict *ConflictError
+		if err := repo.Update(stale); !errors.As(err, &conflict) || conflict.Current != 1 {
+			t.Fatalf("update after a tag was added: %v, want a conflict at revision 1", err)
+		}
+
+		// Adding a tag the todo already has changes nothing
+		if err := repo.AddTagToTodo(todo.ID, "work"); err != nil {
+			t.Fatal(err)
+		}
+		stale, _ = repo.GetByID(todo.ID)
+		if stale.Revision != 1 {
+			t.Errorf("revision after adding the same tag again = %d, want 1", stale.Revision)
+		}
+
+		if err := repo.RemoveTagFromTodo(todo.ID, "work"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Update(stale); !errors.As(err, &conflict) || conflict.Current != 2 {
+			t.Fatalf("update after a tag was removed: %v, want a conflict at revision 2", err)
+		}
+	})
+}
+
+func TestConformanceTags(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo TodoRepository) {
+		todo := newTestTodo("Tagged")
+		if err := repo.Create(todo); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tag := range []string{"work", "home", "work"} {
+			if err := repo.AddTagToTodo(todo.ID, tag); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := repo.CreateTag(&models.Tag{Name: "home"}); err == nil {
+			t.Error("expected an error creating an existing tag")
+		}
+		tags, err := repo.GetAllTags()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tags) != 2 || tags[0].Name != "home" || tags[1].Name != "work" {
+			t.Fatalf("expected tags home and work, got %+v", tags)
+		}
+		got, _ := repo.GetByID(todo.ID)
+		if !sameTags(got.Tags, "home", "work") {
+			t.Errorf("expected tags home and work on the todo, got %v", got.Tags)
+		}
+
+		// Updating the todo keeps its tags
+		if err := repo.Update(got); err != nil {
+			t.Fatal(err)
+		}
+
+		work := tags[1]
+		work.Name = "office"
+		if err := repo.UpdateTag(work); err != nil {
+			t.Fatal(err)
+		}
+		got, _ = repo.GetByID(todo.ID)
+		if !sameTags(got.Tags, "home", "office") {
+			t.Errorf("expected the renamed tag on the todo, got %v", got.Tags)
+		}
+
+		if err := repo.DeleteTag(tags[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		got, _ = repo.GetByID(todo.ID)
+		if !sameTags(got.Tags, "office") {
+			t.Errorf("expected the deleted tag to be taken off the todo, got %v", got.Tags)
+		}
+
+		if err := repo.RemoveTagFromTodo(todo.ID, "office"); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.RemoveTagFromTodo(todo.ID, "unknown"); err == nil {
+			t.Error("expected an error removing an unknown tag")
+		}
+		got, _ = repo.GetByID(todo.ID)
+		if len(got.Tags) != 0 {
+			t.Errorf("expected no tags, got %v", got.Tags)
+		}
+	})
+}
+
+func TestConformanceOrder(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo TodoRepository) {
+		now := time.Now()
+		for i, title := range []string{"Oldest", "Middle", "Newest", "Newest twin"} {
+			todo := newTestTodo(title)
+			todo.CreatedAt = now.Add(time.Duration(min(i, 2)) * time.Minute)
+			if err := repo.Create(todo); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		todos, err := repo.GetAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, len(todos))
+		for i, todo := range todos {
+			got[i] = todo.Title
+		}
+		if want := []string{"Newest twin", "Newest", "Middle", "Oldest"}; !slices.Equal(got, want) {
+			t.Errorf("order = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestConformancePages(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo TodoRepository) {
+		pager, ok := repo.(Pager)
+		if !ok {
+			t.Fatal("backend doesn't load pages")
+		}
+
+		// Priorities and update times repeat, so the ID decides between some todos
+		now := time.Now()
+		for i := range 25 {
+			todo := newTestTodo("Todo")
+			todo.Priority = models.Priority(i % 3)
+			todo.UpdatedAt = now.Add(time.Duration(i/4) * time.Minute)
+			if i%5 == 0 {
+				todo.Status = models.Done
+			}
+			if err := repo.Create(todo); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		all, err := repo.GetAll(StatusFilter(models.Open))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := PageOf(all, nil, 0)
+
+		var got []*models.Todo
+		var after *Cursor
+		for {
+			page, more, err := pager.GetPage(after, 4, StatusFilter(models.Open))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) > 4 || (more && len(page) != 4) {
+				t.Fatalf("page of %d todos, more %v", len(page), more)
+			}
+			got = append(got, page...)
+			if !more {
+				break
+			}
+			after = CursorOf(page[len(page)-1])
+		}
+
+		if len(got) != 20 || len(got) != len(want) {
+			t.Fatalf("pages held %d todos, want 20", len(got))
+		}
+		for i := range got {
+			if got[i].ID != want[i].ID {
+				t.Fatalf("todo %d of the pages is %d, want %d", i, got[i].ID, want[i].ID)
+			}
+			if i > 0 && !InListOrder(got[i-1], got[i]) {
+				t.Errorf("todo %d is listed before todo %d", got[i].ID, got[i-1].ID)
+			}
+		}
+
+		rest, more, err := pager.GetPage(CursorOf(got[9]), 0, StatusFilter(models.Open))
+		if err != nil || more || len(rest) != 10 || rest[0].ID != got[10].ID {
+			t.Errorf("rest of the list = %d todos, more %v, %v", len(rest), more, err)
+		}
+	})
+}
+
+func TestConformancePagesAcrossOffsets(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo TodoRepository) {
+		// Saved around a DST switch and received from a replica in New York, the wall
+		// clocks sort the other way around than the instants
+		base := time.Date(2026, 3, 29, 8, 0, 0, 0, time.UTC)
+		zones := []*time.Location{
+			time.FixedZone("CEST", 2*60*60),
+			time.FixedZone("CET", 60*60),
+			time.FixedZone("EST", -5*60*60),
+		}
+		for i := range 9 {
+			todo := newTestTodo("Todo")
+			todo.UpdatedAt = base.Add(time.Duration(i) * 10 * time.Minute).In(zones[i%len(zones)])
+			if err := repo.Create(todo); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		all, err := repo.GetAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i < len(all); i++ {
+			if !InListOrder(all[i-1], all[i]) {
+				t.Errorf("todo %d is listed before todo %d", all[i].ID, all[i-1].ID)
+			}
+		}
+
+		pager := repo.(Pager)
+		var got []int64
+		var after *Cursor
+		for more := true; more; {
+			// Pages that skip back would never run out
+			if len(got) > len(all) {
+				t.Fatalf("pages hold more todos than the list: %v", got)
+			}
+			var page []*models.Todo
+			page, more, err = pager.GetPage(after, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, todo := range page {
+				got = append(got, todo.ID)
+			}
+			if more {
+				after = CursorOf(page[len(page)-1])
+			}
+		}
+		want := make([]int64, len(all))
+		for i, todo := range all {
+			want[i] = todo.ID
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("pages = %v, want %v", got, want)
+		}
+
+		since, err := repo.GetAll(Where(FieldUpdatedAt, OpGe, base.Add(45*time.Minute).In(zones[2])))
+		if err != nil || len(since) != 4 {
+			t.Errorf("todos updated since 08:45 UTC = %d, %v, want 4", len(since), err)
+		}
+	})
+}
+
+func TestConformanceFilters(t *testing.T) {
+	today := startOfToday()
+	yesterday := today.AddDate(0, 0, -1).Add(9 * time.Hour)
+	laterToday := today.Add(15 * time.Hour)
+	nextWeek := today.AddDate(0, 0, 4).Add(9 * time.Hour)
+	farAway := today.AddDate(0, 1, 0)
+
+	fixtures := []struct {
+		title       string
+		description string
+		status      models.Status
+		priority    models.Priority
+		due         *time.Time
+		archived    bool
+		tags        []string
+	}{
+		{"Overdue invoice", "", models.Open, models.Low, &yesterday, false, []string{"work"}},
+		{"Call the plumber", "", models.Open, models.Medium, &laterToday, false, []string{"home"}},
+		{"Plan the offsite", "", models.Blocked, models.High, &nextWeek, false, []string{"work"}},
+		{"Renew passport", "", models.Open, models.Major, &farAway, false, nil},
+		{"Fix the login bug", "", models.Doing, models.Low, nil, false, []string{"work"}},
+		{"Ship release", "", models.Done, models.Critical, nil, false, nil},
+		{"Old idea", "", models.Open, models.High, nil, true, nil},
+		{"Raise prices 10%", "see plan_b", models.Open, models.Low, nil, false, nil},
+	}
+
+	tests := []struct {
+		name    string
+		filters []Filter
+		want    []string
+	}{
+		{"no filter", nil, []string{"Overdue invoice", "Call the plumber", "Plan the offsite", "Renew passport", "Fix the login bug", "Ship release", "Old idea", "Raise prices 10%"}},
+		{"today", []Filter{AllTodayFilter(models.High, 7), NotArchivedFilter()}, []string{"Overdue invoice", "Call the plumber", "Plan the offsite", "Renew passport", "Fix the login bug"}},
+		{"overdue", []Filter{OverDueFilter()}, []string{"Overdue invoice"}},
+		{"due today", []Filter{DueTodayFilter()}, []string{"Call the plumber"}},
+		{"coming up", []Filter{ComingUpFilter(7)}, []string{"Plan the offsite"}},
+		{"completed", []Filter{CompletedTodayFilter()}, []string{"Ship release"}},
+		{"priority", []Filter{PrioAboveHighFilter(models.High)}, []string{"Old idea"}},
+		{"status", []Filter{StatusFilter(models.Open), NotArchivedFilter()}, []string{"Overdue invoice", "Call the plumber", "Renew passport", "Raise prices 10%"}},
+		{"archived", []Filter{ArchivedFilter()}, []string{"Old idea"}},
+		{"min priority", []Filter{PriorityFilter(models.Major)}, []string{"Renew passport", "Ship release"}},
+		{"due before", []Filter{DueDateFilter(today.AddDate(0, 0, 1))}, []string{"Overdue invoice", "Call the plumber"}},
+		{"search", []Filter{SearchFilter("THE")}, []string{"Call the plumber", "Plan the offsite", "Fix the login bug"}},
+		{"search for a percent sign", []Filter{SearchFilter("%")}, []string{"Raise prices 10%"}},
+		{"search for an underscore", []Filter{SearchFilter("_")}, []string{"Raise prices 10%"}},
+		{"tag", []Filter{TagFilter("work")}, []string{"Overdue invoice", "Plan the offsite", "Fix the login bug"}},
+		{"tag and status", []Filter{TagFilter("work"), StatusFilter(models.Doing)}, []string{"Fix the login bug"}},
+		{"tag contains", []Filter{Where(FieldTag, OpContains, "OR")}, []string{"Overdue invoice", "Plan the offsite", "Fix the login bug"}},
+		{"not tagged", []Filter{Not(TagFilter("work"))}, []string{"Call the plumber", "Renew passport", "Ship release", "Old idea", "Raise prices 10%"}},
+		{"not overdue", []Filter{Not(OverDueFilter())}, []string{"Call the plumber", "Plan the offsite", "Renew passport", "Fix the login bug", "Ship release", "Old idea", "Raise prices 10%"}},
+		{"has a due date", []Filter{Where(FieldDueDate, OpIsSet, nil)}, []string{"Overdue invoice", "Call the plumber", "Plan the offsite", "Renew passport"}},
+		{"title", []Filter{Where(FieldTitle, OpEq, "Ship release")}, []string{"Ship release"}},
+		{"created before today", []Filter{Where(FieldCreatedAt, OpLt, Day(0))}, nil},
+		{"empty and", []Filter{And()}, []string{"Overdue invoice", "Call the plumber", "Plan the offsite", "Renew passport", "Fix the login bug", "Ship release", "Old idea", "Raise prices 10%"}},
+		{"empty or", []Filter{Or()}, nil},
+	}
+
+	forEachBackend(t, func(t *testing.T, repo TodoRepository) {
+		for _, fixture := range fixtures {
+			todo := newTestTodo(fixture.title)
+			todo.Description, todo.Status, todo.Priority = fixture.description, fixture.status, fixture.priority
+			todo.DueDate, todo.Archived = fixture.due, fixture.archived
+			if err := repo.Create(todo); err != nil {
+				t.Fatal(err)
+			}
+			for _, tag := range fixture.tags {
+				if err := repo.AddTagToTodo(todo.ID, tag); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := repo.GetAll(tt.filters...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if titles(got) != sortedTitles(tt.want) {
+					t.Errorf("selected %s, want %s", titles(got), sortedTitles(tt.want))
+				}
+			})
+		}
+	})
+}
+
+func TestConformanceInvalidFilters(t *testing.T) {
+	invalid := map[string]Filter{
+		"zero filter":       {},
+		"unknown field":     Where("colour", OpEq, "red"),
+		"wrong operator":    Where(FieldTag, OpLt, "work"),
+		"wrong value":       Where(FieldStatus, OpEq, 1),
+		"missing date":      Where(FieldDueDate, OpLt, nil),
+		"nested in a group": Or(StatusFilter(models.Open), Not(Where(FieldArchived, OpContains, true))),
+	}
+
+	forEachBackend(t, func(t *testing.T, repo TodoRepository) {
+		for name, filter := range invalid {
+			if _, err := repo.GetAll(filter); !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("%s: GetAll() error = %v, want ErrInvalidFilter", name, err)
+			}
+		}
+	})
+}
+
+func titles(todos []*models.Todo) string {
+	names := make([]string, len(todos))
+	for i, todo := range todos {
+		names[i] = todo.Title
+	}
+	return sortedTitles(names)
+}
+
+func sortedTitles(names []string) string {
+	names = slices.Clone(names)
+	slices.Sort(names)
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+func sameTags(tags []string, want ...string) bool {
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	return slices.Equal(tags, want)
+}
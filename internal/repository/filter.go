@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+// A Filter is a small expression tree over the fields of a todo: comparisons combined with
+// And, Or and Not. The SQLite repository compiles it to a WHERE clause, other backends
+// evaluate it with Match. Both have to select the same todos, the conformance tests check
+// that they do.
+
+// ErrInvalidFilter matches the error returned for a filter that compares a field in a way
+// it doesn't support
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Field is a property of a todo a filter can compare
+type Field string
+
+const (
+	FieldStatus      Field = "status"      // models.Status
+	FieldPriority    Field = "priority"    // models.Priority
+	FieldArchived    Field = "archived"    // bool
+	FieldDueDate     Field = "due_date"    // time.Time or Day, may be unset
+	FieldCreatedAt   Field = "created_at"  // time.Time or Day
+	FieldUpdatedAt   Field = "updated_at"  // time.Time or Day
+	FieldTitle       Field = "title"       // string
+	FieldDescription Field = "description" // string
+	FieldTag         Field = "tag"         // string, matched against each tag of the todo
+)
+
+// Operator is how a field is compared to the value of a filter
+type Operator string
+
+const (
+	OpEq Operator = "="
+	OpNe Operator = "!="
+	OpLt Operator = "<"
+	OpLe Operator = "<="
+	OpGt Operator = ">"
+	OpGe Operator = ">="
+	// OpIsSet matches todos that have a due date, it takes no value
+	OpIsSet Operator = "is_set"
+	// OpContains matches text containing the value, ignoring the case of ASCII letters like
+	// SQLite's LIKE does
+	OpContains Operator = "contains"
+)
+
+// Day is a date relative to the start of today, e.g. Day(1) is midnight tomorrow. It is
+// resolved each time the filter is evaluated, so a filter kept past midnight moves along.
+type Day int
+
+// time returns the start of the day, counted from today
+func (d Day) time(today time.Time) time.Time {
+	return today.AddDate(0, 0, int(d))
+}
+
+// operators lists what each field can be compared with
+var operators = map[Field][]Operator{
+	FieldStatus:      {OpEq, OpNe, OpLt, OpLe, OpGt, OpGe},
+	FieldPriority:    {OpEq, OpNe, OpLt, OpLe, OpGt, OpGe},
+	FieldArchived:    {OpEq, OpNe},
+	FieldDueDate:     {OpLt, OpLe, OpGt, OpGe, OpIsSet},
+	FieldCreatedAt:   {OpLt, OpLe, OpGt, OpGe},
+	FieldUpdatedAt:   {OpLt, OpLe, OpGt, OpGe},
+	FieldTitle:       {OpEq, OpNe, OpContains},
+	FieldDescription: {OpEq, OpNe, OpContains},
+	FieldTag:         {OpEq, OpContains},
+}
+
+type filterKind int
+
+const (
+	kindCompare filterKind = iota
+	kindAnd
+	kindOr
+	kindNot
+)
+
+// Filter selects todos. The zero Filter compares nothing and is invalid, build one with
+// Where, And, Or and Not.
+type Filter struct {
+	kind     filterKind
+	field    Field
+	op       Operator
+	value    any
+	operands []Filter
+}
+
+// Where compares a field of the todo with a value. A comparison with a due date matches
+// only todos that have one, for a tag it matches todos with at least one such tag.
+func Where(field Field, op Operator, value any) Filter {
+	return Filter{kind: kindCompare, field: field, op: op, value: value}
+}
+
+// And matches todos that pass every filter, without filters it matches all todos
+func And(filters ...Filter) Filter {
+	return Filter{kind: kindAnd, operands: filters}
+}
+
+// Or matches todos that pass any of the filters, without filters it matches none
+func Or(filters ...Filter) Filter {
+	return Filter{kind: kindOr, operands: filters}
+}
+
+// Not matches todos that don't pass the filter
+func Not(filter Filter) Filter {
+	return Filter{kind: kindNot, operands: []Filter{filter}}
+}
+
+// Validate checks that every comparison uses an operator and value type its field supports
+func (f Filter) Validate() error {
+	switch f.kind {
+	case kindAnd, kindOr, kindNot:
+		for _, operand := range f.operands {
+			if err := operand.Validate(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	allowed, ok := operators[f.field]
+	if !ok {
+		return fmt.Errorf("%w: unknown field %q", ErrInvalidFilter, f.field)
+	}
+	if !slices.Contains(allowed, f.op) {
+		return fmt.Errorf("%w: %s doesn't support %s", ErrInvalidFilter, f.field, f.op)
+	}
+
+	valid := false
+	switch f.field {
+	case FieldStatus:
+		_, valid = f.value.(models.Status)
+	case FieldPriority:
+		_, valid = f.value.(models.Priority)
+	case FieldArchived:
+		_, valid = f.value.(bool)
+	case FieldDueDate, FieldCreatedAt, FieldUpdatedAt:
+		switch f.value.(type) {
+		case time.Time, Day:
+			valid = true
+		case nil:
+			valid = f.op == OpIsSet
+		}
+	case FieldTitle, FieldDescription, FieldTag:
+		_, valid = f.value.(string)
+	}
+	if !valid {
+		return fmt.Errorf("%w: %s can't be compared with %T", ErrInvalidFilter, f.field, f.value)
+	}
+	return nil
+}
+
+// Match reports whether the todo passes the filter, an invalid filter matches nothing
+func (f Filter) Match(todo *models.Todo) bool {
+	return f.Validate() == nil && f.match(todo, startOfToday())
+}
+
+// MatchAll reports whether the todo passes every filter. The filters must be valid.
+func MatchAll(todo *models.Todo, filters ...Filter) bool {
+	return And(filters...).match(todo, startOfToday())
+}
+
+// match evaluates the filter, Day values are resolved against today
+func (f Filter) match(todo *models.Todo, today time.Time) bool {
+	switch f.kind {
+	case kindAnd:
+		for _, operand := range f.operands {
+			if !operand.match(todo, today) {
+				return false
+			}
+		}
+		return true
+	case kindOr:
+		for _, operand := range f.operands {
+			if operand.match(todo, today) {
+				return true
+			}
+		}
+		return false
+	case kindNot:
+		return !f.operands[0].match(todo, today)
+	}
+
+	switch f.field {
+	case FieldStatus:
+		return compare(int(todo.Status), f.op, int(f.value.(models.Status)))
+	case FieldPriority:
+		return compare(int(todo.Priority), f.op, int(f.value.(models.Priority)))
+	case FieldArchived:
+		return (todo.Archived == f.value.(bool)) == (f.op == OpEq)
+	case FieldDueDate:
+		if todo.DueDate == nil {
+			return false
+		}
+		if f.op == OpIsSet {
+			return true
+		}
+		return compare(todo.DueDate.UnixNano(), f.op, f.timeValue(today).UnixNano())
+	case FieldCreatedAt:
+		return compare(todo.CreatedAt.UnixNano(), f.op, f.timeValue(today).UnixNano())
+	case FieldUpdatedAt:
+		return compare(todo.UpdatedAt.UnixNano(), f.op, f.timeValue(today).UnixNano())
+	case FieldTitle:
+		return matchText(todo.Title, f.op, f.value.(string))
+	case FieldDescription:
+		return matchText(todo.Description, f.op, f.value.(string))
+	case FieldTag:
+		return slices.ContainsFunc(todo.Tags, func(tag string) bool { return matchText(tag, f.op, f.value.(string)) })
+	}
+	return false
+}
+
+// timeValue returns the value of a date comparison
+func (f Filter) timeValue(today time.Time) time.Time {
+	if day, ok := f.value.(Day); ok {
+		return day.time(today)
+	}
+	return f.value.(time.Time)
+}
+
+func compare[T int | int64](a T, op Operator, b T) bool {
+	switch op {
+	case OpEq:
+		return a == b
+	case OpNe:
+		return a != b
+	case OpLt:
+		return a < b
+	case OpLe:
+		return a <= b
+	case OpGt:
+		return a > b
+	case OpGe:
+		return a >= b
+	}
+	return false
+}
+
+func matchText(text string, op Operator, value string) bool {
+	switch op {
+	case OpEq:
+		return text == value
+	case OpNe:
+		return text != value
+	case OpContains:
+		return strings.Contains(foldASCII(text), foldASCII(value))
+	}
+	return false
+}
+
+// foldASCII lowers only ASCII letters, SQLite's LIKE doesn't fold other letters either
+func foldASCII(text string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, text)
+}
+
+func startOfToday() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// ===========================================================================
+// Named filters
+// ===========================================================================
+
+// unfinished matches todos that are not done
+func unfinished() Filter {
+	return Where(FieldStatus, OpNe, models.Done)
+}
+
+// PrioAboveHighFilter matches unfinished todos of at least minPriority that are not due in the future
+func PrioAboveHighFilter(minPriority models.Priority) Filter {
+	return And(
+		Where(FieldPriority, OpGe, minPriority),
+		Or(Not(Where(FieldDueDate, OpIsSet, nil)), Where(FieldDueDate, OpLt, Day(0))),
+		unfinished(),
+	)
+}
+
+// OverDueFilter matches unfinished todos that were due before today
+func OverDueFilter() Filter {
+	return And(Where(FieldDueDate, OpLt, Day(0)), unfinished())
+}
+
+// DueTodayFilter matches unfinished todos due today
+func DueTodayFilter() Filter {
+	return And(Where(FieldDueDate, OpGe, Day(0)), Where(FieldDueDate, OpLt, Day(1)), unfinished())
+}
+
+// ComingUpFilter matches unfinished todos due after today and within the next days
+func ComingUpFilter(days int) Filter {
+	return And(Where(FieldDueDate, OpGe, Day(1)), Where(FieldDueDate, OpLt, Day(days)), unfinished())
+}
+
+// CompletedTodayFilter matches todos that were finished today
+func CompletedTodayFilter() Filter {
+	return And(Where(FieldStatus, OpEq, models.Done), Where(FieldUpdatedAt, OpGe, Day(0)))
+}
+
+// AllTodayFilter combines all filters for the Today dashboard:
+// - High priority tasks (minPriority and up)
+// - Overdue tasks
+// - Tasks due today
+// - Tasks in progress
+// - Coming up tasks (next comingUpDays days)
+func AllTodayFilter(minPriority models.Priority, comingUpDays int) Filter {
+	// Overdue, due today and coming up together cover every due date before the window end
+	return Or(
+		Where(FieldStatus, OpEq, models.Doing),
+		And(
+			unfinished(),
+			Or(Where(FieldPriority, OpGe, minPriority), Where(FieldDueDate, OpLt, Day(max(comingUpDays, 1)))),
+		),
+	)
+}
+
+func StatusFilter(status models.Status) Filter {
+	return Where(FieldStatus, OpEq, status)
+}
+
+func ArchivedFilter() Filter {
+	return Where(FieldArchived, OpEq, true)
+}
+
+func NotArchivedFilter() Filter {
+	return Where(FieldArchived, OpEq, false)
+}
+
+func PriorityFilter(minPriority models.Priority) Filter {
+	return Where(FieldPriority, OpGe, minPriority)
+}
+
+func DueDateFilter(beforeDate time.Time) Filter {
+	return Where(FieldDueDate, OpLe, beforeDate)
+}
+
+// SearchFilter matches the query anywhere in the title or description
+func SearchFilter(query string) Filter {
+	return Or(Where(FieldTitle, OpContains, query), Where(FieldDescription, OpContains, query))
+}
+
+func TagFilter(tagName string) Filter {
+	return Where(FieldTag, OpEq, tagName)
+}
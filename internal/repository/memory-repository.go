@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+// InMemoryTodoRepository keeps todos and tags in memory. It behaves like the SQLite
+// repository, which makes it a stand-in for the database in tests.
+type InMemoryTodoRepository struct {
+	mu        sync.RWMutex
+	todos     map[int64]*models.Todo
+	tags      []*models.Tag
+	nextID    int64
+	nextTagID int64
+}
+
+func NewInMemoryTodoRepository() *InMemoryTodoRepository {
+	return &InMemoryTodoRepository{todos: make(map[int64]*models.Todo)}
+}
+
+// Close is a no-op, there is nothing to release
+func (r *InMemoryTodoRepository) Close() error {
+	return nil
+}
+
+// Create stores a copy of the todo. Tags are added with AddTagToTodo, as in the database.
+func (r *InMemoryTodoRepository) Create(todo *models.Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if todo.UID == "" {
+		todo.UID = newUID()
+	}
+	r.nextID++
+	todo.ID = r.nextID
+
+	stored := copyTodo(todo)
+	stored.Tags = []string{}
+	stored.Revision = 0
+	r.todos[todo.ID] = stored
+	return nil
+}
+
+func (r *InMemoryTodoRepository) GetByID(id int64) (*models.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.todos[id]
+	if !ok {
+		return nil, fmt.Errorf("todo with id %d not found", id)
+	}
+	return copyTodo(todo), nil
+}
+
+func (r *InMemoryTodoRepository) GetAll(filters ...Filter) ([]*models.Todo, error) {
+	if err := And(filters...).Validate(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todos := []*models.Todo{}
+	for _, todo := range r.todos {
+		if MatchAll(todo, filters...) {
+			todos = append(todos, copyTodo(todo))
+		}
+	}
+
+	sort.Slice(todos, func(i, j int) bool {
+		if todos[i].CreatedAt.Equal(todos[j].CreatedAt) {
+			return todos[i].ID > todos[j].ID
+		}
+		return todos[i].CreatedAt.After(todos[j].CreatedAt)
+	})
+	return todos, nil
+}
+
+// GetPage returns the page of the list after the cursor, see Pager
+func (r *InMemoryTodoRepository) GetPage(after *Cursor, limit int, filters ...Filter) ([]*models.Todo, bool, error) {
+	todos, err := r.GetAll(filters...)
+	if err != nil {
+		return nil, false, err
+	}
+	page, more := PageOf(todos, after, limit)
+	return page, more, nil
+}
+
+// Update stores the todo if it wasn't changed since it was read, otherwise it returns a
+// ConflictError. Tags are kept as they are stored, they change through AddTagToTodo.
+func (r *InMemoryTodoRepository) Update(todo *models.Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.todos[todo.ID]
+	if !ok {
+		return &ConflictError{ID: todo.ID, Revision: todo.Revision, Deleted: true}
+	}
+	if stored.Revision != todo.Revision {
+		return &ConflictError{ID: todo.ID, Revision: todo.Revision, Current: stored.Revision}
+	}
+
+	updated := copyTodo(todo)
+	updated.UID = stored.UID
+	updated.CreatedAt = stored.CreatedAt
+	updated.Tags = stored.Tags
+	updated.UpdatedAt = time.Now()
+	updated.Revision++
+	r.todos[todo.ID] = updated
+
+	todo.Revision++
+	return nil
+}
+
+func (r *InMemoryTodoRepository) Delete(id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.todos, id)
+	return nil
+}
+
+func (r *InMemoryTodoRepository) GetOpen() ([]*models.Todo, error) {
+	return r.GetAll(StatusFilter(models.Open), NotArchivedFilter())
+}
+
+func (r *InMemoryTodoRepository) GetActive() ([]*models.Todo, error) {
+	return r.GetAll(StatusFilter(models.Doing), NotArchivedFilter())
+}
+
+func (r *InMemoryTodoRepository) GetCompleted() ([]*models.Todo, error) {
+	return r.GetAll(StatusFilter(models.Done), NotArchivedFilter())
+}
+
+func (r *InMemoryTodoRepository) GetBlocked() ([]*models.Todo, error) {
+	return r.GetAll(StatusFilter(models.Blocked), NotArchivedFilter())
+}
+
+func (r *InMemoryTodoRepository) Search(query string) ([]*models.Todo, error) {
+	return r.GetAll(SearchFilter(query))
+}
+
+// ===========================================================================
+// Tags
+// ===========================================================================
+
+// AddTagToTodo tags the todo, creating the tag when it doesn't exist yet
+func (r *InMemoryTodoRepository) AddTagToTodo(todoID int64, tagName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[todoID]
+	if !ok {
+		return fmt.Errorf("todo with id %d not found", todoID)
+	}
+	if r.tagIndex(tagName) < 0 {
+		r.addTag(&models.Tag{Name: tagName})
+	}
+	if !slices.Contains(todo.Tags, tagName) {
+		todo.Tags = append(todo.Tags, tagName)
+		todo.Revision++
+	}
+	return nil
+}
+
+func (r *InMemoryTodoRepository) RemoveTagFromTodo(todoID int64, tagName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tagIndex(tagName) < 0 {
+		return fmt.Errorf("tag %q not found", tagName)
+	}
+	if todo, ok := r.todos[todoID]; ok && slices.Contains(todo.Tags, tagName) {
+		todo.Tags = slices.DeleteFunc(todo.Tags, func(name string) bool { return name == tagName })
+		todo.Revision++
+	}
+	return nil
+}
+
+// GetAllTags returns copies of all tags, ordered by name
+func (r *InMemoryTodoRepository) GetAllTags() ([]*models.Tag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tags := make([]*models.Tag, len(r.tags))
+	for i, tag := range r.tags {
+		c := *tag
+		tags[i] = &c
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (r *InMemoryTodoRepository) CreateTag(tag *models.Tag) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tagIndex(tag.Name) >= 0 {
+		return fmt.Errorf("tag %q already exists", tag.Name)
+	}
+	r.addTag(tag)
+	return nil
+}
+
+// DeleteTag removes a tag and takes it off every todo
+func (r *InMemoryTodoRepository) DeleteTag(id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.tags, func(tag *models.Tag) bool { return tag.ID == id })
+	if i < 0 {
+		return nil
+	}
+	name := r.tags[i].Name
+	r.tags = slices.Delete(r.tags, i, i+1)
+	r.renameTagOnTodos(name, "")
+	return nil
+}
+
+// UpdateTag changes a tag, a new name is given to every todo with the tag
+func (r *InMemoryTodoRepository) UpdateTag(tag *models.Tag) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := slices.IndexFunc(r.tags, func(existing *models.Tag) bool { return existing.ID == tag.ID })
+	if i < 0 {
+		return fmt.Errorf("tag with id %d not found", tag.ID)
+	}
+	stored := r.tags[i]
+	if stored.Name != tag.Name && r.tagIndex(tag.Name) >= 0 {
+		return fmt.Errorf("tag %q already exists", tag.Name)
+	}
+
+	tag.UpdatedAt = time.Now()
+	oldName := stored.Name
+	stored.Name = tag.Name
+	stored.Description = tag.Description
+	stored.UpdatedAt = tag.UpdatedAt
+	if oldName != tag.Name {
+		r.renameTagOnTodos(oldName, tag.Name)
+	}
+	return nil
+}
+
+// addTag stores a new tag, the caller holds the lock
+func (r *InMemoryTodoRepository) addTag(tag *models.Tag) {
+	now := time.Now()
+	tag.CreatedAt = now
+	tag.UpdatedAt = now
+	if tag.UID == "" {
+		tag.UID = newUID()
+	}
+	r.nextTagID++
+	tag.ID = r.nextTagID
+
+	c := *tag
+	r.tags = append(r.tags, &c)
+}
+
+func (r *InMemoryTodoRepository) tagIndex(name string) int {
+	return slices.IndexFunc(r.tags, func(tag *models.Tag) bool { return tag.Name == name })
+}
+
+// renameTagOnTodos replaces a tag on every todo that has it, an empty name removes it
+func (r *InMemoryTodoRepository) renameTagOnTodos(oldName, newName string) {
+	for _, todo := range r.todos {
+		if !slices.Contains(todo.Tags, oldName) {
+			continue
+		}
+		todo.Tags = slices.DeleteFunc(todo.Tags, func(name string) bool { return name == oldName })
+		if newName != "" && !slices.Contains(todo.Tags, newName) {
+			todo.Tags = append(todo.Tags, newName)
+		}
+	}
+}
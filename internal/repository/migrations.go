@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -16,6 +17,39 @@ type Migration struct {
 	Rollback func(tx *sql.Tx) error // Optional rollback function
 }
 
+// MigrationStatus is the state of a migration in a database
+type MigrationStatus struct {
+	ID        int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Unknown marks a migration applied by a newer version, which this version doesn't have
+	Unknown bool
+	// Reversible is set when the migration can be rolled back
+	Reversible bool
+}
+
+// SchemaTooNewError is returned for a database migrated by a newer version. An older
+// version doesn't know the schema and would corrupt the data by writing to it.
+type SchemaTooNewError struct {
+	Applied int // the newest migration applied to the database
+	Known   int // the newest migration this version has
+}
+
+func (e *SchemaTooNewError) Error() string {
+	return fmt.Sprintf("the database was migrated to version %d by a newer version of todo, this version only knows up to %d: "+
+		"upgrade todo, or run `todo db rollback --to %d` with the newer version", e.Applied, e.Known, e.Known)
+}
+
+// LatestMigration returns the ID of the newest migration
+func LatestMigration(migrations []Migration) int {
+	latest := 0
+	for _, migration := range migrations {
+		latest = max(latest, migration.ID)
+	}
+	return latest
+}
+
 // MigrationManager handles database migrations
 type MigrationManager struct {
 	db *sql.DB
@@ -58,8 +92,24 @@ func (m *MigrationManager) GetAppliedMigrations() (map[int]bool, error) {
 	return appliedMigrations, rows.Err()
 }
 
+// CheckVersion returns a SchemaTooNewError when a migration was applied that isn't in migrations
+func (m *MigrationManager) CheckVersion(migrations []Migration) error {
+	var newest sql.NullInt64
+	if err := m.db.QueryRow("SELECT MAX(id) FROM schema_migrations").Scan(&newest); err != nil {
+		return fmt.Errorf("couldn't get applied migrations: %w", err)
+	}
+	if known := LatestMigration(migrations); int(newest.Int64) > known {
+		return &SchemaTooNewError{Applied: int(newest.Int64), Known: known}
+	}
+	return nil
+}
+
 // ApplyMigrations applies all pending migrations
 func (m *MigrationManager) ApplyMigrations(migrations []Migration) error {
+	if err := m.CheckVersion(migrations); err != nil {
+		return err
+	}
+
 	appliedMigrations, err := m.GetAppliedMigrations()
 	if err != nil {
 		return fmt.Errorf("couldn't get applied migrations: %w", err)
@@ -172,3 +222,142 @@ func (m *MigrationManager) ValidateMigration(migrationID int) (bool, error) {
 		return true, nil
 	}
 }
+
+// Status returns the state of every migration, including those applied by a newer version
+func (m *MigrationManager) Status(migrations []Migration) ([]MigrationStatus, error) {
+	rows, err := m.db.Query("SELECT id, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recorded := make(map[int]MigrationStatus)
+	for rows.Next() {
+		status := MigrationStatus{Applied: true, Unknown: true}
+		if err := rows.Scan(&status.ID, &status.Name, &status.AppliedAt); err != nil {
+			return nil, err
+		}
+		recorded[status.ID] = status
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{ID: migration.ID, Name: migration.Name, Reversible: migration.Rollback != nil}
+		if applied, ok := recorded[migration.ID]; ok {
+			status.Applied, status.AppliedAt = true, applied.AppliedAt
+			delete(recorded, migration.ID)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, status := range recorded {
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses, nil
+}
+
+// Pending returns the migrations ApplyMigrations would apply
+func (m *MigrationManager) Pending(migrations []Migration) ([]Migration, error) {
+	applied, err := m.GetAppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if applied[migration.ID] {
+			// Migrations recorded without their changes are applied again
+			if valid, err := m.ValidateMigration(migration.ID); err != nil || valid {
+				continue
+			}
+		}
+		pending = append(pending, migration)
+	}
+	return pending, nil
+}
+
+// DryRun runs the pending migrations in a transaction that is rolled back, to see whether
+// they apply. It returns the migrations that would be applied.
+func (m *MigrationManager) DryRun(migrations []Migration) ([]Migration, error) {
+	if err := m.CheckVersion(migrations); err != nil {
+		return nil, err
+	}
+	pending, err := m.Pending(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, migration := range pending {
+		if err := migration.RunSQL(tx); err != nil {
+			return pending, fmt.Errorf("migration %d would fail: %w", migration.ID, err)
+		}
+	}
+	return pending, nil
+}
+
+// Rollbacks returns the migrations RollbackTo would undo, newest first. It fails when one of
+// them can't be rolled back.
+func (m *MigrationManager) Rollbacks(migrations []Migration, target int) ([]Migration, error) {
+	if err := m.CheckVersion(migrations); err != nil {
+		return nil, err
+	}
+	applied, err := m.GetAppliedMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get applied migrations: %w", err)
+	}
+
+	var undo []Migration
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.ID <= target || !applied[migration.ID] {
+			continue
+		}
+		if migration.Rollback == nil {
+			return nil, fmt.Errorf("migration %d (%s) can't be rolled back", migration.ID, migration.Name)
+		}
+		undo = append(undo, migration)
+	}
+	return undo, nil
+}
+
+// RollbackTo undoes the migrations applied after target, newest first, so an older version
+// can open the database. Nothing is undone when one of them can't be rolled back. It returns
+// the migrations that were rolled back.
+func (m *MigrationManager) RollbackTo(migrations []Migration, target int) ([]Migration, error) {
+	undo, err := m.Rollbacks(migrations, target)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, migration := range undo {
+		log.Info("Rolling back migration", "id", migration.ID, "name", migration.Name)
+
+		tx, err := m.db.Begin()
+		if err != nil {
+			return undo[:i], fmt.Errorf("couldn't start transaction for migration %d: %w", migration.ID, err)
+		}
+		if err := migration.Rollback(tx); err != nil {
+			tx.Rollback()
+			return undo[:i], fmt.Errorf("rollback of migration %d failed: %w", migration.ID, err)
+		}
+		if _, err := tx.Exec("DELETE FROM schema_migrations WHERE id = ?", migration.ID); err != nil {
+			tx.Rollback()
+			return undo[:i], fmt.Errorf("couldn't remove record of migration %d: %w", migration.ID, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return undo[:i], fmt.Errorf("couldn't commit rollback of migration %d: %w", migration.ID, err)
+		}
+	}
+
+	return undo, nil
+}
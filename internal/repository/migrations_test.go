@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func migrationIDs(migrations []Migration) []int {
+	ids := []int{}
+	for _, migration := range migrations {
+		ids = append(ids, migration.ID)
+	}
+	return ids
+}
+
+func tableExists(t *testing.T, r *SQLiteTodoRepository, table string) bool {
+	t.Helper()
+	var count int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func TestRollbackAndMigrateAgain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.sql")
+	db, err := OpenSQLiteTodoRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo := newTestTodo("Survives")
+	if err := db.Create(todo); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddTagToTodo(todo.ID, "work"); err != nil {
+		t.Fatal(err)
+	}
+
+	migrations := GetAllMigrations()
+	manager := NewMigrationManager(db.db)
+	undone, err := manager.RollbackTo(migrations, 1)
+	if err != nil {
+		t.Fatalf("RollbackTo() error: %v", err)
+	}
+	if got := migrationIDs(undone); len(got) != len(migrations)-1 || got[0] != LatestMigration(migrations) {
+		t.Errorf("RollbackTo() undid %v, want every migration after 1, newest first", got)
+	}
+
+	for _, column := range []string{"time_spent", "revision", "uid"} {
+		if exists, _ := manager.ColumnExists("todos", column); exists {
+			t.Errorf("todos.%s still exists", column)
+		}
+	}
+	if tableExists(t, db, "change_log") {
+		t.Error("change_log still exists")
+	}
+	pending, err := manager.Pending(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != len(migrations)-1 {
+		t.Errorf("Pending() = %v after the rollback", migrationIDs(pending))
+	}
+	db.Close()
+
+	// Opening the database migrates it again
+	db, err = OpenSQLiteTodoRepository(path)
+	if err != nil {
+		t.Fatalf("OpenSQLiteTodoRepository() error: %v", err)
+	}
+	defer db.Close()
+	got, err := db.GetByID(todo.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error: %v", err)
+	}
+	if got.Title != "Survives" || len(got.Tags) != 1 || got.UID == "" {
+		t.Errorf("GetByID() = %+v, want the todo with its tag and a new uid", got)
+	}
+}
+
+func TestRollbackStopsAtIrreversibleMigrations(t *testing.T) {
+	db := newTestDB(t)
+	migrations := GetAllMigrations()
+	manager := NewMigrationManager(db.db)
+
+	if _, err := manager.RollbackTo(migrations, 0); err == nil {
+		t.Fatal("RollbackTo(0) succeeded, the initial schema can't be rolled back")
+	}
+	pending, err := manager.Pending(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("a refused rollback undid %v", migrationIDs(pending))
+	}
+}
+
+func TestDryRunDoesNotMigrate(t *testing.T) {
+	db := newTestDB(t)
+	migrations := GetAllMigrations()
+	manager := NewMigrationManager(db.db)
+	if _, err := manager.RollbackTo(migrations, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := manager.DryRun(migrations)
+	if err != nil {
+		t.Fatalf("DryRun() error: %v", err)
+	}
+	if got := migrationIDs(pending); len(got) != len(migrations)-5 || got[0] != 6 {
+		t.Errorf("DryRun() = %v, want the migrations after 5", got)
+	}
+	if tableExists(t, db, "change_log") {
+		t.Error("DryRun() created the change_log table")
+	}
+
+	statuses, err := manager.Status(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if applied := status.ID <= 5; status.Applied != applied {
+			t.Errorf("migration %d applied = %v, want %v", status.ID, status.Applied, applied)
+		}
+	}
+}
+
+func TestRefuseDatabaseOfNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.sql")
+	db, err := OpenSQLiteTodoRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := LatestMigration(GetAllMigrations())
+	_, err = db.db.Exec("INSERT INTO schema_migrations (id, name, applied_at) VALUES (?, 'From the future', CURRENT_TIMESTAMP)", latest+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := NewMigrationManager(db.db).Status(GetAllMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := statuses[len(statuses)-1]; last.ID != latest+1 || !last.Unknown {
+		t.Errorf("Status() ends with %+v, want the unknown migration", last)
+	}
+	db.Close()
+
+	_, err = OpenSQLiteTodoRepository(path)
+	var tooNew *SchemaTooNewError
+	if !errors.As(err, &tooNew) {
+		t.Fatalf("OpenSQLiteTodoRepository() error = %v, want a SchemaTooNewError", err)
+	}
+	if tooNew.Applied != latest+1 || tooNew.Known != latest {
+		t.Errorf("SchemaTooNewError = %+v", tooNew)
+	}
+}
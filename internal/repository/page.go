@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+// Lists show todos by priority, high to low, then the most recently updated first. Long
+// lists are loaded a page at a time: a page ends at a Cursor, the next one starts after it.
+// Pages are found by the values of the cursor rather than by an offset, so loading a page
+// costs the same at the end of a list as at its start.
+
+// Cursor marks the last todo of a page
+type Cursor struct {
+	Priority  models.Priority
+	UpdatedAt time.Time
+	ID        int64
+}
+
+// CursorOf returns the cursor of the page ending at the todo
+func CursorOf(todo *models.Todo) *Cursor {
+	return &Cursor{Priority: todo.Priority, UpdatedAt: todo.UpdatedAt, ID: todo.ID}
+}
+
+// Pager is implemented by backends that load a list one page at a time
+type Pager interface {
+	// GetPage returns up to limit todos passing the filters, in list order, that come after
+	// the cursor. A nil cursor starts at the top, a limit of 0 or less returns the rest of the
+	// list. more is set when todos follow the page.
+	GetPage(after *Cursor, limit int, filters ...Filter) (todos []*models.Todo, more bool, err error)
+}
+
+// InListOrder reports whether todo a is listed before todo b
+func InListOrder(a, b *models.Todo) bool {
+	return CursorOf(a).before(b)
+}
+
+// before reports whether the cursor comes before the todo in list order
+func (c *Cursor) before(todo *models.Todo) bool {
+	if c.Priority != todo.Priority {
+		return c.Priority > todo.Priority
+	}
+	if !c.UpdatedAt.Equal(todo.UpdatedAt) {
+		return c.UpdatedAt.After(todo.UpdatedAt)
+	}
+	return c.ID > todo.ID
+}
+
+// PageOf sorts the todos in list order and returns the page after the cursor, for backends
+// that hold every todo in memory anyway
+func PageOf(todos []*models.Todo, after *Cursor, limit int) ([]*models.Todo, bool) {
+	sort.Slice(todos, func(i, j int) bool { return InListOrder(todos[i], todos[j]) })
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(todos), func(i int) bool { return after.before(todos[i]) })
+	}
+	todos = todos[start:]
+
+	if limit <= 0 || len(todos) <= limit {
+		return todos, false
+	}
+	return todos[:limit], true
+}
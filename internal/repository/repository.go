@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/martijnspitter/tui-todo/internal/models"
@@ -30,174 +32,71 @@ type TodoRepository interface {
 	UpdateTag(tag *models.Tag) error
 }
 
-// Filter returns a WHERE clause fragment and associated arguments
-type Filter func() (string, []any)
-
-func PrioAboveHighFilter() Filter {
-	return func() (string, []any) {
-		now := time.Now()
-		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		return "(priority >= ? AND (due_date IS NULL OR due_date < ?) AND status != ?)", []interface{}{
-			models.Major,
-			today,
-			models.Done,
-		}
-	}
+// Store is a repository that holds open resources
+type Store interface {
+	TodoRepository
+	Close() error
 }
 
-func OverDueFilter() Filter {
-	return func() (string, []any) {
-		now := time.Now()
-		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		return "(due_date IS NOT NULL AND due_date < ? AND status != ?)", []interface{}{
-			today,
-			models.Done,
-		}
-	}
+// ExternalChange is a todo that was created, edited or deleted outside the program
+type ExternalChange struct {
+	ID int64
+	// Before is the todo as it was known before the change, nil for a new todo
+	Before  *models.Todo
+	Deleted bool
 }
 
-func DueTodayFilter() Filter {
-	return func() (string, []any) {
-		now := time.Now()
-		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		tomorrow := today.Add(24 * time.Hour)
-
-		return "(due_date IS NOT NULL AND due_date >= ? AND due_date < ? AND status != ?)",
-			[]interface{}{
-				today,
-				tomorrow,
-				models.Done, // Exclude completed tasks
-			}
-	}
+// ExternalChangeSource is implemented by backends whose data can be edited by other tools.
+// The service polls it when its directory changes and notifies the instances of the changes.
+type ExternalChangeSource interface {
+	ExternalChanges() ([]ExternalChange, error)
+	// Acknowledge records a change to the todo that was notified by another instance
+	Acknowledge(id int64)
+	// WatchDir is the directory the todos are stored in
+	WatchDir() string
+	// HoldsTodo reports whether a file in WatchDir is a todo, other files don't need a poll
+	HoldsTodo(name string) bool
 }
 
-func ComingUpFilter() Filter {
-	return func() (string, []any) {
-		now := time.Now()
-		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		tomorrow := today.Add(24 * time.Hour)    // Start from tomorrow
-		inThreeDays := today.Add(72 * time.Hour) // Show next 3 days from today
-
-		return "(due_date IS NOT NULL AND due_date >= ? AND due_date < ? AND status != ?)",
-			[]interface{}{
-				tomorrow,
-				inThreeDays,
-				models.Done, // Exclude completed tasks
-			}
-	}
+// ErrConflict matches the error Update returns when the todo changed since it was read
+var ErrConflict = errors.New("todo was changed concurrently")
+
+// ConflictError reports an update that was based on an outdated revision of the todo
+type ConflictError struct {
+	ID int64
+	// Revision is the revision the update was based on, Current the one that is stored
+	Revision int64
+	Current  int64
+	// Deleted is set when the todo no longer exists
+	Deleted bool
 }
 
-func CompletedTodayFilter() Filter {
-	return func() (string, []any) {
-		now := time.Now()
-		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-
-		return "status = ? AND t.updated_at >= ?",
-			[]interface{}{models.Done, today}
+func (e *ConflictError) Error() string {
+	if e.Deleted {
+		return fmt.Sprintf("todo %d was deleted", e.ID)
 	}
+	return fmt.Sprintf("todo %d is at revision %d, update was based on %d", e.ID, e.Current, e.Revision)
 }
 
-// AllTodayFilter combines all filters for the Today dashboard:
-// - High priority tasks
-// - Overdue tasks
-// - Tasks due today
-// - Tasks in progress
-// - Coming up tasks (next 3 days)
-func AllTodayFilter() Filter {
-	return func() (string, []any) {
-		now := time.Now()
-		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		tomorrow := today.Add(24 * time.Hour)
-		inThreeDays := today.Add(72 * time.Hour)
-
-		whereClause := `(
-            -- High priority tasks
-            (priority >= ? AND status != ?)
-            OR
-            -- Overdue tasks
-            (due_date IS NOT NULL AND due_date < ? AND status != ?)
-            OR
-            -- Due today
-            (due_date IS NOT NULL AND due_date >= ? AND due_date < ? AND status != ?)
-            OR
-            -- In progress tasks
-            (status = ?)
-            OR
-            -- Coming up tasks
-            (due_date IS NOT NULL AND due_date >= ? AND due_date < ? AND status != ?)
-        )`
-
-		args := []interface{}{
-			// High priority args
-			models.Major,
-			models.Done,
-
-			// Overdue args
-			today,
-			models.Done,
-
-			// Due today args
-			today,
-			tomorrow,
-			models.Done,
-
-			// In progress arg
-			models.Doing,
-
-			// Coming up args
-			tomorrow,
-			inThreeDays,
-			models.Done,
-		}
-
-		return whereClause, args
-	}
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
 }
 
-func StatusFilter(status models.Status) Filter {
-	return func() (string, []any) {
-		return "status = ?", []any{status}
-	}
+// ReplicaStore applies changes replicated from another machine. Todos and tags are matched
+// by their UID because IDs are only unique within one database.
+type ReplicaStore interface {
+	GetByUID(uid string) (*models.Todo, error)
+	SaveReplica(todo *models.Todo) error
+	DeleteReplica(uid string) (int64, error)
+	SaveTagReplica(tag *models.Tag) error
+	DeleteTagReplica(uid string) (int64, error)
 }
 
-func ArchivedFilter() Filter {
-	return func() (string, []any) {
-		return "archived = 1", []any{}
-	}
-}
-
-func NotArchivedFilter() Filter {
-	return func() (string, []any) {
-		return "archived = 0", []any{}
-	}
-}
-
-func PriorityFilter(minPriority models.Priority) Filter {
-	return func() (string, []any) {
-		return "priority >= ?", []any{minPriority}
-	}
-}
-
-func DueDateFilter(beforeDate time.Time) Filter {
-	return func() (string, []any) {
-		return "due_date IS NOT NULL AND due_date <= ?", []interface{}{beforeDate}
-	}
-}
-
-func SearchFilter(query string) Filter {
-	return func() (string, []any) {
-		searchTerm := "%" + query + "%"
-		return "(title LIKE ? OR description LIKE ?)", []interface{}{searchTerm, searchTerm}
-	}
-}
-
-func TagFilter(tagName string) Filter {
-	return func() (string, []any) {
-		return `id IN (
-            SELECT tt.todo_id
-            FROM todo_tags tt
-            JOIN tags t ON tt.tag_id = t.id
-            WHERE t.name = ?
-        )`, []any{tagName}
-	}
+// ChangeLogStore persists the ordered log of changes that instances replay after they were
+// disconnected. Sequence numbers only grow, also when records are compacted away.
+type ChangeLogStore interface {
+	AppendChange(record *models.ChangeRecord) error
+	ChangesSince(seq int64) ([]*models.ChangeRecord, error)
+	ChangeHead() (int64, error)
+	CompactChanges(deletedBefore time.Time) (int64, error)
 }
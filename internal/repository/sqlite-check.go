@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// ForeignKeyViolation is a row that refers to a row that doesn't exist
+type ForeignKeyViolation struct {
+	Table  string
+	RowID  int64
+	Parent string // the table the missing row should be in
+}
+
+// linkTables only hold references, their rows can be removed when what they link is gone
+var linkTables = []string{"todo_tags"}
+
+// IntegrityCheck runs SQLite's integrity check and returns the problems it reports, none
+// when the database is sound
+func IntegrityCheck(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var problem string
+		if err := rows.Scan(&problem); err != nil {
+			return nil, err
+		}
+		if problem != "ok" {
+			problems = append(problems, problem)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// ForeignKeyCheck returns the rows that refer to rows that don't exist
+func ForeignKeyCheck(db *sql.DB) ([]ForeignKeyViolation, error) {
+	rows, err := db.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var violations []ForeignKeyViolation
+	for rows.Next() {
+		var violation ForeignKeyViolation
+		var rowID sql.NullInt64
+		var foreignKey int
+		if err := rows.Scan(&violation.Table, &rowID, &violation.Parent, &foreignKey); err != nil {
+			return nil, err
+		}
+		violation.RowID = rowID.Int64
+		violations = append(violations, violation)
+	}
+	return violations, rows.Err()
+}
+
+// Removable reports whether the row only links other rows, so removing it loses nothing
+func (v ForeignKeyViolation) Removable() bool {
+	return slices.Contains(linkTables, v.Table)
+}
+
+// RemoveViolations deletes the removable rows among violations and returns how many it deleted
+func RemoveViolations(db *sql.DB, violations []ForeignKeyViolation) (int64, error) {
+	var removed int64
+	err := retryBusy(func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		removed = 0
+		for _, violation := range violations {
+			if !violation.Removable() {
+				continue
+			}
+			// Removable limited the table to one of linkTables
+			result, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE rowid = ?", violation.Table), violation.RowID)
+			if err != nil {
+				return err
+			}
+			count, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			removed += count
+		}
+		return tx.Commit()
+	})
+	return removed, err
+}
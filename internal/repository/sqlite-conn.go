@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Several instances of the app share one database. Every pooled connection enforces the
+// foreign keys, so deletes cascade to todo_tags, and uses the write-ahead log so readers
+// don't block the writer. A connection waits up to 5 seconds for a lock held by another
+// process, and transactions take the write lock when they begin rather than failing halfway
+// when they upgrade from reading to writing.
+var connectionParams = url.Values{
+	"_pragma": {
+		"busy_timeout(5000)",
+		"foreign_keys(1)",
+		"journal_mode(WAL)",
+	},
+	"_txlock": {"immediate"},
+}
+
+const (
+	// writeAttempts is how often a write is tried when the database stays locked
+	writeAttempts = 5
+	// retryDelay is the wait after the first locked attempt, it doubles on every attempt
+	retryDelay = 50 * time.Millisecond
+)
+
+// OpenSQLiteDB opens the database at path with the connection settings of the repository,
+// without initializing or migrating it
+func OpenSQLiteDB(path string) (*sql.DB, error) {
+	dsn, err := sqliteDSN(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxLifetime(time.Hour)
+	return db, nil
+}
+
+// sqliteDSN returns the file URI of the database at path with the connection settings. The
+// path is escaped, so a ?, # or % in a directory name doesn't end up in the settings.
+func sqliteDSN(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	// A URI path starts with a slash, also before the drive letter on Windows
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs
+	}
+	dsn := url.URL{Scheme: "file", Path: abs, RawQuery: connectionParams.Encode()}
+	return dsn.String(), nil
+}
+
+// retryBusy runs a write, trying again with backoff while another connection holds the lock
+// for longer than the busy timeout
+func retryBusy(write func() error) error {
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		err := write()
+		if err == nil || !isBusy(err) || attempt == writeAttempts {
+			return err
+		}
+
+		// Jitter keeps instances that were blocked together from retrying together
+		time.Sleep(delay + rand.N(delay))
+		delay *= 2
+	}
+}
+
+// isBusy reports whether an error is SQLite's "database is locked"
+func isBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	// Extended result codes keep the primary code in the lowest byte
+	code := sqliteErr.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func countRows(t *testing.T, r *SQLiteTodoRepository, query string, args ...any) int {
+	t.Helper()
+	var count int
+	if err := r.db.QueryRow(query, args...).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestEveryConnectionIsConfigured(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	// Hold several connections at once so the pool has to open new ones
+	var conns []*sql.Conn
+	for range 3 {
+		conn, err := db.db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+
+	for i, conn := range conns {
+		var foreignKeys, busyTimeout int
+		var journalMode string
+		if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode); err != nil {
+			t.Fatal(err)
+		}
+		if foreignKeys != 1 || busyTimeout == 0 || journalMode != "wal" {
+			t.Errorf("connection %d: foreign_keys = %d, busy_timeout = %d, journal_mode = %s", i, foreignKeys, busyTimeout, journalMode)
+		}
+	}
+}
+
+func TestPathIsEscaped(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "what? #1 100%")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "todo.sql")
+	db, err := OpenSQLiteTodoRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("database wasn't created at %s: %v", path, err)
+	}
+	if foreignKeys := countRows(t, db, "PRAGMA foreign_keys"); foreignKeys != 1 {
+		t.Errorf("foreign_keys = %d, the connection settings were lost", foreignKeys)
+	}
+}
+
+func TestDeletesCascadeToTagLinks(t *testing.T) {
+	db := newTestDB(t)
+	first, second := newTestTodo("First"), newTestTodo("Second")
+	if err := db.Create(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(second); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int64{first.ID, second.ID} {
+		if err := db.AddTagToTodo(id, "work"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.AddTagToTodo(id, "home"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.Delete(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if links := countRows(t, db, "SELECT COUNT(*) FROM todo_tags WHERE todo_id = ?", first.ID); links != 0 {
+		t.Errorf("the deleted todo still has %d tag links", links)
+	}
+
+	tags, err := db.GetAllTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range tags {
+		if tag.Name == "work" {
+			if err := db.DeleteTag(tag.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	got, err := db.GetByID(second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Tags) != 1 || got.Tags[0] != "home" {
+		t.Errorf("tags after deleting work = %v, want [home]", got.Tags)
+	}
+	if links := countRows(t, db, "SELECT COUNT(*) FROM todo_tags"); links != 1 {
+		t.Errorf("%d tag links left, want 1", links)
+	}
+}
+
+func TestOrphanedTagLinksAreRemoved(t *testing.T) {
+	db := newTestDB(t)
+	todo := newTestTodo("Tagged")
+	if err := db.Create(todo); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddTagToTodo(todo.ID, "work"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Links left behind while foreign keys weren't enforced
+	ctx := context.Background()
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		"PRAGMA foreign_keys = OFF",
+		"INSERT INTO todo_tags (todo_id, tag_id) VALUES (9999, 1)",
+		fmt.Sprintf("INSERT INTO todo_tags (todo_id, tag_id) VALUES (%d, 9999)", todo.ID),
+		"PRAGMA foreign_keys = ON",
+	} {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn.Close()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := GetAllMigrations()[8].RunSQL(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if links := countRows(t, db, "SELECT COUNT(*) FROM todo_tags"); links != 1 {
+		t.Errorf("%d tag links left, want only the one of the tagged todo", links)
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	// Every writer opens the database itself, like the instances of the app do
+	path := filepath.Join(t.TempDir(), "todo.sql")
+	const writers, todosPerWriter = 4, 25
+
+	repos := make([]*SQLiteTodoRepository, writers)
+	for i := range repos {
+		db, err := OpenSQLiteTodoRepository(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		repos[i] = db
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*todosPerWriter)
+	for w, db := range repos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range todosPerWriter {
+				todo := newTestTodo(fmt.Sprintf("Writer %d todo %d", w, i))
+				if err := db.Create(todo); err != nil {
+					errs <- fmt.Errorf("Create: %w", err)
+					continue
+				}
+				todo.Title += " (edited)"
+				if err := db.Update(todo); err != nil {
+					errs <- fmt.Errorf("Update: %w", err)
+				}
+				// Tagging advances the revision, so it comes after the update of this copy
+				if err := db.AddTagToTodo(todo.ID, fmt.Sprintf("tag-%d", i%3)); err != nil {
+					errs <- fmt.Errorf("AddTagToTodo: %w", err)
+				}
+				if i%5 == 0 {
+					if err := db.Delete(todo.ID); err != nil {
+						errs <- fmt.Errorf("Delete: %w", err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	db := repos[0]
+	expected := writers * (todosPerWriter - todosPerWriter/5)
+	if todos := countRows(t, db, "SELECT COUNT(*) FROM todos"); todos != expected {
+		t.Errorf("%d todos stored, want %d", todos, expected)
+	}
+	if edited := countRows(t, db, "SELECT COUNT(*) FROM todos WHERE title LIKE '%(edited)'"); edited != expected {
+		t.Errorf("%d todos edited, want %d", edited, expected)
+	}
+	if links := countRows(t, db, "SELECT COUNT(*) FROM todo_tags"); links != expected {
+		t.Errorf("%d tag links, want one per todo", links)
+	}
+}
+
+func TestRetryBusy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.sql")
+	db, err := OpenSQLiteTodoRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// A connection that doesn't wait for the lock another one holds
+	holder, err := OpenSQLiteDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Close()
+	tx, err := holder.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	impatient, err := sql.Open("sqlite", path+"?_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer impatient.Close()
+	_, busy := impatient.Begin()
+	if !isBusy(busy) {
+		t.Fatalf("Begin() error = %v, want the database to be locked", busy)
+	}
+
+	attempts := 0
+	err = retryBusy(func() error {
+		attempts++
+		if attempts < 3 {
+			return busy
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("retryBusy() = %v after %d attempts, want success on the third", err, attempts)
+	}
+
+	attempts = 0
+	if err := retryBusy(func() error { attempts++; return busy }); !isBusy(err) || attempts != writeAttempts {
+		t.Errorf("retryBusy() = %v after %d attempts, want the lock error after %d", err, attempts, writeAttempts)
+	}
+
+	other := errors.New("no such table")
+	attempts = 0
+	if err := retryBusy(func() error { attempts++; return other }); err != other || attempts != 1 {
+		t.Errorf("retryBusy() = %v after %d attempts, want other errors returned right away", err, attempts)
+	}
+}
@@ -0,0 +1,377 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/martijnspitter/tui-todo/internal/encryption"
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+// An encrypted database keeps the titles and descriptions of its todos, and the change log
+// that repeats them, sealed with the key of its passphrase. The encryption table holds the
+// salt and a verifier to check the key, it is empty while the database is in plain text.
+// Everything else, tags included, stays readable so the lists can be filtered and ordered
+// in SQL.
+
+// The fields a sealed value belongs to, they are authenticated with the value
+const (
+	titleField       = "todos.title"
+	descriptionField = "todos.description"
+	payloadField     = "change_log.payload"
+)
+
+// ErrLocked is returned for reads and writes of an encrypted database that wasn't unlocked
+var ErrLocked = errors.New("the database is encrypted and hasn't been unlocked")
+
+// ErrNotEncrypted is returned when decrypting a database that is in plain text
+var ErrNotEncrypted = errors.New("the database isn't encrypted")
+
+// ErrAlreadyEncrypted is returned when encrypting a database that is encrypted
+var ErrAlreadyEncrypted = errors.New("the database is already encrypted")
+
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// ReadEncryptionHeader returns the encryption settings of the database, nil when it is in
+// plain text
+func ReadEncryptionHeader(db *sql.DB) (*encryption.Header, error) {
+	return readHeader(db)
+}
+
+func readHeader(q querier) (*encryption.Header, error) {
+	var tables int
+	if err := q.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'encryption'").Scan(&tables); err != nil {
+		return nil, err
+	}
+	if tables == 0 {
+		return nil, nil
+	}
+
+	header := &encryption.Header{}
+	err := q.QueryRow("SELECT salt, kdf_time, kdf_memory, kdf_threads, verifier FROM encryption WHERE id = 1").
+		Scan(&header.Salt, &header.Params.Time, &header.Params.Memory, &header.Params.Threads, &header.Verifier)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// Encrypted reports whether the titles and descriptions are stored encrypted
+func (r *SQLiteTodoRepository) Encrypted() bool {
+	return r.header != nil
+}
+
+// EncryptionHeader returns the encryption settings, nil for a database in plain text
+func (r *SQLiteTodoRepository) EncryptionHeader() *encryption.Header {
+	return r.header
+}
+
+// Unlock checks the key of an encrypted database and uses it from then on. It has to be
+// called before the repository is used.
+func (r *SQLiteTodoRepository) Unlock(key []byte) error {
+	if r.header == nil {
+		return ErrNotEncrypted
+	}
+	c, err := r.header.Check(key)
+	if err != nil {
+		return err
+	}
+	r.cipher = c
+	return nil
+}
+
+// sealText encrypts the value of a field when the database is encrypted
+func (r *SQLiteTodoRepository) sealText(field, value string) (string, error) {
+	if r.header == nil {
+		return value, nil
+	}
+	if r.cipher == nil {
+		return "", ErrLocked
+	}
+	return r.cipher.Seal(field, value), nil
+}
+
+// openText decrypts a value read from the database
+func (r *SQLiteTodoRepository) openText(field, value string) (string, error) {
+	if !encryption.IsSealed(value) {
+		return value, nil
+	}
+	if r.cipher == nil {
+		return "", ErrLocked
+	}
+	return r.cipher.Open(field, value)
+}
+
+// sealTodo returns the title and description of the todo as they are stored
+func (r *SQLiteTodoRepository) sealTodo(todo *models.Todo) (string, string, error) {
+	title, err := r.sealText(titleField, todo.Title)
+	if err != nil {
+		return "", "", err
+	}
+	description, err := r.sealText(descriptionField, todo.Description)
+	if err != nil {
+		return "", "", err
+	}
+	return title, description, nil
+}
+
+// openTodo decrypts the title and description of a todo that was read from the database
+func (r *SQLiteTodoRepository) openTodo(todo *models.Todo) error {
+	var err error
+	if todo.Title, err = r.openText(titleField, todo.Title); err != nil {
+		return fmt.Errorf("todo %d: %w", todo.ID, err)
+	}
+	if todo.Description, err = r.openText(descriptionField, todo.Description); err != nil {
+		return fmt.Errorf("todo %d: %w", todo.ID, err)
+	}
+	return nil
+}
+
+// ===========================================================================
+// Search index
+// ===========================================================================
+
+// textIndex holds the decrypted title and description of every todo, so filters on them
+// work on an encrypted database, where SQL only sees ciphertext. It is brought up to date
+// before it is used by comparing revisions, which also picks up the changes other
+// instances made.
+type textIndex struct {
+	mu      sync.Mutex
+	entries map[int64]indexedText
+}
+
+type indexedText struct {
+	revision    int64
+	title       string
+	description string
+}
+
+// textMatcher returns the filters' text comparisons for compileFilters, nil when they can
+// run in SQL
+func (r *SQLiteTodoRepository) textMatcher(filters []Filter) (textMatcher, error) {
+	if r.header == nil || !slices.ContainsFunc(filters, Filter.comparesText) {
+		return nil, nil
+	}
+	if r.cipher == nil {
+		return nil, ErrLocked
+	}
+	if err := r.index.refresh(r); err != nil {
+		return nil, err
+	}
+	return r.index.matching, nil
+}
+
+// refresh decrypts the todos that were created or changed since the last refresh and drops
+// those that were deleted
+func (x *textIndex) refresh(r *SQLiteTodoRepository) error {
+	rows, err := r.db.Query("SELECT id, revision, title, COALESCE(description, '') FROM todos")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.entries == nil {
+		x.entries = make(map[int64]indexedText)
+	}
+
+	seen := make(map[int64]bool, len(x.entries))
+	for rows.Next() {
+		var id, revision int64
+		var title, description string
+		if err := rows.Scan(&id, &revision, &title, &description); err != nil {
+			return err
+		}
+		seen[id] = true
+		if entry, ok := x.entries[id]; ok && entry.revision == revision {
+			continue
+		}
+
+		todo := &models.Todo{ID: id, Title: title, Description: description}
+		if err := r.openTodo(todo); err != nil {
+			return err
+		}
+		x.entries[id] = indexedText{revision: revision, title: todo.Title, description: todo.Description}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id := range x.entries {
+		if !seen[id] {
+			delete(x.entries, id)
+		}
+	}
+	return nil
+}
+
+// matching returns the IDs of the todos whose title or description matches a comparison
+func (x *textIndex) matching(f Filter) []int64 {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	ids := []int64{}
+	for id, entry := range x.entries {
+		if f.Match(&models.Todo{Title: entry.title, Description: entry.description}) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// ===========================================================================
+// Encrypting and decrypting
+// ===========================================================================
+
+// EncryptDatabase encrypts the titles, descriptions and change log of a database in plain
+// text with a key derived from passphrase. It returns the new settings and the key.
+func EncryptDatabase(db *sql.DB, passphrase string, params encryption.KDFParams) (*encryption.Header, []byte, error) {
+	header, err := ReadEncryptionHeader(db)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header != nil {
+		return nil, nil, ErrAlreadyEncrypted
+	}
+
+	header, key, err := encryption.NewHeader(passphrase, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := encryption.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = rewriteText(db, func(field, value string) (string, error) {
+		if encryption.IsSealed(value) {
+			return value, nil
+		}
+		return c.Seal(field, value), nil
+	}, func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO encryption (id, salt, kdf_time, kdf_memory, kdf_threads, verifier) VALUES (1, ?, ?, ?, ?, ?)",
+			header.Salt, header.Params.Time, header.Params.Memory, header.Params.Threads, header.Verifier)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, key, nil
+}
+
+// DecryptDatabase stores the titles, descriptions and change log of an encrypted database
+// in plain text again and removes the encryption settings
+func DecryptDatabase(db *sql.DB, key []byte) error {
+	header, err := ReadEncryptionHeader(db)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return ErrNotEncrypted
+	}
+	c, err := header.Check(key)
+	if err != nil {
+		return err
+	}
+
+	return rewriteText(db, c.Open, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM encryption")
+		return err
+	})
+}
+
+// rewriteText passes every title, description and change log payload through transform and
+// stores the result, in one transaction with finish. Afterwards the file is rebuilt, so the
+// old values don't linger in free pages or the write-ahead log.
+func rewriteText(db *sql.DB, transform func(field, value string) (string, error), finish func(tx *sql.Tx) error) error {
+	err := retryBusy(func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		type row struct {
+			id     int64
+			values []string
+		}
+		readRows := func(query string) ([]row, error) {
+			rows, err := tx.Query(query)
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+
+			var result []row
+			for rows.Next() {
+				var r row
+				var first, second string
+				if err := rows.Scan(&r.id, &first, &second); err != nil {
+					return nil, err
+				}
+				r.values = []string{first, second}
+				result = append(result, r)
+			}
+			return result, rows.Err()
+		}
+
+		todos, err := readRows("SELECT id, title, COALESCE(description, '') FROM todos")
+		if err != nil {
+			return err
+		}
+		for _, todo := range todos {
+			title, err := transform(titleField, todo.values[0])
+			if err != nil {
+				return fmt.Errorf("todo %d: %w", todo.id, err)
+			}
+			description, err := transform(descriptionField, todo.values[1])
+			if err != nil {
+				return fmt.Errorf("todo %d: %w", todo.id, err)
+			}
+			// The content stays the same, so the revision does too
+			if _, err := tx.Exec("UPDATE todos SET title = ?, description = ? WHERE id = ?", title, description, todo.id); err != nil {
+				return err
+			}
+		}
+
+		records, err := readRows("SELECT seq, payload, '' FROM change_log")
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			payload, err := transform(payloadField, record.values[0])
+			if err != nil {
+				return fmt.Errorf("change %d: %w", record.id, err)
+			}
+			if _, err := tx.Exec("UPDATE change_log SET payload = ? WHERE seq = ?", payload, record.id); err != nil {
+				return err
+			}
+		}
+
+		if err := finish(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("failed to rebuild the database: %w", err)
+	}
+	if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to clear the write-ahead log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/martijnspitter/tui-todo/internal/encryption"
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+// testKDFParams keep the tests fast, real databases use encryption.DefaultKDFParams
+var testKDFParams = encryption.KDFParams{Time: 1, Memory: 64, Threads: 1}
+
+const testPassphrase = "correct horse battery staple"
+
+func newEncryptedTestDB(tb testing.TB) *SQLiteTodoRepository {
+	tb.Helper()
+	db := newTestDB(tb)
+	encryptTestDB(tb, db)
+	return db
+}
+
+// encryptTestDB encrypts the database and unlocks the repository, as reopening it would
+func encryptTestDB(tb testing.TB, db *SQLiteTodoRepository) []byte {
+	tb.Helper()
+	header, key, err := EncryptDatabase(db.db, testPassphrase, testKDFParams)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	db.header = header
+	if err := db.Unlock(key); err != nil {
+		tb.Fatal(err)
+	}
+	return key
+}
+
+// fileContains reports whether the database or its write-ahead log holds text
+func fileContains(t *testing.T, path, text string) bool {
+	t.Helper()
+	for _, file := range []string{path, path + "-wal"} {
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte(text)) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestEncryptAndDecryptDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.sql")
+	db, err := OpenSQLiteTodoRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo := newTestTodo("Performance review of Sam")
+	todo.Description = "Talk about the promotion"
+	if err := db.Create(todo); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AppendChange(&models.ChangeRecord{Key: "todo:1", Payload: []byte(`{"title":"Performance review of Sam"}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	key := encryptTestDB(t, db)
+	db.Close()
+	for _, text := range []string{"Performance review", "promotion"} {
+		if fileContains(t, path, text) {
+			t.Errorf("the encrypted database still contains %q", text)
+		}
+	}
+
+	// A reopened database can't be read or written until it is unlocked
+	db, err = OpenSQLiteTodoRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if !db.Encrypted() {
+		t.Fatal("Encrypted() = false after encrypting")
+	}
+	if _, err := db.GetByID(todo.ID); !errors.Is(err, ErrLocked) {
+		t.Errorf("GetByID() on a locked database: %v, want ErrLocked", err)
+	}
+	if err := db.Create(newTestTodo("Leaked")); !errors.Is(err, ErrLocked) {
+		t.Errorf("Create() on a locked database: %v, want ErrLocked", err)
+	}
+	if err := db.Unlock(make([]byte, encryption.KeySize)); !errors.Is(err, encryption.ErrWrongPassphrase) {
+		t.Errorf("Unlock() with the wrong key: %v", err)
+	}
+	if err := db.Unlock(key); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.GetByID(todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != todo.Title || got.Description != todo.Description {
+		t.Errorf("GetByID() = %q, %q", got.Title, got.Description)
+	}
+	changes, err := db.ChangesSince(0)
+	if err != nil || len(changes) != 1 || !bytes.Contains(changes[0].Payload, []byte("Performance review")) {
+		t.Errorf("ChangesSince() = %v, %v, want the decrypted payload", changes, err)
+	}
+
+	if err := DecryptDatabase(db.db, key); err != nil {
+		t.Fatalf("DecryptDatabase() error: %v", err)
+	}
+	if header, err := ReadEncryptionHeader(db.db); err != nil || header != nil {
+		t.Errorf("ReadEncryptionHeader() after decrypting = %v, %v", header, err)
+	}
+	var title string
+	if err := db.db.QueryRow("SELECT title FROM todos WHERE id = ?", todo.ID).Scan(&title); err != nil || title != todo.Title {
+		t.Errorf("stored title after decrypting = %q, %v", title, err)
+	}
+}
+
+func TestEncryptTwice(t *testing.T) {
+	db := newEncryptedTestDB(t)
+	if _, _, err := EncryptDatabase(db.db, "another passphrase", testKDFParams); !errors.Is(err, ErrAlreadyEncrypted) {
+		t.Errorf("EncryptDatabase() of an encrypted database: %v", err)
+	}
+	if err := DecryptDatabase(newTestDB(t).db, make([]byte, encryption.KeySize)); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("DecryptDatabase() of a plain database: %v", err)
+	}
+}
+
+func TestSearchEncryptedSeesOtherInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.sql")
+	first, err := OpenSQLiteTodoRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	key := encryptTestDB(t, first)
+
+	second, err := OpenSQLiteTodoRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	if err := second.Unlock(key); err != nil {
+		t.Fatal(err)
+	}
+
+	todo := newTestTodo("Rotate the API keys")
+	if err := first.Create(todo); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := second.Search("api KEYS"); err != nil || len(found) != 1 {
+		t.Fatalf("Search() = %v, %v, want the todo created by the other instance", found, err)
+	}
+
+	todo.Title = "Rotate the passwords"
+	if err := first.Update(todo); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := second.Search("api keys"); len(found) != 0 {
+		t.Errorf("Search() still finds the old title: %v", found)
+	}
+	if found, _ := second.GetAll(Not(Where(FieldTitle, OpContains, "password"))); len(found) != 0 {
+		t.Errorf("a negated search found %v", found)
+	}
+
+	if err := first.Delete(todo.ID); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := second.Search("passwords"); len(found) != 0 {
+		t.Errorf("Search() finds a deleted todo: %v", found)
+	}
+}
+
+func TestRollbackRefusesEncryptedDatabase(t *testing.T) {
+	db := newEncryptedTestDB(t)
+	migrations := GetAllMigrations()
+	if _, err := NewMigrationManager(db.db).RollbackTo(migrations, 9); err == nil {
+		t.Fatal("RollbackTo() removed the encryption settings of an encrypted database")
+	}
+	if header, err := ReadEncryptionHeader(db.db); err != nil || header == nil {
+		t.Errorf("encryption settings after the refused rollback: %v, %v", header, err)
+	}
+}
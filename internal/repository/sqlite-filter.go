@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+// columns maps the fields of a filter onto the columns of the todos table, which GetAll
+// selects as t
+var columns = map[Field]string{
+	FieldStatus:      "t.status",
+	FieldPriority:    "t.priority",
+	FieldArchived:    "t.archived",
+	FieldDueDate:     "t.due_date",
+	FieldCreatedAt:   "t.created_at",
+	FieldUpdatedAt:   "t.updated_at",
+	FieldTitle:       "t.title",
+	FieldDescription: "COALESCE(t.description, '')",
+}
+
+// textMatcher returns the IDs of the todos a comparison of the title or description
+// matches, for a database where those columns are encrypted
+type textMatcher func(f Filter) []int64
+
+// compileFilters turns the filters into a WHERE clause without the WHERE and its arguments.
+// Text comparisons are left to text when it is set.
+func compileFilters(filters []Filter, text textMatcher) (string, []any, error) {
+	filter := And(filters...)
+	if err := filter.Validate(); err != nil {
+		return "", nil, err
+	}
+
+	var args []any
+	clause := filter.compile(startOfToday(), &args, text)
+	return clause, args, nil
+}
+
+// comparesText reports whether the filter compares the title or description
+func (f Filter) comparesText() bool {
+	if f.kind != kindCompare {
+		return slices.ContainsFunc(f.operands, Filter.comparesText)
+	}
+	return f.field == FieldTitle || f.field == FieldDescription
+}
+
+// compile writes the filter as SQL that selects the same todos match does. Day values are
+// resolved against today.
+func (f Filter) compile(today time.Time, args *[]any, text textMatcher) string {
+	switch f.kind {
+	case kindAnd, kindOr:
+		if len(f.operands) == 0 {
+			if f.kind == kindAnd {
+				return "1 = 1"
+			}
+			return "1 = 0"
+		}
+		joiner := " AND "
+		if f.kind == kindOr {
+			joiner = " OR "
+		}
+		clauses := make([]string, len(f.operands))
+		for i, operand := range f.operands {
+			clauses[i] = operand.compile(today, args, text)
+		}
+		return "(" + strings.Join(clauses, joiner) + ")"
+	case kindNot:
+		return "NOT " + f.operands[0].compile(today, args, text)
+	}
+
+	if f.field == FieldTag {
+		*args = append(*args, sqlText(f.op, f.value.(string)))
+		return "EXISTS (SELECT 1 FROM todo_tags ftt JOIN tags ftag ON ftag.id = ftt.tag_id WHERE ftt.todo_id = t.id AND " +
+			sqlComparison("ftag.name", f.op) + ")"
+	}
+
+	column := columns[f.field]
+	switch f.field {
+	case FieldDueDate:
+		// A todo without a due date matches no comparison, also not after NOT
+		if f.op == OpIsSet {
+			return "(" + column + " IS NOT NULL)"
+		}
+		*args = append(*args, dbTime(f.timeValue(today)))
+		return "(" + column + " IS NOT NULL AND " + sqlComparison(column, f.op) + ")"
+	case FieldCreatedAt, FieldUpdatedAt:
+		*args = append(*args, dbTime(f.timeValue(today)))
+	case FieldArchived:
+		archived := 0
+		if f.value.(bool) {
+			archived = 1
+		}
+		*args = append(*args, archived)
+	case FieldTitle, FieldDescription:
+		if text != nil {
+			// SQL only sees ciphertext, the index knows which todos match
+			ids, _ := json.Marshal(text(f))
+			*args = append(*args, string(ids))
+			return "(t.id IN (SELECT value FROM json_each(?)))"
+		}
+		*args = append(*args, sqlText(f.op, f.value.(string)))
+	default:
+		*args = append(*args, f.value)
+	}
+	return "(" + sqlComparison(column, f.op) + ")"
+}
+
+// sqlComparison compares the column with the next argument
+func sqlComparison(column string, op Operator) string {
+	if op == OpContains {
+		return column + ` LIKE ? ESCAPE '\'`
+	}
+	return column + " " + string(op) + " ?"
+}
+
+// sqlText returns the argument for a text comparison, for OpContains a LIKE pattern in which
+// the wildcards of the value match themselves
+func sqlText(op Operator, value string) string {
+	if op != OpContains {
+		return value
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return "%" + escaper.Replace(value) + "%"
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -60,6 +61,9 @@ func GetAllMigrations() []Migration {
 
 				return nil
 			},
+			Rollback: func(tx *sql.Tx) error {
+				return dropColumns(tx, "todos", "time_spent", "time_started")
+			},
 		},
 		{
 			ID:   3,
@@ -135,6 +139,309 @@ func GetAllMigrations() []Migration {
 
 				return nil
 			},
+			Rollback: func(tx *sql.Tx) error {
+				return dropColumns(tx, "tags", "description", "created_at", "updated_at")
+			},
+		},
+		{
+			ID:   4,
+			Name: "Add uid columns for replication",
+			RunSQL: func(tx *sql.Tx) error {
+				for _, table := range []string{"todos", "tags"} {
+					var uidExists int
+					err := tx.QueryRow(`
+                        SELECT COUNT(*) FROM pragma_table_info(?)
+                        WHERE name = 'uid'
+                    `, table).Scan(&uidExists)
+					if err != nil {
+						return fmt.Errorf("failed to check for uid column on %s: %w", table, err)
+					}
+
+					if uidExists == 0 {
+						_, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN uid TEXT`, table))
+						if err != nil {
+							return fmt.Errorf("failed to add uid column to %s: %w", table, err)
+						}
+					}
+
+					// Give every existing row its own identity
+					_, err = tx.Exec(fmt.Sprintf(`UPDATE %s SET uid = lower(hex(randomblob(16))) WHERE uid IS NULL`, table))
+					if err != nil {
+						return fmt.Errorf("failed to set uid values on %s: %w", table, err)
+					}
+
+					_, err = tx.Exec(fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS idx_%s_uid ON %s(uid)`, table, table))
+					if err != nil {
+						return fmt.Errorf("failed to create uid index on %s: %w", table, err)
+					}
+				}
+
+				return nil
+			},
+			Rollback: func(tx *sql.Tx) error {
+				for _, table := range []string{"todos", "tags"} {
+					// An indexed column can't be dropped
+					if _, err := tx.Exec(fmt.Sprintf(`DROP INDEX IF EXISTS idx_%s_uid`, table)); err != nil {
+						return fmt.Errorf("failed to drop uid index on %s: %w", table, err)
+					}
+					if err := dropColumns(tx, table, "uid"); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID:   5,
+			Name: "Add revision column for conflict detection",
+			RunSQL: func(tx *sql.Tx) error {
+				var revisionExists int
+				err := tx.QueryRow(`
+                    SELECT COUNT(*) FROM pragma_table_info('todos')
+                    WHERE name = 'revision'
+                `).Scan(&revisionExists)
+				if err != nil {
+					return fmt.Errorf("failed to check for revision column: %w", err)
+				}
+
+				if revisionExists == 0 {
+					_, err := tx.Exec(`ALTER TABLE todos ADD COLUMN revision INTEGER NOT NULL DEFAULT 0`)
+					if err != nil {
+						return fmt.Errorf("failed to add revision column: %w", err)
+					}
+				}
+
+				return nil
+			},
+			Rollback: func(tx *sql.Tx) error {
+				return dropColumns(tx, "todos", "revision")
+			},
+		},
+		{
+			ID:   6,
+			Name: "Create change log for replaying missed changes",
+			RunSQL: func(tx *sql.Tx) error {
+				_, err := tx.Exec(`
+                    CREATE TABLE IF NOT EXISTS change_log (
+                        seq INTEGER PRIMARY KEY AUTOINCREMENT,
+                        entity_key TEXT NOT NULL,
+                        deleted BOOLEAN NOT NULL DEFAULT 0,
+                        payload TEXT NOT NULL,
+                        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+                    )
+                `)
+				if err != nil {
+					return fmt.Errorf("failed to create change_log table: %w", err)
+				}
+
+				_, err = tx.Exec(`CREATE INDEX IF NOT EXISTS idx_change_log_entity_key ON change_log(entity_key)`)
+				if err != nil {
+					return fmt.Errorf("failed to create change_log index: %w", err)
+				}
+
+				return nil
+			},
+			Rollback: func(tx *sql.Tx) error {
+				if _, err := tx.Exec(`DROP TABLE IF EXISTS change_log`); err != nil {
+					return fmt.Errorf("failed to drop change_log table: %w", err)
+				}
+				return nil
+			},
 		},
+		{
+			ID:   7,
+			Name: "Strip monotonic clock readings from timestamps",
+			RunSQL: func(tx *sql.Tx) error {
+				// Times taken with time.Now() were stored with a suffix like " m=+0.010547299",
+				// which keeps them from comparing equal to the same time read back
+				columns := map[string][]string{
+					"todos": {"created_at", "updated_at", "due_date", "time_started"},
+					"tags":  {"created_at", "updated_at"},
+				}
+				for table, names := range columns {
+					for _, column := range names {
+						_, err := tx.Exec(fmt.Sprintf(
+							`UPDATE %[1]s SET %[2]s = substr(%[2]s, 1, instr(%[2]s, ' m=') - 1) WHERE instr(%[2]s, ' m=') > 0`,
+							table, column))
+						if err != nil {
+							return fmt.Errorf("failed to strip %s.%s: %w", table, column, err)
+						}
+					}
+				}
+
+				return nil
+			},
+			Rollback: func(tx *sql.Tx) error {
+				// Older versions read the timestamps without clock readings just as well
+				return nil
+			},
+		},
+		{
+			ID:   8,
+			Name: "Index the columns lists filter and page on",
+			RunSQL: func(tx *sql.Tx) error {
+				indexes := []string{
+					`CREATE INDEX IF NOT EXISTS idx_todos_status ON todos(status)`,
+					`CREATE INDEX IF NOT EXISTS idx_todos_due_date ON todos(due_date)`,
+					`CREATE INDEX IF NOT EXISTS idx_todos_archived ON todos(archived)`,
+					`CREATE INDEX IF NOT EXISTS idx_todos_updated_at ON todos(updated_at)`,
+					// The order of the panes, so a page is read without sorting the table
+					`CREATE INDEX IF NOT EXISTS idx_todos_list_order ON todos(archived, priority DESC, updated_at DESC, id DESC)`,
+					`CREATE INDEX IF NOT EXISTS idx_todos_status_list_order ON todos(status, archived, priority DESC, updated_at DESC, id DESC)`,
+				}
+				for _, index := range indexes {
+					if _, err := tx.Exec(index); err != nil {
+						return fmt.Errorf("failed to create index: %w", err)
+					}
+				}
+
+				return nil
+			},
+			Rollback: func(tx *sql.Tx) error {
+				indexes := []string{
+					"idx_todos_status", "idx_todos_due_date", "idx_todos_archived", "idx_todos_updated_at",
+					"idx_todos_list_order", "idx_todos_status_list_order",
+				}
+				for _, index := range indexes {
+					if _, err := tx.Exec(`DROP INDEX IF EXISTS ` + index); err != nil {
+						return fmt.Errorf("failed to drop index %s: %w", index, err)
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			ID:   9,
+			Name: "Remove tag links of deleted todos and tags",
+			RunSQL: func(tx *sql.Tx) error {
+				// Foreign keys weren't enforced before, so deletes didn't cascade to todo_tags
+				_, err := tx.Exec(`
+                    DELETE FROM todo_tags
+                    WHERE todo_id NOT IN (SELECT id FROM todos) OR tag_id NOT IN (SELECT id FROM tags)
+                `)
+				if err != nil {
+					return fmt.Errorf("failed to remove orphaned tag links: %w", err)
+				}
+
+				return nil
+			},
+			Rollback: func(tx *sql.Tx) error {
+				// The removed links pointed nowhere, there is nothing to restore
+				return nil
+			},
+		},
+		{
+			ID:   10,
+			Name: "Create encryption settings",
+			RunSQL: func(tx *sql.Tx) error {
+				// A row is only stored while the database is encrypted
+				_, err := tx.Exec(`
+                    CREATE TABLE IF NOT EXISTS encryption (
+                        id INTEGER PRIMARY KEY CHECK (id = 1),
+                        salt BLOB NOT NULL,
+                        kdf_time INTEGER NOT NULL,
+                        kdf_memory INTEGER NOT NULL,
+                        kdf_threads INTEGER NOT NULL,
+                        verifier TEXT NOT NULL
+                    )
+                `)
+				if err != nil {
+					return fmt.Errorf("failed to create encryption table: %w", err)
+				}
+
+				return nil
+			},
+			Rollback: func(tx *sql.Tx) error {
+				var encrypted int
+				if err := tx.QueryRow("SELECT COUNT(*) FROM encryption").Scan(&encrypted); err != nil {
+					return fmt.Errorf("failed to read encryption settings: %w", err)
+				}
+				// Older versions would show the ciphertext and write plain text next to it
+				if encrypted > 0 {
+					return errors.New("the database is encrypted, run `todo db decrypt` first")
+				}
+
+				if _, err := tx.Exec("DROP TABLE encryption"); err != nil {
+					return fmt.Errorf("failed to drop encryption table: %w", err)
+				}
+				return nil
+			},
+		},
+		{
+			ID:   11,
+			Name: "Store timestamps in UTC",
+			RunSQL: func(tx *sql.Tx) error {
+				// Timestamps are stored as text with the offset they were taken in, which
+				// sorts by wall clock instead of instant across a DST switch or replicas
+				// from another time zone
+				return rewriteTimes(tx, dbTime)
+			},
+			Rollback: func(tx *sql.Tx) error {
+				// Older versions show the stored time as it is, in UTC it would be hours off
+				return rewriteTimes(tx, func(t time.Time) time.Time { return t.Local() })
+			},
+		},
+	}
+}
+
+// rewriteTimes passes every timestamp through convert
+func rewriteTimes(tx *sql.Tx, convert func(time.Time) time.Time) error {
+	columns := []struct {
+		table, key string
+		names      []string
+	}{
+		{"todos", "id", []string{"created_at", "updated_at", "due_date", "time_started"}},
+		{"tags", "id", []string{"created_at", "updated_at"}},
+		{"change_log", "seq", []string{"created_at"}},
+	}
+	for _, table := range columns {
+		for _, column := range table.names {
+			rows, err := tx.Query(fmt.Sprintf(`SELECT %s, %s FROM %s WHERE %[2]s IS NOT NULL`, table.key, column, table.table))
+			if err != nil {
+				return fmt.Errorf("failed to read %s.%s: %w", table.table, column, err)
+			}
+			values := make(map[int64]time.Time)
+			for rows.Next() {
+				var key int64
+				var value time.Time
+				if err := rows.Scan(&key, &value); err != nil {
+					rows.Close()
+					return fmt.Errorf("failed to read %s.%s: %w", table.table, column, err)
+				}
+				values[key] = value
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("failed to read %s.%s: %w", table.table, column, err)
+			}
+
+			for key, value := range values {
+				_, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = ? WHERE %s = ?`, table.table, column, table.key), convert(value), key)
+				if err != nil {
+					return fmt.Errorf("failed to rewrite %s.%s: %w", table.table, column, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dropColumns removes the columns a migration added, those that don't exist are skipped
+func dropColumns(tx *sql.Tx, table string, columns ...string) error {
+	for _, column := range columns {
+		var exists int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check for %s column: %w", column, err)
+		}
+		if exists == 0 {
+			continue
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s`, table, column)); err != nil {
+			return fmt.Errorf("failed to drop %s column from %s: %w", column, table, err)
+		}
 	}
+	return nil
 }
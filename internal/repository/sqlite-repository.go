@@ -1,35 +1,40 @@
 package repository
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
-	"sort"
 	"strings"
 	"time"
 
 	"slices"
 
+	"github.com/martijnspitter/tui-todo/internal/encryption"
 	"github.com/martijnspitter/tui-todo/internal/models"
 	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
-	_ "modernc.org/sqlite"
 )
 
 type SQLiteTodoRepository struct {
 	db *sql.DB
+
+	// header is set for an encrypted database, cipher once it was unlocked
+	header *encryption.Header
+	cipher *encryption.Cipher
+	index  textIndex
 }
 
 func NewSQLiteTodoRepository(version string) (*SQLiteTodoRepository, error) {
-	path := osoperations.GetFilePath("todo.sql", version)
+	return OpenSQLiteTodoRepository(osoperations.GetFilePath("todo.sql", version))
+}
 
-	db, err := sql.Open("sqlite", path)
+// OpenSQLiteTodoRepository opens the database at path, e.g. that of another workspace
+func OpenSQLiteTodoRepository(path string) (*SQLiteTodoRepository, error) {
+	db, err := OpenSQLiteDB(path)
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(time.Hour)
-
 	// Initialize database schema if needed
 	if err := initSchema(db); err != nil {
 		return nil, err
@@ -44,7 +49,12 @@ func NewSQLiteTodoRepository(version string) (*SQLiteTodoRepository, error) {
 		return nil, fmt.Errorf("couldn't apply migrations: %w", err)
 	}
 
-	return &SQLiteTodoRepository{db: db}, nil
+	header, err := ReadEncryptionHeader(db)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read encryption settings: %w", err)
+	}
+
+	return &SQLiteTodoRepository{db: db, header: header}, nil
 }
 
 func (r *SQLiteTodoRepository) Close() error {
@@ -52,46 +62,57 @@ func (r *SQLiteTodoRepository) Close() error {
 }
 
 func (r *SQLiteTodoRepository) Create(todo *models.Todo) error {
-	// Implementation with SQL
-	stmt, err := r.db.Prepare(`
-        INSERT INTO todos (title, description, status, created_at, updated_at, priority, due_date, archived, time_spent, time_started)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-    `)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	result, err := stmt.Exec(
-		todo.Title,
-		todo.Description,
-		todo.Status,
-		todo.CreatedAt,
-		todo.UpdatedAt,
-		todo.Priority,
-		todo.DueDate,
-		todo.Archived,
-		todo.TimeSpent,
-		todo.TimeStarted,
-	)
-	if err != nil {
-		return err
-	}
+	return retryBusy(func() error {
+		if todo.UID == "" {
+			todo.UID = newUID()
+		}
+		title, description, err := r.sealTodo(todo)
+		if err != nil {
+			return err
+		}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return err
-	}
+		// Implementation with SQL
+		stmt, err := r.db.Prepare(`
+	        INSERT INTO todos (uid, title, description, status, created_at, updated_at, priority, due_date, archived, time_spent, time_started)
+	        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	    `)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		result, err := stmt.Exec(
+			todo.UID,
+			title,
+			description,
+			todo.Status,
+			dbTime(todo.CreatedAt),
+			dbTime(todo.UpdatedAt),
+			todo.Priority,
+			dbTimePtr(todo.DueDate),
+			todo.Archived,
+			todo.TimeSpent,
+			dbTimePtr(todo.TimeStarted),
+		)
+		if err != nil {
+			return err
+		}
 
-	todo.ID = id
-	return nil
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		todo.ID = id
+		return nil
+	})
 }
 
 func (r *SQLiteTodoRepository) GetByID(id int64) (*models.Todo, error) {
 	// Query to get todo with its tags in a single operation
 	rows, err := r.db.Query(`
-        SELECT t.id, t.title, t.description, t.status, t.created_at, t.updated_at,
-               t.due_date, t.priority, t.archived, tag.name as tag_name, t.time_spent, t.time_started
+        SELECT t.id, t.uid, t.title, t.description, t.status, t.created_at, t.updated_at,
+               t.due_date, t.priority, t.archived, tag.name as tag_name, t.time_spent, t.time_started, t.revision
         FROM todos t
         LEFT JOIN todo_tags tt ON t.id = tt.todo_id
         LEFT JOIN tags tag ON tt.tag_id = tag.id
@@ -110,6 +131,7 @@ func (r *SQLiteTodoRepository) GetByID(id int64) (*models.Todo, error) {
 	// Process result rows
 	for rows.Next() {
 		var todoID int64
+		var uid sql.NullString
 		var title, description string
 		var status models.Status
 		var createdAt, updatedAt time.Time
@@ -119,10 +141,12 @@ func (r *SQLiteTodoRepository) GetByID(id int64) (*models.Todo, error) {
 		var archived bool
 		var timeSpent int64
 		var timeStarted sql.NullTime
+		var revision int64
 
 		// Scan row data
 		if err := rows.Scan(
 			&todoID,
+			&uid,
 			&title,
 			&description,
 			&status,
@@ -134,6 +158,7 @@ func (r *SQLiteTodoRepository) GetByID(id int64) (*models.Todo, error) {
 			&tagName,
 			&timeSpent,
 			&timeStarted,
+			&revision,
 		); err != nil {
 			return nil, err
 		}
@@ -142,6 +167,7 @@ func (r *SQLiteTodoRepository) GetByID(id int64) (*models.Todo, error) {
 		if !foundTodo {
 			todo = &models.Todo{
 				ID:          todoID,
+				UID:         uid.String,
 				Title:       title,
 				Description: description,
 				Status:      status,
@@ -151,6 +177,7 @@ func (r *SQLiteTodoRepository) GetByID(id int64) (*models.Todo, error) {
 				Tags:        []string{},
 				Archived:    archived,
 				TimeSpent:   timeSpent,
+				Revision:    revision,
 			}
 
 			if dueDate.Valid {
@@ -185,181 +212,196 @@ func (r *SQLiteTodoRepository) GetByID(id int64) (*models.Todo, error) {
 		return nil, fmt.Errorf("todo with id %d not found", id)
 	}
 
+	localTimes(todo)
+	if err := r.openTodo(todo); err != nil {
+		return nil, err
+	}
 	return todo, nil
 }
 
-func (r *SQLiteTodoRepository) GetAll(filters ...Filter) ([]*models.Todo, error) {
-	// Base query with joins to fetch todos and their tags
-	query := `
-     SELECT t.id, t.title, t.description, t.status, t.created_at, t.updated_at,
-            t.due_date, t.priority, t.archived, tag.name as tag_name, t.time_spent, t.time_started
+// todoColumns selects a todo as scanTodos reads it. The tags are aggregated per todo, so
+// every todo is one row and the order of the query is kept.
+const todoColumns = `
+     SELECT t.id, t.uid, t.title, t.description, t.status, t.created_at, t.updated_at,
+            t.due_date, t.priority, t.archived, t.time_spent, t.time_started, t.revision,
+            (SELECT GROUP_CONCAT(tag.name, char(31))
+               FROM todo_tags tt JOIN tags tag ON tag.id = tt.tag_id
+              WHERE tt.todo_id = t.id) AS tag_names
      FROM todos t
-     LEFT JOIN todo_tags tt ON t.id = tt.todo_id
-     LEFT JOIN tags tag ON tt.tag_id = tag.id
- `
-
-	// Apply any filters
-	args := []any{}
-	if len(filters) > 0 {
-		query += " WHERE "
-		filterClauses := []string{}
-
-		for _, filter := range filters {
-			clause, filterArgs := filter()
-			if clause != "" {
-				filterClauses = append(filterClauses, clause)
-				args = append(args, filterArgs...)
-			}
-		}
+`
+
+// tagSeparator separates the tag names of tag_names, a character tags don't contain
+const tagSeparator = "\x1f"
+
+func (r *SQLiteTodoRepository) GetAll(filters ...Filter) ([]*models.Todo, error) {
+	text, err := r.textMatcher(filters)
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := compileFilters(filters, text)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.queryTodos(todoColumns+" WHERE "+where+" ORDER BY t.created_at DESC, t.id DESC", args...)
+}
 
-		query += strings.Join(filterClauses, " AND ")
+// GetPage returns the page of the list after the cursor, see Pager
+func (r *SQLiteTodoRepository) GetPage(after *Cursor, limit int, filters ...Filter) ([]*models.Todo, bool, error) {
+	text, err := r.textMatcher(filters)
+	if err != nil {
+		return nil, false, err
+	}
+	where, args, err := compileFilters(filters, text)
+	if err != nil {
+		return nil, false, err
+	}
+
+	query := todoColumns + " WHERE " + where
+	if after != nil {
+		query += " AND (t.priority, t.updated_at, t.id) < (?, ?, ?)"
+		args = append(args, after.Priority, dbTime(after.UpdatedAt), after.ID)
+	}
+	query += " ORDER BY t.priority DESC, t.updated_at DESC, t.id DESC"
+	if limit > 0 {
+		// One more than the page tells whether todos follow it
+		query += " LIMIT ?"
+		args = append(args, limit+1)
 	}
 
-	// Add ordering
-	query += " ORDER BY t.created_at DESC"
+	todos, err := r.queryTodos(query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	if limit > 0 && len(todos) > limit {
+		return todos[:limit], true, nil
+	}
+	return todos, false, nil
+}
 
-	// Execute the query
+// queryTodos runs a query that selects todoColumns
+func (r *SQLiteTodoRepository) queryTodos(query string, args ...any) ([]*models.Todo, error) {
 	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	// Map to store todos by ID to avoid duplicates
-	todosMap := make(map[int64]*models.Todo)
-
-	// Iterate through the result set
+	todos := []*models.Todo{}
 	for rows.Next() {
-		var todoID int64
-		var title, description string
-		var status models.Status
-		var createdAt, updatedAt time.Time
-		var dueDate sql.NullTime
-		var priority models.Priority
-		var tagName sql.NullString
-		var archived bool
-		var timeSpent int64
-		var timeStarted sql.NullTime
+		var uid, tagNames sql.NullString
+		var dueDate, timeStarted sql.NullTime
+		todo := &models.Todo{Tags: []string{}}
 
-		// Scan the row
 		if err := rows.Scan(
-			&todoID,
-			&title,
-			&description,
-			&status,
-			&createdAt,
-			&updatedAt,
+			&todo.ID,
+			&uid,
+			&todo.Title,
+			&todo.Description,
+			&todo.Status,
+			&todo.CreatedAt,
+			&todo.UpdatedAt,
 			&dueDate,
-			&priority,
-			&archived,
-			&tagName,
-			&timeSpent,
+			&todo.Priority,
+			&todo.Archived,
+			&todo.TimeSpent,
 			&timeStarted,
+			&todo.Revision,
+			&tagNames,
 		); err != nil {
 			return nil, err
 		}
 
-		// Get or create todo in the map
-		todo, exists := todosMap[todoID]
-		if !exists {
-			todo = &models.Todo{
-				ID:          todoID,
-				Title:       title,
-				Description: description,
-				Status:      status,
-				CreatedAt:   createdAt,
-				UpdatedAt:   updatedAt,
-				Priority:    priority,
-				Archived:    archived,
-				Tags:        []string{},
-				TimeSpent:   timeSpent,
-			}
+		todo.UID = uid.String
+		if dueDate.Valid {
+			todo.DueDate = &dueDate.Time
+		}
+		if timeStarted.Valid {
+			todo.TimeStarted = &timeStarted.Time
+		}
+		if tagNames.String != "" {
+			todo.Tags = strings.Split(tagNames.String, tagSeparator)
+		}
+		localTimes(todo)
+		if err := r.openTodo(todo); err != nil {
+			return nil, err
+		}
 
-			if dueDate.Valid {
-				todo.DueDate = &dueDate.Time
-			}
+		todos = append(todos, todo)
+	}
 
-			if timeStarted.Valid {
-				todo.TimeStarted = &timeStarted.Time
-			}
+	return todos, rows.Err()
+}
 
-			todosMap[todoID] = todo
+// Update stores the todo if it wasn't changed since it was read, otherwise it returns a
+// ConflictError. On success the todo's revision is advanced.
+func (r *SQLiteTodoRepository) Update(todo *models.Todo) error {
+	return retryBusy(func() error {
+		title, description, err := r.sealTodo(todo)
+		if err != nil {
+			return err
 		}
 
-		// Add tag if present
-		if tagName.Valid && tagName.String != "" {
-			// Check if tag is already in the slice to avoid duplicates
-			tagExists := slices.Contains(todo.Tags, tagName.String)
-
-			if !tagExists {
-				todo.Tags = append(todo.Tags, tagName.String)
-			}
+		stmt, err := r.db.Prepare(`
+	        UPDATE todos
+	        SET title = ?, description = ?, status = ?, updated_at = ?, due_date = ?, priority = ?, archived = ?,
+	            time_spent = ?, time_started = ?, revision = revision + 1
+	        WHERE id = ? AND revision = ?
+	    `)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		result, err := stmt.Exec(
+			title,
+			description,
+			todo.Status,
+			dbTime(time.Now()), // Update the updated_at time
+			dbTimePtr(todo.DueDate),
+			todo.Priority,
+			todo.Archived,
+			todo.TimeSpent,
+			dbTimePtr(todo.TimeStarted),
+			todo.ID,
+			todo.Revision,
+		)
+		if err != nil {
+			return err
 		}
-	}
-
-	// Check for errors from iterating over rows
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
 
-	// Convert map to slice
-	todos := make([]*models.Todo, 0, len(todosMap))
-	for _, todo := range todosMap {
-		todos = append(todos, todo)
-	}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return r.conflict(todo)
+		}
 
-	// Sort by created_at to maintain order
-	sort.Slice(todos, func(i, j int) bool {
-		return todos[i].CreatedAt.After(todos[j].CreatedAt)
+		todo.Revision++
+		return nil
 	})
-
-	return todos, nil
 }
 
-func (r *SQLiteTodoRepository) Update(todo *models.Todo) error {
-	stmt, err := r.db.Prepare(`
-        UPDATE todos
-        SET title = ?, description = ?, status = ?, updated_at = ?, due_date = ?, priority = ?, archived = ?,
-            time_spent = ?, time_started = ?
-        WHERE id = ?
-    `)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+// conflict explains why an update matched no row
+func (r *SQLiteTodoRepository) conflict(todo *models.Todo) error {
+	conflict := &ConflictError{ID: todo.ID, Revision: todo.Revision}
 
-	var dueDate any
-	if todo.DueDate != nil {
-		dueDate = *todo.DueDate
-	} else {
-		dueDate = nil
+	err := r.db.QueryRow("SELECT revision FROM todos WHERE id = ?", todo.ID).Scan(&conflict.Current)
+	if err == sql.ErrNoRows {
+		conflict.Deleted = true
+	} else if err != nil {
+		return err
 	}
 
-	var timeStarted any
-	if todo.TimeStarted != nil {
-		timeStarted = *todo.TimeStarted
-	} else {
-		timeStarted = nil
-	}
-
-	_, err = stmt.Exec(
-		todo.Title,
-		todo.Description,
-		todo.Status,
-		time.Now(), // Update the updated_at time
-		dueDate,
-		todo.Priority,
-		todo.Archived,
-		todo.TimeSpent,
-		timeStarted,
-		todo.ID,
-	)
-	return err
+	return conflict
 }
 
 func (r *SQLiteTodoRepository) Delete(id int64) error {
-	_, err := r.db.Exec("DELETE FROM todos WHERE id = ?", id)
-	return err
+	return retryBusy(func() error {
+		_, err := r.db.Exec("DELETE FROM todos WHERE id = ?", id)
+		return err
+	})
 }
 
 func (r *SQLiteTodoRepository) GetOpen() ([]*models.Todo, error) {
@@ -391,61 +433,70 @@ func (r *SQLiteTodoRepository) Search(query string) ([]*models.Todo, error) {
 }
 
 func (r *SQLiteTodoRepository) AddTagToTodo(todoID int64, tagName string) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// Get or create tag
-	var tagID int64
-	err = tx.QueryRow("SELECT id FROM tags WHERE name = ?", tagName).Scan(&tagID)
-	if err == sql.ErrNoRows {
-		// Tag doesn't exist, create it
-		result, err := tx.Exec("INSERT INTO tags (name) VALUES (?)", tagName)
+	return retryBusy(func() error {
+		tx, err := r.db.Begin()
 		if err != nil {
 			return err
 		}
-		tagID, err = result.LastInsertId()
+		defer tx.Rollback()
+
+		tagID, err := getOrCreateTag(tx, tagName)
 		if err != nil {
 			return err
 		}
-	} else if err != nil {
-		return err
-	}
 
-	// Add relationship (ignore if already exists)
-	_, err = tx.Exec(
-		"INSERT OR IGNORE INTO todo_tags (todo_id, tag_id) VALUES (?, ?)",
-		todoID, tagID)
-	if err != nil {
-		return err
-	}
+		// Add relationship (ignore if already exists)
+		result, err := tx.Exec(
+			"INSERT OR IGNORE INTO todo_tags (todo_id, tag_id) VALUES (?, ?)",
+			todoID, tagID)
+		if err != nil {
+			return err
+		}
+		if err := bumpRevision(tx, todoID, result); err != nil {
+			return err
+		}
 
-	return tx.Commit()
+		return tx.Commit()
+	})
 }
 
 func (r *SQLiteTodoRepository) RemoveTagFromTodo(todoID int64, tagName string) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+	return retryBusy(func() error {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
 
-	var tagID int64
-	err = tx.QueryRow("SELECT id FROM tags WHERE name = ?", tagName).Scan(&tagID)
-	if err != nil {
-		return err
-	}
+		var tagID int64
+		err = tx.QueryRow("SELECT id FROM tags WHERE name = ?", tagName).Scan(&tagID)
+		if err != nil {
+			return err
+		}
 
-	_, err = tx.Exec(
-		"DELETE FROM todo_tags WHERE todo_id = ? AND tag_id = ?",
-		todoID, tagID)
-	if err != nil {
+		result, err := tx.Exec(
+			"DELETE FROM todo_tags WHERE todo_id = ? AND tag_id = ?",
+			todoID, tagID)
+		if err != nil {
+			return err
+		}
+		if err := bumpRevision(tx, todoID, result); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// bumpRevision advances the revision of a todo whose tags changed, so an update made from a
+// copy read before the change is a conflict
+func bumpRevision(tx *sql.Tx, todoID int64, result sql.Result) error {
+	changed, err := result.RowsAffected()
+	if err != nil || changed == 0 {
 		return err
 	}
-
-	return tx.Commit()
+	_, err = tx.Exec("UPDATE todos SET revision = revision + 1 WHERE id = ?", todoID)
+	return err
 }
 
 func (r *SQLiteTodoRepository) GetTodoTags(todoID int64) ([]string, error) {
@@ -480,8 +531,8 @@ func (r *SQLiteTodoRepository) GetTodoTags(todoID int64) ([]string, error) {
 // FindTodosByTag returns todos with the specified tag
 func (r *SQLiteTodoRepository) FindTodosByTag(tagName string) ([]*models.Todo, error) {
 	rows, err := r.db.Query(`
-        SELECT t.id, t.title, t.description, t.status, t.created_at, t.updated_at, t.due_date, t.priority, t.archived,
-               t.time_spent, t.time_started
+        SELECT t.id, t.uid, t.title, t.description, t.status, t.created_at, t.updated_at, t.due_date, t.priority, t.archived,
+               t.time_spent, t.time_started, t.revision
         FROM todos t
         JOIN todo_tags tt ON t.id = tt.todo_id
         JOIN tags tag ON tt.tag_id = tag.id
@@ -495,11 +546,13 @@ func (r *SQLiteTodoRepository) FindTodosByTag(tagName string) ([]*models.Todo, e
 	var todos []*models.Todo
 	for rows.Next() {
 		todo := &models.Todo{}
+		var uid sql.NullString
 		var dueDate sql.NullTime
 		var timeStarted sql.NullTime
 
 		if err := rows.Scan(
 			&todo.ID,
+			&uid,
 			&todo.Title,
 			&todo.Description,
 			&todo.Status,
@@ -510,10 +563,12 @@ func (r *SQLiteTodoRepository) FindTodosByTag(tagName string) ([]*models.Todo, e
 			&todo.Archived,
 			&todo.TimeSpent,
 			&timeStarted,
+			&todo.Revision,
 		); err != nil {
 			return nil, err
 		}
 
+		todo.UID = uid.String
 		if dueDate.Valid {
 			todo.DueDate = &dueDate.Time
 		}
@@ -522,6 +577,11 @@ func (r *SQLiteTodoRepository) FindTodosByTag(tagName string) ([]*models.Todo, e
 			todo.TimeStarted = &timeStarted.Time
 		}
 
+		localTimes(todo)
+		if err := r.openTodo(todo); err != nil {
+			return nil, err
+		}
+
 		// Get tags for this todo
 		tags, err := r.GetTodoTags(todo.ID)
 		if err != nil {
@@ -542,7 +602,7 @@ func (r *SQLiteTodoRepository) FindTodosByTag(tagName string) ([]*models.Todo, e
 // GetAllTags returns all tags in the system
 func (r *SQLiteTodoRepository) GetAllTags() ([]*models.Tag, error) {
 	rows, err := r.db.Query(`
-		SELECT id, name, description, created_at, updated_at
+		SELECT id, uid, name, description, created_at, updated_at
 		FROM tags
 		ORDER BY name
 	`)
@@ -554,10 +614,13 @@ func (r *SQLiteTodoRepository) GetAllTags() ([]*models.Tag, error) {
 	var tags []*models.Tag
 	for rows.Next() {
 		tag := &models.Tag{}
-		var description sql.NullString
-		if err := rows.Scan(&tag.ID, &tag.Name, &description, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+		var uid, description sql.NullString
+		if err := rows.Scan(&tag.ID, &uid, &tag.Name, &description, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
 			return nil, err
 		}
+		tag.UID = uid.String
+		tag.CreatedAt = tag.CreatedAt.Local()
+		tag.UpdatedAt = tag.UpdatedAt.Local()
 		// Convert NULL to empty string
 		if description.Valid {
 			tag.Description = description.String
@@ -576,75 +639,282 @@ func (r *SQLiteTodoRepository) GetAllTags() ([]*models.Tag, error) {
 
 // DeleteTag removes a tag from the system
 func (r *SQLiteTodoRepository) DeleteTag(id int64) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+	return retryBusy(func() error {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
 
-	// Delete from tags table (will cascade to todo_tags)
-	_, err = tx.Exec("DELETE FROM tags WHERE id = ?", id)
-	if err != nil {
-		return err
-	}
+		// Delete from tags table (will cascade to todo_tags)
+		_, err = tx.Exec("DELETE FROM tags WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
 
-	return tx.Commit()
+		return tx.Commit()
+	})
 }
 
 // UpdateTag updates an existing tag
 func (r *SQLiteTodoRepository) UpdateTag(tag *models.Tag) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+	return retryBusy(func() error {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
 
-	// Update the timestamp
-	tag.UpdatedAt = time.Now()
+		// Update the timestamp
+		tag.UpdatedAt = time.Now()
 
-	stmt, err := tx.Prepare("UPDATE tags SET name = ?, description = ?, updated_at = ? WHERE id = ?")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-	_, err = stmt.Exec(tag.Name, tag.Description, tag.UpdatedAt, tag.ID)
-	if err != nil {
-		return err
-	}
-	return tx.Commit()
+		stmt, err := tx.Prepare("UPDATE tags SET name = ?, description = ?, updated_at = ? WHERE id = ?")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		_, err = stmt.Exec(tag.Name, tag.Description, dbTime(tag.UpdatedAt), tag.ID)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
 }
 
 // CreateTag creates a new tag in the system
 func (r *SQLiteTodoRepository) CreateTag(tag *models.Tag) error {
-	tx, err := r.db.Begin()
+	return retryBusy(func() error {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// Set timestamps
+		now := time.Now()
+		tag.CreatedAt = now
+		tag.UpdatedAt = now
+		if tag.UID == "" {
+			tag.UID = newUID()
+		}
+
+		stmt, err := tx.Prepare("INSERT INTO tags (uid, name, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?)")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		result, err := stmt.Exec(tag.UID, tag.Name, tag.Description, dbTime(tag.CreatedAt), dbTime(tag.UpdatedAt))
+		if err != nil {
+			return err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		tag.ID = id
+		return tx.Commit()
+	})
+}
+
+// ===========================================================================
+// Replication
+// ===========================================================================
+
+// GetByUID returns the todo with the given replication identity
+func (r *SQLiteTodoRepository) GetByUID(uid string) (*models.Todo, error) {
+	var id int64
+	err := r.db.QueryRow("SELECT id FROM todos WHERE uid = ?", uid).Scan(&id)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer tx.Rollback()
+	return r.GetByID(id)
+}
 
-	// Set timestamps
-	now := time.Now()
-	tag.CreatedAt = now
-	tag.UpdatedAt = now
+// SaveReplica inserts or updates a todo received from another machine. Timestamps are kept
+// as they are and the tags are replaced by the todo's tags. todo.ID is set to the local ID.
+func (r *SQLiteTodoRepository) SaveReplica(todo *models.Todo) error {
+	return retryBusy(func() error {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
 
-	stmt, err := tx.Prepare("INSERT INTO tags (name, description, created_at, updated_at) VALUES (?, ?, ?, ?)")
-	if err != nil {
-		return err
+		title, description, err := r.sealTodo(todo)
+		if err != nil {
+			return err
+		}
+
+		var id int64
+		err = tx.QueryRow("SELECT id FROM todos WHERE uid = ?", todo.UID).Scan(&id)
+		switch {
+		case err == sql.ErrNoRows:
+			result, err := tx.Exec(`
+	            INSERT INTO todos (uid, title, description, status, created_at, updated_at, priority, due_date, archived, time_spent, time_started)
+	            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	        `, todo.UID, title, description, todo.Status, dbTime(todo.CreatedAt), dbTime(todo.UpdatedAt), todo.Priority,
+				dbTimePtr(todo.DueDate), todo.Archived, todo.TimeSpent, dbTimePtr(todo.TimeStarted))
+			if err != nil {
+				return err
+			}
+			if id, err = result.LastInsertId(); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			_, err := tx.Exec(`
+	            UPDATE todos
+	            SET title = ?, description = ?, status = ?, created_at = ?, updated_at = ?, priority = ?, due_date = ?,
+	                archived = ?, time_spent = ?, time_started = ?, revision = revision + 1
+	            WHERE id = ?
+	        `, title, description, todo.Status, dbTime(todo.CreatedAt), dbTime(todo.UpdatedAt), todo.Priority, dbTimePtr(todo.DueDate),
+				todo.Archived, todo.TimeSpent, dbTimePtr(todo.TimeStarted), id)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec("DELETE FROM todo_tags WHERE todo_id = ?", id); err != nil {
+			return err
+		}
+		for _, tagName := range todo.Tags {
+			tagID, err := getOrCreateTag(tx, tagName)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec("INSERT OR IGNORE INTO todo_tags (todo_id, tag_id) VALUES (?, ?)", id, tagID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		todo.ID = id
+		return nil
+	})
+}
+
+// DeleteReplica removes the todo with the given replication identity, a missing todo is fine
+func (r *SQLiteTodoRepository) DeleteReplica(uid string) (int64, error) {
+	var id int64
+	err := r.db.QueryRow("SELECT id FROM todos WHERE uid = ?", uid).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
 	}
-	defer stmt.Close()
+	return id, r.Delete(id)
+}
 
-	result, err := stmt.Exec(tag.Name, tag.Description, tag.CreatedAt, tag.UpdatedAt)
-	if err != nil {
-		return err
+// SaveTagReplica inserts or updates a tag received from another machine. A local tag with the
+// same name but another identity is taken over, tag names are unique.
+func (r *SQLiteTodoRepository) SaveTagReplica(tag *models.Tag) error {
+	return retryBusy(func() error {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var id int64
+		err = tx.QueryRow("SELECT id FROM tags WHERE uid = ? OR name = ? ORDER BY uid = ? DESC LIMIT 1", tag.UID, tag.Name, tag.UID).Scan(&id)
+		switch {
+		case err == sql.ErrNoRows:
+			result, err := tx.Exec("INSERT INTO tags (uid, name, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+				tag.UID, tag.Name, tag.Description, dbTime(tag.CreatedAt), dbTime(tag.UpdatedAt))
+			if err != nil {
+				return err
+			}
+			if id, err = result.LastInsertId(); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			_, err := tx.Exec("UPDATE tags SET uid = ?, name = ?, description = ?, updated_at = ? WHERE id = ?",
+				tag.UID, tag.Name, tag.Description, dbTime(tag.UpdatedAt), id)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		tag.ID = id
+		return nil
+	})
+}
+
+// DeleteTagReplica removes the tag with the given replication identity, a missing tag is fine
+func (r *SQLiteTodoRepository) DeleteTagReplica(uid string) (int64, error) {
+	var id int64
+	err := r.db.QueryRow("SELECT id FROM tags WHERE uid = ?", uid).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
 	}
+	return id, r.DeleteTag(id)
+}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return err
+// getOrCreateTag returns the ID of the tag with the given name, creating it when needed
+func getOrCreateTag(tx *sql.Tx, tagName string) (int64, error) {
+	var tagID int64
+	err := tx.QueryRow("SELECT id FROM tags WHERE name = ?", tagName).Scan(&tagID)
+	if err == sql.ErrNoRows {
+		// Tag doesn't exist, create it
+		result, err := tx.Exec("INSERT INTO tags (uid, name) VALUES (?, ?)", newUID(), tagName)
+		if err != nil {
+			return 0, err
+		}
+		return result.LastInsertId()
+	}
+	return tagID, err
+}
+
+// dbTime returns a time as it is stored. The driver stores the text of the time, so it is
+// kept in UTC, where the text sorts like the instants do, and without the monotonic clock
+// reading, which would keep it from comparing equal to the same time read back.
+func dbTime(t time.Time) time.Time {
+	return t.Round(0).UTC()
+}
+
+// dbTimePtr is dbTime for a time that may be unset
+func dbTimePtr(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return dbTime(*t)
+}
+
+// localTimes shows the times of a todo read from the database in the local time zone
+func localTimes(todo *models.Todo) {
+	todo.CreatedAt = todo.CreatedAt.Local()
+	todo.UpdatedAt = todo.UpdatedAt.Local()
+	if todo.DueDate != nil {
+		dueDate := todo.DueDate.Local()
+		todo.DueDate = &dueDate
 	}
+	if todo.TimeStarted != nil {
+		timeStarted := todo.TimeStarted.Local()
+		todo.TimeStarted = &timeStarted
+	}
+}
 
-	tag.ID = id
-	return tx.Commit()
+// newUID returns a random identity for a todo or tag, unique across machines
+func newUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
 }
 
 func initSchema(db *sql.DB) error {
@@ -708,3 +978,102 @@ func initSchema(db *sql.DB) error {
 
 	return nil
 }
+
+// ===========================================================================
+// Change log
+// ===========================================================================
+
+// AppendChange adds a record to the change log and sets its sequence number
+func (r *SQLiteTodoRepository) AppendChange(record *models.ChangeRecord) error {
+	return retryBusy(func() error {
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = time.Now()
+		}
+		// The payload holds the todo as it was sent to the other instances
+		payload, err := r.sealText(payloadField, string(record.Payload))
+		if err != nil {
+			return err
+		}
+
+		result, err := r.db.Exec("INSERT INTO change_log (entity_key, deleted, payload, created_at) VALUES (?, ?, ?, ?)",
+			record.Key, record.Deleted, payload, dbTime(record.CreatedAt))
+		if err != nil {
+			return err
+		}
+
+		record.Seq, err = result.LastInsertId()
+		return err
+	})
+}
+
+// ChangesSince returns the records after seq, oldest first
+func (r *SQLiteTodoRepository) ChangesSince(seq int64) ([]*models.ChangeRecord, error) {
+	rows, err := r.db.Query("SELECT seq, entity_key, deleted, payload, created_at FROM change_log WHERE seq > ? ORDER BY seq", seq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*models.ChangeRecord
+	for rows.Next() {
+		var record models.ChangeRecord
+		var payload string
+		if err := rows.Scan(&record.Seq, &record.Key, &record.Deleted, &payload, &record.CreatedAt); err != nil {
+			return nil, err
+		}
+		payload, err := r.openText(payloadField, payload)
+		if err != nil {
+			return nil, fmt.Errorf("change %d: %w", record.Seq, err)
+		}
+		record.Payload = []byte(payload)
+		record.CreatedAt = record.CreatedAt.Local()
+		records = append(records, &record)
+	}
+
+	return records, rows.Err()
+}
+
+// ChangeHead returns the sequence number of the latest record, which survives compaction
+func (r *SQLiteTodoRepository) ChangeHead() (int64, error) {
+	var head int64
+	err := r.db.QueryRow("SELECT seq FROM sqlite_sequence WHERE name = 'change_log'").Scan(&head)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return head, err
+}
+
+// CompactChanges keeps only the latest record of every entity and drops records of deletes
+// made before deletedBefore. It returns the number of removed records.
+func (r *SQLiteTodoRepository) CompactChanges(deletedBefore time.Time) (int64, error) {
+	var removed int64
+	err := retryBusy(func() error {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		superseded, err := tx.Exec(`
+	        DELETE FROM change_log
+	        WHERE seq NOT IN (SELECT MAX(seq) FROM change_log GROUP BY entity_key)
+	    `)
+		if err != nil {
+			return err
+		}
+		expired, err := tx.Exec("DELETE FROM change_log WHERE deleted = 1 AND created_at < ?", dbTime(deletedBefore))
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		removedSuperseded, _ := superseded.RowsAffected()
+		removedDeletes, _ := expired.RowsAffected()
+		removed = removedSuperseded + removedDeletes
+		return nil
+	})
+	return removed, err
+}
@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+func newTestDB(tb testing.TB) *SQLiteTodoRepository {
+	tb.Helper()
+	db, err := OpenSQLiteTodoRepository(filepath.Join(tb.TempDir(), "todo.sql"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestTimestampsWithoutClockReadings(t *testing.T) {
+	db := newTestDB(t)
+
+	// A row from before the timestamps were stripped
+	_, err := db.db.Exec(`INSERT INTO todos (uid, title, description, status, created_at, updated_at, priority)
+		VALUES ('old', 'Old', '', 0, '2025-01-02 03:04:05.6 +0000 UTC m=+0.010547299', '2025-01-02 03:04:05.6 +0000 UTC m=+0.010547299', 0)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := GetAllMigrations()[6].RunSQL(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	todo := newTestTodo("New")
+	started := time.Now()
+	todo.TimeStarted = &started
+	if err := db.Create(todo); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.db.Query("SELECT title, created_at || updated_at || COALESCE(time_started, '') FROM todos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var title, stamps string
+		if err := rows.Scan(&title, &stamps); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(stamps, " m=") {
+			t.Errorf("%s has clock readings in its timestamps: %s", title, stamps)
+		}
+	}
+}
+
+func TestTimestampsInUTC(t *testing.T) {
+	db := newTestDB(t)
+
+	// Rows from before the timestamps were stored in UTC, on both sides of a DST switch
+	_, err := db.db.Exec(`INSERT INTO todos (uid, title, description, status, created_at, updated_at, due_date, priority)
+		VALUES ('summer', 'Summer', '', 0, '2026-03-29 10:30:00 +0200 CEST', '2026-03-29 10:30:00 +0200 CEST', '2026-03-30 09:00:00 +0200 CEST', 0),
+		       ('winter', 'Winter', '', 0, '2026-03-29 09:45:00 +0100 CET', '2026-03-29 09:45:00 +0100 CET', NULL, 0)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := GetAllMigrations()[10].RunSQL(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var summer, winter, due string
+	err = db.db.QueryRow("SELECT s.updated_at || '', w.updated_at || '', s.due_date || '' FROM todos s, todos w WHERE s.uid = 'summer' AND w.uid = 'winter'").
+		Scan(&summer, &winter, &due)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summer != "2026-03-29 08:30:00 +0000 UTC" || winter != "2026-03-29 08:45:00 +0000 UTC" || due != "2026-03-30 07:00:00 +0000 UTC" {
+		t.Errorf("stored timestamps = %q, %q, %q, want them in UTC", summer, winter, due)
+	}
+
+	todos, err := db.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 2 || todos[0].Title != "Winter" {
+		t.Fatalf("GetAll() = %v, want the todo updated last first", todos)
+	}
+	if todos[0].UpdatedAt.Location() != time.Local || !todos[0].UpdatedAt.Equal(time.Date(2026, 3, 29, 8, 45, 0, 0, time.UTC)) {
+		t.Errorf("UpdatedAt = %v, want 08:45 UTC in the local time zone", todos[0].UpdatedAt)
+	}
+}
+
+// ===========================================================================
+// Benchmarks
+// ===========================================================================
+
+const benchmarkTodos = 100_000
+
+// seedBenchmarkDB fills a database with todos, most of them archived as after years of use
+func seedBenchmarkDB(b *testing.B) *SQLiteTodoRepository {
+	b.Helper()
+	db := newTestDB(b)
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, name := range []string{"work", "home", "errands"} {
+		if _, err := tx.Exec("INSERT INTO tags (uid, name) VALUES (?, ?)", newUID(), name); err != nil {
+			b.Fatal(err)
+		}
+	}
+	stmt, err := tx.Prepare(`INSERT INTO todos (uid, title, description, status, created_at, updated_at, priority, due_date, archived)
+		VALUES (?, ?, '', ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	start := time.Now().AddDate(-5, 0, 0)
+	for i := range benchmarkTodos {
+		created := dbTime(start.Add(time.Duration(i) * 25 * time.Minute))
+		var due any
+		if i%4 == 0 {
+			due = created.AddDate(0, 0, 7)
+		}
+		result, err := stmt.Exec(newUID(), fmt.Sprintf("Todo %d", i), models.Status(i%4), created, created,
+			models.Priority(i%5), due, i < benchmarkTodos*9/10)
+		if err != nil {
+			b.Fatal(err)
+		}
+		id, _ := result.LastInsertId()
+		if _, err := tx.Exec("INSERT INTO todo_tags (todo_id, tag_id) VALUES (?, ?)", id, i%3+1); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	return db
+}
+
+func BenchmarkGetAll(b *testing.B) {
+	db := seedBenchmarkDB(b)
+	b.ResetTimer()
+	for range b.N {
+		if _, err := db.GetAll(NotArchivedFilter()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetAllArchived(b *testing.B) {
+	db := seedBenchmarkDB(b)
+	b.ResetTimer()
+	for range b.N {
+		if _, err := db.GetAll(ArchivedFilter()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetFirstPage(b *testing.B) {
+	db := seedBenchmarkDB(b)
+	b.ResetTimer()
+	for range b.N {
+		if _, _, err := db.GetPage(nil, 100, ArchivedFilter()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetPageDeepInTheList(b *testing.B) {
+	db := seedBenchmarkDB(b)
+	todos, _, err := db.GetPage(nil, 50_000, ArchivedFilter())
+	if err != nil {
+		b.Fatal(err)
+	}
+	after := CursorOf(todos[len(todos)-1])
+
+	b.ResetTimer()
+	for range b.N {
+		if _, _, err := db.GetPage(after, 100, ArchivedFilter()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkToday(b *testing.B) {
+	db := seedBenchmarkDB(b)
+	b.ResetTimer()
+	for range b.N {
+		if _, err := db.GetAll(AllTodayFilter(models.High, 7), NotArchivedFilter()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
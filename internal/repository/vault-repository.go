@@ -0,0 +1,1020 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v3"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+// VaultTodoRepository keeps every todo in a Markdown file with YAML front matter, so todos
+// can live in git and be edited in any editor. The body of the file is the description, the
+// tags and their descriptions are kept in tags.yaml.
+//
+// Instances on the same machine share the directory. Nothing is cached beyond the parsed
+// files, which are read again when their size or modification time changes. Writes replace
+// files atomically and are serialized between processes with a lock file.
+
+const (
+	vaultTagsFile = "tags.yaml"
+	vaultLockFile = ".lock"
+	vaultExt      = ".md"
+	// vaultLockStale is the age after which a lock file is considered left behind by a crash
+	vaultLockStale = 10 * time.Second
+	// vaultSlugLength keeps file names readable for todos with a long title
+	vaultSlugLength = 40
+)
+
+var (
+	frontMatterDelimiter = []byte("---")
+	vaultNamePattern     = regexp.MustCompile(`^(\d+)-`)
+)
+
+type VaultTodoRepository struct {
+	dir string
+	mu  sync.Mutex
+	// files caches the parsed todo files by name
+	files map[string]*vaultFile
+	// known is the state of every todo as written by this repository or last reported as an
+	// external change, by ID
+	known map[int64]*vaultFile
+	// pending holds external changes seen once, they are reported when they are still there
+	// on the next poll, unless another instance acknowledged them in the meantime
+	pending map[string]vaultStamp
+}
+
+// vaultFile is a parsed todo file
+type vaultFile struct {
+	name  string
+	stamp vaultStamp
+	todo  *models.Todo
+	// extra holds front matter keys the repository doesn't know, they are written back as is
+	extra map[string]any
+	err   error
+}
+
+type vaultStamp struct {
+	name    string
+	modTime time.Time
+	size    int64
+}
+
+// vaultFrontMatter is the YAML header of a todo file
+type vaultFrontMatter struct {
+	ID          int64          `yaml:"id"`
+	UID         string         `yaml:"uid"`
+	Title       string         `yaml:"title"`
+	Status      string         `yaml:"status"`
+	Priority    string         `yaml:"priority"`
+	Tags        []string       `yaml:"tags,omitempty"`
+	Due         *time.Time     `yaml:"due,omitempty"`
+	Archived    bool           `yaml:"archived,omitempty"`
+	Created     time.Time      `yaml:"created"`
+	Updated     time.Time      `yaml:"updated"`
+	TimeSpent   int64          `yaml:"time_spent,omitempty"`
+	TimeStarted *time.Time     `yaml:"time_started,omitempty"`
+	Revision    int64          `yaml:"revision"`
+	Extra       map[string]any `yaml:",inline"`
+}
+
+type vaultTag struct {
+	ID          int64     `yaml:"id"`
+	UID         string    `yaml:"uid"`
+	Name        string    `yaml:"name"`
+	Description string    `yaml:"description,omitempty"`
+	Created     time.Time `yaml:"created"`
+	Updated     time.Time `yaml:"updated"`
+}
+
+// NewVaultTodoRepository opens the vault in dir, creating the directory when needed
+func NewVaultTodoRepository(dir string) (*VaultTodoRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	r := &VaultTodoRepository{
+		dir:     dir,
+		files:   make(map[string]*vaultFile),
+		known:   make(map[int64]*vaultFile),
+		pending: make(map[string]vaultStamp),
+	}
+
+	// What is on disk when the vault is opened is not a change, files added while no
+	// instance was running get their ID right away
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	files, err := r.scan()
+	if err != nil {
+		return nil, err
+	}
+	byID := indexByID(files)
+	for id, file := range byID {
+		r.known[id] = file
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, file := range files {
+		if file.err != nil || byID[file.todo.ID] == file {
+			continue
+		}
+		if _, err := r.adopt(file, byID); err != nil {
+			log.Warn("Failed to add todo file to the vault", "file", file.name, "error", err)
+		}
+	}
+	return r, nil
+}
+
+// Dir returns the directory of the vault
+func (r *VaultTodoRepository) Dir() string {
+	return r.dir
+}
+
+func (r *VaultTodoRepository) Close() error {
+	return nil
+}
+
+// ===========================================================================
+// Todos
+// ===========================================================================
+
+func (r *VaultTodoRepository) Create(todo *models.Todo) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	files, err := r.scan()
+	if err != nil {
+		return err
+	}
+
+	if todo.UID == "" {
+		todo.UID = newUID()
+	}
+	todo.ID = nextVaultID(files)
+
+	// Tags are added with AddTagToTodo, as in the database
+	stored := *todo
+	stored.Tags = nil
+	return r.write(vaultFileName(todo.ID, todo.Title), &stored, nil)
+}
+
+func (r *VaultTodoRepository) GetByID(id int64) (*models.Todo, error) {
+	files, err := r.scan()
+	if err != nil {
+		return nil, err
+	}
+	file, ok := indexByID(files)[id]
+	if !ok {
+		return nil, fmt.Errorf("todo with id %d not found", id)
+	}
+	return copyTodo(file.todo), nil
+}
+
+func (r *VaultTodoRepository) GetAll(filters ...Filter) ([]*models.Todo, error) {
+	if err := And(filters...).Validate(); err != nil {
+		return nil, err
+	}
+
+	files, err := r.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	todos := []*models.Todo{}
+	for _, file := range indexByID(files) {
+		if MatchAll(file.todo, filters...) {
+			todos = append(todos, copyTodo(file.todo))
+		}
+	}
+
+	sort.Slice(todos, func(i, j int) bool {
+		if todos[i].CreatedAt.Equal(todos[j].CreatedAt) {
+			return todos[i].ID > todos[j].ID
+		}
+		return todos[i].CreatedAt.After(todos[j].CreatedAt)
+	})
+	return todos, nil
+}
+
+// GetPage returns the page of the list after the cursor, see Pager
+func (r *VaultTodoRepository) GetPage(after *Cursor, limit int, filters ...Filter) ([]*models.Todo, bool, error) {
+	todos, err := r.GetAll(filters...)
+	if err != nil {
+		return nil, false, err
+	}
+	page, more := PageOf(todos, after, limit)
+	return page, more, nil
+}
+
+// Update stores the todo if it wasn't changed since it was read, otherwise it returns a
+// ConflictError. Tags are kept as they are stored, they change through AddTagToTodo.
+func (r *VaultTodoRepository) Update(todo *models.Todo) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	files, err := r.scan()
+	if err != nil {
+		return err
+	}
+	file, ok := indexByID(files)[todo.ID]
+	if !ok {
+		return &ConflictError{ID: todo.ID, Revision: todo.Revision, Deleted: true}
+	}
+	if file.todo.Revision != todo.Revision {
+		return &ConflictError{ID: todo.ID, Revision: todo.Revision, Current: file.todo.Revision}
+	}
+
+	stored := *todo
+	stored.Tags = file.todo.Tags
+	stored.UID = file.todo.UID
+	stored.CreatedAt = file.todo.CreatedAt
+	stored.UpdatedAt = time.Now()
+	stored.Revision++
+
+	// A file is renamed with its title, unless it was named by hand
+	name := file.name
+	if name == vaultFileName(file.todo.ID, file.todo.Title) {
+		name = vaultFileName(stored.ID, stored.Title)
+	}
+	if err := r.write(name, &stored, file.extra); err != nil {
+		return err
+	}
+	if name != file.name {
+		if err := os.Remove(filepath.Join(r.dir, file.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		r.remember(name)
+	}
+
+	todo.Revision++
+	return nil
+}
+
+func (r *VaultTodoRepository) Delete(id int64) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	files, err := r.scan()
+	if err != nil {
+		return err
+	}
+	file, ok := indexByID(files)[id]
+	if !ok {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(r.dir, file.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	r.mu.Lock()
+	delete(r.files, file.name)
+	delete(r.known, id)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *VaultTodoRepository) GetOpen() ([]*models.Todo, error) {
+	return r.GetAll(StatusFilter(models.Open), NotArchivedFilter())
+}
+
+func (r *VaultTodoRepository) GetActive() ([]*models.Todo, error) {
+	return r.GetAll(StatusFilter(models.Doing), NotArchivedFilter())
+}
+
+func (r *VaultTodoRepository) GetCompleted() ([]*models.Todo, error) {
+	return r.GetAll(StatusFilter(models.Done), NotArchivedFilter())
+}
+
+func (r *VaultTodoRepository) GetBlocked() ([]*models.Todo, error) {
+	return r.GetAll(StatusFilter(models.Blocked), NotArchivedFilter())
+}
+
+func (r *VaultTodoRepository) Search(query string) ([]*models.Todo, error) {
+	return r.GetAll(SearchFilter(query))
+}
+
+// ===========================================================================
+// Tags
+// ===========================================================================
+
+func (r *VaultTodoRepository) AddTagToTodo(todoID int64, tagName string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	files, err := r.scan()
+	if err != nil {
+		return err
+	}
+	file, ok := indexByID(files)[todoID]
+	if !ok {
+		return fmt.Errorf("todo with id %d not found", todoID)
+	}
+	if err := r.ensureTags(tagName); err != nil {
+		return err
+	}
+	if slices.Contains(file.todo.Tags, tagName) {
+		return nil
+	}
+
+	todo := copyTodo(file.todo)
+	todo.Tags = append(todo.Tags, tagName)
+	todo.Revision++
+	return r.write(file.name, todo, file.extra)
+}
+
+func (r *VaultTodoRepository) RemoveTagFromTodo(todoID int64, tagName string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tags, err := r.readTags()
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(tags, func(tag vaultTag) bool { return tag.Name == tagName }) {
+		return fmt.Errorf("tag %q not found", tagName)
+	}
+
+	files, err := r.scan()
+	if err != nil {
+		return err
+	}
+	file, ok := indexByID(files)[todoID]
+	if !ok || !slices.Contains(file.todo.Tags, tagName) {
+		return nil
+	}
+
+	todo := copyTodo(file.todo)
+	todo.Tags = slices.DeleteFunc(todo.Tags, func(name string) bool { return name == tagName })
+	todo.Revision++
+	return r.write(file.name, todo, file.extra)
+}
+
+func (r *VaultTodoRepository) GetAllTags() ([]*models.Tag, error) {
+	tags, err := r.readTags()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*models.Tag, len(tags))
+	for i, tag := range tags {
+		result[i] = &models.Tag{
+			ID:          tag.ID,
+			UID:         tag.UID,
+			Name:        tag.Name,
+			Description: tag.Description,
+			CreatedAt:   tag.Created,
+			UpdatedAt:   tag.Updated,
+		}
+	}
+	return result, nil
+}
+
+func (r *VaultTodoRepository) CreateTag(tag *models.Tag) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tags, err := r.readTags()
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(tags, func(existing vaultTag) bool { return existing.Name == tag.Name }) {
+		return fmt.Errorf("tag %q already exists", tag.Name)
+	}
+
+	now := time.Now()
+	tag.CreatedAt = now
+	tag.UpdatedAt = now
+	if tag.UID == "" {
+		tag.UID = newUID()
+	}
+	tag.ID = nextTagID(tags)
+
+	tags = append(tags, vaultTag{ID: tag.ID, UID: tag.UID, Name: tag.Name, Description: tag.Description, Created: now, Updated: now})
+	return r.writeTags(tags)
+}
+
+// DeleteTag removes a tag and takes it off every todo
+func (r *VaultTodoRepository) DeleteTag(id int64) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tags, err := r.readTags()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(tags, func(tag vaultTag) bool { return tag.ID == id })
+	if i < 0 {
+		return nil
+	}
+	name := tags[i].Name
+
+	if err := r.writeTags(slices.Delete(tags, i, i+1)); err != nil {
+		return err
+	}
+	return r.renameTagOnTodos(name, "")
+}
+
+// UpdateTag changes a tag, a new name is written to every todo with the tag
+func (r *VaultTodoRepository) UpdateTag(tag *models.Tag) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tags, err := r.readTags()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(tags, func(existing vaultTag) bool { return existing.ID == tag.ID })
+	if i < 0 {
+		return fmt.Errorf("tag with id %d not found", tag.ID)
+	}
+	oldName := tags[i].Name
+
+	tag.UpdatedAt = time.Now()
+	tags[i].Name = tag.Name
+	tags[i].Description = tag.Description
+	tags[i].Updated = tag.UpdatedAt
+	if err := r.writeTags(tags); err != nil {
+		return err
+	}
+
+	if oldName == tag.Name {
+		return nil
+	}
+	return r.renameTagOnTodos(oldName, tag.Name)
+}
+
+// renameTagOnTodos replaces a tag on every todo that has it, an empty name removes it
+func (r *VaultTodoRepository) renameTagOnTodos(oldName, newName string) error {
+	files, err := r.scan()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range indexByID(files) {
+		if !slices.Contains(file.todo.Tags, oldName) {
+			continue
+		}
+		todo := copyTodo(file.todo)
+		todo.Tags = slices.DeleteFunc(todo.Tags, func(name string) bool { return name == oldName })
+		if newName != "" && !slices.Contains(todo.Tags, newName) {
+			todo.Tags = append(todo.Tags, newName)
+		}
+		if err := r.write(file.name, todo, file.extra); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureTags adds the tags that don't exist yet to tags.yaml, the caller holds the lock
+func (r *VaultTodoRepository) ensureTags(names ...string) error {
+	tags, err := r.readTags()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	now := time.Now()
+	for _, name := range names {
+		if name == "" || slices.ContainsFunc(tags, func(tag vaultTag) bool { return tag.Name == name }) {
+			continue
+		}
+		tags = append(tags, vaultTag{ID: nextTagID(tags), UID: newUID(), Name: name, Created: now, Updated: now})
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return r.writeTags(tags)
+}
+
+func (r *VaultTodoRepository) readTags() ([]vaultTag, error) {
+	data, err := os.ReadFile(filepath.Join(r.dir, vaultTagsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []vaultTag
+	if err := yaml.Unmarshal(data, &tags); err != nil {
+		return nil, fmt.Errorf("couldn't read %s: %w", vaultTagsFile, err)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (r *VaultTodoRepository) writeTags(tags []vaultTag) error {
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	data, err := yaml.Marshal(tags)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(r.dir, vaultTagsFile), data)
+}
+
+// ===========================================================================
+// External changes
+// ===========================================================================
+
+// ExternalChanges returns the todos that were changed in the directory by something else
+// than an instance of the program, e.g. an editor or git. A change is reported once it was
+// seen on two polls, so instances have time to acknowledge their own writes. New files
+// without an ID, and copies of an existing file, get an ID and UID written into them.
+func (r *VaultTodoRepository) ExternalChanges() ([]ExternalChange, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	files, err := r.scan()
+	if err != nil {
+		return nil, err
+	}
+	byID := indexByID(files)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := make(map[string]vaultStamp)
+	// ready reports whether a change was already seen on the previous poll
+	ready := func(key string, stamp vaultStamp) bool {
+		pending[key] = stamp
+		previous, ok := r.pending[key]
+		return ok && previous == stamp
+	}
+
+	var changes []ExternalChange
+	for _, file := range files {
+		if file.err != nil {
+			continue
+		}
+
+		if owner, ok := byID[file.todo.ID]; !ok || owner != file {
+			if ready("adopt:"+file.name, file.stamp) {
+				change, err := r.adopt(file, byID)
+				if err != nil {
+					log.Warn("Failed to add todo file to the vault", "file", file.name, "error", err)
+					continue
+				}
+				changes = append(changes, change)
+			}
+			continue
+		}
+
+		known, ok := r.known[file.todo.ID]
+		if ok && known.stamp == file.stamp {
+			continue
+		}
+		if !ready("change:"+file.name, file.stamp) {
+			continue
+		}
+
+		change := ExternalChange{ID: file.todo.ID}
+		if ok {
+			change.Before = copyTodo(known.todo)
+		}
+		r.known[file.todo.ID] = file
+		if err := r.ensureTags(file.todo.Tags...); err != nil {
+			log.Warn("Failed to add the tags of a todo file", "file", file.name, "error", err)
+		}
+		changes = append(changes, change)
+	}
+
+	for id, known := range r.known {
+		if _, ok := byID[id]; ok {
+			continue
+		}
+		if !ready("delete:"+strconv.FormatInt(id, 10), vaultStamp{}) {
+			continue
+		}
+		delete(r.known, id)
+		changes = append(changes, ExternalChange{ID: id, Before: copyTodo(known.todo), Deleted: true})
+	}
+
+	r.pending = pending
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ID < changes[j].ID })
+	return changes, nil
+}
+
+// WatchDir returns the directory of the vault
+func (r *VaultTodoRepository) WatchDir() string {
+	return r.dir
+}
+
+// HoldsTodo reports whether a file in the vault is a todo. Dot files include the lock and
+// the temporary files of atomic writes.
+func (r *VaultTodoRepository) HoldsTodo(name string) bool {
+	return !strings.HasPrefix(name, ".") && filepath.Ext(name) == vaultExt
+}
+
+// Acknowledge records the stored state of a todo, a change another instance already
+// reported is then not reported again
+func (r *VaultTodoRepository) Acknowledge(id int64) {
+	files, err := r.scan()
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if file, ok := indexByID(files)[id]; ok {
+		r.known[id] = file
+	} else {
+		delete(r.known, id)
+	}
+}
+
+// adopt gives a file that was created outside the program an ID and UID of its own. The
+// caller holds r.mu.
+func (r *VaultTodoRepository) adopt(file *vaultFile, byID map[int64]*vaultFile) (ExternalChange, error) {
+	todo := copyTodo(file.todo)
+	// IDs of files deleted since the last poll are not handed out again before the delete is
+	// reported
+	todo.ID = 1
+	for id := range byID {
+		todo.ID = max(todo.ID, id+1)
+	}
+	for id := range r.known {
+		todo.ID = max(todo.ID, id+1)
+	}
+	todo.UID = newUID()
+	todo.Revision = 0
+
+	r.mu.Unlock()
+	err := r.write(file.name, todo, file.extra)
+	if err == nil {
+		err = r.ensureTags(todo.Tags...)
+	}
+	r.mu.Lock()
+	if err != nil {
+		return ExternalChange{}, err
+	}
+
+	byID[todo.ID] = r.files[file.name]
+	return ExternalChange{ID: todo.ID}, nil
+}
+
+// ===========================================================================
+// Files
+// ===========================================================================
+
+// scan reads the directory and parses the todo files that changed since the last scan
+func (r *VaultTodoRepository) scan() ([]*vaultFile, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var files []*vaultFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !r.HoldsTodo(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		seen[name] = true
+
+		stamp := vaultStamp{name: name, modTime: info.ModTime(), size: info.Size()}
+		if cached, ok := r.files[name]; ok && cached.stamp == stamp {
+			files = append(files, cached)
+			continue
+		}
+
+		file := &vaultFile{name: name, stamp: stamp}
+		data, err := os.ReadFile(filepath.Join(r.dir, name))
+		if err == nil {
+			file.todo, file.extra, err = parseVaultFile(name, data)
+		}
+		if err != nil {
+			log.Warn("Skipping unreadable todo file", "file", name, "error", err)
+			file.err = err
+		}
+		r.files[name] = file
+		files = append(files, file)
+	}
+
+	for name := range r.files {
+		if !seen[name] {
+			delete(r.files, name)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	return files, nil
+}
+
+// write stores a todo in the named file and records it as written by this repository
+func (r *VaultTodoRepository) write(name string, todo *models.Todo, extra map[string]any) error {
+	data, err := encodeVaultFile(todo, extra)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(r.dir, name), data); err != nil {
+		return err
+	}
+	r.remember(name)
+	return nil
+}
+
+// remember parses a file this repository wrote, so it is neither read again nor reported as
+// an external change
+func (r *VaultTodoRepository) remember(name string) {
+	path := filepath.Join(r.dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	todo, extra, err := parseVaultFile(name, data)
+	if err != nil {
+		return
+	}
+
+	file := &vaultFile{
+		name:  name,
+		stamp: vaultStamp{name: name, modTime: info.ModTime(), size: info.Size()},
+		todo:  todo,
+		extra: extra,
+	}
+	r.mu.Lock()
+	r.files[name] = file
+	r.known[todo.ID] = file
+	r.mu.Unlock()
+}
+
+// lock serializes writes between the processes sharing the vault. A lock left behind by a
+// process that crashed is taken over after vaultLockStale.
+func (r *VaultTodoRepository) lock() (func(), error) {
+	path := filepath.Join(r.dir, vaultLockFile)
+	deadline := time.Now().Add(2 * vaultLockStale)
+
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > vaultLockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("vault %s is locked", r.dir)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// parseVaultFile reads a todo file. A file without front matter is a todo titled after the
+// file, with the whole file as its description.
+func parseVaultFile(name string, data []byte) (*models.Todo, map[string]any, error) {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	var meta vaultFrontMatter
+	body := data
+	if bytes.HasPrefix(data, append(frontMatterDelimiter, '\n')) {
+		rest := data[len(frontMatterDelimiter)+1:]
+		end := bytes.Index(rest, append(append([]byte("\n"), frontMatterDelimiter...), '\n'))
+		header := rest
+		body = nil
+		if end >= 0 {
+			header = rest[:end+1]
+			body = rest[end+len(frontMatterDelimiter)+2:]
+		} else if trimmed, ok := bytes.CutSuffix(rest, append([]byte("\n"), frontMatterDelimiter...)); ok {
+			header = trimmed
+		} else {
+			return nil, nil, errors.New("front matter is not closed with ---")
+		}
+		if err := yaml.Unmarshal(header, &meta); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	todo := &models.Todo{
+		ID:          meta.ID,
+		UID:         meta.UID,
+		Title:       strings.TrimSpace(meta.Title),
+		Description: strings.Trim(string(body), "\n"),
+		DueDate:     meta.Due,
+		Archived:    meta.Archived,
+		CreatedAt:   meta.Created,
+		UpdatedAt:   meta.Updated,
+		Tags:        []string{},
+		TimeSpent:   meta.TimeSpent,
+		TimeStarted: meta.TimeStarted,
+		Revision:    meta.Revision,
+	}
+	for _, tag := range meta.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(todo.Tags, tag) {
+			todo.Tags = append(todo.Tags, tag)
+		}
+	}
+
+	if todo.Title == "" {
+		todo.Title = vaultNamePattern.ReplaceAllString(strings.TrimSuffix(name, vaultExt), "")
+	}
+	if meta.Status != "" {
+		status, err := models.ParseStatus(meta.Status)
+		if err != nil {
+			return nil, nil, err
+		}
+		todo.Status = status
+	}
+	todo.Priority = models.Low
+	if meta.Priority != "" {
+		priority, err := models.ParsePriority(meta.Priority)
+		if err != nil {
+			return nil, nil, err
+		}
+		todo.Priority = priority
+	}
+	if todo.CreatedAt.IsZero() {
+		todo.CreatedAt = time.Now()
+	}
+	if todo.UpdatedAt.IsZero() {
+		todo.UpdatedAt = todo.CreatedAt
+	}
+
+	return todo, meta.Extra, nil
+}
+
+// encodeVaultFile writes a todo as front matter followed by its description
+func encodeVaultFile(todo *models.Todo, extra map[string]any) ([]byte, error) {
+	meta := vaultFrontMatter{
+		ID:          todo.ID,
+		UID:         todo.UID,
+		Title:       todo.Title,
+		Status:      strings.TrimPrefix(todo.Status.String(), "status."),
+		Priority:    strings.TrimPrefix(todo.Priority.String(), "priority."),
+		Tags:        todo.Tags,
+		Due:         todo.DueDate,
+		Archived:    todo.Archived,
+		Created:     todo.CreatedAt,
+		Updated:     todo.UpdatedAt,
+		TimeSpent:   todo.TimeSpent,
+		TimeStarted: todo.TimeStarted,
+		Revision:    todo.Revision,
+		Extra:       extra,
+	}
+	header, err := yaml.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(frontMatterDelimiter)
+	buf.WriteByte('\n')
+	buf.Write(header)
+	buf.Write(frontMatterDelimiter)
+	buf.WriteByte('\n')
+	if description := strings.Trim(todo.Description, "\n"); description != "" {
+		buf.WriteString("\n" + description + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// vaultFileName returns the file for a todo, e.g. 42-write-annual-report.md
+func vaultFileName(id int64, title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+
+	// Long titles are cut between words
+	slug := strings.Join(words, "-")
+	if len(slug) > vaultSlugLength {
+		slug = slug[:vaultSlugLength]
+		if i := strings.LastIndexByte(slug, '-'); i > 0 {
+			slug = slug[:i]
+		}
+		slug = strings.ToValidUTF8(slug, "")
+	}
+
+	name := strconv.FormatInt(id, 10)
+	if slug != "" {
+		name += "-" + slug
+	}
+	return name + vaultExt
+}
+
+// writeFileAtomic replaces a file through a temporary file, so readers never see half of it
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// indexByID returns the readable todo files by ID. When files share an ID, e.g. after a file
+// was copied, the first by name keeps it.
+func indexByID(files []*vaultFile) map[int64]*vaultFile {
+	byID := make(map[int64]*vaultFile, len(files))
+	for _, file := range files {
+		if file.err != nil || file.todo.ID <= 0 || file.todo.UID == "" {
+			continue
+		}
+		if _, taken := byID[file.todo.ID]; !taken {
+			byID[file.todo.ID] = file
+		}
+	}
+	return byID
+}
+
+func nextVaultID(files []*vaultFile) int64 {
+	var next int64 = 1
+	for _, file := range files {
+		if file.err == nil {
+			next = max(next, file.todo.ID+1)
+		}
+	}
+	return next
+}
+
+func nextTagID(tags []vaultTag) int64 {
+	var next int64 = 1
+	for _, tag := range tags {
+		next = max(next, tag.ID+1)
+	}
+	return next
+}
+
+func copyTodo(todo *models.Todo) *models.Todo {
+	if todo == nil {
+		return nil
+	}
+	c := *todo
+	c.Tags = slices.Clone(todo.Tags)
+	if c.Tags == nil {
+		c.Tags = []string{}
+	}
+	return &c
+}
@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+func newTestVault(t *testing.T) *VaultTodoRepository {
+	t.Helper()
+	vault, err := NewVaultTodoRepository(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vault
+}
+
+func newTestTodo(title string) *models.Todo {
+	now := time.Now()
+	return &models.Todo{Title: title, Status: models.Open, Priority: models.Medium, CreatedAt: now, UpdatedAt: now}
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	vault := newTestVault(t)
+
+	due := time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)
+	todo := newTestTodo("Write annual report")
+	todo.Description = "## Outline\n\n- numbers\n- plans"
+	todo.DueDate = &due
+	todo.Priority = models.High
+	if err := vault.Create(todo); err != nil {
+		t.Fatal(err)
+	}
+	if todo.ID != 1 || todo.UID == "" {
+		t.Fatalf("expected ID 1 and a UID, got %d %q", todo.ID, todo.UID)
+	}
+
+	data, err := os.ReadFile(filepath.Join(vault.Dir(), "1-write-annual-report.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "---\n") || !strings.HasSuffix(string(data), "---\n\n## Outline\n\n- numbers\n- plans\n") {
+		t.Errorf("unexpected file:\n%s", data)
+	}
+
+	got, err := vault.GetByID(todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != todo.Title || got.Description != todo.Description || got.Priority != models.High || !got.DueDate.Equal(due) {
+		t.Errorf("todo didn't survive the round trip: %+v", got)
+	}
+
+	got.Title = "Write the annual report"
+	got.Status = models.Doing
+	if err := vault.Update(got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Revision != 1 {
+		t.Errorf("expected revision 1 after update, got %d", got.Revision)
+	}
+	if _, err := os.Stat(filepath.Join(vault.Dir(), "1-write-the-annual-report.md")); err != nil {
+		t.Errorf("expected the file to follow the title: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(vault.Dir(), "1-write-annual-report.md")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("expected the old file to be gone")
+	}
+
+	if err := vault.Delete(todo.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vault.GetByID(todo.ID); err == nil {
+		t.Error("expected deleted todo to be gone")
+	}
+}
+
+func TestVaultUpdateConflict(t *testing.T) {
+	vault := newTestVault(t)
+	todo := newTestTodo("Shared")
+	if err := vault.Create(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := vault.GetByID(todo.ID)
+	second, _ := vault.GetByID(todo.ID)
+	if err := vault.Update(first); err != nil {
+		t.Fatal(err)
+	}
+
+	err := vault.Update(second)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.Current != 1 {
+		t.Fatalf("expected a conflict at revision 1, got %v", err)
+	}
+
+	if err := vault.Delete(todo.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.Update(first); !errors.As(err, &conflict) || !conflict.Deleted {
+		t.Errorf("expected a conflict for a deleted todo, got %v", err)
+	}
+}
+
+func TestVaultTags(t *testing.T) {
+	vault := newTestVault(t)
+	todo := newTestTodo("Tagged")
+	if err := vault.Create(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tag := range []string{"work", "home"} {
+		if err := vault.AddTagToTodo(todo.ID, tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tags, err := vault.GetAllTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0].Name != "home" || tags[1].Name != "work" {
+		t.Fatalf("expected tags home and work, got %+v", tags)
+	}
+
+	work := tags[1]
+	work.Name = "office"
+	if err := vault.UpdateTag(work); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := vault.GetByID(todo.ID)
+	if !slices.Contains(got.Tags, "office") || slices.Contains(got.Tags, "work") {
+		t.Errorf("expected the renamed tag on the todo, got %v", got.Tags)
+	}
+
+	if err := vault.DeleteTag(tags[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = vault.GetByID(todo.ID)
+	if !slices.Equal(got.Tags, []string{"office"}) {
+		t.Errorf("expected the deleted tag to be taken off the todo, got %v", got.Tags)
+	}
+
+	if err := vault.RemoveTagFromTodo(todo.ID, "office"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = vault.GetByID(todo.ID)
+	if len(got.Tags) != 0 {
+		t.Errorf("expected no tags, got %v", got.Tags)
+	}
+}
+
+func TestVaultExternalChanges(t *testing.T) {
+	vault := newTestVault(t)
+	kept := newTestTodo("Kept")
+	edited := newTestTodo("Edited")
+	for _, todo := range []*models.Todo{kept, edited} {
+		if err := vault.Create(todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Changes made through the repository are not external
+	poll := func() []ExternalChange {
+		t.Helper()
+		changes, err := vault.ExternalChanges()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changes
+	}
+	if changes := append(poll(), poll()...); len(changes) != 0 {
+		t.Fatalf("expected no external changes, got %+v", changes)
+	}
+
+	path := filepath.Join(vault.Dir(), vaultFileName(edited.ID, edited.Title))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, append(data, "Added in an editor\n"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(vault.Dir(), "groceries.md"), []byte("- milk\n- bread\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(vault.Dir(), vaultFileName(kept.ID, kept.Title))); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes are reported once they have settled
+	if changes := poll(); len(changes) != 0 {
+		t.Fatalf("expected changes to wait for the next poll, got %+v", changes)
+	}
+	changes := poll()
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 external changes, got %+v", changes)
+	}
+	if changes[0].ID != kept.ID || !changes[0].Deleted || changes[0].Before.Title != "Kept" {
+		t.Errorf("expected todo %d to be deleted, got %+v", kept.ID, changes[0])
+	}
+	if changes[1].ID != edited.ID || changes[1].Before == nil || changes[1].Before.Description != "" {
+		t.Errorf("expected todo %d to be updated, got %+v", edited.ID, changes[1])
+	}
+	if changes[2].ID != 3 || changes[2].Before != nil {
+		t.Errorf("expected the new file to become todo 3, got %+v", changes[2])
+	}
+
+	added, err := vault.GetByID(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Title != "groceries" || added.Description != "- milk\n- bread" || added.UID == "" {
+		t.Errorf("unexpected adopted todo: %+v", added)
+	}
+	got, _ := vault.GetByID(edited.ID)
+	if got.Description != "Added in an editor" {
+		t.Errorf("expected the edited description, got %q", got.Description)
+	}
+
+	if changes := append(poll(), poll()...); len(changes) != 0 {
+		t.Errorf("expected changes to be reported once, got %+v", changes)
+	}
+}
+
+func TestVaultAcknowledge(t *testing.T) {
+	dir := t.TempDir()
+	primary, err := NewVaultTodoRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondary, err := NewVaultTodoRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A write by another instance is announced over sync, not found by polling
+	todo := newTestTodo("From another instance")
+	if err := secondary.Create(todo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := primary.ExternalChanges(); err != nil {
+		t.Fatal(err)
+	}
+	primary.Acknowledge(todo.ID)
+
+	changes, err := primary.ExternalChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected acknowledged change not to be reported, got %+v", changes)
+	}
+}
+
+func TestVaultFileName(t *testing.T) {
+	tests := map[string]string{
+		"Write annual report":  "7-write-annual-report.md",
+		"Fix: login/logout!":   "7-fix-login-logout.md",
+		"":                     "7.md",
+		"Überprüfung der Akte": "7-überprüfung-der-akte.md",
+		"A very long title that keeps going well past the limit": "7-a-very-long-title-that-keeps-going-well.md",
+	}
+	for title, want := range tests {
+		if got := vaultFileName(7, title); got != want {
+			t.Errorf("vaultFileName(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
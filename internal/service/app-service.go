@@ -1,16 +1,19 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/martijnspitter/tui-todo/internal/config"
+	"github.com/martijnspitter/tui-todo/internal/hooks"
 	"github.com/martijnspitter/tui-todo/internal/models"
 	"github.com/martijnspitter/tui-todo/internal/repository"
 	"github.com/martijnspitter/tui-todo/internal/socket_sync"
-	"github.com/martijnspitter/tui-todo/internal/utils"
+	"github.com/martijnspitter/tui-todo/internal/workspace"
 	"slices"
 )
 
@@ -23,19 +26,42 @@ type UpdateInfo struct {
 	CheckedAt   time.Time
 }
 
-type NotificationCallback func(notificationType string, todoID int64)
+type NotificationCallback func(change Change)
+
+// ErrTodoConflict is returned when a todo was changed elsewhere after it was loaded for editing
+var ErrTodoConflict = errors.New("error.todo_conflict")
+
+// ErrRemoteEncrypted is returned when sync.remote is set for an encrypted database, the outbox
+// and the relay log would keep its titles and descriptions in plain text
+var ErrRemoteEncrypted = errors.New("sync.remote can't be used with an encrypted database")
 
 type AppService struct {
 	todoRepo       repository.TodoRepository
+	config         *config.Config
 	updateInfo     *UpdateInfo
 	syncManager    *socket_sync.Manager
+	remote         *socket_sync.RemoteClient
+	hooks          *hooks.Runner
+	workspace      workspace.Workspace
+	workspaceRoot  string
+	nextWorkspace  *workspace.Workspace
 	notifCallbacks []NotificationCallback
-	mutex          sync.Mutex
+	// editing is the todo announced as open for editing, see presence.go
+	editing   int64
+	announced bool
+	// incompatible holds the refused instances until onIncompatible is set, see presence.go
+	incompatible   []*socket_sync.IncompatibleError
+	onIncompatible func(err *socket_sync.IncompatibleError)
+	// today caches the Today dashboard, a change bumps todayGeneration, see today.go
+	today           *TodayData
+	todayGeneration int
+	mutex           sync.Mutex
 }
 
 func NewAppService(todoRepo repository.TodoRepository) *AppService {
 	return &AppService{
 		todoRepo:   todoRepo,
+		config:     config.Default(),
 		updateInfo: &UpdateInfo{},
 	}
 }
@@ -47,6 +73,33 @@ func (s *AppService) SetSyncManager(manager *socket_sync.Manager) {
 	s.syncManager = manager
 }
 
+// CheckRemote reports whether the todos may be replicated through a relay
+func (s *AppService) CheckRemote() error {
+	if repo, ok := s.todoRepo.(interface{ Encrypted() bool }); ok && repo.Encrypted() {
+		return ErrRemoteEncrypted
+	}
+	return nil
+}
+
+// SetRemote replicates changes through a relay, a database that never synced before
+// publishes its existing todos and tags first
+func (s *AppService) SetRemote(remote *socket_sync.RemoteClient) {
+	s.remote = remote
+	if remote.Fresh() {
+		s.publishAll()
+	}
+}
+
+func (s *AppService) SetConfig(cfg *config.Config) {
+	s.config = cfg
+	// The dashboard sections depend on the priority and coming up settings
+	s.dropToday()
+}
+
+func (s *AppService) GetConfig() *config.Config {
+	return s.config
+}
+
 // ===========================================================================
 // Todo Methods
 // ===========================================================================
@@ -65,7 +118,22 @@ func (s *AppService) SaveTodo(todo *models.Todo, tags []string) error {
 	}
 }
 
+// NewTodo returns an unsaved todo pre-filled with the configured defaults
+func (s *AppService) NewTodo() *models.Todo {
+	return &models.Todo{
+		ID:       -1,
+		Priority: s.config.DefaultPriority(),
+		Status:   s.config.DefaultStatus(),
+	}
+}
+
 func (s *AppService) CreateTodo(title, description string, priority models.Priority, tags []string, dueDate *time.Time, status models.Status) error {
+	_, err := s.createTodo(title, description, priority, tags, dueDate, status)
+	return err
+}
+
+// createTodo creates a todo and returns it with its ID
+func (s *AppService) createTodo(title, description string, priority models.Priority, tags []string, dueDate *time.Time, status models.Status) (*models.Todo, error) {
 	todo := &models.Todo{
 		Title:       title,
 		Description: description,
@@ -83,54 +151,77 @@ func (s *AppService) CreateTodo(title, description string, priority models.Prior
 		todo.TimeStarted = &now
 	}
 
+	if err := s.checkHooks(socket_sync.TodoCreated, nil, withTags(todo, tags)); err != nil {
+		return nil, err
+	}
+
 	err := s.todoRepo.Create(todo)
 	if err != nil {
-		log.Error("Failed to create todo", "error", err, "title", title)
-		return fmt.Errorf("error.create_failed")
+		log.Error("Failed to create todo", "error", err)
+		return nil, fmt.Errorf("error.create_failed")
 	}
 
+	// The tags are part of the created todo, peers and hooks hear about them in one change
 	for _, tag := range tags {
-		err := s.AddTagToTodo(todo.ID, tag)
+		err := s.todoRepo.AddTagToTodo(todo.ID, tag)
 		if err != nil {
 			log.Error("Could not add tag: %s %w", tag, err)
-			return fmt.Errorf("error.tag_add_failed")
+			return nil, fmt.Errorf("error.tag_add_failed")
 		}
 	}
 
-	s.notify(socket_sync.TodoCreated, todo.ID)
+	s.notifyTodo(socket_sync.TodoCreated, todo.ID, nil)
 
-	return nil
+	return todo, nil
 }
 
 func (s *AppService) UpdateTodo(todo *models.Todo, tags []string) error {
+	before := s.syncBaseline(todo.ID)
+	if err := s.checkHooks(socket_sync.TodoUpdated, before, withTags(todo, tags)); err != nil {
+		return err
+	}
+
 	todo.UpdatedAt = time.Now()
 	err := s.todoRepo.Update(todo)
+	var conflict *repository.ConflictError
+	if errors.As(err, &conflict) {
+		log.Warn("Todo was changed by another instance", "id", todo.ID, "error", err)
+		if conflict.Deleted {
+			return fmt.Errorf("error.todo_not_found")
+		}
+		return ErrTodoConflict
+	}
 	if err != nil {
 		log.Error("Failed to update todo", "error", err, "id", todo.ID)
 		return fmt.Errorf("error.update_failed")
 	}
 
 	for _, tag := range tags {
-		err := s.AddTagToTodo(todo.ID, tag)
+		err := s.todoRepo.AddTagToTodo(todo.ID, tag)
 		if err != nil {
 			log.Error("Could not add tag: %s %w", tag, err)
 			return fmt.Errorf("error.tag_add_failed")
 		}
 	}
 
-	s.notify(socket_sync.TodoUpdated, todo.ID)
+	s.notifyTodo(socket_sync.TodoUpdated, todo.ID, before)
 
 	return nil
 }
 
 func (s *AppService) DeleteTodo(id int64) error {
+	before := s.syncBaseline(id)
+	if err := s.checkHooks(socket_sync.TodoDeleted, before, nil); err != nil {
+		return err
+	}
+
 	err := s.todoRepo.Delete(id)
 	if err != nil {
 		log.Error("Failed to delete todo", "error", err, "id", id)
 		return fmt.Errorf("error.delete_failed")
 	}
 
-	s.notify(socket_sync.TodoDeleted, id)
+	s.notifyTodo(socket_sync.TodoDeleted, id, before)
 
 	return nil
 }
@@ -144,6 +235,7 @@ func (s *AppService) MarkAsOpen(id int64) error {
 		log.Error("Failed to fetch todo for status change", "error", err, "id", id)
 		return fmt.Errorf("error.todo_not_found")
 	}
+	before := *todo
 
 	// If transitioning from Doing to Open, calculate elapsed time
 	if todo.Status == models.Doing && todo.TimeStarted != nil {
@@ -155,13 +247,17 @@ func (s *AppService) MarkAsOpen(id int64) error {
 	todo.Status = models.Open
 	todo.UpdatedAt = time.Now()
 
+	if err := s.checkHooks(socket_sync.TodoUpdated, &before, todo); err != nil {
+		return err
+	}
+
 	err = s.todoRepo.Update(todo)
 	if err != nil {
 		log.Error("Failed to mark todo as open", "error", err, "id", id)
 		return fmt.Errorf("error.status_change_failed")
 	}
 
-	s.notify(socket_sync.TodoUpdated, todo.ID)
+	s.notifyTodo(socket_sync.TodoUpdated, todo.ID, &before)
 
 	return nil
 }
@@ -172,6 +268,7 @@ func (s *AppService) MarkAsDoing(id int64) error {
 		log.Error("Failed to fetch todo for status change", "error", err, "id", id)
 		return fmt.Errorf("error.todo_not_found")
 	}
+	before := *todo
 
 	// Set time_started only if not already in Doing status
 	if todo.Status != models.Doing {
@@ -182,13 +279,17 @@ func (s *AppService) MarkAsDoing(id int64) error {
 	todo.Status = models.Doing
 	todo.UpdatedAt = time.Now()
 
+	if err := s.checkHooks(socket_sync.TodoUpdated, &before, todo); err != nil {
+		return err
+	}
+
 	err = s.todoRepo.Update(todo)
 	if err != nil {
 		log.Error("Failed to mark todo as doing", "error", err, "id", id)
 		return fmt.Errorf("error.status_change_failed")
 	}
 
-	s.notify(socket_sync.TodoUpdated, todo.ID)
+	s.notifyTodo(socket_sync.TodoUpdated, todo.ID, &before)
 
 	return nil
 }
@@ -199,6 +300,7 @@ func (s *AppService) MarkAsDone(id int64) error {
 		log.Error("Failed to fetch todo for status change", "error", err, "id", id)
 		return fmt.Errorf("error.todo_not_found")
 	}
+	before := *todo
 
 	// Calculate and accumulate time spent if task was in Doing status
 	if todo.Status == models.Doing && todo.TimeStarted != nil {
@@ -210,13 +312,17 @@ func (s *AppService) MarkAsDone(id int64) error {
 	todo.Status = models.Done
 	todo.UpdatedAt = time.Now()
 
+	if err := s.checkHooks(socket_sync.TodoUpdated, &before, todo); err != nil {
+		return err
+	}
+
 	err = s.todoRepo.Update(todo)
 	if err != nil {
 		log.Error("Failed to mark todo as done", "error", err, "id", id)
 		return fmt.Errorf("error.status_change_failed")
 	}
 
-	s.notify(socket_sync.TodoUpdated, todo.ID)
+	s.notifyTodo(socket_sync.TodoUpdated, todo.ID, &before)
 
 	return nil
 }
@@ -227,17 +333,22 @@ func (s *AppService) ArchiveTodo(todoID int64) error {
 		log.Error("Failed to fetch todo for archiving", "error", err, "id", todoID)
 		return fmt.Errorf("error.todo_not_found")
 	}
+	before := *todo
 
 	todo.Archived = true
 	todo.UpdatedAt = time.Now()
 
+	if err := s.checkHooks(socket_sync.TodoUpdated, &before, todo); err != nil {
+		return err
+	}
+
 	err = s.todoRepo.Update(todo)
 	if err != nil {
 		log.Error("Failed to archive todo", "error", err, "id", todoID)
 		return fmt.Errorf("error.archive_failed")
 	}
 
-	s.notify(socket_sync.TodoUpdated, todo.ID)
+	s.notifyTodo(socket_sync.TodoUpdated, todo.ID, &before)
 
 	return nil
 }
@@ -248,6 +359,7 @@ func (s *AppService) UnarchiveTodo(todoID int64) error {
 		log.Error("Failed to fetch todo for unarchiving", "error", err, "id", todoID)
 		return fmt.Errorf("error.todo_not_found")
 	}
+	before := *todo
 
 	todo.Archived = false
 	todo.UpdatedAt = time.Now()
@@ -258,7 +370,7 @@ func (s *AppService) UnarchiveTodo(todoID int64) error {
 		return fmt.Errorf("error.unarchive_failed")
 	}
 
-	s.notify(socket_sync.TodoUpdated, todo.ID)
+	s.notifyTodo(socket_sync.TodoUpdated, todo.ID, &before)
 
 	return nil
 }
@@ -269,6 +381,7 @@ func (s *AppService) MarkAsBlocked(id int64) error {
 		log.Error("Failed to fetch todo for status change", "error", err, "id", id)
 		return fmt.Errorf("error.todo_not_found")
 	}
+	before := *todo
 
 	// If transitioning from Doing to Blocked, calculate elapsed time
 	if todo.Status == models.Doing && todo.TimeStarted != nil {
@@ -280,13 +393,17 @@ func (s *AppService) MarkAsBlocked(id int64) error {
 	todo.Status = models.Blocked
 	todo.UpdatedAt = time.Now()
 
+	if err := s.checkHooks(socket_sync.TodoUpdated, &before, todo); err != nil {
+		return err
+	}
+
 	err = s.todoRepo.Update(todo)
 	if err != nil {
 		log.Error("Failed to mark todo as blocked", "error", err, "id", id)
 		return fmt.Errorf("error.status_change_failed")
 	}
 
-	s.notify(socket_sync.TodoUpdated, todo.ID)
+	s.notifyTodo(socket_sync.TodoUpdated, todo.ID, &before)
 
 	return nil
 }
@@ -295,25 +412,31 @@ func (s *AppService) MarkAsBlocked(id int64) error {
 // Tag methods
 // ===========================================================================
 func (s *AppService) AddTagToTodo(todoID int64, tag string) error {
+	before := s.syncBaseline(todoID)
+	if err := s.checkHooks(socket_sync.TodoUpdated, before, withTags(before, []string{tag})); err != nil {
+		return err
+	}
+
 	err := s.todoRepo.AddTagToTodo(todoID, tag)
 	if err != nil {
 		log.Error("Failed to add tag to todo", "error", err, "todoID", todoID, "tag", tag)
 		return fmt.Errorf("error.tag_add_failed")
 	}
 
-	s.notify(socket_sync.TodoUpdated, todoID)
+	s.notifyTodo(socket_sync.TodoUpdated, todoID, before)
 
 	return nil
 }
 
 func (s *AppService) RemoveTagFromTodo(todoID int64, tag string) error {
+	before := s.syncBaseline(todoID)
 	err := s.todoRepo.RemoveTagFromTodo(todoID, tag)
 	if err != nil {
 		log.Error("Failed to remove tag from todo", "error", err, "todoID", todoID, "tag", tag)
 		return fmt.Errorf("error.tag_remove_failed")
 	}
 
-	s.notify(socket_sync.TodoUpdated, todoID)
+	s.notifyTodo(socket_sync.TodoUpdated, todoID, before)
 
 	return nil
 }
@@ -337,16 +460,28 @@ func (s *AppService) CreateTag(tag *models.Tag) error {
 		log.Error("Failed to create tag", "error", err, "tag", tag)
 		return fmt.Errorf("error.tag_create_failed")
 	}
+
+	s.notifyTag(socket_sync.TagCreated, tag.ID, tag)
+
 	return nil
 }
 
 // DeleteTag removes a tag from the system
 func (s *AppService) DeleteTag(id int64) error {
+	// The tag is gone after the delete, keep it so other machines can match it
+	var before *models.Tag
+	if s.syncManager != nil {
+		before = s.findTag(id)
+	}
+
 	err := s.todoRepo.DeleteTag(id)
 	if err != nil {
 		log.Error("Failed to delete tag", "error", err, "tag", id)
 		return fmt.Errorf("error.tag_delete_failed")
 	}
+
+	s.notifyTag(socket_sync.TagDeleted, id, before)
+
 	return nil
 }
 
@@ -358,8 +493,7 @@ func (s *AppService) UpdateTag(tag *models.Tag) error {
 		return fmt.Errorf("error.tag_update_failed")
 	}
 
-	// Notify that the tag has been updated
-	s.notify(socket_sync.TodoUpdated, tag.ID)
+	s.notifyTag(socket_sync.TagUpdated, tag.ID, tag)
 
 	return nil
 }
@@ -373,6 +507,7 @@ func (s *AppService) SetDueDate(todoID int64, dueDate time.Time) error {
 		log.Error("Failed to fetch todo for setting due date", "error", err, "id", todoID)
 		return fmt.Errorf("error.todos_not_found")
 	}
+	before := *todo
 
 	todo.DueDate = &dueDate
 	todo.UpdatedAt = time.Now()
@@ -383,7 +518,7 @@ func (s *AppService) SetDueDate(todoID int64, dueDate time.Time) error {
 		return fmt.Errorf("error.update_failed")
 	}
 
-	s.notify(socket_sync.TodoUpdated, todo.ID)
+	s.notifyTodo(socket_sync.TodoUpdated, todo.ID, &before)
 
 	return nil
 }
@@ -394,6 +529,7 @@ func (s *AppService) ClearDueDate(todoID int64) error {
 		log.Error("Failed to fetch todo for clearing due date", "error", err, "id", todoID)
 		return fmt.Errorf("error.todos_not_found")
 	}
+	before := *todo
 
 	todo.DueDate = nil
 	todo.UpdatedAt = time.Now()
@@ -404,7 +540,7 @@ func (s *AppService) ClearDueDate(todoID int64) error {
 		return fmt.Errorf("error.update_failed")
 	}
 
-	s.notify(socket_sync.TodoUpdated, todo.ID)
+	s.notifyTodo(socket_sync.TodoUpdated, todo.ID, &before)
 
 	return nil
 }
@@ -418,6 +554,7 @@ func (s *AppService) SetPriority(todoID int64, priority models.Priority) error {
 		log.Error("Failed to fetch todo for setting priority", "error", err, "id", todoID)
 		return fmt.Errorf("error.todos_not_found")
 	}
+	before := *todo
 
 	todo.Priority = priority
 	todo.UpdatedAt = time.Now()
@@ -428,7 +565,7 @@ func (s *AppService) SetPriority(todoID int64, priority models.Priority) error {
 		return fmt.Errorf("error.update_failed")
 	}
 
-	s.notify(socket_sync.TodoUpdated, todo.ID)
+	s.notifyTodo(socket_sync.TodoUpdated, todo.ID, &before)
 
 	return nil
 }
@@ -539,6 +676,17 @@ func (s *AppService) GetAllTodos(showArchived bool) ([]*models.Todo, error) {
 	return sortTodos(todos), nil
 }
 
+// GetTodosMatching returns the todos matching all filters
+func (s *AppService) GetTodosMatching(filters ...repository.Filter) ([]*models.Todo, error) {
+	todos, err := s.todoRepo.GetAll(filters...)
+	if err != nil {
+		log.Error("Failed to fetch todos", "error", err)
+		return nil, fmt.Errorf("error.todos_not_found")
+	}
+
+	return sortTodos(todos), nil
+}
+
 func (s *AppService) GetTodo(id int64) (*models.Todo, error) {
 	todo, err := s.todoRepo.GetByID(id)
 	if err != nil {
@@ -589,93 +737,39 @@ func (s *AppService) GetBlockedTodos() ([]*models.Todo, error) {
 }
 
 // ===========================================================================
-// Today methods
+// Helpers
 // ===========================================================================
-func (s *AppService) GetTodosForToday() (highPrio, dueToday, inProgress, blockedTasks, overDue, comingUp []*models.Todo, error error) {
-	// Get todos that are due today and not archived
-	highPrio, err := s.todoRepo.GetAll(repository.PrioAboveHighFilter(), repository.NotArchivedFilter())
-	if err != nil {
-		log.Error("Failed to fetch highPrio for today", "error", err)
-		return nil, nil, nil, nil, nil, nil, fmt.Errorf("error.todos_not_found")
-	}
-	dueToday, err = s.todoRepo.GetAll(repository.DueTodayFilter(), repository.NotArchivedFilter())
-	if err != nil {
-		log.Error("Failed to fetch dueToday for today", "error", err)
-		return nil, nil, nil, nil, nil, nil, fmt.Errorf("error.todos_not_found")
-	}
-	inProgress, err = s.todoRepo.GetActive()
-	if err != nil {
-		log.Error("Failed to fetch inProgress for today", "error", err)
-		return nil, nil, nil, nil, nil, nil, fmt.Errorf("error.todos_not_found")
-	}
-	blockedTasks, err = s.todoRepo.GetBlocked()
-	if err != nil {
-		log.Error("Failed to fetch blockedTasks for today", "error", err)
-		return nil, nil, nil, nil, nil, nil, fmt.Errorf("error.todos_not_found")
-	}
-	overDue, err = s.todoRepo.GetAll(repository.OverDueFilter(), repository.NotArchivedFilter())
-	if err != nil {
-		log.Error("Failed to fetch overDue for today", "error", err)
-		return nil, nil, nil, nil, nil, nil, fmt.Errorf("error.todos_not_found")
-	}
-	comingUp, err = s.todoRepo.GetAll(repository.ComingUpFilter(), repository.NotArchivedFilter())
-	if err != nil {
-		log.Error("Failed to fetch comingUp for today", "error", err)
-		return nil, nil, nil, nil, nil, nil, fmt.Errorf("error.todos_not_found")
-	}
-
-	return sortTodos(highPrio), sortTodos(dueToday), sortTodos(inProgress), sortTodos(blockedTasks), sortTodos(overDue), sortTodos(comingUp), nil
-}
 
-func (s *AppService) GetTodayCompletionStats() (completed int, total int, formattedTimeSpent string) {
-	var timeSpent int64
-	// Get tasks completed today
-	completedToday, err := s.todoRepo.GetAll(repository.CompletedTodayFilter())
-	if err != nil {
-		log.Error("Failed to fetch today's completed tasks", "error", err)
-		return 0, 0, ""
-	}
-
-	// Get all tasks that would show in today's dashboard (not completed yet)
-	currentTodayTasks, err := s.todoRepo.GetAll(repository.AllTodayFilter(), repository.NotArchivedFilter())
-	if err != nil {
-		log.Error("Failed to fetch today's tasks", "error", err)
-		return len(completedToday), len(completedToday), ""
-	}
-
-	for _, todo := range completedToday {
-		timeSpent += todo.GetTotalSeconds()
-	}
-	for _, todo := range currentTodayTasks {
-		if todo.Status == models.Blocked {
-			continue // Skip blocked tasks for time tracking
-		}
-		timeSpent += todo.GetTotalSeconds()
+// PatchTodos replaces the todo with the same ID in a sorted list, or removes it when the
+// todo is nil or no longer belongs in the list. The result is sorted like the queries.
+func PatchTodos(todos []*models.Todo, id int64, todo *models.Todo, belongs bool) []*models.Todo {
+	patched := slices.DeleteFunc(slices.Clone(todos), func(t *models.Todo) bool {
+		return t.ID == id
+	})
+	if todo != nil && belongs {
+		patched = append(patched, todo)
 	}
+	return sortTodos(patched)
+}
 
-	activeToday := 0
-	for _, t := range currentTodayTasks {
-		if t.Status == models.Blocked {
-			continue
-		}
-		activeToday++
+// PatchTags is PatchTodos for the tag list, which is sorted by name
+func PatchTags(tags []*models.Tag, id int64, tag *models.Tag) []*models.Tag {
+	patched := slices.DeleteFunc(slices.Clone(tags), func(t *models.Tag) bool {
+		return t.ID == id
+	})
+	if tag != nil {
+		patched = append(patched, tag)
 	}
-	return len(completedToday), len(completedToday) + activeToday, utils.FormatTime(timeSpent)
+	sort.SliceStable(patched, func(i, j int) bool {
+		return patched[i].Name < patched[j].Name
+	})
+	return patched
 }
 
-// ===========================================================================
-// Helpers
-// ===========================================================================
 func sortTodos(todos []*models.Todo) []*models.Todo {
-	// Sort todos by priority (high to low) and then by updatedAt (newest first)
+	// Sort todos by priority (high to low) and then by updatedAt (newest first), like the pages
 	sort.Slice(todos, func(i, j int) bool {
-		// If priorities are different, sort by priority (high to low)
-		if todos[i].Priority != todos[j].Priority {
-			return todos[i].Priority > todos[j].Priority
-		}
-
-		// If priorities are the same, sort by updatedAt (newest first)
-		return todos[i].UpdatedAt.After(todos[j].UpdatedAt)
+		return repository.InListOrder(todos[i], todos[j])
 	})
 
 	return todos
@@ -702,13 +796,14 @@ func (s *AppService) AdvanceStatus(todoID int64) (models.Status, error) {
 		err = s.MarkAsDone(todoID)
 	}
 
+	if errors.Is(err, ErrHookVetoed) {
+		return 0, err
+	}
 	if err != nil {
 		log.Error("Failed to advance status", "error", err, "todoID", todoID, "fromStatus", todo.Status, "toStatus", newStatus)
 		return 0, fmt.Errorf("error.update_failed")
 	}
 
-	s.notify(socket_sync.TodoUpdated, todo.ID)
-
 	return newStatus, nil
 }
 
@@ -738,6 +833,111 @@ func (s *AppService) GetFilteredTodos(currentView ViewType, showArchived bool) (
 	return todos, nil
 }
 
+// PageSize is how many todos a pane loads at a time
+const PageSize = 100
+
+// GetFilteredTodosPage returns up to limit todos of a pane that follow the cursor, in the
+// order of GetFilteredTodos. A limit of 0 or less loads the rest of the pane. more is set
+// when todos follow the page.
+func (s *AppService) GetFilteredTodosPage(currentView ViewType, showArchived bool, after *repository.Cursor, limit int) (todos []*models.Todo, more bool, err error) {
+	filters, ok := paneFilters(currentView, showArchived)
+	if !ok {
+		log.Info("Unknown view", currentView)
+		return nil, false, nil
+	}
+
+	if pager, ok := s.todoRepo.(repository.Pager); ok {
+		todos, more, err = pager.GetPage(after, limit, filters...)
+	} else {
+		todos, err = s.todoRepo.GetAll(filters...)
+		todos, more = repository.PageOf(todos, after, limit)
+	}
+	if err != nil {
+		log.Error("Failed to fetch a page of todos", "error", err, "view", currentView)
+		return nil, false, fmt.Errorf("error.todos_not_found")
+	}
+
+	return todos, more, nil
+}
+
+// paneFilters returns the filters of the queries behind a pane
+func paneFilters(view ViewType, showArchived bool) ([]repository.Filter, bool) {
+	switch view {
+	case OpenPane:
+		return []repository.Filter{repository.StatusFilter(models.Open), repository.NotArchivedFilter()}, true
+	case DoingPane:
+		return []repository.Filter{repository.StatusFilter(models.Doing), repository.NotArchivedFilter()}, true
+	case DonePane:
+		return []repository.Filter{repository.StatusFilter(models.Done), repository.NotArchivedFilter()}, true
+	case BlockedPane:
+		return []repository.Filter{repository.StatusFilter(models.Blocked), repository.NotArchivedFilter()}, true
+	case AllPane:
+		if showArchived {
+			return []repository.Filter{repository.ArchivedFilter()}, true
+		}
+		return []repository.Filter{repository.NotArchivedFilter()}, true
+	}
+	return nil, false
+}
+
+// InPane reports whether a todo is listed in a pane, mirroring the queries of GetFilteredTodos
+func InPane(todo *models.Todo, view ViewType, showArchived bool) bool {
+	switch view {
+	case OpenPane:
+		return !todo.Archived && todo.Status == models.Open
+	case DoingPane:
+		return !todo.Archived && todo.Status == models.Doing
+	case DonePane:
+		return !todo.Archived && todo.Status == models.Done
+	case BlockedPane:
+		return !todo.Archived && todo.Status == models.Blocked
+	case AllPane:
+		return todo.Archived == showArchived
+	}
+	return false
+}
+
+// GetTodosForExport returns the todos shown in a pane, optionally narrowed down to a single tag.
+// For the Today pane all dashboard sections are combined without duplicates.
+func (s *AppService) GetTodosForExport(currentView ViewType, showArchived bool, tagName string) ([]*models.Todo, error) {
+	var todos []*models.Todo
+
+	if currentView == TodayPane {
+		highPrio, dueToday, inProgress, blocked, overDue, comingUp, err := s.GetTodosForToday()
+		if err != nil {
+			return nil, err
+		}
+
+		seen := make(map[int64]bool)
+		for _, section := range [][]*models.Todo{highPrio, overDue, dueToday, inProgress, blocked, comingUp} {
+			for _, todo := range section {
+				if !seen[todo.ID] {
+					seen[todo.ID] = true
+					todos = append(todos, todo)
+				}
+			}
+		}
+	} else {
+		var err error
+		todos, err = s.GetFilteredTodos(currentView, showArchived)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if tagName == "" {
+		return todos, nil
+	}
+
+	filtered := make([]*models.Todo, 0, len(todos))
+	for _, todo := range todos {
+		if slices.Contains(todo.Tags, tagName) {
+			filtered = append(filtered, todo)
+		}
+	}
+	return filtered, nil
+}
+
 // ===========================================================================
 // Update Info Methods
 // ===========================================================================
@@ -778,16 +978,396 @@ func (s *AppService) OnNotification(notification socket_sync.Notification) {
 	callbacks := slices.Clone(s.notifCallbacks)
 	s.mutex.Unlock()
 
+	if s.remote != nil && !notification.Replicated {
+		s.forwardToRelay(notification)
+	}
+	s.acknowledgeExternalChange(notification)
+
+	change := NewChange(notification)
+	s.todayNotified(change)
 	for _, cb := range callbacks {
-		cb(string(notification.Type), notification.ID)
+		cb(change)
 	}
 }
 
-func (s *AppService) notify(nt socket_sync.NotificationType, id int64) {
-	if s.syncManager != nil {
-		if err := s.syncManager.NotifyChange(nt, id); err != nil {
-			log.Warn("Failed to notify other instances", "error", err)
-			// Continue anyway - don't fail the operation due to sync issues
+// notifyTodo tells other instances and the hooks about a changed todo. The todo is read back
+// after the write so the snapshot includes its tags, before is the state it is diffed against.
+func (s *AppService) notifyTodo(nt socket_sync.NotificationType, id int64, before *models.Todo) {
+	// Without a cached dashboard nothing needs the todo read back
+	if s.syncManager == nil && s.hooks == nil && !s.todayCached() {
+		s.todayChanged(id, nil, false)
+		return
+	}
+
+	var after *models.Todo
+	if nt != socket_sync.TodoDeleted {
+		todo, err := s.todoRepo.GetByID(id)
+		if err != nil {
+			log.Warn("Failed to read todo for sync, peers will reload", "error", err, "id", id)
+		} else {
+			after = todo
+		}
+	}
+	s.todayChanged(id, after, after != nil || nt == socket_sync.TodoDeleted)
+
+	if s.syncManager == nil && s.hooks == nil {
+		return
+	}
+	s.runHooks(nt, before, after)
+	if s.syncManager == nil {
+		return
+	}
+
+	notification := socket_sync.NewNotification(nt, socket_sync.EntityTodo, id, before, after)
+	notification.UID = todoUID(after, before)
+	s.notify(notification)
+}
+
+// notifyTag tells other instances about a changed tag, for deletes tag is the removed tag
+func (s *AppService) notifyTag(nt socket_sync.NotificationType, id int64, tag *models.Tag) {
+	s.todayTagChanged(nt)
+	if s.syncManager == nil {
+		return
+	}
+
+	var notification socket_sync.Notification
+	if nt == socket_sync.TagDeleted {
+		notification = socket_sync.NewNotification(nt, socket_sync.EntityTag, id, nil, nil)
+	} else {
+		notification = socket_sync.NewNotification(nt, socket_sync.EntityTag, id, nil, tag)
+	}
+	if tag != nil {
+		notification.UID = tag.UID
+	}
+	s.notify(notification)
+}
+
+func (s *AppService) notify(notification socket_sync.Notification) {
+	if err := s.syncManager.NotifyChange(notification); err != nil {
+		log.Warn("Failed to notify other instances", "error", err)
+		// Continue anyway - don't fail the operation due to sync issues
+	}
+
+	// The primary hears its own changes back from the server, a secondary only those of
+	// others. Callbacks like the HTTP event stream need every change.
+	if !s.syncManager.IsPrimary() {
+		s.OnNotification(notification)
+	}
+}
+
+// syncBaseline returns the stored todo for diffing against after a write, nil without sync
+// or hooks
+func (s *AppService) syncBaseline(id int64) *models.Todo {
+	if s.syncManager == nil && s.hooks == nil {
+		return nil
+	}
+	todo, err := s.todoRepo.GetByID(id)
+	if err != nil {
+		return nil
+	}
+	return todo
+}
+
+// Change is a todo or tag change reported by another instance
+type Change struct {
+	Type         socket_sync.NotificationType
+	ID           int64
+	notification socket_sync.Notification
+}
+
+// NewChange wraps a notification received from another instance
+func NewChange(notification socket_sync.Notification) Change {
+	return Change{Type: notification.Type, ID: notification.ID, notification: notification}
+}
+
+// CanPatch reports whether the change can be applied in place. Peers speaking another
+// protocol version, or messages without the changed entity, require a full reload.
+func (c Change) CanPatch() bool {
+	if c.notification.Version != socket_sync.ProtocolVersion {
+		return false
+	}
+	return c.IsDeleted() || c.notification.HasPayload()
+}
+
+// IsTag reports whether the change concerns a tag rather than a todo
+func (c Change) IsTag() bool {
+	return c.notification.Entity == socket_sync.EntityTag
+}
+
+// UID identifies the changed todo or tag across machines
+func (c Change) UID() string {
+	return c.notification.UID
+}
+
+// Seq is the position of the change in the primary's change log, 0 when it wasn't logged
+func (c Change) Seq() int64 {
+	return c.notification.Seq
+}
+
+func (c Change) IsDeleted() bool {
+	return c.Type == socket_sync.TodoDeleted || c.Type == socket_sync.TagDeleted
+}
+
+// Todo returns the changed todo. current is the receiver's copy, which a diff is applied to,
+// and may be nil when the receiver doesn't have it. Deleted todos are reported as nil.
+func (c Change) Todo(current *models.Todo) (*models.Todo, bool) {
+	if c.IsTag() {
+		return nil, false
+	}
+	if c.IsDeleted() {
+		return nil, true
+	}
+
+	var todo models.Todo
+	if current != nil {
+		todo = *current
+	} else if c.notification.Snapshot == nil {
+		return nil, false
+	}
+	if err := c.notification.Decode(&todo); err != nil {
+		log.Warn("Failed to decode todo change", "error", err, "id", c.ID)
+		return nil, false
+	}
+	return &todo, true
+}
+
+// Tag returns the changed tag, deleted tags are reported as nil
+func (c Change) Tag() (*models.Tag, bool) {
+	if !c.IsTag() {
+		return nil, false
+	}
+	if c.IsDeleted() {
+		return nil, true
+	}
+
+	var tag models.Tag
+	if err := c.notification.Decode(&tag); err != nil {
+		log.Warn("Failed to decode tag change", "error", err, "id", c.ID)
+		return nil, false
+	}
+	return &tag, true
+}
+
+// ===========================================================================
+// Replication
+// ===========================================================================
+
+// OnRemoteChange applies a change made on another machine and tells the local instances
+// about it. Todos keep the most recently updated version when both machines changed them.
+func (s *AppService) OnRemoteChange(notification socket_sync.Notification) {
+	store, ok := s.todoRepo.(repository.ReplicaStore)
+	if !ok {
+		log.Warn("Repository does not support replication, ignoring remote change", "type", notification.Type)
+		return
+	}
+	if notification.UID == "" {
+		log.Warn("Ignoring remote change without identity", "type", notification.Type)
+		return
+	}
+
+	var err error
+	if isTagNotification(notification) {
+		err = s.applyRemoteTag(store, notification)
+	} else {
+		err = s.applyRemoteTodo(store, notification)
+	}
+	if err != nil {
+		log.Error("Failed to apply remote change", "error", err, "type", notification.Type, "uid", notification.UID)
+	}
+}
+
+func (s *AppService) applyRemoteTodo(store repository.ReplicaStore, notification socket_sync.Notification) error {
+	if notification.Type == socket_sync.TodoDeleted {
+		id, err := store.DeleteReplica(notification.UID)
+		if err != nil || id == 0 {
+			return err
+		}
+		s.notifyReplicated(socket_sync.NewNotification(socket_sync.TodoDeleted, socket_sync.EntityTodo, id, nil, nil), notification.UID)
+		return nil
+	}
+
+	var todo models.Todo
+	if err := notification.Decode(&todo); err != nil {
+		return err
+	}
+	todo.UID = notification.UID
+
+	nt := socket_sync.TodoCreated
+	current, err := store.GetByUID(todo.UID)
+	if err == nil {
+		if current.UpdatedAt.After(todo.UpdatedAt) {
+			// The local version is newer and reaches the other machine the same way
+			return nil
+		}
+		nt = socket_sync.TodoUpdated
+	}
+
+	if err := store.SaveReplica(&todo); err != nil {
+		return err
+	}
+	// Read back for the local revision, the snapshot carries the one of the other machine
+	saved, err := s.todoRepo.GetByID(todo.ID)
+	if err != nil {
+		return err
+	}
+	s.notifyReplicated(socket_sync.NewNotification(nt, socket_sync.EntityTodo, saved.ID, current, saved), saved.UID)
+	return nil
+}
+
+func (s *AppService) applyRemoteTag(store repository.ReplicaStore, notification socket_sync.Notification) error {
+	if notification.Type == socket_sync.TagDeleted {
+		id, err := store.DeleteTagReplica(notification.UID)
+		if err != nil || id == 0 {
+			return err
+		}
+		s.notifyReplicated(socket_sync.NewNotification(socket_sync.TagDeleted, socket_sync.EntityTag, id, nil, nil), notification.UID)
+		return nil
+	}
+
+	var tag models.Tag
+	if err := notification.Decode(&tag); err != nil {
+		return err
+	}
+	tag.UID = notification.UID
+
+	nt := socket_sync.TagCreated
+	if current := s.findTagByUID(tag.UID); current != nil {
+		if current.UpdatedAt.After(tag.UpdatedAt) {
+			return nil
+		}
+		nt = socket_sync.TagUpdated
+	}
+
+	if err := store.SaveTagReplica(&tag); err != nil {
+		return err
+	}
+	s.notifyReplicated(socket_sync.NewNotification(nt, socket_sync.EntityTag, tag.ID, nil, &tag), tag.UID)
+	return nil
+}
+
+// notifyReplicated tells the local instances about a change that was applied from the relay,
+// it is marked so it isn't sent back
+func (s *AppService) notifyReplicated(notification socket_sync.Notification, uid string) {
+	notification.UID = uid
+	notification.Replicated = true
+
+	if s.syncManager == nil {
+		s.OnNotification(notification)
+		return
+	}
+	s.notify(notification)
+}
+
+// forwardToRelay sends a local change to the relay. The full entity is read back because
+// notifications between instances may only carry a diff.
+func (s *AppService) forwardToRelay(notification socket_sync.Notification) {
+	isTag := isTagNotification(notification)
+	entity := socket_sync.EntityTodo
+	if isTag {
+		entity = socket_sync.EntityTag
+	}
+
+	var change socket_sync.Notification
+	switch {
+	case notification.Type == socket_sync.TodoDeleted || notification.Type == socket_sync.TagDeleted:
+		if notification.UID == "" {
+			log.Warn("Deleted entity has no identity, other machines keep it", "type", notification.Type, "id", notification.ID)
+			return
+		}
+		change = socket_sync.NewNotification(notification.Type, entity, notification.ID, nil, nil)
+		change.UID = notification.UID
+	case isTag:
+		tag := s.findTag(notification.ID)
+		if tag == nil {
+			return
+		}
+		change = socket_sync.NewNotification(notification.Type, entity, tag.ID, nil, tag)
+		change.UID = tag.UID
+	default:
+		todo, err := s.todoRepo.GetByID(notification.ID)
+		if err != nil {
+			log.Warn("Failed to read todo for relay", "error", err, "id", notification.ID)
+			return
+		}
+		change = socket_sync.NewNotification(notification.Type, entity, todo.ID, nil, todo)
+		change.UID = todo.UID
+	}
+
+	if err := s.remote.Publish(change); err != nil {
+		log.Warn("Failed to queue change for relay", "error", err)
+	}
+}
+
+// publishAll sends every todo and tag to the relay, used the first time a database syncs
+func (s *AppService) publishAll() {
+	tags, err := s.todoRepo.GetAllTags()
+	if err != nil {
+		log.Error("Failed to read tags for relay", "error", err)
+		return
+	}
+	for _, tag := range tags {
+		change := socket_sync.NewNotification(socket_sync.TagCreated, socket_sync.EntityTag, tag.ID, nil, tag)
+		change.UID = tag.UID
+		if err := s.remote.Publish(change); err != nil {
+			log.Warn("Failed to queue tag for relay", "error", err, "tag", tag.Name)
+		}
+	}
+
+	todos, err := s.todoRepo.GetAll()
+	if err != nil {
+		log.Error("Failed to read todos for relay", "error", err)
+		return
+	}
+	for _, todo := range todos {
+		change := socket_sync.NewNotification(socket_sync.TodoCreated, socket_sync.EntityTodo, todo.ID, nil, todo)
+		change.UID = todo.UID
+		if err := s.remote.Publish(change); err != nil {
+			log.Warn("Failed to queue todo for relay", "error", err, "id", todo.ID)
+		}
+	}
+	log.Info("Published existing data to relay", "todos", len(todos), "tags", len(tags))
+}
+
+func (s *AppService) findTag(id int64) *models.Tag {
+	tags, err := s.todoRepo.GetAllTags()
+	if err != nil {
+		return nil
+	}
+	for _, tag := range tags {
+		if tag.ID == id {
+			return tag
+		}
+	}
+	return nil
+}
+
+func (s *AppService) findTagByUID(uid string) *models.Tag {
+	tags, err := s.todoRepo.GetAllTags()
+	if err != nil {
+		return nil
+	}
+	for _, tag := range tags {
+		if tag.UID == uid {
+			return tag
+		}
+	}
+	return nil
+}
+
+// isTagNotification also recognizes tag changes from peers that don't send the entity
+func isTagNotification(notification socket_sync.Notification) bool {
+	switch notification.Type {
+	case socket_sync.TagCreated, socket_sync.TagUpdated, socket_sync.TagDeleted:
+		return true
+	}
+	return notification.Entity == socket_sync.EntityTag
+}
+
+func todoUID(todos ...*models.Todo) string {
+	for _, todo := range todos {
+		if todo != nil && todo.UID != "" {
+			return todo.UID
 		}
 	}
+	return ""
 }
@@ -1,17 +1,26 @@
 package service_test
 
 import (
+	"bytes"
 	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/charmbracelet/log"
+	"github.com/martijnspitter/tui-todo/internal/encryption"
 	"github.com/martijnspitter/tui-todo/internal/models"
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
 	"github.com/martijnspitter/tui-todo/internal/repository"
 	"github.com/martijnspitter/tui-todo/internal/service"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
 	"pgregory.net/rapid"
 )
 
-// MockTodoRepository implements repository.TodoRepository for testing
+// MockTodoRepository implements repository.TodoRepository for testing. Its GetAll ignores
+// filters, tests that depend on them use newMemoryRepository.
 type MockTodoRepository struct {
 	// These fields track calls to the methods
 	CreatedTodos []*models.Todo
@@ -1576,6 +1585,153 @@ func TestGetFilteredTodos(t *testing.T) {
 	}
 }
 
+// newMemoryRepository stores the todos in an in-memory repository, which evaluates filters
+// like the database does
+func newMemoryRepository(t *testing.T, todos ...*models.Todo) *repository.InMemoryTodoRepository {
+	t.Helper()
+	repo := repository.NewInMemoryTodoRepository()
+	for _, todo := range todos {
+		tags := todo.Tags
+		if err := repo.Create(todo); err != nil {
+			t.Fatal(err)
+		}
+		for _, tag := range tags {
+			if err := repo.AddTagToTodo(todo.ID, tag); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return repo
+}
+
+func TestGetFilteredTodosMatchesInPane(t *testing.T) {
+	var todos []*models.Todo
+	for _, status := range []models.Status{models.Open, models.Doing, models.Done, models.Blocked} {
+		for _, archived := range []bool{false, true} {
+			todo := createTestTodo(0)
+			todo.Status, todo.Archived = status, archived
+			todos = append(todos, todo)
+		}
+	}
+	svc := service.NewAppService(newMemoryRepository(t, todos...))
+
+	for _, view := range []service.ViewType{service.OpenPane, service.DoingPane, service.DonePane, service.BlockedPane, service.AllPane} {
+		for _, showArchived := range []bool{false, true} {
+			got, err := svc.GetFilteredTodos(view, showArchived)
+			if err != nil {
+				t.Fatalf("GetFilteredTodos(%v, %v) error: %v", view, showArchived, err)
+			}
+			want := 0
+			for _, todo := range todos {
+				if service.InPane(todo, view, showArchived) {
+					want++
+				}
+			}
+			for _, todo := range got {
+				if !service.InPane(todo, view, showArchived) {
+					t.Errorf("GetFilteredTodos(%v, %v) returned %+v, which InPane leaves out", view, showArchived, todo)
+				}
+			}
+			if len(got) != want {
+				t.Errorf("GetFilteredTodos(%v, %v) returned %d todos, InPane lists %d", view, showArchived, len(got), want)
+			}
+		}
+	}
+}
+
+func TestGetFilteredTodosPage(t *testing.T) {
+	var todos []*models.Todo
+	for i := range 12 {
+		todo := createTestTodo(int64(i + 1))
+		todo.Priority = models.Priority(i % 3)
+		todo.UpdatedAt = todo.UpdatedAt.Add(time.Duration(i/2) * time.Minute)
+		todos = append(todos, todo)
+	}
+
+	repos := map[string]func() repository.TodoRepository{
+		"backend with pages": func() repository.TodoRepository { return newMemoryRepository(t, todos...) },
+		"backend without":    func() repository.TodoRepository { return &MockTodoRepository{MockTodos: todos} },
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			svc := service.NewAppService(repo())
+			want, err := svc.GetFilteredTodos(service.OpenPane, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []*models.Todo
+			var after *repository.Cursor
+			for more := true; more; {
+				var page []*models.Todo
+				page, more, err = svc.GetFilteredTodosPage(service.OpenPane, false, after, 5)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, page...)
+				if len(page) > 0 {
+					after = repository.CursorOf(page[len(page)-1])
+				}
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("pages held %d todos, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if got[i].ID != want[i].ID {
+					t.Errorf("todo %d of the pages is %d, want %d", i, got[i].ID, want[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestGetTodosForExport(t *testing.T) {
+	t.Run("Tag filter narrows pane todos", func(t *testing.T) {
+		tagged := createTestTodo(0)
+		tagged.Tags = []string{"work"}
+		untagged := createTestTodo(0)
+		untagged.Tags = nil
+		svc := service.NewAppService(newMemoryRepository(t, tagged, untagged))
+
+		todos, err := svc.GetTodosForExport(service.OpenPane, false, "work")
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if len(todos) != 1 || todos[0].ID != tagged.ID {
+			t.Errorf("Expected only the tagged todo, got %v", todos)
+		}
+	})
+
+	t.Run("Today sections are deduplicated", func(t *testing.T) {
+		// An overdue todo of high priority is in two sections
+		yesterday := time.Now().AddDate(0, 0, -1)
+		urgent := createTestTodo(0)
+		urgent.Priority, urgent.DueDate = models.Critical, &yesterday
+		doing := createTestTodo(0)
+		doing.Status = models.Doing
+		someday := createTestTodo(0)
+		svc := service.NewAppService(newMemoryRepository(t, urgent, doing, someday))
+
+		todos, err := svc.GetTodosForExport(service.TodayPane, false, "")
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if len(todos) != 2 {
+			t.Errorf("Expected 2 unique todos, got %d", len(todos))
+		}
+	})
+
+	t.Run("Repository error", func(t *testing.T) {
+		mockRepo := &MockTodoRepository{MockError: errors.New("repository error")}
+		svc := service.NewAppService(mockRepo)
+
+		if _, err := svc.GetTodosForExport(service.AllPane, false, ""); err == nil {
+			t.Error("Expected error but got nil")
+		}
+	})
+}
+
 func TestUpdateInfoMethods(t *testing.T) {
 	// Test SetUpdateInfo and GetUpdateInfo
 	t.Run("SetUpdateInfo_GetUpdateInfo", func(t *testing.T) {
@@ -2089,3 +2245,426 @@ func TestTimeTrackingProperties(t *testing.T) {
 		}
 	}))
 }
+
+func TestRemoteChange(t *testing.T) {
+	due := time.Date(2025, 3, 1, 9, 0, 0, 0, time.Local)
+	current := &models.Todo{ID: 4, Title: "Write report", Status: models.Open, DueDate: &due, Tags: []string{"work"}}
+
+	t.Run("Snapshot replaces the todo", func(t *testing.T) {
+		after := *current
+		after.Status = models.Doing
+		change := service.NewChange(socket_sync.NewNotification(socket_sync.TodoUpdated, socket_sync.EntityTodo, 4, current, &after))
+
+		if !change.CanPatch() {
+			t.Fatalf("CanPatch() = false, want true")
+		}
+		todo, ok := change.Todo(current)
+		if !ok || todo.Status != models.Doing || todo.Tags[0] != "work" {
+			t.Fatalf("Todo() = %+v, %v", todo, ok)
+		}
+		if todo == current {
+			t.Errorf("Todo() should return a new todo")
+		}
+	})
+
+	t.Run("Diff is applied to a copy", func(t *testing.T) {
+		after := *current
+		newDue := due.AddDate(0, 0, 1)
+		after.DueDate = &newDue
+		notification := socket_sync.NewNotification(socket_sync.TodoUpdated, socket_sync.EntityTodo, 4, current, &after)
+		notification.Snapshot = nil
+		change := service.NewChange(notification)
+
+		todo, ok := change.Todo(current)
+		if !ok || !todo.DueDate.Equal(newDue) || todo.Title != "Write report" {
+			t.Fatalf("Todo() = %+v, %v", todo, ok)
+		}
+		if !current.DueDate.Equal(due) {
+			t.Errorf("the receiver's copy was modified: %v", current.DueDate)
+		}
+
+		// Without a copy to apply the diff to the change can't be patched in
+		if _, ok := change.Todo(nil); ok {
+			t.Errorf("Todo(nil) with a diff should not be patchable")
+		}
+	})
+
+	t.Run("Deleted todo", func(t *testing.T) {
+		change := service.NewChange(socket_sync.NewNotification(socket_sync.TodoDeleted, socket_sync.EntityTodo, 4, nil, nil))
+		todo, ok := change.Todo(current)
+		if !change.CanPatch() || !ok || todo != nil {
+			t.Errorf("Todo() = %v, %v, want nil, true", todo, ok)
+		}
+	})
+
+	t.Run("Tag change", func(t *testing.T) {
+		tag := &models.Tag{ID: 2, Name: "home"}
+		change := service.NewChange(socket_sync.NewNotification(socket_sync.TagUpdated, socket_sync.EntityTag, 2, nil, tag))
+		if !change.IsTag() {
+			t.Fatalf("IsTag() = false")
+		}
+		if _, ok := change.Todo(current); ok {
+			t.Errorf("a tag change should not decode as a todo")
+		}
+		decoded, ok := change.Tag()
+		if !ok || decoded.Name != "home" {
+			t.Errorf("Tag() = %+v, %v", decoded, ok)
+		}
+	})
+
+	t.Run("Other protocol versions reload", func(t *testing.T) {
+		legacy := service.NewChange(socket_sync.Notification{Type: socket_sync.TodoUpdated, ID: 4})
+		if legacy.CanPatch() {
+			t.Errorf("CanPatch() = true for a notification without version")
+		}
+
+		newer := socket_sync.NewNotification(socket_sync.TodoUpdated, socket_sync.EntityTodo, 4, nil, current)
+		newer.Version = socket_sync.ProtocolVersion + 1
+		if service.NewChange(newer).CanPatch() {
+			t.Errorf("CanPatch() = true for a newer protocol version")
+		}
+	})
+}
+
+func TestInPane(t *testing.T) {
+	testCases := []struct {
+		name         string
+		todo         models.Todo
+		view         service.ViewType
+		showArchived bool
+		expected     bool
+	}{
+		{"Open todo in open pane", models.Todo{Status: models.Open}, service.OpenPane, false, true},
+		{"Archived todo not in open pane", models.Todo{Status: models.Open, Archived: true}, service.OpenPane, false, false},
+		{"Doing todo not in done pane", models.Todo{Status: models.Doing}, service.DonePane, false, false},
+		{"Blocked todo in blocked pane", models.Todo{Status: models.Blocked}, service.BlockedPane, false, true},
+		{"All pane hides archived", models.Todo{Archived: true}, service.AllPane, false, false},
+		{"All pane shows only archived", models.Todo{}, service.AllPane, true, false},
+		{"Today is not a list pane", models.Todo{}, service.TodayPane, false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := service.InPane(&tc.todo, tc.view, tc.showArchived); got != tc.expected {
+				t.Errorf("InPane() = %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestInTodaySection(t *testing.T) {
+	now := time.Date(2025, 6, 10, 14, 0, 0, 0, time.Local)
+	at := func(days int) *time.Time {
+		d := time.Date(2025, 6, 10+days, 9, 0, 0, 0, time.Local)
+		return &d
+	}
+
+	testCases := []struct {
+		name     string
+		todo     models.Todo
+		section  service.TodaySection
+		expected bool
+	}{
+		{"Major without due date is high priority", models.Todo{Priority: models.Major}, service.HighPrioritySection, true},
+		{"Major due later is not high priority", models.Todo{Priority: models.Major, DueDate: at(2)}, service.HighPrioritySection, false},
+		{"Done is not high priority", models.Todo{Priority: models.Critical, Status: models.Done}, service.HighPrioritySection, false},
+		{"Due this morning", models.Todo{DueDate: at(0)}, service.DueTodaySection, true},
+		{"Due yesterday is overdue", models.Todo{DueDate: at(-1)}, service.OverDueSection, true},
+		{"Due yesterday is not due today", models.Todo{DueDate: at(-1)}, service.DueTodaySection, false},
+		{"Due in two days is coming up", models.Todo{DueDate: at(2)}, service.ComingUpSection, true},
+		{"Due in three days is outside the window", models.Todo{DueDate: at(3)}, service.ComingUpSection, false},
+		{"Doing is in progress", models.Todo{Status: models.Doing}, service.InProgressSection, true},
+		{"Blocked", models.Todo{Status: models.Blocked}, service.BlockedSection, true},
+		{"Archived is never shown", models.Todo{Status: models.Doing, Archived: true}, service.InProgressSection, false},
+	}
+
+	appService := service.NewAppService(&MockTodoRepository{})
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := appService.InTodaySection(&tc.todo, tc.section, now); got != tc.expected {
+				t.Errorf("InTodaySection() = %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestPatchTodos(t *testing.T) {
+	low := &models.Todo{ID: 1, Priority: models.Low}
+	high := &models.Todo{ID: 2, Priority: models.High}
+	todos := []*models.Todo{high, low}
+
+	raised := &models.Todo{ID: 1, Priority: models.Critical}
+	patched := service.PatchTodos(todos, 1, raised, true)
+	if len(patched) != 2 || patched[0] != raised || patched[1] != high {
+		t.Errorf("patched = %v, want the raised todo first", patched)
+	}
+
+	removed := service.PatchTodos(todos, 2, high, false)
+	if len(removed) != 1 || removed[0] != low {
+		t.Errorf("removed = %v, want only the low todo", removed)
+	}
+
+	if todos[0] != high || todos[1] != low {
+		t.Errorf("the original list was modified")
+	}
+
+	tags := service.PatchTags([]*models.Tag{{ID: 1, Name: "b"}, {ID: 2, Name: "c"}}, 3, &models.Tag{ID: 3, Name: "a"})
+	if len(tags) != 3 || tags[0].Name != "a" {
+		t.Errorf("tags = %v, want the new tag first", tags)
+	}
+}
+
+// machine is an app instance with its own database, connected to a relay
+type machine struct {
+	service *service.AppService
+	repo    *repository.SQLiteTodoRepository
+	remote  *socket_sync.RemoteClient
+}
+
+func startMachine(t *testing.T, relayAddress string) *machine {
+	t.Helper()
+
+	dir := t.TempDir()
+	osoperations.SetDataDir(dir)
+	t.Cleanup(func() { osoperations.SetDataDir("") })
+
+	repo, err := repository.NewSQLiteTodoRepository("test")
+	if err != nil {
+		t.Fatalf("NewSQLiteTodoRepository() error: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	appService := service.NewAppService(repo)
+	manager, err := socket_sync.NewManager("test", appService)
+	if err != nil {
+		t.Fatalf("NewManager() error: %v", err)
+	}
+	appService.SetSyncManager(manager)
+	if err := manager.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	t.Cleanup(func() { manager.Stop() })
+
+	remote, err := socket_sync.NewRemoteClient(socket_sync.RemoteConfig{
+		Address:   relayAddress,
+		Secret:    "shared",
+		StatePath: filepath.Join(dir, "sync-remote.json"),
+	}, appService)
+	if err != nil {
+		t.Fatalf("NewRemoteClient() error: %v", err)
+	}
+	appService.SetRemote(remote)
+	remote.Start()
+	t.Cleanup(func() { remote.Stop() })
+
+	return &machine{service: appService, repo: repo, remote: remote}
+}
+
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReplicationBetweenMachines(t *testing.T) {
+	relayLog := filepath.Join(t.TempDir(), "relay.log")
+	relay, err := socket_sync.NewRelay(socket_sync.RelayConfig{Listen: "127.0.0.1:0", Secret: "shared", LogPath: relayLog})
+	if err != nil {
+		t.Fatalf("NewRelay() error: %v", err)
+	}
+	if err := relay.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	address := relay.Addr().String()
+
+	laptop := startMachine(t, address)
+	desktop := startMachine(t, address)
+	eventually(t, "both machines to connect", func() bool { return laptop.remote.Connected() && desktop.remote.Connected() })
+
+	if err := laptop.service.CreateTodo("Buy milk", "", models.Low, []string{"home"}, nil, models.Open); err != nil {
+		t.Fatalf("CreateTodo() error: %v", err)
+	}
+	todos, _ := laptop.repo.GetAll()
+	uid := todos[0].UID
+
+	var replica *models.Todo
+	eventually(t, "the todo to reach the desktop", func() bool {
+		replica, err = desktop.repo.GetByUID(uid)
+		return err == nil
+	})
+	if replica.Title != "Buy milk" || len(replica.Tags) != 1 || replica.Tags[0] != "home" {
+		t.Errorf("replica = %+v, want the todo with its tag", replica)
+	}
+
+	replica.Title = "Buy oat milk"
+	if err := desktop.service.UpdateTodo(replica, replica.Tags); err != nil {
+		t.Fatalf("UpdateTodo() error: %v", err)
+	}
+	eventually(t, "the update to reach the laptop", func() bool {
+		todo, err := laptop.repo.GetByUID(uid)
+		return err == nil && todo.Title == "Buy oat milk"
+	})
+
+	// A change made while the relay is down is delivered after it restarts
+	if err := relay.Stop(); err != nil {
+		t.Fatalf("Stop() error: %v", err)
+	}
+	eventually(t, "the laptop to notice", func() bool { return !laptop.remote.Connected() })
+
+	if err := laptop.service.DeleteTodo(todos[0].ID); err != nil {
+		t.Fatalf("DeleteTodo() error: %v", err)
+	}
+	if laptop.remote.Pending() != 1 {
+		t.Fatalf("Pending() = %d, want the delete queued", laptop.remote.Pending())
+	}
+
+	relay, err = socket_sync.NewRelay(socket_sync.RelayConfig{Listen: address, Secret: "shared", LogPath: relayLog})
+	if err != nil {
+		t.Fatalf("NewRelay() error: %v", err)
+	}
+	if err := relay.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	defer relay.Stop()
+
+	eventually(t, "the delete to reach the desktop", func() bool {
+		_, err := desktop.repo.GetByUID(uid)
+		return err != nil
+	})
+}
+
+func TestUpdateTodoConflict(t *testing.T) {
+	osoperations.SetDataDir(t.TempDir())
+	t.Cleanup(func() { osoperations.SetDataDir("") })
+
+	repo, err := repository.NewSQLiteTodoRepository("test")
+	if err != nil {
+		t.Fatalf("NewSQLiteTodoRepository() error: %v", err)
+	}
+	defer repo.Close()
+	appService := service.NewAppService(repo)
+
+	if err := appService.CreateTodo("Report", "", models.Low, nil, nil, models.Open); err != nil {
+		t.Fatalf("CreateTodo() error: %v", err)
+	}
+	todos, _ := repo.GetAll()
+
+	// Two instances open the same todo for editing
+	mine, _ := appService.GetTodo(todos[0].ID)
+	theirs, _ := appService.GetTodo(todos[0].ID)
+
+	theirs.Title = "Annual report"
+	if err := appService.UpdateTodo(theirs, nil); err != nil {
+		t.Fatalf("first UpdateTodo() error: %v", err)
+	}
+
+	mine.Title = "Quarterly report"
+	if err := appService.UpdateTodo(mine, nil); !errors.Is(err, service.ErrTodoConflict) {
+		t.Fatalf("second UpdateTodo() error = %v, want ErrTodoConflict", err)
+	}
+
+	stored, _ := appService.GetTodo(mine.ID)
+	if stored.Title != "Annual report" {
+		t.Errorf("Title = %q, the conflicting save must not overwrite", stored.Title)
+	}
+
+	// Saving on top of the stored revision succeeds
+	mine.Revision = stored.Revision
+	if err := appService.UpdateTodo(mine, nil); err != nil {
+		t.Errorf("UpdateTodo() after resolving error: %v", err)
+	}
+
+	if err := repo.Delete(mine.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := appService.UpdateTodo(mine, nil); err == nil || err.Error() != "error.todo_not_found" {
+		t.Errorf("UpdateTodo() of a deleted todo error = %v, want error.todo_not_found", err)
+	}
+}
+
+func TestEncryptedDataDirHoldsNoPlainText(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todo.sql")
+
+	// The log goes to the data dir, as it does in the app
+	logFile, err := os.Create(filepath.Join(dir, "debug.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.SetOutput(logFile)
+	log.SetLevel(log.DebugLevel)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetLevel(log.InfoLevel)
+		logFile.Close()
+	})
+
+	repo, err := repository.OpenSQLiteTodoRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appService := service.NewAppService(repo)
+	if err := appService.CreateTodo("Secret plan", "Buy the surprise gift", models.Low, []string{"home"}, nil, models.Open); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	db, err := repository.OpenSQLiteDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, key, err := repository.EncryptDatabase(db, "correct horse battery staple", encryption.KDFParams{Time: 1, Memory: 64, Threads: 1})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err = repository.OpenSQLiteTodoRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Unlock(key); err != nil {
+		t.Fatal(err)
+	}
+	appService = service.NewAppService(repo)
+	if err := appService.CheckRemote(); !errors.Is(err, service.ErrRemoteEncrypted) {
+		t.Errorf("CheckRemote() of an encrypted database = %v", err)
+	}
+	todos, err := appService.GetTodosMatching(repository.Where(repository.FieldTitle, repository.OpContains, "secret"))
+	if err != nil || len(todos) != 1 {
+		t.Fatalf("searching the encrypted database = %v, %v", todos, err)
+	}
+	todos[0].Title = "Secret plan, second draft"
+	if err := appService.UpdateTodo(todos[0], nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := appService.CreateTodo("Hidden agenda", "", models.High, nil, nil, models.Doing); err != nil {
+		t.Fatal(err)
+	}
+	repo.Close()
+
+	err = filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		for _, text := range []string{"Secret plan", "surprise gift", "second draft", "Hidden agenda"} {
+			if bytes.Contains(data, []byte(text)) {
+				t.Errorf("%s holds %q in plain text", filepath.Base(file), text)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
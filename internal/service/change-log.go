@@ -0,0 +1,80 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+// DeletedChangeRetention is how long the change log remembers deletes. Other changes are
+// kept until a later change of the same todo or tag supersedes them.
+const DeletedChangeRetention = 30 * 24 * time.Hour
+
+// ChangeLog keeps the sync change log in the database, so the primary can replay what an
+// instance missed while it was disconnected
+type ChangeLog struct {
+	store repository.ChangeLogStore
+}
+
+// NewChangeLog returns the change log of the repository, ok is false when it keeps none
+func NewChangeLog(repo repository.TodoRepository) (*ChangeLog, bool) {
+	store, ok := repo.(repository.ChangeLogStore)
+	if !ok {
+		return nil, false
+	}
+	return &ChangeLog{store: store}, true
+}
+
+// Append stores the notification and sets its Seq
+func (c *ChangeLog) Append(notification *socket_sync.Notification) error {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	record := &models.ChangeRecord{
+		Key:     notification.EntityKey(),
+		Deleted: notification.Type == socket_sync.TodoDeleted || notification.Type == socket_sync.TagDeleted,
+		Payload: payload,
+	}
+	if err := c.store.AppendChange(record); err != nil {
+		return err
+	}
+
+	notification.Seq = record.Seq
+	return nil
+}
+
+// Since returns the notifications logged after seq, oldest first
+func (c *ChangeLog) Since(seq int64) ([]socket_sync.Notification, error) {
+	records, err := c.store.ChangesSince(seq)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := make([]socket_sync.Notification, 0, len(records))
+	for _, record := range records {
+		var notification socket_sync.Notification
+		if err := json.Unmarshal(record.Payload, &notification); err != nil {
+			return nil, err
+		}
+		notification.Seq = record.Seq
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+// Head returns the Seq of the latest logged notification
+func (c *ChangeLog) Head() (int64, error) {
+	return c.store.ChangeHead()
+}
+
+// Compact drops superseded notifications and deletes older than DeletedChangeRetention
+func (c *ChangeLog) Compact() error {
+	_, err := c.store.CompactChanges(time.Now().Add(-DeletedChangeRetention))
+	return err
+}
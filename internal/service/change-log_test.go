@@ -0,0 +1,85 @@
+package service_test
+
+import (
+	"slices"
+	"testing"
+
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/service"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+func TestChangeLog(t *testing.T) {
+	osoperations.SetDataDir(t.TempDir())
+	t.Cleanup(func() { osoperations.SetDataDir("") })
+
+	repo, err := repository.NewSQLiteTodoRepository("test")
+	if err != nil {
+		t.Fatalf("NewSQLiteTodoRepository() error: %v", err)
+	}
+	defer repo.Close()
+
+	changeLog, ok := service.NewChangeLog(repo)
+	if !ok {
+		t.Fatal("NewChangeLog() found no change log in the SQLite repository")
+	}
+
+	changes := []socket_sync.Notification{
+		{Type: socket_sync.TodoCreated, Entity: socket_sync.EntityTodo, ID: 1, UID: "a"},
+		{Type: socket_sync.TodoCreated, Entity: socket_sync.EntityTodo, ID: 2, UID: "b"},
+		{Type: socket_sync.TodoUpdated, Entity: socket_sync.EntityTodo, ID: 1, UID: "a"},
+		{Type: socket_sync.TodoDeleted, Entity: socket_sync.EntityTodo, ID: 2, UID: "b"},
+	}
+	for i := range changes {
+		if err := changeLog.Append(&changes[i]); err != nil {
+			t.Fatalf("Append() error: %v", err)
+		}
+		if changes[i].Seq != int64(i+1) {
+			t.Errorf("Seq = %d, want %d", changes[i].Seq, i+1)
+		}
+	}
+
+	t.Run("Since returns later changes in order", func(t *testing.T) {
+		since, err := changeLog.Since(1)
+		if err != nil {
+			t.Fatalf("Since() error: %v", err)
+		}
+		if got := seqsOf(since); !slices.Equal(got, []int64{2, 3, 4}) {
+			t.Errorf("Since(1) = %v, want 2, 3 and 4", got)
+		}
+		if since[1].Type != socket_sync.TodoUpdated || since[1].UID != "a" {
+			t.Errorf("Since(1)[1] = %+v, want the update of a", since[1])
+		}
+	})
+
+	t.Run("Compact keeps the latest change of every todo", func(t *testing.T) {
+		if err := changeLog.Compact(); err != nil {
+			t.Fatalf("Compact() error: %v", err)
+		}
+		since, err := changeLog.Since(0)
+		if err != nil {
+			t.Fatalf("Since() error: %v", err)
+		}
+		if got := seqsOf(since); !slices.Equal(got, []int64{3, 4}) {
+			t.Errorf("Since(0) = %v, want 3 and 4", got)
+		}
+
+		// The head doesn't move back when the latest records are compacted away
+		head, err := changeLog.Head()
+		if err != nil {
+			t.Fatalf("Head() error: %v", err)
+		}
+		if head != 4 {
+			t.Errorf("Head() = %d, want 4", head)
+		}
+	})
+}
+
+func seqsOf(notifications []socket_sync.Notification) []int64 {
+	var seqs []int64
+	for _, notification := range notifications {
+		seqs = append(seqs, notification.Seq)
+	}
+	return seqs
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"slices"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+// TodoField is a part of a todo that is merged on its own when two edits conflict
+type TodoField int
+
+const (
+	TitleField TodoField = iota
+	DescriptionField
+	TagsField
+	DueDateField
+	PriorityField
+	StatusField
+)
+
+// TodoFields lists the mergeable fields in the order of the edit modal
+var TodoFields = []TodoField{TitleField, DescriptionField, TagsField, DueDateField, PriorityField, StatusField}
+
+var todoFieldKeys = map[TodoField]string{
+	TitleField:       "field.title",
+	DescriptionField: "field.description",
+	TagsField:        "field.tags",
+	DueDateField:     "field.due",
+	PriorityField:    "field.priority",
+	StatusField:      "field.status",
+}
+
+// String returns the translation key of the field name
+func (f TodoField) String() string {
+	return todoFieldKeys[f]
+}
+
+// Conflict is a save that lost against another edit of the same todo. Base is the todo as
+// it was opened for editing, Mine the edited version and Theirs the version stored now.
+type Conflict struct {
+	Base   models.Todo
+	Mine   models.Todo
+	Theirs models.Todo
+}
+
+func NewConflict(base, mine, theirs *models.Todo) *Conflict {
+	return &Conflict{Base: *base, Mine: *mine, Theirs: *theirs}
+}
+
+// Conflicting returns the fields both sides changed to different values
+func (c *Conflict) Conflicting() []TodoField {
+	var fields []TodoField
+	for _, field := range TodoFields {
+		if c.changed(&c.Mine, field) && c.changed(&c.Theirs, field) && !fieldEqual(&c.Mine, &c.Theirs, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Merge combines both edits on top of the stored revision. Fields changed on one side take
+// that side's value, conflicting fields take mine when useMine is set for them.
+func (c *Conflict) Merge(useMine map[TodoField]bool) *models.Todo {
+	merged := c.Theirs
+	merged.Tags = slices.Clone(c.Theirs.Tags)
+
+	for _, field := range TodoFields {
+		if !c.changed(&c.Mine, field) {
+			continue
+		}
+		if c.changed(&c.Theirs, field) && !useMine[field] {
+			continue
+		}
+		copyField(&merged, &c.Mine, field)
+	}
+
+	return &merged
+}
+
+// KeepMine overwrites every field with mine on top of the stored revision
+func (c *Conflict) KeepMine() *models.Todo {
+	merged := c.Theirs
+	for _, field := range TodoFields {
+		copyField(&merged, &c.Mine, field)
+	}
+	return &merged
+}
+
+func (c *Conflict) changed(side *models.Todo, field TodoField) bool {
+	return !fieldEqual(&c.Base, side, field)
+}
+
+func fieldEqual(a, b *models.Todo, field TodoField) bool {
+	switch field {
+	case TitleField:
+		return a.Title == b.Title
+	case DescriptionField:
+		return a.Description == b.Description
+	case TagsField:
+		tagsA, tagsB := slices.Clone(a.Tags), slices.Clone(b.Tags)
+		slices.Sort(tagsA)
+		slices.Sort(tagsB)
+		return slices.Equal(tagsA, tagsB)
+	case DueDateField:
+		if a.DueDate == nil || b.DueDate == nil {
+			return a.DueDate == nil && b.DueDate == nil
+		}
+		return a.DueDate.Equal(*b.DueDate)
+	case PriorityField:
+		return a.Priority == b.Priority
+	case StatusField:
+		return a.Status == b.Status
+	}
+	return true
+}
+
+func copyField(dst, src *models.Todo, field TodoField) {
+	switch field {
+	case TitleField:
+		dst.Title = src.Title
+	case DescriptionField:
+		dst.Description = src.Description
+	case TagsField:
+		dst.Tags = slices.Clone(src.Tags)
+	case DueDateField:
+		dst.DueDate = src.DueDate
+	case PriorityField:
+		dst.Priority = src.Priority
+	case StatusField:
+		dst.Status = src.Status
+	}
+}
@@ -0,0 +1,61 @@
+package service_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/service"
+)
+
+func TestConflict(t *testing.T) {
+	due := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	base := &models.Todo{ID: 1, Title: "Report", Description: "Draft", Tags: []string{"work"}, Priority: models.Low, Status: models.Open, Revision: 3}
+
+	mine := *base
+	mine.Title = "Quarterly report"
+	mine.Priority = models.High
+	mine.Tags = []string{"work", "q2"}
+
+	theirs := *base
+	theirs.Title = "Annual report"
+	theirs.DueDate = &due
+	theirs.Status = models.Doing
+	theirs.Tags = []string{"q2", "work"}
+	theirs.Revision = 4
+
+	conflict := service.NewConflict(base, &mine, &theirs)
+
+	t.Run("Only fields changed differently conflict", func(t *testing.T) {
+		got := conflict.Conflicting()
+		if !slices.Equal(got, []service.TodoField{service.TitleField}) {
+			t.Errorf("Conflicting() = %v, want only the title", got)
+		}
+	})
+
+	t.Run("Merge takes the changed side", func(t *testing.T) {
+		merged := conflict.Merge(nil)
+		if merged.Title != "Annual report" {
+			t.Errorf("Title = %q, want theirs without a choice", merged.Title)
+		}
+		if merged.Priority != models.High || merged.Status != models.Doing || merged.DueDate == nil {
+			t.Errorf("merged = %+v, want my priority and their status and due date", merged)
+		}
+		if merged.Revision != 4 {
+			t.Errorf("Revision = %d, want the stored revision", merged.Revision)
+		}
+
+		merged = conflict.Merge(map[service.TodoField]bool{service.TitleField: true})
+		if merged.Title != "Quarterly report" {
+			t.Errorf("Title = %q, want mine when chosen", merged.Title)
+		}
+	})
+
+	t.Run("KeepMine overwrites their changes", func(t *testing.T) {
+		kept := conflict.KeepMine()
+		if kept.Title != "Quarterly report" || kept.Status != models.Open || kept.DueDate != nil || kept.Revision != 4 {
+			t.Errorf("KeepMine() = %+v", kept)
+		}
+	})
+}
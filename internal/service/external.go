@@ -0,0 +1,158 @@
+package service
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+// ===========================================================================
+// External changes
+// ===========================================================================
+
+// ExternalPollInterval is how often a backend that can be edited by other tools is checked
+// while its directory can't be watched, and how long a change settles before it is checked
+const ExternalPollInterval = 2 * time.Second
+
+// ExternalRescanInterval is how often a watched directory is checked anyway, events can get
+// lost, e.g. on network drives
+const ExternalRescanInterval = time.Minute
+
+// WatchExternalChanges watches the repository for todos that were changed outside the program
+// and notifies them like changes made in the interface. Only the primary instance checks, the
+// others learn about the changes through sync. When the directory can't be watched it is
+// polled every interval. It returns a function that stops watching, repositories that can't
+// be edited externally are not watched.
+func (s *AppService) WatchExternalChanges(interval time.Duration) (stop func()) {
+	source, ok := s.todoRepo.(repository.ExternalChangeSource)
+	if !ok {
+		return func() {}
+	}
+
+	// The directory is watched before returning, so no change made after it is missed
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		if err = watcher.Add(source.WatchDir()); err != nil {
+			watcher.Close()
+		}
+	}
+	if err != nil {
+		log.Warn("Failed to watch for external changes, polling instead", "dir", source.WatchDir(), "error", err)
+		watcher = nil
+	}
+
+	done := make(chan struct{})
+	go s.watchExternalChanges(source, watcher, interval, done)
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// watchExternalChanges checks the repository after its directory changed, or every interval
+// without a watcher
+func (s *AppService) watchExternalChanges(source repository.ExternalChangeSource, watcher *fsnotify.Watcher, interval time.Duration, done <-chan struct{}) {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	rescan := interval
+	if watcher != nil {
+		defer watcher.Close()
+		events, errs = watcher.Events, watcher.Errors
+		rescan = ExternalRescanInterval
+	}
+
+	ticker := time.NewTicker(rescan)
+	defer ticker.Stop()
+
+	// A change is reported once it looked the same on two checks, so after an event the
+	// directory is checked until it was quiet for two intervals
+	var settle <-chan time.Time
+	quiet := 0
+	changed := func() {
+		quiet = 0
+		if settle == nil {
+			settle = time.After(interval)
+		}
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.pollExternalChanges(source)
+		case event := <-events:
+			if source.HoldsTodo(filepath.Base(event.Name)) {
+				changed()
+			}
+		case err := <-errs:
+			// Events may have been dropped
+			log.Warn("Watching for external changes failed", "error", err)
+			changed()
+		case <-settle:
+			s.pollExternalChanges(source)
+			settle = nil
+			if quiet++; quiet < 2 {
+				settle = time.After(interval)
+			}
+		}
+	}
+}
+
+func (s *AppService) pollExternalChanges(source repository.ExternalChangeSource) {
+	if s.syncManager != nil && !s.syncManager.IsPrimary() {
+		return
+	}
+
+	changes, err := source.ExternalChanges()
+	if err != nil {
+		log.Warn("Failed to check for external changes", "error", err)
+		return
+	}
+	for _, change := range changes {
+		s.applyExternalChange(change)
+	}
+}
+
+// applyExternalChange notifies an external change. Without sync the instance is alone and
+// tells its own callbacks.
+func (s *AppService) applyExternalChange(change repository.ExternalChange) {
+	nt := socket_sync.TodoUpdated
+	switch {
+	case change.Deleted:
+		nt = socket_sync.TodoDeleted
+	case change.Before == nil:
+		nt = socket_sync.TodoCreated
+	}
+	log.Info("Todo changed outside the program", "id", change.ID, "type", nt)
+
+	s.notifyTodo(nt, change.ID, change.Before)
+	if s.syncManager != nil {
+		return
+	}
+
+	// Without the stored todo the interface reloads, which is fine for a deleted one
+	var after any
+	if !change.Deleted {
+		if todo, err := s.todoRepo.GetByID(change.ID); err == nil {
+			after = todo
+		}
+	}
+	s.OnNotification(socket_sync.NewNotification(nt, socket_sync.EntityTodo, change.ID, change.Before, after))
+}
+
+// acknowledgeExternalChange keeps a notified change from being reported as external when
+// this instance polls the repository
+func (s *AppService) acknowledgeExternalChange(notification socket_sync.Notification) {
+	if notification.Entity != socket_sync.EntityTodo {
+		return
+	}
+	if source, ok := s.todoRepo.(repository.ExternalChangeSource); ok {
+		source.Acknowledge(notification.ID)
+	}
+}
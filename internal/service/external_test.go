@@ -0,0 +1,45 @@
+package service_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/service"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+func TestWatchExternalChanges(t *testing.T) {
+	dir := t.TempDir()
+	vault, err := repository.NewVaultTodoRepository(dir)
+	if err != nil {
+		t.Fatalf("NewVaultTodoRepository() error: %v", err)
+	}
+	appService := service.NewAppService(vault)
+
+	changes := make(chan service.Change, 1)
+	appService.RegisterNotificationCallback(func(change service.Change) {
+		changes <- change
+	})
+	stop := appService.WatchExternalChanges(10 * time.Millisecond)
+	defer stop()
+
+	if err := os.WriteFile(filepath.Join(dir, "call-mom.md"), []byte("---\ntitle: Call mom\npriority: high\n---\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case change := <-changes:
+		if change.Type != socket_sync.TodoCreated || !change.CanPatch() {
+			t.Errorf("change = %+v, want a created todo that can be patched in", change)
+		}
+		todo, err := appService.GetTodo(change.ID)
+		if err != nil || todo.Title != "Call mom" {
+			t.Errorf("GetTodo() = %+v, %v", todo, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the new file was not noticed")
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/martijnspitter/tui-todo/internal/gitlink"
+	"github.com/martijnspitter/tui-todo/internal/models"
+)
+
+// ===========================================================================
+// Git integration
+// ===========================================================================
+
+// LinkedTodo returns the todo a branch was created for
+func (s *AppService) LinkedTodo(branch string) (*models.Todo, bool) {
+	id, ok := gitlink.ParseBranch(branch)
+	if !ok {
+		return nil, false
+	}
+	// Branches outlive their todos, a missing one is not worth an error in the log
+	todo, err := s.todoRepo.GetByID(id)
+	if err != nil {
+		return nil, false
+	}
+	return todo, true
+}
+
+// StartLinkedTodo moves the todo of a branch that was checked out to Doing. Todos that are
+// done or archived are left alone, checking out an old branch doesn't reopen its work.
+func (s *AppService) StartLinkedTodo(branch string) (*models.Todo, error) {
+	todo, ok := s.LinkedTodo(branch)
+	if !ok || todo.Status == models.Doing || todo.Status == models.Done || todo.Archived {
+		return nil, nil
+	}
+
+	if err := s.MarkAsDoing(todo.ID); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// ApplyCommitMessage marks the todos a commit closes as done and adds a note to the ones it
+// refers to. A todo that doesn't exist is skipped with a warning, it never fails the commit.
+func (s *AppService) ApplyCommitMessage(message, branch string) ([]gitlink.Reference, error) {
+	refs := gitlink.ParseReferences(message)
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	subject := gitlink.Subject(message)
+
+	var applied []gitlink.Reference
+	for _, ref := range refs {
+		todo, err := s.todoRepo.GetByID(ref.ID)
+		if err != nil {
+			log.Warn("Commit refers to an unknown todo", "id", ref.ID)
+			continue
+		}
+
+		if ref.Closes {
+			if todo.Status != models.Done {
+				if err := s.MarkAsDone(todo.ID); err != nil {
+					return applied, err
+				}
+			}
+		} else {
+			todo.Description = appendNote(todo.Description, commitNote(subject, branch))
+			if err := s.UpdateTodo(todo, nil); err != nil {
+				return applied, err
+			}
+		}
+		applied = append(applied, ref)
+	}
+	return applied, nil
+}
+
+func commitNote(subject, branch string) string {
+	note := fmt.Sprintf("Referenced by commit %q", subject)
+	if branch != "" {
+		note += " on " + branch
+	}
+	return note + ", " + time.Now().Format(time.DateTime)
+}
+
+func appendNote(description, note string) string {
+	description = strings.TrimRight(description, "\n")
+	if description == "" {
+		return note
+	}
+	return description + "\n\n" + note
+}
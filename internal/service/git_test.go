@@ -0,0 +1,72 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/service"
+)
+
+func TestGitIntegration(t *testing.T) {
+	osoperations.SetDataDir(t.TempDir())
+	t.Cleanup(func() { osoperations.SetDataDir("") })
+
+	repo, err := repository.NewSQLiteTodoRepository("test")
+	if err != nil {
+		t.Fatalf("NewSQLiteTodoRepository() error: %v", err)
+	}
+	defer repo.Close()
+	appService := service.NewAppService(repo)
+
+	for _, title := range []string{"Write report", "Review report"} {
+		if err := appService.CreateTodo(title, "Draft", models.Low, nil, nil, models.Open); err != nil {
+			t.Fatalf("CreateTodo() error: %v", err)
+		}
+	}
+
+	t.Run("checkout moves the linked todo to Doing", func(t *testing.T) {
+		if _, ok := appService.LinkedTodo("main"); ok {
+			t.Error("main is linked to a todo")
+		}
+
+		todo, err := appService.StartLinkedTodo("feature/todo-1-write-report")
+		if err != nil || todo == nil {
+			t.Fatalf("StartLinkedTodo() = %v, %v", todo, err)
+		}
+		if got, _ := appService.GetTodo(1); got.Status != models.Doing {
+			t.Errorf("status = %v, want Doing", got.Status)
+		}
+
+		if todo, err := appService.StartLinkedTodo("todo-99-gone"); todo != nil || err != nil {
+			t.Errorf("StartLinkedTodo() for a missing todo = %v, %v", todo, err)
+		}
+	})
+
+	t.Run("commit message closes and notes todos", func(t *testing.T) {
+		message := "Finish the report\n\nCloses TODO-1\nRefs TODO-2\nRefs TODO-99\n"
+		applied, err := appService.ApplyCommitMessage(message, "todo-1-write-report")
+		if err != nil {
+			t.Fatalf("ApplyCommitMessage() error: %v", err)
+		}
+		if len(applied) != 2 {
+			t.Errorf("applied = %+v, want the two existing todos", applied)
+		}
+
+		closed, _ := appService.GetTodo(1)
+		if closed.Status != models.Done {
+			t.Errorf("status = %v, want Done", closed.Status)
+		}
+		referenced, _ := appService.GetTodo(2)
+		if !strings.HasPrefix(referenced.Description, "Draft\n\nReferenced by commit \"Finish the report\" on todo-1-write-report") {
+			t.Errorf("description = %q", referenced.Description)
+		}
+
+		// Done todos stay done when their branch is checked out again
+		if todo, _ := appService.StartLinkedTodo("todo-1-write-report"); todo != nil {
+			t.Error("StartLinkedTodo() reopened a done todo")
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/martijnspitter/tui-todo/internal/hooks"
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+// ===========================================================================
+// Event hooks
+// ===========================================================================
+
+// ErrHookVetoed is returned when a pre-hook rejected a change
+var ErrHookVetoed = errors.New("error.hook_vetoed")
+
+// HookVetoError carries the hook that rejected a change and what it printed.
+// errors.Is(err, ErrHookVetoed) holds for it and its message is the same translation key.
+type HookVetoError struct {
+	*hooks.VetoError
+}
+
+func (e *HookVetoError) Error() string {
+	return ErrHookVetoed.Error()
+}
+
+func (e *HookVetoError) Is(target error) bool {
+	return target == ErrHookVetoed
+}
+
+func (e *HookVetoError) Unwrap() error {
+	return e.VetoError
+}
+
+// Reason returns the first line the hook printed, empty when it printed nothing
+func (e *HookVetoError) Reason() string {
+	for _, line := range strings.Split(e.Output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// hookPayload is what hooks read from stdin. Todo is the todo after the change, or the
+// removed todo for deletes.
+type hookPayload struct {
+	Event  hooks.Event `json:"event"`
+	Todo   APITodo     `json:"todo"`
+	Before *APITodo    `json:"before,omitempty"`
+	Tag    string      `json:"tag,omitempty"`
+}
+
+type hookEvent struct {
+	event hooks.Event
+	tag   string
+}
+
+// SetHooks runs the scripts in the hooks directory on changes made by this instance
+func (s *AppService) SetHooks(runner *hooks.Runner) {
+	s.hooks = runner
+}
+
+// hookEvents lists the events of a change to a todo. before is nil for new todos, after
+// for deleted ones.
+func hookEvents(nt socket_sync.NotificationType, before, after *models.Todo) []hookEvent {
+	switch nt {
+	case socket_sync.TodoCreated:
+		return []hookEvent{{event: hooks.Create}}
+	case socket_sync.TodoDeleted:
+		return []hookEvent{{event: hooks.Delete}}
+	}
+	if before == nil || after == nil {
+		return nil
+	}
+
+	var events []hookEvent
+	if before.Status != after.Status {
+		events = append(events, hookEvent{event: hooks.StatusChange})
+		if after.Status == models.Done {
+			events = append(events, hookEvent{event: hooks.Done})
+		}
+	}
+	if !before.Archived && after.Archived {
+		events = append(events, hookEvent{event: hooks.Archive})
+	}
+	for _, tag := range after.Tags {
+		if !slices.Contains(before.Tags, tag) {
+			events = append(events, hookEvent{event: hooks.TagAdded, tag: tag})
+		}
+	}
+	return events
+}
+
+func newHookPayload(e hookEvent, before, after *models.Todo) hookPayload {
+	payload := hookPayload{Event: e.event, Tag: e.tag}
+	if after != nil {
+		payload.Todo = NewAPITodo(after)
+		if before != nil {
+			b := NewAPITodo(before)
+			payload.Before = &b
+		}
+	} else if before != nil {
+		payload.Todo = NewAPITodo(before)
+	}
+	return payload
+}
+
+// checkHooks runs the pre-hooks of a change before it is written
+func (s *AppService) checkHooks(nt socket_sync.NotificationType, before, after *models.Todo) error {
+	if s.hooks == nil {
+		return nil
+	}
+	for _, e := range hookEvents(nt, before, after) {
+		if err := s.hooks.Check(e.event, newHookPayload(e, before, after)); err != nil {
+			var veto *hooks.VetoError
+			if errors.As(err, &veto) {
+				return &HookVetoError{VetoError: veto}
+			}
+			return ErrHookVetoed
+		}
+	}
+	return nil
+}
+
+// runHooks starts the on-hooks of a change after it was written
+func (s *AppService) runHooks(nt socket_sync.NotificationType, before, after *models.Todo) {
+	if s.hooks == nil {
+		return
+	}
+	for _, e := range hookEvents(nt, before, after) {
+		log.Debug("Running hook", "event", e.event, "tag", e.tag)
+		s.hooks.Run(e.event, newHookPayload(e, before, after))
+	}
+}
+
+// withTags returns a copy of the todo that also has the tags, for checking a change before
+// the tags are added
+func withTags(todo *models.Todo, tags []string) *models.Todo {
+	if todo == nil {
+		return nil
+	}
+	copied := *todo
+	copied.Tags = slices.Clone(todo.Tags)
+	for _, tag := range tags {
+		if !slices.Contains(copied.Tags, tag) {
+			copied.Tags = append(copied.Tags, tag)
+		}
+	}
+	return &copied
+}
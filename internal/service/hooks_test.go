@@ -0,0 +1,187 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/hooks"
+	"github.com/martijnspitter/tui-todo/internal/models"
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/service"
+)
+
+func TestHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks in tests are shell scripts")
+	}
+
+	osoperations.SetDataDir(t.TempDir())
+	t.Cleanup(func() { osoperations.SetDataDir("") })
+
+	repo, err := repository.NewSQLiteTodoRepository("test")
+	if err != nil {
+		t.Fatalf("NewSQLiteTodoRepository() error: %v", err)
+	}
+	defer repo.Close()
+	appService := service.NewAppService(repo)
+
+	// Every on-hook saves its payload in a file of its own, the pre-hook refuses deletes of
+	// todos tagged keep
+	dir := t.TempDir()
+	eventDir := t.TempDir()
+	for _, event := range []hooks.Event{hooks.Create, hooks.StatusChange, hooks.Done, hooks.Archive, hooks.Delete, hooks.TagAdded} {
+		script := "#!/bin/sh\ncat > \"$(mktemp " + eventDir + "/event.XXXXXX)\"\n"
+		if err := os.WriteFile(filepath.Join(dir, "on-"+string(event)), []byte(script), 0o755); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	veto := "#!/bin/sh\nif grep -q '\"keep\"'; then echo 'todos tagged keep stay'; exit 1; fi\n"
+	if err := os.WriteFile(filepath.Join(dir, "pre-delete"), []byte(veto), 0o755); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	runner := hooks.NewRunner(dir, 5*time.Second)
+	appService.SetHooks(runner)
+
+	events := func(t *testing.T) []string {
+		t.Helper()
+		runner.Wait()
+		files, err := filepath.Glob(filepath.Join(eventDir, "event.*"))
+		if err != nil {
+			t.Fatalf("Glob() error: %v", err)
+		}
+
+		var got []string
+		for _, file := range files {
+			line, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("ReadFile() error: %v", err)
+			}
+			os.Remove(file)
+
+			var payload struct {
+				Event string `json:"event"`
+				Tag   string `json:"tag"`
+				Todo  struct {
+					Title string `json:"title"`
+				} `json:"todo"`
+			}
+			if err := json.Unmarshal(line, &payload); err != nil {
+				t.Fatalf("payload %q: %v", line, err)
+			}
+			if payload.Todo.Title != "Write report" {
+				t.Errorf("payload %q has the wrong todo", line)
+			}
+			got = append(got, strings.TrimSuffix(payload.Event+" "+payload.Tag, " "))
+		}
+		// Hooks run concurrently, so the events have no fixed order
+		return got
+	}
+	wantEvents := func(t *testing.T, got []string, want ...string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+		for _, w := range want {
+			found := false
+			for _, g := range got {
+				found = found || g == w
+			}
+			if !found {
+				t.Errorf("events = %v, want %v", got, want)
+			}
+		}
+	}
+
+	if err := appService.CreateTodo("Write report", "", models.Low, []string{"keep"}, nil, models.Open); err != nil {
+		t.Fatalf("CreateTodo() error: %v", err)
+	}
+	// The tags are part of the new todo, not separate events
+	wantEvents(t, events(t), "create")
+	todos, err := appService.GetTodosMatching()
+	if err != nil || len(todos) != 1 {
+		t.Fatalf("GetTodosMatching() = %v, %v", todos, err)
+	}
+	todo := todos[0]
+
+	if err := appService.MarkAsDone(todo.ID); err != nil {
+		t.Fatalf("MarkAsDone() error: %v", err)
+	}
+	wantEvents(t, events(t), "status-change", "done")
+
+	if err := appService.AddTagToTodo(todo.ID, "work"); err != nil {
+		t.Fatalf("AddTagToTodo() error: %v", err)
+	}
+	if err := appService.ArchiveTodo(todo.ID); err != nil {
+		t.Fatalf("ArchiveTodo() error: %v", err)
+	}
+	wantEvents(t, events(t), "tag-added work", "archive")
+
+	err = appService.DeleteTodo(todo.ID)
+	if !errors.Is(err, service.ErrHookVetoed) {
+		t.Fatalf("DeleteTodo() = %v, want the pre-hook to veto it", err)
+	}
+	var vetoed *service.HookVetoError
+	if !errors.As(err, &vetoed) || vetoed.Hook != "pre-delete" || vetoed.Reason() != "todos tagged keep stay" {
+		t.Errorf("DeleteTodo() = %#v, want the hook and its output", err)
+	}
+	if _, err := appService.GetTodo(todo.ID); err != nil {
+		t.Errorf("vetoed todo was deleted: %v", err)
+	}
+	wantEvents(t, events(t))
+
+	if err := appService.RemoveTagFromTodo(todo.ID, "keep"); err != nil {
+		t.Fatalf("RemoveTagFromTodo() error: %v", err)
+	}
+	if err := appService.DeleteTodo(todo.ID); err != nil {
+		t.Fatalf("DeleteTodo() error: %v", err)
+	}
+	wantEvents(t, events(t), "delete")
+}
+
+func TestAdvanceStatusReturnsTheVeto(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks in tests are shell scripts")
+	}
+
+	osoperations.SetDataDir(t.TempDir())
+	t.Cleanup(func() { osoperations.SetDataDir("") })
+
+	repo, err := repository.NewSQLiteTodoRepository("test")
+	if err != nil {
+		t.Fatalf("NewSQLiteTodoRepository() error: %v", err)
+	}
+	defer repo.Close()
+	appService := service.NewAppService(repo)
+
+	dir := t.TempDir()
+	veto := "#!/bin/sh\necho 'first finish the review' >&2\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(dir, "pre-"+string(hooks.StatusChange)), []byte(veto), 0o755); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	appService.SetHooks(hooks.NewRunner(dir, 5*time.Second))
+
+	if err := appService.CreateTodo("Write report", "", models.Low, nil, nil, models.Open); err != nil {
+		t.Fatalf("CreateTodo() error: %v", err)
+	}
+	todos, err := appService.GetTodosMatching()
+	if err != nil || len(todos) != 1 {
+		t.Fatalf("GetTodosMatching() = %v, %v", todos, err)
+	}
+
+	_, err = appService.AdvanceStatus(todos[0].ID)
+	var vetoed *service.HookVetoError
+	if !errors.Is(err, service.ErrHookVetoed) || !errors.As(err, &vetoed) {
+		t.Fatalf("AdvanceStatus() = %v, want the veto", err)
+	}
+	if vetoed.Reason() != "first finish the review" {
+		t.Errorf("Reason() = %q", vetoed.Reason())
+	}
+}
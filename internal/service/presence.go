@@ -0,0 +1,87 @@
+package service
+
+import (
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+// ===========================================================================
+// Presence
+// ===========================================================================
+
+// SetEditing tells the other instances which todo is open for editing, 0 when none is. With
+// sync.lock_edits the todo is claimed until the edit modal closes or the instance goes away.
+func (s *AppService) SetEditing(id int64) {
+	if s.syncManager == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	if s.editing == id && s.announced {
+		s.mutex.Unlock()
+		return
+	}
+	s.editing, s.announced = id, true
+	s.mutex.Unlock()
+
+	s.syncManager.SetPresence(id, s.config.Sync.LockEdits)
+}
+
+// Peers returns the other instances that use the same socket
+func (s *AppService) Peers() []socket_sync.Presence {
+	if s.syncManager == nil {
+		return nil
+	}
+	return s.syncManager.Peers()
+}
+
+// TodoEditors returns the other instances that have the todo open for editing
+func (s *AppService) TodoEditors(id int64) []socket_sync.Presence {
+	var editors []socket_sync.Presence
+	for _, peer := range s.Peers() {
+		if id != 0 && peer.TodoID == id {
+			editors = append(editors, peer)
+		}
+	}
+	return editors
+}
+
+// OnPresenceChange sets a function that is called when another instance comes, goes or opens
+// another todo
+func (s *AppService) OnPresenceChange(fn func()) {
+	if s.syncManager != nil {
+		s.syncManager.OnPresence(fn)
+	}
+}
+
+// ===========================================================================
+// Incompatible instances
+// ===========================================================================
+
+// IncompatiblePeer records an instance that couldn't join the sync, the interface shows it
+// once it has registered with OnIncompatiblePeer
+func (s *AppService) IncompatiblePeer(err *socket_sync.IncompatibleError) {
+	s.mutex.Lock()
+	fn := s.onIncompatible
+	if fn == nil {
+		s.incompatible = append(s.incompatible, err)
+	}
+	s.mutex.Unlock()
+
+	if fn != nil {
+		fn(err)
+	}
+}
+
+// OnIncompatiblePeer sets a function that is called for an instance that couldn't join the
+// sync, also for the ones that were refused before it was set
+func (s *AppService) OnIncompatiblePeer(fn func(err *socket_sync.IncompatibleError)) {
+	s.mutex.Lock()
+	s.onIncompatible = fn
+	missed := s.incompatible
+	s.incompatible = nil
+	s.mutex.Unlock()
+
+	for _, err := range missed {
+		fn(err)
+	}
+}
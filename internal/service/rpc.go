@@ -0,0 +1,296 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+// ===========================================================================
+// JSON-RPC handlers
+// ===========================================================================
+
+// APITodo is a todo as tools and the HTTP API see it, with names for the status and priority
+type APITodo struct {
+	ID          int64      `json:"id"`
+	UID         string     `json:"uid"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	Priority    string     `json:"priority"`
+	Tags        []string   `json:"tags"`
+	DueDate     *time.Time `json:"due_date,omitempty"`
+	Archived    bool       `json:"archived"`
+	TimeSpent   int64      `json:"time_spent"` // seconds, including a running session
+	Revision    int64      `json:"revision"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// NewAPITodo converts a todo for tools and the HTTP API
+func NewAPITodo(todo *models.Todo) APITodo {
+	tags := todo.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return APITodo{
+		ID:          todo.ID,
+		UID:         todo.UID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Status:      strings.TrimPrefix(todo.Status.String(), "status."),
+		Priority:    strings.TrimPrefix(todo.Priority.String(), "priority."),
+		Tags:        tags,
+		DueDate:     todo.DueDate,
+		Archived:    todo.Archived,
+		TimeSpent:   todo.GetTotalSeconds(),
+		Revision:    todo.Revision,
+		CreatedAt:   todo.CreatedAt,
+		UpdatedAt:   todo.UpdatedAt,
+	}
+}
+
+type rpcListParams struct {
+	View     string `json:"view"`
+	Archived bool   `json:"archived"`
+	Tag      string `json:"tag"`
+}
+
+type rpcIDParams struct {
+	ID int64 `json:"id"`
+}
+
+type rpcCreateParams struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Priority    string     `json:"priority"`
+	Status      string     `json:"status"`
+	DueDate     *time.Time `json:"due_date"`
+	Tags        []string   `json:"tags"`
+}
+
+// rpcUpdateParams only changes the fields that are given. A revision makes the update fail
+// when the todo changed since the tool read it, a due_date of null clears the due date.
+type rpcUpdateParams struct {
+	ID          int64           `json:"id"`
+	Revision    *int64          `json:"revision"`
+	Title       *string         `json:"title"`
+	Description *string         `json:"description"`
+	Priority    *string         `json:"priority"`
+	DueDate     json.RawMessage `json:"due_date"`
+	Tags        *[]string       `json:"tags"`
+}
+
+type rpcSetStatusParams struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+}
+
+// HandleRPC answers the JSON-RPC requests tools send to the primary instance
+func (s *AppService) HandleRPC(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "list":
+		return s.rpcList(params)
+	case "get":
+		return s.rpcGet(params)
+	case "create":
+		return s.rpcCreate(params)
+	case "update":
+		return s.rpcUpdate(params)
+	case "setStatus":
+		return s.rpcSetStatus(params)
+	}
+	return nil, &socket_sync.RPCError{Code: socket_sync.RPCMethodNotFound, Message: "method not found: " + method}
+}
+
+func (s *AppService) rpcList(params json.RawMessage) (any, error) {
+	p := rpcListParams{View: "all"}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	view, err := ParseViewName(p.View)
+	if err != nil || view == TagsPane {
+		return nil, invalidParams("error.unknown_view")
+	}
+
+	todos, err := s.GetTodosForExport(view, p.Archived, p.Tag)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	result := make([]APITodo, 0, len(todos))
+	for _, todo := range todos {
+		result = append(result, NewAPITodo(todo))
+	}
+	return result, nil
+}
+
+func (s *AppService) rpcGet(params json.RawMessage) (any, error) {
+	var p rpcIDParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	return s.rpcTodo(p.ID)
+}
+
+func (s *AppService) rpcCreate(params json.RawMessage) (any, error) {
+	var p rpcCreateParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(p.Title) == "" {
+		return nil, invalidParams("error.validation")
+	}
+
+	priority := s.config.DefaultPriority()
+	if p.Priority != "" {
+		var err error
+		if priority, err = models.ParsePriority(p.Priority); err != nil {
+			return nil, invalidParams(err.Error())
+		}
+	}
+	status := s.config.DefaultStatus()
+	if p.Status != "" {
+		var err error
+		if status, err = models.ParseStatus(p.Status); err != nil {
+			return nil, invalidParams(err.Error())
+		}
+	}
+
+	todo, err := s.createTodo(p.Title, p.Description, priority, p.Tags, p.DueDate, status)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return s.rpcTodo(todo.ID)
+}
+
+func (s *AppService) rpcUpdate(params json.RawMessage) (any, error) {
+	var p rpcUpdateParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	todo, err := s.GetTodo(p.ID)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	if p.Revision != nil && *p.Revision != todo.Revision {
+		return nil, rpcError(ErrTodoConflict)
+	}
+
+	if p.Title != nil {
+		if strings.TrimSpace(*p.Title) == "" {
+			return nil, invalidParams("error.validation")
+		}
+		todo.Title = *p.Title
+	}
+	if p.Description != nil {
+		todo.Description = *p.Description
+	}
+	if p.Priority != nil {
+		if todo.Priority, err = models.ParsePriority(*p.Priority); err != nil {
+			return nil, invalidParams(err.Error())
+		}
+	}
+	if p.DueDate != nil {
+		var dueDate *time.Time
+		if err := json.Unmarshal(p.DueDate, &dueDate); err != nil {
+			return nil, invalidParams("error.due_date_invalid")
+		}
+		todo.DueDate = dueDate
+	}
+
+	var tags []string
+	if p.Tags != nil {
+		tags = *p.Tags
+	}
+	if err := s.UpdateTodo(todo, tags); err != nil {
+		return nil, rpcError(err)
+	}
+
+	// The tags replace the existing ones, UpdateTodo only adds
+	if p.Tags != nil {
+		for _, tag := range todo.Tags {
+			if slices.Contains(tags, tag) {
+				continue
+			}
+			if err := s.RemoveTagFromTodo(todo.ID, tag); err != nil {
+				return nil, rpcError(err)
+			}
+		}
+	}
+
+	return s.rpcTodo(todo.ID)
+}
+
+func (s *AppService) rpcSetStatus(params json.RawMessage) (any, error) {
+	var p rpcSetStatusParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	status, err := models.ParseStatus(p.Status)
+	if err != nil {
+		return nil, invalidParams(err.Error())
+	}
+	if _, err := s.GetTodo(p.ID); err != nil {
+		return nil, rpcError(err)
+	}
+
+	switch status {
+	case models.Open:
+		err = s.MarkAsOpen(p.ID)
+	case models.Doing:
+		err = s.MarkAsDoing(p.ID)
+	case models.Done:
+		err = s.MarkAsDone(p.ID)
+	case models.Blocked:
+		err = s.MarkAsBlocked(p.ID)
+	}
+	if err != nil {
+		return nil, rpcError(err)
+	}
+
+	return s.rpcTodo(p.ID)
+}
+
+func (s *AppService) rpcTodo(id int64) (any, error) {
+	todo, err := s.GetTodo(id)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return NewAPITodo(todo), nil
+}
+
+// decodeParams reads the params of a request, which may be left out when none are required
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return invalidParams(err.Error())
+	}
+	return nil
+}
+
+func invalidParams(message string) error {
+	return &socket_sync.RPCError{Code: socket_sync.RPCInvalidParams, Message: message}
+}
+
+// rpcError turns a service error into a typed error, its message stays the translation key
+func rpcError(err error) error {
+	code := socket_sync.RPCInternalError
+	switch {
+	case errors.Is(err, ErrTodoConflict):
+		code = socket_sync.RPCConflict
+	case err.Error() == "error.todo_not_found":
+		code = socket_sync.RPCNotFound
+	}
+	return &socket_sync.RPCError{Code: code, Message: err.Error()}
+}
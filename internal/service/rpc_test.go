@@ -0,0 +1,111 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/service"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+func TestHandleRPC(t *testing.T) {
+	osoperations.SetDataDir(t.TempDir())
+	t.Cleanup(func() { osoperations.SetDataDir("") })
+
+	repo, err := repository.NewSQLiteTodoRepository("test")
+	if err != nil {
+		t.Fatalf("NewSQLiteTodoRepository() error: %v", err)
+	}
+	defer repo.Close()
+	appService := service.NewAppService(repo)
+
+	call := func(t *testing.T, method, params string) (service.APITodo, error) {
+		t.Helper()
+		result, err := appService.HandleRPC(method, json.RawMessage(params))
+		if err != nil {
+			return service.APITodo{}, err
+		}
+		todo, _ := result.(service.APITodo)
+		return todo, nil
+	}
+	wantCode := func(t *testing.T, err error, code int) {
+		t.Helper()
+		var rpcErr *socket_sync.RPCError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != code {
+			t.Errorf("error = %v, want code %d", err, code)
+		}
+	}
+
+	created, err := call(t, "create", `{"title":"Write report","priority":"high","tags":["work"],"due_date":"2025-05-01T12:00:00Z"}`)
+	if err != nil {
+		t.Fatalf("create error: %v", err)
+	}
+	if created.ID == 0 || created.Priority != "high" || created.Status != "open" || len(created.Tags) != 1 || created.DueDate == nil {
+		t.Errorf("create = %+v", created)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		got, err := call(t, "get", `{"id":`+strconv.FormatInt(created.ID, 10)+`}`)
+		if err != nil || got.Title != "Write report" {
+			t.Errorf("get = %+v, %v", got, err)
+		}
+
+		_, err = call(t, "get", `{"id":999}`)
+		wantCode(t, err, socket_sync.RPCNotFound)
+	})
+
+	t.Run("update changes only the given fields", func(t *testing.T) {
+		got, err := call(t, "update", `{"id":`+strconv.FormatInt(created.ID, 10)+`,"revision":`+strconv.FormatInt(created.Revision, 10)+`,"title":"Write annual report","tags":["q2"],"due_date":null}`)
+		if err != nil {
+			t.Fatalf("update error: %v", err)
+		}
+		if got.Title != "Write annual report" || got.Priority != "high" || got.DueDate != nil {
+			t.Errorf("update = %+v", got)
+		}
+		if len(got.Tags) != 1 || got.Tags[0] != "q2" {
+			t.Errorf("tags = %v, want them replaced", got.Tags)
+		}
+
+		// The revision the tool read is outdated now
+		_, err = call(t, "update", `{"id":`+strconv.FormatInt(created.ID, 10)+`,"revision":`+strconv.FormatInt(created.Revision, 10)+`,"title":"Stale"}`)
+		wantCode(t, err, socket_sync.RPCConflict)
+	})
+
+	t.Run("setStatus", func(t *testing.T) {
+		got, err := call(t, "setStatus", `{"id":`+strconv.FormatInt(created.ID, 10)+`,"status":"doing"}`)
+		if err != nil || got.Status != "doing" {
+			t.Errorf("setStatus = %+v, %v", got, err)
+		}
+
+		_, err = call(t, "setStatus", `{"id":`+strconv.FormatInt(created.ID, 10)+`,"status":"someday"}`)
+		wantCode(t, err, socket_sync.RPCInvalidParams)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		result, err := appService.HandleRPC("list", json.RawMessage(`{"view":"doing"}`))
+		if err != nil {
+			t.Fatalf("list error: %v", err)
+		}
+		if todos := result.([]service.APITodo); len(todos) != 1 || todos[0].ID != created.ID {
+			t.Errorf("list = %+v", todos)
+		}
+
+		_, err = appService.HandleRPC("list", json.RawMessage(`{"view":"tags"}`))
+		wantCode(t, err, socket_sync.RPCInvalidParams)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		_, err := call(t, "create", `{"title":" "}`)
+		wantCode(t, err, socket_sync.RPCInvalidParams)
+
+		_, err = call(t, "create", `["not","an","object"]`)
+		wantCode(t, err, socket_sync.RPCInvalidParams)
+
+		_, err = call(t, "delete", `{}`)
+		wantCode(t, err, socket_sync.RPCMethodNotFound)
+	})
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+	"github.com/martijnspitter/tui-todo/internal/utils"
+)
+
+// ===========================================================================
+// Today methods
+// ===========================================================================
+
+// The Today dashboard is loaded with a single query and divided into its sections here. The
+// result is cached until a change touches it or the day ends, so refreshing the dashboard
+// after every sync notification doesn't query the database again.
+
+// TodayData is everything the Today dashboard shows
+type TodayData struct {
+	HighPrio   []*models.Todo
+	DueToday   []*models.Todo
+	InProgress []*models.Todo
+	Blocked    []*models.Todo
+	OverDue    []*models.Todo
+	ComingUp   []*models.Todo
+
+	// Completed counts the todos finished today, Total adds the unfinished ones on the
+	// dashboard that aren't blocked. TimeSpent is the time tracked on all of them.
+	Completed int
+	Total     int
+	TimeSpent string
+
+	// day is the day the data was loaded on, ids holds every todo it was computed from
+	day time.Time
+	ids map[int64]bool
+	// counted are the todos whose time is in TimeSpent
+	counted []*models.Todo
+}
+
+// section returns the list of a section
+func (d *TodayData) section(section TodaySection) *[]*models.Todo {
+	switch section {
+	case HighPrioritySection:
+		return &d.HighPrio
+	case DueTodaySection:
+		return &d.DueToday
+	case InProgressSection:
+		return &d.InProgress
+	case BlockedSection:
+		return &d.Blocked
+	case OverDueSection:
+		return &d.OverDue
+	default:
+		return &d.ComingUp
+	}
+}
+
+// clone copies the lists so callers can't change the cached data. The time spent is worked
+// out on every call, it keeps growing while a timer runs.
+func (d *TodayData) clone() *TodayData {
+	c := *d
+	for _, section := range todaySections {
+		*c.section(section) = slices.Clone(*d.section(section))
+	}
+
+	var timeSpent int64
+	for _, todo := range d.counted {
+		timeSpent += todo.GetTotalSeconds()
+	}
+	c.TimeSpent = utils.FormatTime(timeSpent)
+	return &c
+}
+
+// GetTodayData returns the sections and stats of the Today dashboard
+func (s *AppService) GetTodayData() (*TodayData, error) {
+	now := time.Now()
+
+	s.mutex.Lock()
+	cached, generation := s.today, s.todayGeneration
+	s.mutex.Unlock()
+	if cached != nil && cached.day.Equal(startOfDay(now)) {
+		return cached.clone(), nil
+	}
+
+	data, err := s.loadToday(now)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	// The result may have missed a change made while it was loading
+	if s.todayGeneration == generation {
+		s.today = data
+	}
+	s.mutex.Unlock()
+
+	return data.clone(), nil
+}
+
+func (s *AppService) GetTodosForToday() (highPrio, dueToday, inProgress, blockedTasks, overDue, comingUp []*models.Todo, error error) {
+	data, err := s.GetTodayData()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, err
+	}
+	return data.HighPrio, data.DueToday, data.InProgress, data.Blocked, data.OverDue, data.ComingUp, nil
+}
+
+func (s *AppService) GetTodayCompletionStats() (completed int, total int, formattedTimeSpent string) {
+	data, err := s.GetTodayData()
+	if err != nil {
+		return 0, 0, ""
+	}
+	return data.Completed, data.Total, data.TimeSpent
+}
+
+// loadToday fetches everything on the dashboard, and the todos finished today, at once
+func (s *AppService) loadToday(now time.Time) (*TodayData, error) {
+	onDashboard, completedToday := s.todayFilters()
+	todos, err := s.todoRepo.GetAll(repository.Or(onDashboard, completedToday))
+	if err != nil {
+		log.Error("Failed to fetch todos for today", "error", err)
+		return nil, fmt.Errorf("error.todos_not_found")
+	}
+
+	data := &TodayData{day: startOfDay(now), ids: make(map[int64]bool, len(todos))}
+	sections := s.todaySectionFilters()
+	allToday := repository.And(
+		repository.AllTodayFilter(s.config.HighPriority(), s.config.Today.ComingUpDays),
+		repository.NotArchivedFilter(),
+	)
+
+	for _, todo := range todos {
+		data.ids[todo.ID] = true
+		for section, filter := range sections {
+			if filter.Match(todo) {
+				list := data.section(section)
+				*list = append(*list, todo)
+			}
+		}
+
+		switch {
+		case completedToday.Match(todo):
+			data.Completed++
+			data.counted = append(data.counted, todo)
+		case todo.Status != models.Blocked && allToday.Match(todo):
+			// Blocked tasks don't count towards the day's work
+			data.Total++
+			data.counted = append(data.counted, todo)
+		}
+	}
+	data.Total += data.Completed
+
+	for _, section := range todaySections {
+		sortTodos(*data.section(section))
+	}
+	return data, nil
+}
+
+// todayFilters returns the filters for the todos listed on the dashboard and for those
+// finished today, which only count in the stats
+func (s *AppService) todayFilters() (onDashboard, completedToday repository.Filter) {
+	onDashboard = repository.And(
+		repository.Or(
+			repository.AllTodayFilter(s.config.HighPriority(), s.config.Today.ComingUpDays),
+			repository.StatusFilter(models.Blocked),
+		),
+		repository.NotArchivedFilter(),
+	)
+	return onDashboard, repository.CompletedTodayFilter()
+}
+
+// todaySectionFilters returns the filter of each section
+func (s *AppService) todaySectionFilters() map[TodaySection]repository.Filter {
+	notArchived := repository.NotArchivedFilter()
+	return map[TodaySection]repository.Filter{
+		HighPrioritySection: repository.And(repository.PrioAboveHighFilter(s.config.HighPriority()), notArchived),
+		DueTodaySection:     repository.And(repository.DueTodayFilter(), notArchived),
+		InProgressSection:   repository.And(repository.StatusFilter(models.Doing), notArchived),
+		BlockedSection:      repository.And(repository.StatusFilter(models.Blocked), notArchived),
+		OverDueSection:      repository.And(repository.OverDueFilter(), notArchived),
+		ComingUpSection:     repository.And(repository.ComingUpFilter(s.config.Today.ComingUpDays), notArchived),
+	}
+}
+
+// todayChanged drops the cached dashboard when a change to a todo touches it: the todo was
+// on the dashboard, or belongs on it now. todo is nil for a deleted todo, known is false when
+// the change can't be read, which drops the cache too.
+func (s *AppService) todayChanged(id int64, todo *models.Todo, known bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.todayGeneration++
+	if s.today == nil {
+		return
+	}
+	if !known || s.today.ids[id] {
+		s.today = nil
+		return
+	}
+	if todo == nil {
+		return
+	}
+	onDashboard, completedToday := s.todayFilters()
+	if repository.Or(onDashboard, completedToday).Match(todo) {
+		s.today = nil
+	}
+}
+
+// todayTagChanged drops the cached dashboard when a tag is renamed or deleted, todos list
+// their tags by name
+func (s *AppService) todayTagChanged(nt socket_sync.NotificationType) {
+	if nt != socket_sync.TagCreated {
+		s.dropToday()
+	}
+}
+
+// dropToday forgets the cached dashboard, it is loaded again when it is shown
+func (s *AppService) dropToday() {
+	s.mutex.Lock()
+	s.today = nil
+	s.todayGeneration++
+	s.mutex.Unlock()
+}
+
+// todayNotified applies a change from a notification to the cached dashboard
+func (s *AppService) todayNotified(change Change) {
+	if change.IsTag() {
+		s.todayTagChanged(change.Type)
+		return
+	}
+	todo, ok := change.Todo(nil)
+	s.todayChanged(change.ID, todo, ok)
+}
+
+// todayCached reports whether the dashboard is cached, changes only need to be read then
+func (s *AppService) todayCached() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.today != nil
+}
+
+// UntilTomorrow returns the time left until midnight, when the dashboard moves on to the
+// next day
+func UntilTomorrow(now time.Time) time.Duration {
+	return startOfDay(now).AddDate(0, 0, 1).Sub(now)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// TodaySection is one of the lists on the Today dashboard
+type TodaySection int
+
+const (
+	HighPrioritySection TodaySection = iota
+	DueTodaySection
+	InProgressSection
+	BlockedSection
+	OverDueSection
+	ComingUpSection
+)
+
+var todaySections = []TodaySection{
+	HighPrioritySection, DueTodaySection, InProgressSection, BlockedSection, OverDueSection, ComingUpSection,
+}
+
+// InTodaySection reports whether a todo is listed in a section of the Today dashboard. It
+// mirrors the section filters so remote changes can be patched in place.
+func (s *AppService) InTodaySection(todo *models.Todo, section TodaySection, now time.Time) bool {
+	if todo.Archived {
+		return false
+	}
+
+	today := startOfDay(now)
+	tomorrow := today.AddDate(0, 0, 1)
+	due := todo.DueDate
+	open := todo.Status != models.Done
+
+	switch section {
+	case HighPrioritySection:
+		return open && todo.Priority >= s.config.HighPriority() && (due == nil || due.Before(today))
+	case DueTodaySection:
+		return open && due != nil && !due.Before(today) && due.Before(tomorrow)
+	case InProgressSection:
+		return todo.Status == models.Doing
+	case BlockedSection:
+		return todo.Status == models.Blocked
+	case OverDueSection:
+		return open && due != nil && due.Before(today)
+	case ComingUpSection:
+		windowEnd := today.AddDate(0, 0, s.config.Today.ComingUpDays)
+		return open && due != nil && !due.Before(tomorrow) && due.Before(windowEnd)
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package service_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/service"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+)
+
+// countingRepository counts the queries made for lists
+type countingRepository struct {
+	*repository.InMemoryTodoRepository
+	queries int
+}
+
+func (r *countingRepository) GetAll(filters ...repository.Filter) ([]*models.Todo, error) {
+	r.queries++
+	return r.InMemoryTodoRepository.GetAll(filters...)
+}
+
+func todayTestTodos() []*models.Todo {
+	now := time.Now()
+	day := func(days int) *time.Time {
+		d := time.Date(now.Year(), now.Month(), now.Day()+days, 12, 0, 0, 0, now.Location())
+		return &d
+	}
+	todo := func(title string, status models.Status, priority models.Priority, due *time.Time, archived bool) *models.Todo {
+		return &models.Todo{
+			Title: title, Status: status, Priority: priority, DueDate: due, Archived: archived,
+			CreatedAt: now, UpdatedAt: now, TimeSpent: 60,
+		}
+	}
+	return []*models.Todo{
+		todo("Critical", models.Open, models.Critical, nil, false),
+		todo("Due today", models.Open, models.Low, day(0), false),
+		todo("Doing", models.Doing, models.Low, nil, false),
+		todo("Blocked", models.Blocked, models.Low, nil, false),
+		todo("Overdue", models.Open, models.Low, day(-2), false),
+		todo("Tomorrow", models.Open, models.Low, day(1), false),
+		todo("Next month", models.Open, models.Low, day(30), false),
+		todo("Done", models.Done, models.Low, day(0), false),
+		todo("Archived done", models.Done, models.Low, nil, true),
+		todo("Archived", models.Doing, models.Critical, day(0), true),
+		todo("Someday", models.Open, models.Low, nil, false),
+		todo("Later", models.Open, models.Low, day(20), false),
+	}
+}
+
+func TestGetTodayData(t *testing.T) {
+	repo := &countingRepository{InMemoryTodoRepository: newMemoryRepository(t, todayTestTodos()...)}
+	appService := service.NewAppService(repo)
+
+	data, err := appService.GetTodayData()
+	if err != nil {
+		t.Fatalf("GetTodayData() error: %v", err)
+	}
+	if repo.queries != 1 {
+		t.Errorf("GetTodayData() made %d queries, want 1", repo.queries)
+	}
+
+	expected := map[string][]*models.Todo{
+		"Critical":   data.HighPrio,
+		"Due today":  data.DueToday,
+		"Doing":      data.InProgress,
+		"Blocked":    data.Blocked,
+		"Overdue":    data.OverDue,
+		"Tomorrow":   data.ComingUp,
+		"Next month": nil,
+		"Someday":    nil,
+	}
+	for title, section := range expected {
+		listed := 0
+		for _, list := range [][]*models.Todo{data.HighPrio, data.DueToday, data.InProgress, data.Blocked, data.OverDue, data.ComingUp} {
+			if slices.ContainsFunc(list, func(todo *models.Todo) bool { return todo.Title == title }) {
+				listed++
+			}
+		}
+		want := 1
+		if section == nil {
+			want = 0
+		}
+		if listed != want || (section != nil && section[0].Title != title) {
+			t.Errorf("%q is listed in %d sections, want %d", title, listed, want)
+		}
+	}
+
+	// Both done todos finished today, blocked ones don't count
+	if data.Completed != 2 || data.Total != 7 {
+		t.Errorf("stats = %d of %d, want 2 of 7", data.Completed, data.Total)
+	}
+	if data.TimeSpent == "" {
+		t.Error("TimeSpent is empty")
+	}
+}
+
+func TestGetTodayDataIsCached(t *testing.T) {
+	repo := &countingRepository{InMemoryTodoRepository: newMemoryRepository(t, todayTestTodos()...)}
+	appService := service.NewAppService(repo)
+	id := func(title string) int64 {
+		todos, _ := repo.InMemoryTodoRepository.GetAll(repository.Where(repository.FieldTitle, repository.OpEq, title))
+		return todos[0].ID
+	}
+	todo := func(title string) *models.Todo {
+		todo, _ := repo.GetByID(id(title))
+		return todo
+	}
+
+	testCases := []struct {
+		name   string
+		change func() error
+		reload bool
+	}{
+		{"Nothing changed", func() error { return nil }, false},
+		{"Todo off the dashboard changed", func() error { return appService.SetPriority(id("Someday"), models.Medium) }, false},
+		{"Todo moved onto the dashboard", func() error { return appService.MarkAsDoing(id("Someday")) }, true},
+		{"Todo on the dashboard changed", func() error { return appService.SetPriority(id("Critical"), models.Major) }, true},
+		{"Todo off the dashboard deleted", func() error { return appService.DeleteTodo(id("Next month")) }, false},
+		{"Tag created", func() error { return appService.CreateTag(&models.Tag{Name: "new"}) }, false},
+		{"Tag renamed", func() error {
+			tags, _ := appService.GetAllTags()
+			tags[0].Name = "renamed"
+			return appService.UpdateTag(tags[0])
+		}, true},
+		{"Notified change off the dashboard", func() error {
+			changed := todo("Later")
+			changed.Title = "Much later"
+			appService.OnNotification(socket_sync.NewNotification(socket_sync.TodoUpdated, socket_sync.EntityTodo, changed.ID, nil, changed))
+			return nil
+		}, false},
+		{"Notified change onto the dashboard", func() error {
+			changed := todo("Later")
+			changed.Status = models.Blocked
+			appService.OnNotification(socket_sync.NewNotification(socket_sync.TodoUpdated, socket_sync.EntityTodo, changed.ID, nil, changed))
+			return nil
+		}, true},
+		{"Notified change without the todo", func() error {
+			appService.OnNotification(socket_sync.Notification{Type: socket_sync.TodoUpdated, ID: id("Someday")})
+			return nil
+		}, true},
+	}
+
+	if _, err := appService.GetTodayData(); err != nil {
+		t.Fatalf("GetTodayData() error: %v", err)
+	}
+	if err := appService.AddTagToTodo(id("Overdue"), "work"); err != nil {
+		t.Fatalf("AddTagToTodo() error: %v", err)
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := appService.GetTodayData(); err != nil {
+				t.Fatalf("GetTodayData() error: %v", err)
+			}
+			if err := tc.change(); err != nil {
+				t.Fatalf("change error: %v", err)
+			}
+
+			before := repo.queries
+			if _, err := appService.GetTodayData(); err != nil {
+				t.Fatalf("GetTodayData() error: %v", err)
+			}
+			if reloaded := repo.queries > before; reloaded != tc.reload {
+				t.Errorf("reloaded = %v, want %v", reloaded, tc.reload)
+			}
+		})
+	}
+}
+
+func TestTodayTimeSpentRunsWithTheTimer(t *testing.T) {
+	started := time.Now().Add(-2 * time.Hour)
+	now := time.Now()
+	repo := &countingRepository{InMemoryTodoRepository: newMemoryRepository(t, &models.Todo{
+		Title: "Tracking", Status: models.Doing, Priority: models.Low,
+		CreatedAt: now, UpdatedAt: now, TimeSpent: 60, TimeStarted: &started,
+	})}
+	appService := service.NewAppService(repo)
+
+	_, _, first := appService.GetTodayCompletionStats()
+	if !strings.HasPrefix(first, "2h 1m") {
+		t.Errorf("time spent = %q, want the running session included", first)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	_, _, second := appService.GetTodayCompletionStats()
+	if second == first {
+		t.Errorf("time spent stayed at %q while the timer ran", second)
+	}
+	if repo.queries != 1 {
+		t.Errorf("the dashboard was loaded %d times, want once", repo.queries)
+	}
+}
+
+func TestGetTodayDataReturnsCopies(t *testing.T) {
+	appService := service.NewAppService(newMemoryRepository(t, todayTestTodos()...))
+
+	data, _ := appService.GetTodayData()
+	data.HighPrio[0] = nil
+	again, _ := appService.GetTodayData()
+	if again.HighPrio[0] == nil {
+		t.Error("changing the returned data changed the cache")
+	}
+}
+
+func TestUntilTomorrow(t *testing.T) {
+	testCases := []struct {
+		now      time.Time
+		expected time.Duration
+	}{
+		{time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC), 24 * time.Hour},
+		{time.Date(2025, 6, 10, 23, 59, 30, 0, time.UTC), 30 * time.Second},
+		{time.Date(2025, 12, 31, 18, 0, 0, 0, time.UTC), 6 * time.Hour},
+	}
+	for _, tc := range testCases {
+		if got := service.UntilTomorrow(tc.now); got != tc.expected {
+			t.Errorf("UntilTomorrow(%v) = %v, want %v", tc.now, got, tc.expected)
+		}
+	}
+}
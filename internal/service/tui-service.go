@@ -1,7 +1,13 @@
 package service
 
 import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/config"
 	"github.com/martijnspitter/tui-todo/internal/keys"
+	"github.com/martijnspitter/tui-todo/internal/theme"
 )
 
 type ViewType int
@@ -19,14 +25,46 @@ const (
 	ConfirmDeleteModal
 	UpdateModal
 	AboutModal
+	WorkspaceModal
 )
 
+var viewNames = map[ViewType]string{
+	TodayPane:   "today",
+	OpenPane:    "open",
+	DoingPane:   "doing",
+	DonePane:    "done",
+	BlockedPane: "blocked",
+	AllPane:     "all",
+	TagsPane:    "tags",
+}
+
+// Name returns a short, stable name for a pane, used in file names and on the command line
+func (v ViewType) Name() string {
+	if name, ok := viewNames[v]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseViewName converts a pane name back into its ViewType
+func ParseViewName(name string) (ViewType, error) {
+	for view, n := range viewNames {
+		if n == name {
+			return view, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown view %q", name)
+}
+
 type TuiService struct {
 	KeyMap          keys.KeyMap
 	CurrentView     ViewType
 	PrevView        ViewType
 	FilterState     FilterState
 	ShowConfirmQuit bool
+	ConfirmQuit     bool
+	DateFormat      string
+	ListFiltering   bool
 }
 
 type FilterState struct {
@@ -51,28 +89,54 @@ func NewTuiService() *TuiService {
 			IsFilterActive:  false,
 			FilterMode:      FilterByTitle,
 		},
+		ConfirmQuit: true,
+		DateFormat:  time.Stamp,
 	}
 }
 
+// ApplyConfig copies the UI related options from the configuration
+func (t *TuiService) ApplyConfig(cfg *config.Config) {
+	t.ConfirmQuit = cfg.ConfirmQuit
+	t.DateFormat = cfg.DateFormat
+
+	// The configuration is validated on load, invalid values keep the defaults
+	if err := theme.Use(cfg.Theme); err != nil {
+		theme.Set(theme.Dark())
+	}
+	if keyMap, err := keys.FromConfig(cfg.Keys.Preset, cfg.Keys.Bindings); err == nil {
+		t.KeyMap = keyMap
+	}
+}
+
+// CycleTheme switches to the next registered theme and returns its name
+func (t *TuiService) CycleTheme() string {
+	names := theme.Names()
+	next := names[(slices.Index(names, theme.Current().Name)+1)%len(names)]
+	_ = theme.Use(next)
+	return next
+}
+
+// FormatDate formats a timestamp using the configured date format
+func (t *TuiService) FormatDate(date time.Time) string {
+	return date.Format(t.DateFormat)
+}
+
+// SwitchPane switches to the pane at the position of the pressed key in the SwitchPane binding
 func (t *TuiService) SwitchPane(key string) {
-	switch key {
-	case "1":
-		t.CurrentView = TodayPane
-	case "2":
-		t.CurrentView = OpenPane
-	case "3":
-		t.CurrentView = DoingPane
-	case "4":
-		t.CurrentView = DonePane
-	case "5":
-		t.CurrentView = BlockedPane
-	case "6":
-		t.CurrentView = AllPane
-	case "7":
-		t.CurrentView = TagsPane
+	panes := []ViewType{TodayPane, OpenPane, DoingPane, DonePane, BlockedPane, AllPane, TagsPane}
+
+	index := slices.Index(t.KeyMap.SwitchPane.Keys(), key)
+	if index >= 0 && index < len(panes) {
+		t.CurrentView = panes[index]
 	}
 }
 
+// ShouldSequenceKeys reports whether key presses may start a multi key sequence,
+// which is never the case while the user is typing text
+func (t *TuiService) ShouldSequenceKeys() bool {
+	return !t.ShouldShowModal() && !t.FilterState.IsFilterActive && !t.ListFiltering && !t.ShowConfirmQuit
+}
+
 func (t *TuiService) ActivateTagFilter() {
 	t.FilterState.IsFilterActive = true
 	t.FilterState.FilterMode = FilterByTag
@@ -110,6 +174,17 @@ func (t *TuiService) IsTodoView() bool {
 		t.CurrentView == AllPane
 }
 
+// ActivePane returns the pane on screen, or the pane a modal was opened from
+func (t *TuiService) ActivePane() ViewType {
+	if t.CurrentView <= TagsPane {
+		return t.CurrentView
+	}
+	if t.isPrevViewATab() {
+		return t.PrevView
+	}
+	return OpenPane
+}
+
 func (t *TuiService) SwitchToListView() {
 	if t.isPrevViewATab() {
 		t.CurrentView = t.PrevView
@@ -142,7 +217,8 @@ func (t *TuiService) ShouldShowModal() bool {
 		t.CurrentView == AddEditTagModal ||
 		t.CurrentView == ConfirmDeleteModal ||
 		t.CurrentView == UpdateModal ||
-		t.CurrentView == AboutModal)
+		t.CurrentView == AboutModal ||
+		t.CurrentView == WorkspaceModal)
 }
 
 func (t *TuiService) ToggleArchivedInAllView() {
@@ -191,3 +267,8 @@ func (t *TuiService) SwitchToAboutModalView() {
 	t.PrevView = t.CurrentView
 	t.CurrentView = AboutModal
 }
+
+func (t *TuiService) SwitchToWorkspaceModalView() {
+	t.PrevView = t.CurrentView
+	t.CurrentView = WorkspaceModal
+}
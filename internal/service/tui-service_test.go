@@ -3,6 +3,7 @@ package service_test
 import (
 	"testing"
 
+	"github.com/martijnspitter/tui-todo/internal/config"
 	"github.com/martijnspitter/tui-todo/internal/service"
 )
 
@@ -313,6 +314,11 @@ func TestShouldShowModal(t *testing.T) {
 			view:        service.AboutModal,
 			expectModal: true,
 		},
+		{
+			name:        "Workspace Modal is modal",
+			view:        service.WorkspaceModal,
+			expectModal: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -493,3 +499,24 @@ func TestModalPreservesPrevView(t *testing.T) {
 		})
 	}
 }
+
+func TestSwitchPaneRemapped(t *testing.T) {
+	cfg := config.Default()
+	cfg.Keys.Bindings = map[string][]string{
+		"switch_pane": {"f1", "f2", "f3"},
+	}
+
+	svc := service.NewTuiService()
+	svc.ApplyConfig(cfg)
+
+	svc.SwitchPane("f2")
+	if svc.CurrentView != service.OpenPane {
+		t.Errorf("Expected Open pane, got %v", svc.CurrentView)
+	}
+
+	// The old keys no longer switch panes
+	svc.SwitchPane("5")
+	if svc.CurrentView != service.OpenPane {
+		t.Errorf("Expected to stay on Open pane, got %v", svc.CurrentView)
+	}
+}
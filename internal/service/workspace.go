@@ -0,0 +1,110 @@
+package service
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
+	"github.com/martijnspitter/tui-todo/internal/workspace"
+)
+
+// ===========================================================================
+// Workspaces
+// ===========================================================================
+
+// SetWorkspace records the workspace the service runs on, root is the data directory the
+// named workspaces live in
+func (s *AppService) SetWorkspace(current workspace.Workspace, root string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.workspace = current
+	s.workspaceRoot = root
+}
+
+// Workspace returns the workspace the service runs on
+func (s *AppService) Workspace() workspace.Workspace {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.workspace
+}
+
+// Workspaces returns the workspaces that can be switched to, including the project in the
+// working directory
+func (s *AppService) Workspaces() ([]workspace.Workspace, error) {
+	s.mutex.Lock()
+	root := s.workspaceRoot
+	s.mutex.Unlock()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	workspaces, err := workspace.List(root, cwd)
+	if err != nil {
+		log.Error("Failed to list workspaces", "error", err)
+		return nil, fmt.Errorf("error.workspace_list_failed")
+	}
+	return workspaces, nil
+}
+
+// RequestWorkspace asks to reopen the program on another workspace once the interface quits
+func (s *AppService) RequestWorkspace(next workspace.Workspace) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nextWorkspace = &next
+}
+
+// RequestedWorkspace returns the workspace to open next, if one was requested
+func (s *AppService) RequestedWorkspace() (workspace.Workspace, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.nextWorkspace == nil {
+		return workspace.Workspace{}, false
+	}
+	return *s.nextWorkspace, true
+}
+
+// MoveTodo moves a todo with its tags and time spent to the service of another workspace.
+// It gets a new ID and UID there, the original is deleted once the copy is stored. Hooks of
+// both workspaces see a create and a delete.
+func (s *AppService) MoveTodo(id int64, target *AppService) (*models.Todo, error) {
+	todo, err := s.todoRepo.GetByID(id)
+	if err != nil {
+		log.Error("Failed to get todo to move", "error", err, "id", id)
+		return nil, fmt.Errorf("error.todo_not_found")
+	}
+
+	moved := *todo
+	moved.ID, moved.UID, moved.Revision, moved.Tags = 0, "", 0, nil
+
+	if err := s.checkHooks(socket_sync.TodoDeleted, todo, nil); err != nil {
+		return nil, err
+	}
+	if err := target.checkHooks(socket_sync.TodoCreated, nil, withTags(&moved, todo.Tags)); err != nil {
+		return nil, err
+	}
+
+	if err := target.todoRepo.Create(&moved); err != nil {
+		log.Error("Failed to create moved todo", "error", err, "id", id)
+		return nil, fmt.Errorf("error.create_failed")
+	}
+	for _, tag := range todo.Tags {
+		if err := target.todoRepo.AddTagToTodo(moved.ID, tag); err != nil {
+			log.Error("Could not add tag to moved todo", "error", err, "tag", tag)
+			return nil, fmt.Errorf("error.tag_add_failed")
+		}
+	}
+	moved.Tags = todo.Tags
+	target.notifyTodo(socket_sync.TodoCreated, moved.ID, nil)
+
+	if err := s.todoRepo.Delete(id); err != nil {
+		log.Error("Failed to delete moved todo", "error", err, "id", id)
+		return nil, fmt.Errorf("error.delete_failed")
+	}
+	s.notifyTodo(socket_sync.TodoDeleted, id, todo)
+
+	return &moved, nil
+}
@@ -0,0 +1,55 @@
+package service_test
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/repository"
+	"github.com/martijnspitter/tui-todo/internal/service"
+)
+
+func TestMoveTodo(t *testing.T) {
+	open := func(t *testing.T) *service.AppService {
+		t.Helper()
+		repo, err := repository.OpenSQLiteTodoRepository(filepath.Join(t.TempDir(), "todo.sql"))
+		if err != nil {
+			t.Fatalf("OpenSQLiteTodoRepository() error: %v", err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		return service.NewAppService(repo)
+	}
+	work, personal := open(t), open(t)
+
+	if err := work.CreateTodo("Book dentist", "Before June", models.High, []string{"health"}, nil, models.Doing); err != nil {
+		t.Fatalf("CreateTodo() error: %v", err)
+	}
+	original, _ := work.GetTodo(1)
+
+	moved, err := work.MoveTodo(1, personal)
+	if err != nil {
+		t.Fatalf("MoveTodo() error: %v", err)
+	}
+
+	if _, err := work.GetTodo(1); err == nil {
+		t.Error("todo is still in the source workspace")
+	}
+	got, err := personal.GetTodo(moved.ID)
+	if err != nil {
+		t.Fatalf("GetTodo() in the target workspace error: %v", err)
+	}
+	if got.Title != "Book dentist" || got.Description != "Before June" || got.Priority != models.High || got.Status != models.Doing {
+		t.Errorf("moved todo = %+v", got)
+	}
+	if !slices.Equal(got.Tags, []string{"health"}) {
+		t.Errorf("tags = %v, want [health]", got.Tags)
+	}
+	if got.UID == original.UID || !got.CreatedAt.Equal(original.CreatedAt) || got.TimeStarted == nil {
+		t.Errorf("moved todo should keep its history under a new UID: %+v", got)
+	}
+
+	if _, err := work.MoveTodo(99, personal); err == nil {
+		t.Error("MoveTodo() of a missing todo succeeded")
+	}
+}
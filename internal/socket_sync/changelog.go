@@ -0,0 +1,93 @@
+package socket_sync
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+)
+
+const (
+	// HelloTimeout is how long the primary waits for the hello of a client or the first
+	// request of a tool, and a client for the welcome. Silent connections are refused.
+	HelloTimeout = 5 * time.Second
+	// CompactInterval is how often the primary compacts the change log
+	CompactInterval = 1 * time.Hour
+)
+
+// ChangeLog persists the changes of all instances in order. The primary appends every change
+// before broadcasting it, and replays what a client missed when it reconnects.
+type ChangeLog interface {
+	// Append stores the notification and sets its Seq
+	Append(notification *Notification) error
+	// Since returns the notifications after seq, oldest first
+	Since(seq int64) ([]Notification, error)
+	// Head returns the Seq of the latest notification
+	Head() (int64, error)
+	// Compact removes notifications that are superseded by a later one for the same entity
+	Compact() error
+}
+
+// PeerStatus describes an instance taking part in the local sync
+type PeerStatus struct {
+	PID int `json:"pid"`
+	// App is the version of the app, empty for instances from before the handshake
+	App     string `json:"app,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Seq     int64  `json:"seq"`
+	// Lag is the number of logged changes the instance hasn't confirmed yet
+	Lag         int64     `json:"lag"`
+	ConnectedAt time.Time `json:"connected_at"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// SyncStatus is the primary's answer to a SyncStatusRequest
+type SyncStatus struct {
+	Head  int64        `json:"head"`
+	Peers []PeerStatus `json:"peers"`
+}
+
+// QueryStatus asks the primary listening on socketPath for the position of every instance
+func QueryStatus(socketPath string) (*SyncStatus, error) {
+	conn, err := net.DialTimeout(DefaultProtocol, socketPath, 2*time.Second)
+	if err != nil {
+		return nil, &SocketError{Op: "connect", Err: err}
+	}
+	defer conn.Close()
+
+	if err := checkPeer(conn); err != nil {
+		return nil, err
+	}
+
+	// Say hello without a position, so nothing is replayed
+	hello := newHandshakeNotification(SyncHello, localHandshake())
+	hello.Seq = -1
+	request := Notification{Type: SyncStatusRequest, Timestamp: time.Now()}
+	if err := WriteMessage(conn, hello); err != nil {
+		return nil, err
+	}
+	if err := WriteMessage(conn, request); err != nil {
+		return nil, err
+	}
+
+	reader := newMessageReader(conn)
+	for {
+		reply, err := reader.read(ReadTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if reply.Type == SyncRefused {
+			refusal := decodeHandshake(reply)
+			return nil, &IncompatibleError{Peer: refusal, Reason: refusal.Reason, ByPeer: true}
+		}
+		// The primary doesn't know we only came for the status, skip what it broadcasts
+		if reply.Type != SyncStatusReply {
+			continue
+		}
+
+		var status SyncStatus
+		if err := json.Unmarshal(reply.Snapshot, &status); err != nil {
+			return nil, &SocketError{Op: "decode", Err: err}
+		}
+		return &status, nil
+	}
+}
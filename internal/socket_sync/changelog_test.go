@@ -0,0 +1,260 @@
+package socket_sync
+
+import (
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryChangeLog keeps the change log in memory, compaction keeps the latest change of
+// every entity
+type memoryChangeLog struct {
+	mu      sync.Mutex
+	entries []Notification
+	head    int64
+}
+
+func (l *memoryChangeLog) Append(notification *Notification) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.head++
+	notification.Seq = l.head
+	l.entries = append(l.entries, *notification)
+	return nil
+}
+
+func (l *memoryChangeLog) Since(seq int64) ([]Notification, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var since []Notification
+	for _, entry := range l.entries {
+		if entry.Seq > seq {
+			since = append(since, entry)
+		}
+	}
+	return since, nil
+}
+
+func (l *memoryChangeLog) Head() (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.head, nil
+}
+
+func (l *memoryChangeLog) Compact() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	latest := make(map[string]int64)
+	for _, entry := range l.entries {
+		latest[entry.EntityKey()] = entry.Seq
+	}
+	compacted := l.entries[:0:0]
+	for _, entry := range l.entries {
+		if latest[entry.EntityKey()] == entry.Seq {
+			compacted = append(compacted, entry)
+		}
+	}
+	l.entries = compacted
+	return nil
+}
+
+func (l *recordingListener) OnNotification(notification Notification) {
+	l.OnRemoteChange(notification)
+}
+
+func startServer(t *testing.T, changeLog ChangeLog) (*Server, string) {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "todo.sock")
+	server, err := NewServer(socketPath, &recordingListener{})
+	if err != nil {
+		t.Fatalf("NewServer() error: %v", err)
+	}
+	server.changeLog = changeLog
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	t.Cleanup(func() { server.Stop() })
+	return server, socketPath
+}
+
+func startClient(t *testing.T, socketPath string, since int64) (*Client, *recordingListener) {
+	t.Helper()
+
+	listener := &recordingListener{}
+	client, err := NewClient(socketPath, listener, since)
+	if err != nil {
+		t.Fatalf("NewClient() error: %v", err)
+	}
+	if err := client.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	t.Cleanup(func() { client.Stop() })
+	return client, listener
+}
+
+func waitForHello(t *testing.T, server *Server) {
+	t.Helper()
+
+	waitFor(t, "the client to say hello", func() bool {
+		server.clientsMutex.RLock()
+		defer server.clientsMutex.RUnlock()
+		for _, p := range server.clients {
+			if p.ready && p.pid != 0 {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func todoChange(nt NotificationType, id int64) Notification {
+	change := NewNotification(nt, EntityTodo, id, nil, &testTodo{ID: id, Title: "Todo"})
+	change.UID = "todo-" + string(rune('a'+id))
+	return change
+}
+
+func seqs(notifications []Notification) []int64 {
+	var seqs []int64
+	for _, notification := range notifications {
+		seqs = append(seqs, notification.Seq)
+	}
+	return seqs
+}
+
+func TestServerReplaysMissedChanges(t *testing.T) {
+	changeLog := &memoryChangeLog{}
+	server, socketPath := startServer(t, changeLog)
+
+	for id := int64(1); id <= 3; id++ {
+		if err := server.Broadcast(todoChange(TodoCreated, id)); err != nil {
+			t.Fatalf("Broadcast() error: %v", err)
+		}
+	}
+
+	// The client saw the first change before it lost its connection
+	client, changes := startClient(t, socketPath, 1)
+	waitFor(t, "the missed changes", func() bool { return len(changes.received()) == 2 })
+
+	if err := server.Broadcast(todoChange(TodoUpdated, 1)); err != nil {
+		t.Fatalf("Broadcast() error: %v", err)
+	}
+	waitFor(t, "the live change", func() bool { return len(changes.received()) == 3 })
+
+	got := seqs(changes.received())
+	if got[0] != 2 || got[1] != 3 || got[2] != 4 {
+		t.Errorf("received changes %v, want 2, 3 and 4 in order", got)
+	}
+	if client.lastSeq.Load() != 4 {
+		t.Errorf("lastSeq = %d, want 4", client.lastSeq.Load())
+	}
+}
+
+func TestClientWithoutChangeLogSkipsReplay(t *testing.T) {
+	server, socketPath := startServer(t, &memoryChangeLog{})
+	if err := server.Broadcast(todoChange(TodoCreated, 1)); err != nil {
+		t.Fatalf("Broadcast() error: %v", err)
+	}
+
+	_, changes := startClient(t, socketPath, -1)
+	waitForHello(t, server)
+	if err := server.Broadcast(todoChange(TodoCreated, 2)); err != nil {
+		t.Fatalf("Broadcast() error: %v", err)
+	}
+	waitFor(t, "the live change", func() bool { return len(changes.received()) > 0 })
+
+	time.Sleep(50 * time.Millisecond)
+	if got := seqs(changes.received()); len(got) != 1 || got[0] != 2 {
+		t.Errorf("received changes %v, want only the live one", got)
+	}
+}
+
+func TestClientDropsChangesItHasSeen(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "todo.sock")
+	listener, err := net.Listen(DefaultProtocol, socketPath)
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+	defer listener.Close()
+
+	// A primary whose broadcast crossed the replay, so the client gets change 6 twice
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, err := ReadMessage(conn); err != nil {
+			return
+		}
+		if err := WriteMessage(conn, newHandshakeNotification(SyncWelcome, localHandshake())); err != nil {
+			return
+		}
+		for _, seq := range []int64{4, 5, 6, 6, 7} {
+			change := todoChange(TodoUpdated, 1)
+			change.Seq = seq
+			if err := WriteMessage(conn, change); err != nil {
+				return
+			}
+		}
+		time.Sleep(time.Second)
+	}()
+
+	_, changes := startClient(t, socketPath, 5)
+	waitFor(t, "the last change", func() bool {
+		received := changes.received()
+		return len(received) > 0 && received[len(received)-1].Seq == 7
+	})
+	if got := seqs(changes.received()); len(got) != 2 || got[0] != 6 {
+		t.Errorf("received changes %v, want 6 and 7 once", got)
+	}
+}
+
+func TestStatusReportsLag(t *testing.T) {
+	server, socketPath := startServer(t, &memoryChangeLog{})
+	startClient(t, socketPath, 0)
+
+	// The client only lags behind changes made after its hello
+	waitForHello(t, server)
+	for id := int64(1); id <= 2; id++ {
+		if err := server.Broadcast(todoChange(TodoCreated, id)); err != nil {
+			t.Fatalf("Broadcast() error: %v", err)
+		}
+	}
+
+	status, err := QueryStatus(socketPath)
+	if err != nil {
+		t.Fatalf("QueryStatus() error: %v", err)
+	}
+	if status.Head != 2 {
+		t.Errorf("Head = %d, want 2", status.Head)
+	}
+	if len(status.Peers) != 2 || !status.Peers[0].Primary {
+		t.Fatalf("Peers = %+v, want the primary and one client", status.Peers)
+	}
+	if client := status.Peers[1]; client.Seq != 0 || client.Lag != 2 {
+		t.Errorf("client = %+v, want seq 0 and lag 2 before it answers a heartbeat", client)
+	}
+}
+
+func TestCompactKeepsLatestChangePerEntity(t *testing.T) {
+	changeLog := &memoryChangeLog{}
+	server, socketPath := startServer(t, changeLog)
+
+	for _, change := range []Notification{todoChange(TodoCreated, 1), todoChange(TodoCreated, 2), todoChange(TodoUpdated, 1)} {
+		if err := server.Broadcast(change); err != nil {
+			t.Fatalf("Broadcast() error: %v", err)
+		}
+	}
+	if err := changeLog.Compact(); err != nil {
+		t.Fatalf("Compact() error: %v", err)
+	}
+
+	_, changes := startClient(t, socketPath, 0)
+	waitFor(t, "the replay", func() bool { return len(changes.received()) == 2 })
+	if got := seqs(changes.received()); got[0] != 2 || got[1] != 3 {
+		t.Errorf("replayed %v, want 2 and 3", got)
+	}
+}
@@ -3,7 +3,9 @@ package socket_sync
 import (
 	"errors"
 	"net"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -22,19 +24,38 @@ const (
 
 // Client handles the socket client functionality for secondary app instances
 type Client struct {
-	socketPath     string
-	conn           net.Conn
-	connMutex      sync.RWMutex
+	socketPath string
+	conn       net.Conn
+	connMutex  sync.RWMutex
+	// reader belongs to conn, it may hold what the primary sent after its welcome
+	reader *messageReader
+	// primary is what the primary said about itself in its welcome
+	primary        Handshake
 	notifListener  NotificationListener
 	shutdown       chan struct{}
 	shutdownWg     sync.WaitGroup
 	reconnecting   bool
 	reconnectMutex sync.Mutex
 	writeMutex     sync.Mutex
+
+	// lastSeq is the latest logged change received, sent in the hello so the primary replays
+	// what came after it. It is negative when the client can't tell.
+	lastSeq atomic.Int64
+	// pending holds changes that couldn't be sent, they go out after the next reconnect
+	pending      []Notification
+	pendingMutex sync.Mutex
+
+	// presence is repeated after every reconnect and heartbeat, so it doesn't expire
+	presence      *Notification
+	presenceMutex sync.Mutex
+
+	// onIncompatible is told when a primary we reconnect to refuses us or we refuse it
+	onIncompatible func(err *IncompatibleError)
 }
 
-// NewClient creates a new socket client
-func NewClient(socketPath string, listener NotificationListener) (*Client, error) {
+// NewClient creates a new socket client. since is the latest logged change the instance has
+// seen, or negative when it keeps no change log.
+func NewClient(socketPath string, listener NotificationListener, since int64) (*Client, error) {
 	// Validate socket path
 	if err := ValidateSocketPath(socketPath); err != nil {
 		return nil, err
@@ -45,6 +66,7 @@ func NewClient(socketPath string, listener NotificationListener) (*Client, error
 		notifListener: listener,
 		shutdown:      make(chan struct{}),
 	}
+	client.lastSeq.Store(since)
 
 	// Initial connection attempt
 	if err := client.connect(); err != nil {
@@ -71,12 +93,71 @@ func (c *Client) connect() error {
 		return &SocketError{Op: "connect", Err: err}
 	}
 
+	// The socket may live in a directory other users can reach
+	if err := checkPeer(conn); err != nil {
+		conn.Close()
+		return err
+	}
+
+	// Tell the primary who we are and where we are, so it replays what we missed before
+	// anything else
+	hello := newHandshakeNotification(SyncHello, localHandshake())
+	hello.Seq = c.lastSeq.Load()
+	hello.PID = os.Getpid()
+	c.writeMutex.Lock()
+	err = WriteMessage(conn, hello)
+	c.writeMutex.Unlock()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	reader := newMessageReader(conn)
+	primary, err := c.awaitWelcome(reader)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	if presence := c.currentPresence(); presence != nil {
+		c.writeMutex.Lock()
+		err = WriteMessage(conn, *presence)
+		c.writeMutex.Unlock()
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
 	c.conn = conn
-	log.Info("Connected to sync server", "socket", c.socketPath)
+	c.reader = reader
+	c.primary = primary
+	log.Info("Connected to sync server", "socket", c.socketPath, "since", hello.Seq, "version", primary.App)
 
 	return nil
 }
 
+// awaitWelcome reads the answer to our hello. A primary from before the handshake doesn't
+// send one, so we can't tell whether we understand it.
+func (c *Client) awaitWelcome(reader *messageReader) (Handshake, error) {
+	reply, err := reader.read(HelloTimeout)
+	if err != nil && !IsTimeout(err) {
+		return Handshake{}, err
+	}
+
+	primary := decodeHandshake(reply)
+	switch {
+	case err == nil && reply.Type == SyncRefused:
+		return primary, &IncompatibleError{Peer: primary, Reason: primary.Reason, ByPeer: true}
+	case err != nil || reply.Type != SyncWelcome:
+		return primary, &IncompatibleError{Reason: "the primary didn't answer the hello, it is from an older version"}
+	}
+	if reason := primary.check(); reason != "" {
+		return primary, &IncompatibleError{Peer: primary, Reason: reason}
+	}
+	return primary, nil
+}
+
 // Start begins the message receiving loop
 func (c *Client) Start() error {
 	c.shutdownWg.Add(1)
@@ -97,7 +178,7 @@ func (c *Client) receiveLoop() {
 		default:
 			// Get current connection
 			c.connMutex.RLock()
-			conn := c.conn
+			conn, reader := c.conn, c.reader
 			c.connMutex.RUnlock()
 
 			if conn == nil {
@@ -106,13 +187,8 @@ func (c *Client) receiveLoop() {
 				continue
 			}
 
-			err := conn.SetReadDeadline(time.Now().Add(90 * time.Second))
-			if err != nil {
-				log.Error("Failed to set read deadline", "error", err)
-			}
-
 			// Read a message
-			notification, err := ReadMessage(conn)
+			notification, err := reader.read(90 * time.Second)
 			if err != nil {
 				if IsTimeout(err) {
 					continue
@@ -137,9 +213,20 @@ func (c *Client) receiveLoop() {
 			}
 
 			if notification.Type == Heartbeat {
+				// Let the primary know how far we are for `todo sync status`
+				c.sendPosition()
+				c.notifListener.OnNotification(notification)
 				continue
 			}
 
+			if notification.Seq > 0 {
+				// A change can arrive twice when a broadcast crosses the replay after a reconnect
+				if notification.Seq <= c.lastSeq.Load() && notification.Type != SyncReload {
+					continue
+				}
+				c.lastSeq.Store(notification.Seq)
+			}
+
 			// Process the notification
 			log.Info("Received notification from server", "type", notification.Type, "todoID", notification.ID)
 			c.notifListener.OnNotification(notification)
@@ -149,15 +236,19 @@ func (c *Client) receiveLoop() {
 
 // reconnect attempts to reestablish the connection to the server
 func (c *Client) reconnect() {
-	c.reconnectMutex.Lock()
-	defer c.reconnectMutex.Unlock()
-
 	// Avoid multiple simultaneous reconnection attempts
+	c.reconnectMutex.Lock()
 	if c.reconnecting {
+		c.reconnectMutex.Unlock()
 		return
 	}
 	c.reconnecting = true
-	defer func() { c.reconnecting = false }()
+	c.reconnectMutex.Unlock()
+	defer func() {
+		c.reconnectMutex.Lock()
+		c.reconnecting = false
+		c.reconnectMutex.Unlock()
+	}()
 
 	// Use exponential backoff for reconnection attempts
 	delay := InitialReconnectDelay
@@ -172,10 +263,15 @@ func (c *Client) reconnect() {
 		err := c.connect()
 		if err == nil {
 			log.Info("Successfully reconnected to server")
+			c.flushPending()
 			return
 		}
 
 		log.Error("Reconnection failed", "attempt", attempt, "error", err)
+		var incompatible *IncompatibleError
+		if errors.As(err, &incompatible) && c.onIncompatible != nil {
+			c.onIncompatible(incompatible)
+		}
 
 		// Wait before next attempt, with exponential backoff
 		select {
@@ -193,8 +289,19 @@ func (c *Client) reconnect() {
 	log.Error("Failed to reconnect after maximum attempts", "attempts", MaxReconnectAttempts)
 }
 
-// SendNotification sends a notification to the server
+// SendNotification sends a notification to the server. When it can't be sent the notification
+// is kept and sent after reconnecting.
 func (c *Client) SendNotification(notification Notification) error {
+	if err := c.send(notification); err != nil {
+		// Whatever went wrong, the change goes out after the next reconnect
+		c.queue(notification)
+		return err
+	}
+	return nil
+}
+
+// send writes a notification to the primary without queueing it when that fails
+func (c *Client) send(notification Notification) error {
 	c.connMutex.RLock()
 	conn := c.conn
 	c.connMutex.RUnlock()
@@ -207,16 +314,93 @@ func (c *Client) SendNotification(notification Notification) error {
 	err := WriteMessage(conn, notification)
 	c.writeMutex.Unlock()
 
-	if err != nil {
-		// If the connection failed, try to reconnect
-		if IsSocketClosed(err) && !c.isShuttingDown() {
-			log.Warn("Connection lost while sending, attempting to reconnect")
-			go c.reconnect()
+	// If the connection failed, try to reconnect
+	if IsSocketClosed(err) && !c.isShuttingDown() {
+		log.Warn("Connection lost while sending, attempting to reconnect")
+		go c.reconnect()
+	}
+	return err
+}
+
+// sendPosition answers a heartbeat with the latest change we have seen
+func (c *Client) sendPosition() {
+	heartbeat := Notification{Type: Heartbeat, Timestamp: time.Now(), Seq: c.lastSeq.Load()}
+	if err := c.SendNotification(heartbeat); err != nil {
+		log.Debug("Failed to answer heartbeat", "error", err)
+	}
+	if presence := c.currentPresence(); presence != nil {
+		if err := c.SendNotification(*presence); err != nil {
+			log.Debug("Failed to repeat presence", "error", err)
 		}
-		return err
 	}
+}
 
-	return nil
+// SetPresence announces the presence of this instance to the others
+func (c *Client) SetPresence(notification Notification) {
+	c.presenceMutex.Lock()
+	c.presence = &notification
+	c.presenceMutex.Unlock()
+
+	// Without a connection it is announced after reconnecting
+	if c.Connected() {
+		if err := c.SendNotification(notification); err != nil {
+			log.Debug("Failed to send presence", "error", err)
+		}
+	}
+}
+
+func (c *Client) currentPresence() *Notification {
+	c.presenceMutex.Lock()
+	defer c.presenceMutex.Unlock()
+	return c.presence
+}
+
+// queue keeps a change for after the next reconnect
+func (c *Client) queue(notification Notification) {
+	// Heartbeats and presence are repeated anyway
+	if notification.Type == Heartbeat || notification.Type == PresenceUpdate {
+		return
+	}
+
+	c.pendingMutex.Lock()
+	defer c.pendingMutex.Unlock()
+	c.pending = append(c.pending, notification)
+}
+
+// takePending returns the changes that weren't sent, e.g. to broadcast them after the
+// instance took over as primary
+func (c *Client) takePending() []Notification {
+	c.pendingMutex.Lock()
+	defer c.pendingMutex.Unlock()
+	pending := c.pending
+	c.pending = nil
+	return pending
+}
+
+// Connected reports whether the client has a connection to the primary
+func (c *Client) Connected() bool {
+	c.connMutex.RLock()
+	defer c.connMutex.RUnlock()
+	return c.conn != nil
+}
+
+// flushPending sends the changes made while the connection was down
+func (c *Client) flushPending() {
+	c.pendingMutex.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.pendingMutex.Unlock()
+
+	for i, notification := range pending {
+		if err := c.send(notification); err != nil {
+			// Keep this one and the rest ahead of changes queued in the meantime
+			c.pendingMutex.Lock()
+			c.pending = append(pending[i:], c.pending...)
+			c.pendingMutex.Unlock()
+			log.Warn("Failed to send changes made while disconnected", "error", err)
+			return
+		}
+	}
 }
 
 // Stop gracefully shuts down the client
@@ -0,0 +1,53 @@
+package socket_sync
+
+import (
+	"bufio"
+	"net"
+	"testing"
+)
+
+// pipeClient returns a client whose connection is one end of a pipe and the other end
+func pipeClient(t *testing.T) (*Client, net.Conn) {
+	t.Helper()
+	conn, peer := net.Pipe()
+	t.Cleanup(func() {
+		conn.Close()
+		peer.Close()
+	})
+	client := &Client{conn: conn, shutdown: make(chan struct{})}
+	return client, peer
+}
+
+func TestFlushPendingKeepsWhatWasNotSent(t *testing.T) {
+	client, peer := pipeClient(t)
+	client.pending = []Notification{
+		{Type: TodoCreated, ID: 1},
+		{Type: TodoCreated, ID: 2},
+		{Type: TodoCreated, ID: 3},
+	}
+
+	// The primary takes the first change and goes away
+	go func() {
+		reader := bufio.NewReader(peer)
+		reader.ReadBytes('\n')
+		peer.Close()
+	}()
+	client.flushPending()
+
+	if len(client.pending) != 2 || client.pending[0].ID != 2 || client.pending[1].ID != 3 {
+		t.Fatalf("pending after a failed flush = %+v, want todos 2 and 3", client.pending)
+	}
+}
+
+func TestSendNotificationQueuesOnAnyWriteError(t *testing.T) {
+	client, peer := pipeClient(t)
+	// Writing to a pipe closed on the other side isn't a closed socket to IsSocketClosed
+	peer.Close()
+
+	if err := client.SendNotification(Notification{Type: TodoUpdated, ID: 7}); err == nil {
+		t.Fatal("SendNotification() on a closed pipe succeeded")
+	}
+	if len(client.pending) != 1 || client.pending[0].ID != 7 {
+		t.Errorf("pending = %+v, want the change that failed", client.pending)
+	}
+}
@@ -0,0 +1,105 @@
+package socket_sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/martijnspitter/tui-todo/internal/version"
+)
+
+// Every connection starts with a handshake. A client says hello with its Handshake, the
+// primary answers with its own in a welcome or refuses the client and hangs up. Both sides
+// check that they understand each other's notifications, a peer from before the handshake
+// sends none and is refused. Tools speaking JSON-RPC skip the handshake.
+
+// MinProtocolVersion is the oldest notification envelope this version understands
+const MinProtocolVersion = 1
+
+// Capabilities a peer can announce in its handshake
+const (
+	// CapabilityChangeLog means the peer replays or catches up on missed changes
+	CapabilityChangeLog = "changelog"
+	// CapabilityPresence means the peer announces itself and the todo it edits
+	CapabilityPresence = "presence"
+	// CapabilityRPC means the primary answers JSON-RPC requests from tools
+	CapabilityRPC = "rpc"
+)
+
+// Handshake describes the app on the other end of a connection
+type Handshake struct {
+	// App is the version of the app, as version.GetVersion reports it
+	App string `json:"app"`
+	// Protocol is the notification envelope the peer sends, MinProtocol the oldest it reads
+	Protocol     int      `json:"protocol"`
+	MinProtocol  int      `json:"min_protocol"`
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Reason explains why the primary refused the client
+	Reason string `json:"reason,omitempty"`
+}
+
+// IncompatibleError is returned for a peer that speaks a protocol this version doesn't
+type IncompatibleError struct {
+	Peer Handshake
+	// Reason is why the connection was refused
+	Reason string
+	// ByPeer is set when the other side refused us, otherwise we refused it
+	ByPeer bool
+}
+
+func (e *IncompatibleError) Error() string {
+	app := e.Peer.App
+	if app == "" {
+		app = "unknown"
+	}
+	if e.ByPeer {
+		return fmt.Sprintf("refused by instance of version %s: %s", app, e.Reason)
+	}
+	return fmt.Sprintf("refused instance of version %s: %s", app, e.Reason)
+}
+
+// localHandshake describes this app
+func localHandshake() Handshake {
+	return Handshake{
+		App:          version.GetVersion(),
+		Protocol:     ProtocolVersion,
+		MinProtocol:  MinProtocolVersion,
+		Capabilities: []string{CapabilityChangeLog, CapabilityPresence, CapabilityRPC},
+	}
+}
+
+// Has reports whether the peer announced a capability
+func (h Handshake) Has(capability string) bool {
+	return slices.Contains(h.Capabilities, capability)
+}
+
+// check returns why we can't talk to a peer with this handshake, or "" when we can
+func (h Handshake) check() string {
+	switch {
+	case h.Protocol == 0:
+		return "no handshake, the instance is from an older version"
+	case h.Protocol < MinProtocolVersion:
+		return fmt.Sprintf("protocol %d is older than the oldest supported %d", h.Protocol, MinProtocolVersion)
+	case h.MinProtocol > ProtocolVersion:
+		return fmt.Sprintf("protocol %d is older than the oldest the instance supports, %d", ProtocolVersion, h.MinProtocol)
+	}
+	return ""
+}
+
+// newHandshakeNotification builds a hello, welcome or refusal carrying h
+func newHandshakeNotification(nt NotificationType, h Handshake) Notification {
+	notification := Notification{Type: nt, Timestamp: time.Now()}
+	notification.Snapshot, _ = json.Marshal(h)
+	return notification
+}
+
+// decodeHandshake returns the handshake of a hello, welcome or refusal. Messages without one
+// yield the zero Handshake, which check refuses.
+func decodeHandshake(notification Notification) Handshake {
+	var h Handshake
+	if notification.Snapshot != nil {
+		_ = json.Unmarshal(notification.Snapshot, &h)
+	}
+	return h
+}
@@ -0,0 +1,179 @@
+package socket_sync
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func dialServer(t *testing.T, socketPath string) (net.Conn, *messageReader) {
+	t.Helper()
+
+	conn, err := net.Dial(DefaultProtocol, socketPath)
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, newMessageReader(conn)
+}
+
+func TestServerRefusesIncompatibleClients(t *testing.T) {
+	newer := localHandshake()
+	newer.MinProtocol = ProtocolVersion + 1
+
+	tests := []struct {
+		name  string
+		hello Notification
+	}{
+		{"hello from before the handshake", Notification{Type: SyncHello, Timestamp: time.Now(), PID: 42}},
+		{"client that needs a newer protocol", newHandshakeNotification(SyncHello, newer)},
+		{"change instead of a hello", todoChange(TodoUpdated, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, socketPath := startServer(t, nil)
+			conn, reader := dialServer(t, socketPath)
+			if err := WriteMessage(conn, tt.hello); err != nil {
+				t.Fatal(err)
+			}
+
+			reply, err := reader.read(ReadTimeout)
+			if err != nil || reply.Type != SyncRefused || decodeHandshake(reply).Reason == "" {
+				t.Fatalf("reply = %+v, %v, want a refusal with a reason", reply, err)
+			}
+			if _, err := reader.read(ReadTimeout); !IsSocketClosed(err) {
+				t.Errorf("connection still open after refusal: %v", err)
+			}
+
+			refusals := 0
+			for _, notification := range server.notifListener.(*recordingListener).received() {
+				if notification.Type == SyncRefused {
+					refusals++
+				}
+			}
+			if refusals != 1 {
+				t.Errorf("listener heard of %d refusals, want 1", refusals)
+			}
+		})
+	}
+}
+
+func TestClientRefusesIncompatiblePrimary(t *testing.T) {
+	older := localHandshake()
+	older.Protocol = MinProtocolVersion - 1
+
+	tests := []struct {
+		name   string
+		answer *Notification
+		byPeer bool
+	}{
+		{"primary that needs a newer protocol", ptr(newHandshakeNotification(SyncRefused, Handshake{App: "v9.0.0", Reason: "too old"})), true},
+		{"primary with an older protocol", ptr(newHandshakeNotification(SyncWelcome, older)), false},
+		{"primary from before the handshake", ptr(todoChange(TodoUpdated, 1)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			socketPath := filepath.Join(t.TempDir(), "todo.sock")
+			listener, err := net.Listen(DefaultProtocol, socketPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				if _, err := ReadMessage(conn); err == nil {
+					WriteMessage(conn, *tt.answer)
+				}
+				time.Sleep(time.Second)
+			}()
+
+			_, err = NewClient(socketPath, &recordingListener{}, 0)
+			var incompatible *IncompatibleError
+			if !errors.As(err, &incompatible) || incompatible.ByPeer != tt.byPeer {
+				t.Errorf("NewClient() error = %v, want an IncompatibleError with ByPeer %v", err, tt.byPeer)
+			}
+		})
+	}
+}
+
+func TestServerWelcomesCompatibleClients(t *testing.T) {
+	server, socketPath := startServer(t, nil)
+	client, _ := startClient(t, socketPath, 0)
+	waitForHello(t, server)
+
+	if client.primary.Protocol != ProtocolVersion || !client.primary.Has(CapabilityPresence) {
+		t.Errorf("primary handshake = %+v", client.primary)
+	}
+	server.clientsMutex.RLock()
+	defer server.clientsMutex.RUnlock()
+	for _, p := range server.clients {
+		if p.handshake.App == "" {
+			t.Errorf("client handshake = %+v, want the app version", p.handshake)
+		}
+	}
+}
+
+func TestServerSkipsMalformedFrames(t *testing.T) {
+	server, socketPath := startServer(t, nil)
+	conn, reader := dialServer(t, socketPath)
+	if err := WriteMessage(conn, newHandshakeNotification(SyncHello, localHandshake())); err != nil {
+		t.Fatal(err)
+	}
+	if welcome, err := reader.read(ReadTimeout); err != nil || welcome.Type != SyncWelcome {
+		t.Fatalf("welcome = %+v, %v", welcome, err)
+	}
+
+	// A few bad frames are skipped, the messages after them still arrive
+	oversized := append(bytes.Repeat([]byte("x"), MaxRPCMessageSize+1), '\n')
+	for _, frame := range [][]byte{[]byte("not json\n"), oversized} {
+		if _, err := conn.Write(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WriteMessage(conn, todoChange(TodoUpdated, 1)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the change after the bad frames", func() bool {
+		return len(server.notifListener.(*recordingListener).received()) == 1
+	})
+
+	// A client that keeps sending them is disconnected
+	for range MaxBadFrames {
+		if _, err := conn.Write([]byte("{\n")); err != nil {
+			break
+		}
+	}
+	if _, err := reader.read(ReadTimeout); !IsSocketClosed(err) {
+		t.Errorf("client still connected after %d bad frames: %v", MaxBadFrames+2, err)
+	}
+}
+
+func TestSocketOnlyForOwner(t *testing.T) {
+	_, socketPath := startServer(t, nil)
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("socket permissions = %o, want 600", perm)
+	}
+
+	conn, _ := dialServer(t, socketPath)
+	if err := checkPeer(conn); err != nil {
+		t.Errorf("checkPeer() of our own process = %v", err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,68 @@
+package socket_sync
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// The primary holds an exclusive lock on a lease file next to the socket. The operating
+// system releases the lock when the process exits, also when it crashes, which a socket file
+// left behind can't tell. Secondaries that lose the primary race for the lease, the winner
+// starts a server and the others reconnect to it.
+
+// LeaseRetryInterval is how often a disconnected secondary tries to take over the lease
+const LeaseRetryInterval = 250 * time.Millisecond
+
+type lease struct {
+	path  string
+	mu    sync.Mutex
+	file  *os.File
+	owned bool
+}
+
+func newLease(socketPath string) *lease {
+	return &lease{path: socketPath + ".lock"}
+}
+
+// TryAcquire takes the lease unless another instance holds it
+func (l *lease) TryAcquire() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.owned {
+		return true, nil
+	}
+	if l.file == nil {
+		file, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0o600)
+		if err != nil {
+			return false, &SocketError{Op: "lease", Err: err}
+		}
+		l.file = file
+	}
+
+	acquired, err := tryLockFile(l.file)
+	if err != nil {
+		return false, &SocketError{Op: "lease", Err: err}
+	}
+	l.owned = acquired
+	return acquired, nil
+}
+
+// Release gives up the lease, if it is held, and closes the lease file
+func (l *lease) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	var err error
+	if l.owned {
+		err = unlockFile(l.file)
+	}
+	l.file.Close()
+	l.file = nil
+	l.owned = false
+	return err
+}
@@ -1,6 +1,7 @@
 package socket_sync
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,22 +17,48 @@ import (
 type Manager struct {
 	socketPath    string
 	notifListener NotificationListener
-	server        *Server
-	client        *Client
-	isPrimary     bool
-	started       atomic.Bool
-	startMutex    sync.Mutex
-	stopped       atomic.Bool
-	notifBuffer   []Notification // Buffer notifications during initialization
-	bufferMutex   sync.Mutex
-	lastPollTime  time.Time
-	pollMutex     sync.Mutex
+	lease         *lease
+	// server or client is set depending on the role, which changes when a secondary takes
+	// over from a primary that is gone
+	server       *Server
+	client       *Client
+	roleMutex    sync.RWMutex
+	isPrimary    atomic.Bool
+	onPromote    func()
+	watchStop    chan struct{}
+	stopWatching sync.Once
+	watchWg      sync.WaitGroup
+	started      atomic.Bool
+	startMutex   sync.Mutex
+	stopped      atomic.Bool
+	notifBuffer  []Notification // Buffer notifications during initialization
+	bufferMutex  sync.Mutex
+	lastPollTime time.Time
+	pollMutex    sync.Mutex
+	changeLog    ChangeLog
+	rpcHandler   RPCHandler
+
+	// instanceID identifies this instance in presence announcements
+	instanceID    string
+	presence      Presence
+	peers         map[string]presenceEntry
+	onPresence    func()
+	presenceMutex sync.Mutex
+
+	// onIncompatible is told about instances that speak another protocol, lastIncompatible
+	// keeps an instance that keeps retrying from being reported every time
+	onIncompatible    func(err *IncompatibleError)
+	lastIncompatible  string
+	incompatibleMutex sync.Mutex
 }
 
 // NewManager creates a new synchronization manager
 func NewManager(version string, listener NotificationListener) (*Manager, error) {
-	socketPath := osoperations.GetFilePath("todo.sock", version)
+	return NewManagerAt(osoperations.GetFilePath("todo.sock", version), listener)
+}
 
+// NewManagerAt creates a manager for the socket at socketPath, e.g. that of another workspace
+func NewManagerAt(socketPath string, listener NotificationListener) (*Manager, error) {
 	if !filepath.IsAbs(socketPath) {
 		absPath, err := filepath.Abs(socketPath)
 		if err != nil {
@@ -40,16 +67,70 @@ func NewManager(version string, listener NotificationListener) (*Manager, error)
 		socketPath = absPath
 	}
 
+	instanceID, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+
 	manager := &Manager{
+		instanceID:    instanceID,
 		socketPath:    socketPath,
 		notifListener: listener,
+		lease:         newLease(socketPath),
+		watchStop:     make(chan struct{}),
 		lastPollTime:  time.Now(),
 	}
 
 	return manager, nil
 }
 
-// Start initializes the sync system with leader election
+// SetChangeLog sets the log the primary keeps of all changes, so instances that were
+// disconnected can catch up. It has to be called before Start.
+func (m *Manager) SetChangeLog(changeLog ChangeLog) {
+	m.changeLog = changeLog
+}
+
+// SetRPCHandler sets the handler for the JSON-RPC requests of tools, which the primary
+// answers. It has to be called before Start.
+func (m *Manager) SetRPCHandler(handler RPCHandler) {
+	m.rpcHandler = handler
+}
+
+// OnPromote sets a function that is called when this instance takes over as the primary
+// after the previous one went away
+func (m *Manager) OnPromote(fn func()) {
+	m.startMutex.Lock()
+	defer m.startMutex.Unlock()
+	m.onPromote = fn
+}
+
+// OnIncompatible sets a function that is called when this instance refuses another one or
+// is refused by the primary, because they speak different protocols. It has to be called
+// before Start.
+func (m *Manager) OnIncompatible(fn func(err *IncompatibleError)) {
+	m.incompatibleMutex.Lock()
+	defer m.incompatibleMutex.Unlock()
+	m.onIncompatible = fn
+}
+
+// reportIncompatible tells about an incompatible instance, unless it was the last one
+func (m *Manager) reportIncompatible(err *IncompatibleError) {
+	m.incompatibleMutex.Lock()
+	if m.lastIncompatible == err.Error() {
+		m.incompatibleMutex.Unlock()
+		return
+	}
+	m.lastIncompatible = err.Error()
+	fn := m.onIncompatible
+	m.incompatibleMutex.Unlock()
+
+	if fn != nil {
+		fn(err)
+	}
+}
+
+// Start initializes the sync system with leader election. The instance holding the lease
+// is the primary, the others connect to it and take over when it goes away.
 func (m *Manager) Start() error {
 	m.startMutex.Lock()
 	defer m.startMutex.Unlock()
@@ -58,37 +139,34 @@ func (m *Manager) Start() error {
 		return nil // Already started
 	}
 
-	// Attempt to become the primary instance by starting a server
-	server, err := NewServer(m.socketPath, m)
+	server, err := m.startServer()
 	if err == nil {
-		// Successfully created server, we are the primary
 		m.server = server
-		m.isPrimary = true
-
-		if err := m.server.Start(); err != nil {
-			return fmt.Errorf("failed to start server: %w", err)
-		}
-
+		m.isPrimary.Store(true)
 		log.Info("This instance is the primary (server)")
 	} else {
-		// Failed to create server, we are a secondary instance
 		log.Info("Failed to create server", "error", err)
 		log.Info("This instance is a secondary (client)")
 
-		// Wait a brief moment to ensure the server is ready
-		time.Sleep(100 * time.Millisecond)
-
-		// Create and start the client
-		client, err := NewClient(m.socketPath, m)
-		if err != nil {
-			return fmt.Errorf("failed to create client: %w", err)
+		client, err := m.startClient()
+		var incompatible *IncompatibleError
+		if errors.As(err, &incompatible) {
+			log.Warn("Can't sync with the primary instance, this instance runs on its own", "error", err)
+			m.reportIncompatible(incompatible)
 		}
-
-		m.client = client
-		m.isPrimary = false
-
-		if err := m.client.Start(); err != nil {
-			return fmt.Errorf("failed to start client: %w", err)
+		if err != nil {
+			// The primary may have gone away while we were looking
+			server, serverErr := m.startServer()
+			if serverErr != nil {
+				return fmt.Errorf("failed to create client: %w", err)
+			}
+			m.server = server
+			m.isPrimary.Store(true)
+			log.Info("This instance is the primary (server)")
+		} else {
+			m.client = client
+			m.watchWg.Add(1)
+			go m.watchLease()
 		}
 	}
 
@@ -100,8 +178,148 @@ func (m *Manager) Start() error {
 	return nil
 }
 
+// startServer takes the lease and starts serving the other instances
+func (m *Manager) startServer() (*Server, error) {
+	acquired, err := m.lease.TryAcquire()
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, errors.New("another instance holds the lease")
+	}
+
+	// A primary from before leases holds the socket without one
+	server, err := NewServer(m.socketPath, m)
+	if err != nil {
+		m.lease.Release()
+		return nil, err
+	}
+	server.changeLog = m.changeLog
+	server.rpcHandler = m.rpcHandler
+
+	if err := server.Start(); err != nil {
+		server.Stop()
+		m.lease.Release()
+		return nil, fmt.Errorf("failed to start server: %w", err)
+	}
+	return server, nil
+}
+
+// startClient connects to the primary
+func (m *Manager) startClient() (*Client, error) {
+	// Wait a brief moment to ensure the server is ready
+	time.Sleep(100 * time.Millisecond)
+
+	// Everything up to the head of the log is in the database we just opened
+	since := int64(-1)
+	if m.changeLog != nil {
+		head, err := m.changeLog.Head()
+		if err != nil {
+			log.Warn("Failed to read change log", "error", err)
+		} else {
+			since = head
+		}
+	}
+
+	client, err := NewClient(m.socketPath, m, since)
+	if err != nil {
+		return nil, err
+	}
+	client.onIncompatible = m.reportIncompatible
+	if err := client.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start client: %w", err)
+	}
+	return client, nil
+}
+
+// watchLease runs on a secondary. While it is disconnected from the primary it tries to
+// take over the lease, the first secondary to get it becomes the primary.
+func (m *Manager) watchLease() {
+	defer m.watchWg.Done()
+
+	ticker := time.NewTicker(LeaseRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.watchStop:
+			return
+		case <-ticker.C:
+		}
+
+		_, client := m.role()
+		if client == nil || client.Connected() {
+			continue
+		}
+		if m.promote() {
+			return
+		}
+		// Another secondary took over, keep trying to reach it
+		go client.reconnect()
+	}
+}
+
+// promote turns a secondary into the primary. The other secondaries reconnect to it and
+// catch up from the change log, changes this instance couldn't send are broadcast.
+func (m *Manager) promote() bool {
+	m.startMutex.Lock()
+	defer m.startMutex.Unlock()
+
+	if m.stopped.Load() {
+		return true
+	}
+
+	server, err := m.startServer()
+	if err != nil {
+		return false
+	}
+
+	m.roleMutex.Lock()
+	client := m.client
+	m.server = server
+	m.client = nil
+	m.isPrimary.Store(true)
+	m.roleMutex.Unlock()
+	log.Info("The primary went away, this instance took over (server)")
+
+	// The other instances announce themselves again when they reconnect
+	m.clearPresence()
+	m.presenceMutex.Lock()
+	if m.presence.Instance != "" {
+		server.SetPresence(newPresenceNotification(m.presence))
+	}
+	m.presenceMutex.Unlock()
+
+	var pending []Notification
+	if client != nil {
+		client.Stop()
+		pending = client.takePending()
+	}
+	for _, notification := range pending {
+		if err := server.Broadcast(notification); err != nil {
+			log.Error("Failed to send change made while disconnected", "error", err)
+		}
+	}
+
+	if m.onPromote != nil {
+		go m.onPromote()
+	}
+	return true
+}
+
+// role returns the server of the primary, or the client of a secondary
+func (m *Manager) role() (*Server, *Client) {
+	m.roleMutex.RLock()
+	defer m.roleMutex.RUnlock()
+	return m.server, m.client
+}
+
 // Stop gracefully shuts down the sync system
 func (m *Manager) Stop() error {
+	// The watcher takes startMutex when it promotes this instance
+	m.stopWatching.Do(func() { close(m.watchStop) })
+	m.watchWg.Wait()
+
 	m.startMutex.Lock()
 	defer m.startMutex.Unlock()
 
@@ -110,10 +328,14 @@ func (m *Manager) Stop() error {
 	}
 
 	var err error
-	if m.isPrimary && m.server != nil {
-		err = m.server.Stop()
-	} else if m.client != nil {
-		err = m.client.Stop()
+	if server, client := m.role(); server != nil {
+		err = server.Stop()
+	} else if client != nil {
+		err = client.Stop()
+	}
+	// The next instance can only take over once the socket is gone
+	if releaseErr := m.lease.Release(); err == nil {
+		err = releaseErr
 	}
 
 	m.stopped.Store(true)
@@ -121,33 +343,27 @@ func (m *Manager) Stop() error {
 }
 
 // NotifyChange broadcasts a change notification to other instances
-func (m *Manager) NotifyChange(notificationType NotificationType, id int64) error {
+func (m *Manager) NotifyChange(notification Notification) error {
 	if m.stopped.Load() {
 		return nil // Already shut down
 	}
 
+	if notification.Timestamp.IsZero() {
+		notification.Timestamp = time.Now()
+	}
+
 	// If not yet started, buffer so we can replay after Start().
 	if !m.started.Load() {
 		m.bufferMutex.Lock()
-		m.notifBuffer = append(m.notifBuffer, Notification{
-			Type:      notificationType,
-			ID:        id,
-			Timestamp: time.Now(),
-		})
+		m.notifBuffer = append(m.notifBuffer, notification)
 		m.bufferMutex.Unlock()
 		return nil
 	}
 
-	notification := Notification{
-		Type:      notificationType,
-		ID:        id,
-		Timestamp: time.Now(),
-	}
-
-	if m.isPrimary && m.server != nil {
-		return m.server.Broadcast(notification)
-	} else if m.client != nil {
-		return m.client.SendNotification(notification)
+	if server, client := m.role(); server != nil {
+		return server.Broadcast(notification)
+	} else if client != nil {
+		return client.SendNotification(notification)
 	}
 
 	// If we're not fully initialized yet, buffer the notification
@@ -165,13 +381,14 @@ func (m *Manager) processBufferedNotifications() {
 	m.notifBuffer = nil
 	m.bufferMutex.Unlock()
 
+	server, client := m.role()
 	for _, notification := range buffer {
-		if m.isPrimary && m.server != nil {
-			if err := m.server.Broadcast(notification); err != nil {
+		if server != nil {
+			if err := server.Broadcast(notification); err != nil {
 				log.Error("Failed to replay buffered notification", "error", err)
 			}
-		} else if m.client != nil {
-			if err := m.client.SendNotification(notification); err != nil {
+		} else if client != nil {
+			if err := client.SendNotification(notification); err != nil {
 				log.Error("Failed to replay buffered notification", "error", err)
 			}
 		}
@@ -180,7 +397,7 @@ func (m *Manager) processBufferedNotifications() {
 
 // IsPrimary returns whether this instance is the primary (server)
 func (m *Manager) IsPrimary() bool {
-	return m.isPrimary
+	return m.isPrimary.Load()
 }
 
 // UpdatePollingTime updates the timestamp of the last polling operation
@@ -205,8 +422,21 @@ func (m *Manager) GetSocketPath() string {
 // OnNotification implements the NotificationListener interface
 // This will be called when a notification is received from another instance
 func (m *Manager) OnNotification(notification Notification) {
-	// Ignore heartbeat messages - they're just for connection maintenance
-	if notification.Type == Heartbeat {
+	// Ignore heartbeat and handshake messages - they're just for connection maintenance
+	switch notification.Type {
+	case Heartbeat:
+		// Announcements that weren't repeated since the last few heartbeats are gone
+		m.prunePresence(time.Now())
+		return
+	case PresenceUpdate:
+		m.updatePresence(notification)
+		return
+	case SyncRefused:
+		// Our server refused another instance
+		peer := decodeHandshake(notification)
+		m.reportIncompatible(&IncompatibleError{Peer: peer, Reason: peer.Reason})
+		return
+	case SyncHello, SyncWelcome, SyncStatusRequest, SyncStatusReply:
 		return
 	}
 
@@ -0,0 +1,156 @@
+package socket_sync
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func startManager(t *testing.T, socketPath string, changeLog ChangeLog) (*Manager, *recordingListener) {
+	t.Helper()
+
+	listener := &recordingListener{}
+	manager, err := NewManagerAt(socketPath, listener)
+	if err != nil {
+		t.Fatalf("NewManagerAt() error: %v", err)
+	}
+	manager.SetChangeLog(changeLog)
+	if err := manager.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	t.Cleanup(func() { manager.Stop() })
+	return manager, listener
+}
+
+func readyClients(m *Manager) int {
+	server, _ := m.role()
+	if server == nil {
+		return 0
+	}
+	server.clientsMutex.RLock()
+	defer server.clientsMutex.RUnlock()
+	count := 0
+	for _, p := range server.clients {
+		if p.ready {
+			count++
+		}
+	}
+	return count
+}
+
+func receivedIDs(l *recordingListener) []int64 {
+	var ids []int64
+	for _, notification := range l.received() {
+		ids = append(ids, notification.ID)
+	}
+	return ids
+}
+
+func TestManagerFailover(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "todo.sock")
+	changeLog := &memoryChangeLog{}
+
+	leader, _ := startManager(t, socketPath, changeLog)
+	second, secondListener := startManager(t, socketPath, changeLog)
+	third, thirdListener := startManager(t, socketPath, changeLog)
+	if !leader.IsPrimary() || second.IsPrimary() || third.IsPrimary() {
+		t.Fatal("expected the first manager to be the only primary")
+	}
+	waitFor(t, "both secondaries to connect", func() bool { return readyClients(leader) == 2 })
+
+	if err := leader.Stop(); err != nil {
+		t.Fatalf("Stop() error: %v", err)
+	}
+
+	// Changes made while there is no primary are delivered once there is one again
+	second.NotifyChange(todoChange(TodoUpdated, 2))
+	third.NotifyChange(todoChange(TodoUpdated, 3))
+
+	waitFor(t, "a secondary to take over", func() bool { return second.IsPrimary() || third.IsPrimary() })
+	primary, primaryListener, secondary, secondaryListener := second, secondListener, third, thirdListener
+	if third.IsPrimary() {
+		primary, primaryListener, secondary, secondaryListener = third, thirdListener, second, secondListener
+	}
+	if secondary.IsPrimary() {
+		t.Fatal("both secondaries became primary")
+	}
+	waitFor(t, "the other secondary to reconnect", func() bool { return readyClients(primary) == 1 })
+
+	waitFor(t, "the new primary to get both changes", func() bool {
+		ids := receivedIDs(primaryListener)
+		return slices.Contains(ids, 2) && slices.Contains(ids, 3)
+	})
+	waitFor(t, "the secondary to get the change of the new primary", func() bool {
+		ids := receivedIDs(secondaryListener)
+		return slices.Contains(ids, 2) || slices.Contains(ids, 3)
+	})
+
+	// The new primary broadcasts like the old one did
+	if err := primary.NotifyChange(todoChange(TodoCreated, 4)); err != nil {
+		t.Fatalf("NotifyChange() error: %v", err)
+	}
+	waitFor(t, "the secondary to get a new change", func() bool {
+		return slices.Contains(receivedIDs(secondaryListener), 4)
+	})
+	if err := secondary.NotifyChange(todoChange(TodoCreated, 5)); err != nil {
+		t.Fatalf("NotifyChange() error: %v", err)
+	}
+	waitFor(t, "the primary to get a new change", func() bool {
+		return slices.Contains(receivedIDs(primaryListener), 5)
+	})
+
+	// And hands over again when it goes away
+	if err := primary.Stop(); err != nil {
+		t.Fatalf("Stop() error: %v", err)
+	}
+	waitFor(t, "the last instance to take over", secondary.IsPrimary)
+}
+
+func TestManagerReplacesSocketOfCrashedPrimary(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "todo.sock")
+
+	// A primary that crashed leaves its socket file behind but not its lease
+	listener, err := net.Listen(DefaultProtocol, socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	if _, err := os.Stat(socketPath); err != nil {
+		t.Fatalf("expected a stale socket file: %v", err)
+	}
+
+	manager, _ := startManager(t, socketPath, nil)
+	if !manager.IsPrimary() {
+		t.Error("expected the manager to take over the stale socket")
+	}
+}
+
+func TestManagerPromoteCallback(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "todo.sock")
+
+	leader, _ := startManager(t, socketPath, nil)
+
+	promoted := make(chan struct{})
+	secondary, err := NewManagerAt(socketPath, &recordingListener{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondary.OnPromote(func() { close(promoted) })
+	if err := secondary.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { secondary.Stop() })
+
+	leader.Stop()
+	waitFor(t, "the secondary to be promoted", func() bool {
+		select {
+		case <-promoted:
+			return true
+		default:
+			return false
+		}
+	})
+}
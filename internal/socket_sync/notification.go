@@ -1,6 +1,13 @@
 package socket_sync
 
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"time"
+)
 
 type NotificationType string
 
@@ -17,14 +24,197 @@ const (
 	TodoDueDateCleared  NotificationType = "TODO_DUE_DATE_CLEARED"
 	TodoPriorityChanged NotificationType = "TODO_PRIORITY_CHANGED"
 
+	TagCreated NotificationType = "TAG_CREATED"
+	TagUpdated NotificationType = "TAG_UPDATED"
+	TagDeleted NotificationType = "TAG_DELETED"
+
 	Heartbeat NotificationType = "HEARTBEAT"
+	// PresenceUpdate carries the Presence of an instance, see presence.go
+	PresenceUpdate NotificationType = "PRESENCE"
+
+	// SyncHello is the first message of a client, Seq is the last change it has seen. The
+	// primary answers with SyncWelcome or SyncRefused, all three carry a Handshake.
+	SyncHello   NotificationType = "SYNC_HELLO"
+	SyncWelcome NotificationType = "SYNC_WELCOME"
+	SyncRefused NotificationType = "SYNC_REFUSED"
+	// SyncReload asks a client to reload everything, the changes it missed are no longer logged
+	SyncReload NotificationType = "SYNC_RELOAD"
+	// SyncStatusRequest asks the primary for a SyncStatus, which it sends as SyncStatusReply
+	SyncStatusRequest NotificationType = "SYNC_STATUS_REQUEST"
+	SyncStatusReply   NotificationType = "SYNC_STATUS"
 )
 
-// Notification represents a change to be broadcast to other instances
+// ProtocolVersion is the version of the notification envelope. Peers from before the
+// envelope send version 0 and only announce that something changed.
+const ProtocolVersion = 1
+
+// Entity names the kind of record a notification carries
+type Entity string
+
+const (
+	EntityTodo Entity = "todo"
+	EntityTag  Entity = "tag"
+)
+
+var ErrNoPayload = errors.New("notification carries no snapshot or diff")
+
+// Notification represents a change to be broadcast to other instances. Version 1, the first
+// versioned envelope, adds the changed entity: a full snapshot, or a diff of the changed
+// fields when the snapshot is too large for a single message. Version 0 peers send only
+// Type, ID and Timestamp.
 type Notification struct {
 	Type      NotificationType `json:"type"`
 	ID        int64            `json:"id"`
 	Timestamp time.Time        `json:"timestamp"`
+
+	Version  int                        `json:"version,omitempty"`
+	Entity   Entity                     `json:"entity,omitempty"`
+	Snapshot json.RawMessage            `json:"snapshot,omitempty"`
+	Diff     map[string]json.RawMessage `json:"diff,omitempty"`
+
+	// UID identifies the entity across machines, IDs are local to one database
+	UID string `json:"uid,omitempty"`
+	// Replicated marks changes received from a relay, they are not sent back to it
+	Replicated bool `json:"replicated,omitempty"`
+
+	// Seq is the position of the change in the primary's change log. Hellos and heartbeats
+	// from clients carry the last position they have seen.
+	Seq int64 `json:"seq,omitempty"`
+	// PID identifies the process of a client in hellos, for `todo sync status`
+	PID int `json:"pid,omitempty"`
+}
+
+// NewNotification builds a versioned notification carrying after as the snapshot. When before
+// is given, the fields that differ from after are added as a diff for when the snapshot
+// doesn't fit in a message.
+func NewNotification(nt NotificationType, entity Entity, id int64, before, after any) Notification {
+	notification := Notification{
+		Type:      nt,
+		ID:        id,
+		Timestamp: time.Now(),
+		Version:   ProtocolVersion,
+		Entity:    entity,
+	}
+
+	if isNil(after) {
+		return notification
+	}
+	snapshot, err := json.Marshal(after)
+	if err != nil {
+		return notification
+	}
+	notification.Snapshot = snapshot
+
+	if !isNil(before) {
+		notification.Diff = diffFields(before, snapshot)
+	}
+
+	return notification
+}
+
+// HasPayload reports whether the notification carries enough to patch the entity in place
+func (n Notification) HasPayload() bool {
+	return n.Snapshot != nil || n.Diff != nil
+}
+
+// Decode writes the changed entity into v, which must be a pointer to the receiver's copy of
+// the entity. A snapshot replaces the copy, a diff is applied on top of it.
+func (n Notification) Decode(v any) error {
+	data := []byte(n.Snapshot)
+	if data == nil {
+		if n.Diff == nil {
+			return ErrNoPayload
+		}
+
+		current, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(current, &fields); err != nil {
+			return err
+		}
+		maps.Copy(fields, n.Diff)
+		if data, err = json.Marshal(fields); err != nil {
+			return err
+		}
+	}
+
+	// Decode into a zeroed value so pointers shared with the old copy are never written through
+	reflect.ValueOf(v).Elem().SetZero()
+	return json.Unmarshal(data, v)
+}
+
+// EntityKey identifies the changed entity, changes with the same key supersede each other
+func (n Notification) EntityKey() string {
+	if n.UID == "" {
+		return fmt.Sprintf("%s#%d", n.Entity, n.ID)
+	}
+	return string(n.Entity) + ":" + n.UID
+}
+
+// encode marshals the notification for the wire. Payloads that would exceed MaxMessageSize
+// are dropped, first the snapshot and then the diff, so receivers fall back to a reload.
+func (n Notification) encode() ([]byte, error) {
+	// Receivers prefer the snapshot, so there is no need to send both
+	var candidates []Notification
+	if n.Snapshot != nil {
+		withSnapshot := n
+		withSnapshot.Diff = nil
+		candidates = append(candidates, withSnapshot)
+	}
+	if n.Diff != nil {
+		withDiff := n
+		withDiff.Snapshot = nil
+		candidates = append(candidates, withDiff)
+	}
+	bare := n
+	bare.Snapshot, bare.Diff = nil, nil
+	candidates = append(candidates, bare)
+
+	var data []byte
+	var err error
+	for _, candidate := range candidates {
+		data, err = json.Marshal(candidate)
+		if err != nil {
+			return nil, err
+		}
+		// Leave room for the newline that frames the message
+		if len(data) < MaxMessageSize {
+			return data, nil
+		}
+	}
+
+	return data, nil
+}
+
+// diffFields returns the top level JSON fields of after that differ from before
+func diffFields(before any, after json.RawMessage) map[string]json.RawMessage {
+	previous, err := json.Marshal(before)
+	if err != nil {
+		return nil
+	}
+
+	var old, current map[string]json.RawMessage
+	if json.Unmarshal(previous, &old) != nil || json.Unmarshal(after, &current) != nil {
+		return nil
+	}
+
+	diff := map[string]json.RawMessage{}
+	for field, value := range current {
+		if string(old[field]) != string(value) {
+			diff[field] = value
+		}
+	}
+	return diff
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	value := reflect.ValueOf(v)
+	return value.Kind() == reflect.Pointer && value.IsNil()
 }
 
 // NotificationListener receives notifications from other app instances
@@ -0,0 +1,112 @@
+package socket_sync
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+)
+
+type testTodo struct {
+	ID          int64
+	Title       string
+	Description string
+	Tags        []string
+}
+
+func roundTrip(t *testing.T, notification Notification) Notification {
+	t.Helper()
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- WriteMessage(client, notification)
+	}()
+
+	received, err := ReadMessage(server)
+	if err != nil {
+		t.Fatalf("ReadMessage() error: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("WriteMessage() error: %v", err)
+	}
+	return received
+}
+
+func TestNotificationSnapshotRoundTrip(t *testing.T) {
+	before := &testTodo{ID: 7, Title: "Old", Tags: []string{"work"}}
+	after := &testTodo{ID: 7, Title: "New", Tags: []string{"work"}}
+
+	received := roundTrip(t, NewNotification(TodoUpdated, EntityTodo, 7, before, after))
+
+	if received.Version != ProtocolVersion || received.Entity != EntityTodo {
+		t.Errorf("envelope = version %d entity %q, want %d %q", received.Version, received.Entity, ProtocolVersion, EntityTodo)
+	}
+	if received.Diff != nil {
+		t.Errorf("diff should not be sent next to a snapshot, got %v", received.Diff)
+	}
+
+	var decoded testTodo
+	if err := received.Decode(&decoded); err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+	if decoded.Title != "New" || decoded.Tags[0] != "work" {
+		t.Errorf("decoded = %+v", decoded)
+	}
+}
+
+func TestNotificationFitsMaxMessageSize(t *testing.T) {
+	large := strings.Repeat("x", MaxMessageSize)
+
+	t.Run("Falls back to diff", func(t *testing.T) {
+		before := &testTodo{ID: 1, Title: "Old", Description: large}
+		after := &testTodo{ID: 1, Title: "New", Description: large}
+
+		received := roundTrip(t, NewNotification(TodoUpdated, EntityTodo, 1, before, after))
+		if received.Snapshot != nil {
+			t.Fatalf("oversized snapshot was sent")
+		}
+		if len(received.Diff) != 1 || string(received.Diff["Title"]) != `"New"` {
+			t.Fatalf("diff = %v, want only the title", received.Diff)
+		}
+
+		// The diff is applied on top of the receiver's copy
+		current := testTodo{ID: 1, Title: "Old", Description: large}
+		if err := received.Decode(&current); err != nil {
+			t.Fatalf("Decode() error: %v", err)
+		}
+		if current.Title != "New" || current.Description != large {
+			t.Errorf("patched = %q with %d byte description", current.Title, len(current.Description))
+		}
+	})
+
+	t.Run("Falls back to a bare notification", func(t *testing.T) {
+		after := &testTodo{ID: 2, Description: large}
+
+		received := roundTrip(t, NewNotification(TodoCreated, EntityTodo, 2, nil, after))
+		if received.HasPayload() {
+			t.Errorf("expected the payload to be dropped")
+		}
+		if received.Type != TodoCreated || received.ID != 2 || received.Version != ProtocolVersion {
+			t.Errorf("bare notification = %+v", received)
+		}
+	})
+}
+
+func TestNotificationFromOlderPeer(t *testing.T) {
+	var received Notification
+	legacy := `{"type":"TODO_UPDATED","id":3,"timestamp":"2025-01-01T10:00:00Z"}`
+	if err := json.Unmarshal([]byte(legacy), &received); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+
+	if received.Version != 0 || received.HasPayload() {
+		t.Errorf("legacy notification = %+v, want version 0 without payload", received)
+	}
+	if err := received.Decode(&testTodo{}); err != ErrNoPayload {
+		t.Errorf("Decode() error = %v, want ErrNoPayload", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package socket_sync
+
+import "net"
+
+// peerUID isn't implemented here, the 0600 permissions of the socket keep other users out
+func peerUID(conn net.Conn) (int, error) {
+	return 0, errPeerCredUnsupported
+}
@@ -0,0 +1,160 @@
+package socket_sync
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	osoperations "github.com/martijnspitter/tui-todo/internal/os-operations"
+)
+
+// Instances announce themselves and the todo they have open for editing. The primary
+// relays the announcements, sends every instance that connects the current ones and tells
+// the others when an instance disconnects. Announcements are repeated with every heartbeat,
+// one that isn't repeated expires, and with it the advisory lock it holds.
+
+// PresenceTTL is how long an announcement lasts without being repeated
+const PresenceTTL = 3 * HeartbeatInterval
+
+// Presence is what an instance announces about itself
+type Presence struct {
+	// Instance tells instances apart, also when they run in one process
+	Instance string `json:"instance"`
+	PID      int    `json:"pid"`
+	TTY      string `json:"tty,omitempty"`
+	// TodoID is the todo open for editing, 0 when none is
+	TodoID int64 `json:"todo_id,omitempty"`
+	// Locked claims TodoID, the other instances warn before saving it
+	Locked bool `json:"locked,omitempty"`
+	// Gone is sent by the primary for an instance that disconnected
+	Gone bool `json:"gone,omitempty"`
+}
+
+// presenceEntry is the announcement of another instance and when it was last repeated
+type presenceEntry struct {
+	presence Presence
+	seen     time.Time
+}
+
+func newPresenceNotification(presence Presence) Notification {
+	notification := Notification{Type: PresenceUpdate, Timestamp: time.Now(), PID: presence.PID}
+	notification.Snapshot, _ = json.Marshal(presence)
+	return notification
+}
+
+func decodePresence(notification Notification) (Presence, bool) {
+	var presence Presence
+	if err := json.Unmarshal(notification.Snapshot, &presence); err != nil || presence.Instance == "" {
+		return Presence{}, false
+	}
+	return presence, true
+}
+
+// SetPresence announces the todo this instance has open for editing, 0 for none. locked
+// claims it, which the other instances respect until the announcement expires.
+func (m *Manager) SetPresence(todoID int64, locked bool) {
+	m.presenceMutex.Lock()
+	m.presence = Presence{
+		Instance: m.instanceID,
+		PID:      os.Getpid(),
+		TTY:      osoperations.TTYName(),
+		TodoID:   todoID,
+		Locked:   locked && todoID != 0,
+	}
+	notification := newPresenceNotification(m.presence)
+	m.presenceMutex.Unlock()
+
+	if server, client := m.role(); server != nil {
+		server.SetPresence(notification)
+	} else if client != nil {
+		client.SetPresence(notification)
+	}
+}
+
+// Peers returns the announcements of the other instances, ordered by PID
+func (m *Manager) Peers() []Presence {
+	m.presenceMutex.Lock()
+	defer m.presenceMutex.Unlock()
+
+	peers := make([]Presence, 0, len(m.peers))
+	for _, entry := range m.peers {
+		if time.Since(entry.seen) < PresenceTTL {
+			peers = append(peers, entry.presence)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		if peers[i].PID == peers[j].PID {
+			return peers[i].Instance < peers[j].Instance
+		}
+		return peers[i].PID < peers[j].PID
+	})
+	return peers
+}
+
+// OnPresence sets a function that is called when another instance comes, goes or opens
+// another todo
+func (m *Manager) OnPresence(fn func()) {
+	m.presenceMutex.Lock()
+	defer m.presenceMutex.Unlock()
+	m.onPresence = fn
+}
+
+// updatePresence records the announcement of another instance
+func (m *Manager) updatePresence(notification Notification) {
+	presence, ok := decodePresence(notification)
+	if !ok || presence.Instance == m.instanceID {
+		return
+	}
+
+	m.presenceMutex.Lock()
+	if m.peers == nil {
+		m.peers = make(map[string]presenceEntry)
+	}
+	previous, known := m.peers[presence.Instance]
+	changed := known
+	if presence.Gone {
+		delete(m.peers, presence.Instance)
+	} else {
+		m.peers[presence.Instance] = presenceEntry{presence: presence, seen: time.Now()}
+		changed = !known || previous.presence != presence
+	}
+	fn := m.onPresence
+	m.presenceMutex.Unlock()
+
+	if changed && fn != nil {
+		fn()
+	}
+}
+
+// prunePresence forgets announcements that weren't repeated in time
+func (m *Manager) prunePresence(now time.Time) {
+	m.presenceMutex.Lock()
+	changed := false
+	for instance, entry := range m.peers {
+		if now.Sub(entry.seen) >= PresenceTTL {
+			delete(m.peers, instance)
+			changed = true
+		}
+	}
+	fn := m.onPresence
+	m.presenceMutex.Unlock()
+
+	if changed && fn != nil {
+		fn()
+	}
+}
+
+// clearPresence forgets every other instance, e.g. after taking over as primary, the
+// instances that are still there announce themselves again when they reconnect
+func (m *Manager) clearPresence() {
+	m.presenceMutex.Lock()
+	changed := len(m.peers) > 0
+	m.peers = nil
+	fn := m.onPresence
+	m.presenceMutex.Unlock()
+
+	if changed && fn != nil {
+		fn()
+	}
+}
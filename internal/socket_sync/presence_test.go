@@ -0,0 +1,86 @@
+package socket_sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func peerTodos(m *Manager) map[string]int64 {
+	todos := make(map[string]int64)
+	for _, peer := range m.Peers() {
+		todos[peer.Instance] = peer.TodoID
+	}
+	return todos
+}
+
+func TestPresenceReachesEveryInstance(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "todo.sock")
+
+	primary, _ := startManager(t, socketPath, nil)
+	primary.SetPresence(0, false)
+	second, _ := startManager(t, socketPath, nil)
+	waitFor(t, "the second instance to connect", func() bool { return readyClients(primary) == 1 })
+
+	// The primary's presence is sent to an instance when it connects
+	waitFor(t, "the second instance to see the primary", func() bool {
+		_, ok := peerTodos(second)[primary.instanceID]
+		return ok
+	})
+
+	second.SetPresence(42, true)
+	third, _ := startManager(t, socketPath, nil)
+	third.SetPresence(7, false)
+
+	waitFor(t, "the primary to see both secondaries", func() bool {
+		todos := peerTodos(primary)
+		return todos[second.instanceID] == 42 && todos[third.instanceID] == 7
+	})
+	waitFor(t, "the third instance to see the second", func() bool {
+		return peerTodos(third)[second.instanceID] == 42
+	})
+	waitFor(t, "the second instance to see the third", func() bool {
+		return peerTodos(second)[third.instanceID] == 7
+	})
+	for _, peer := range third.Peers() {
+		if peer.Instance == second.instanceID && (!peer.Locked || peer.PID != os.Getpid()) {
+			t.Errorf("presence = %+v, want a lock held by this process", peer)
+		}
+	}
+
+	// Closing an instance releases its lock right away
+	second.Stop()
+	waitFor(t, "the others to forget the second instance", func() bool {
+		_, onPrimary := peerTodos(primary)[second.instanceID]
+		_, onThird := peerTodos(third)[second.instanceID]
+		return !onPrimary && !onThird
+	})
+}
+
+func TestPresenceExpires(t *testing.T) {
+	manager, err := NewManagerAt(filepath.Join(t.TempDir(), "todo.sock"), &recordingListener{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := 0
+	manager.OnPresence(func() { changes++ })
+
+	manager.updatePresence(newPresenceNotification(Presence{Instance: "other", PID: 1, TodoID: 3, Locked: true}))
+	manager.updatePresence(newPresenceNotification(Presence{Instance: manager.instanceID, PID: 2}))
+	if peers := manager.Peers(); len(peers) != 1 || peers[0].TodoID != 3 {
+		t.Fatalf("Peers() = %+v, want only the other instance", peers)
+	}
+
+	manager.prunePresence(time.Now().Add(PresenceTTL / 2))
+	if len(manager.Peers()) != 1 {
+		t.Error("presence expired before its TTL")
+	}
+	manager.prunePresence(time.Now().Add(PresenceTTL))
+	if len(manager.Peers()) != 0 {
+		t.Error("presence outlived its TTL")
+	}
+	if changes != 2 {
+		t.Errorf("OnPresence called %d times, want 2", changes)
+	}
+}
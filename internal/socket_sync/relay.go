@@ -0,0 +1,601 @@
+package socket_sync
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	DefaultRelayAddress = ":7420"
+	// MaxRelayFrameSize bounds a frame between relay and clients. Replicated changes always
+	// carry the full entity, so the limit is far above MaxMessageSize.
+	MaxRelayFrameSize = 1 << 20
+	HandshakeTimeout  = 10 * time.Second
+	// relayQueueSize bounds the frames waiting for a client. One that falls further behind
+	// is disconnected and catches up from the log when it reconnects.
+	relayQueueSize = 256
+)
+
+// Frame kinds of the relay protocol. A session starts with a challenge from the relay,
+// answered by a hello that proves the client knows the shared secret. The welcome proves
+// the same for the relay, after which both sides exchange changes.
+const (
+	frameChallenge = "challenge"
+	frameHello     = "hello"
+	frameWelcome   = "welcome"
+	frameReject    = "reject"
+	frameChange    = "change"
+	frameAck       = "ack"
+)
+
+// relayFrame is a single newline terminated JSON message on a relay connection
+type relayFrame struct {
+	Kind   string        `json:"kind"`
+	Nonce  string        `json:"nonce,omitempty"`
+	Node   string        `json:"node,omitempty"`
+	MAC    string        `json:"mac,omitempty"`
+	Since  int64         `json:"since,omitempty"`
+	Seq    int64         `json:"seq,omitempty"`
+	Ref    int64         `json:"ref,omitempty"`
+	Error  string        `json:"error,omitempty"`
+	Change *Notification `json:"change,omitempty"`
+}
+
+// relayEntry is a change in the relay's log. Seq orders all changes, Ref is the number the
+// originating client gave it so a change resent after a reconnect is only stored once.
+type relayEntry struct {
+	Seq    int64        `json:"seq"`
+	Origin string       `json:"origin"`
+	Ref    int64        `json:"ref"`
+	Change Notification `json:"change"`
+}
+
+// RelayConfig configures `todo serve`
+type RelayConfig struct {
+	Listen string
+	Secret string
+	// TLS is used for incoming connections when set
+	TLS *tls.Config
+	// LogPath keeps changes for clients that were offline, empty keeps them in memory only
+	LogPath string
+}
+
+// Relay passes changes between the app instances of several machines and keeps them
+// for clients that reconnect later
+type Relay struct {
+	cfg      RelayConfig
+	listener net.Listener
+	closing  atomic.Bool
+	stop     chan struct{}
+	wg       sync.WaitGroup
+
+	// mu guards the log and serializes the queueing of changes so every client sees them in
+	// order. Nothing is written to a connection while it is held.
+	mu      sync.Mutex
+	entries []relayEntry
+	lastSeq int64
+	lastRef map[string]int64
+	peers   map[*relayPeer]bool
+	logFile *os.File
+}
+
+type relayPeer struct {
+	node string
+	conn net.Conn
+	// frames waits for the writer of the client, so a slow client holds up nobody else
+	frames chan relayFrame
+	done   chan struct{}
+}
+
+// queue hands a frame to the writer of the client without waiting for it
+func (p *relayPeer) queue(frame relayFrame) bool {
+	select {
+	case p.frames <- frame:
+		return true
+	default:
+		return false
+	}
+}
+
+// write writes the changes the client missed and then the queued frames, until the
+// connection fails or the client is unregistered
+func (p *relayPeer) write(backlog []relayFrame) {
+	for _, frame := range backlog {
+		if err := writeFrame(p.conn, frame); err != nil {
+			log.Warn("Relay catch-up failed", "node", p.node, "error", err)
+			p.conn.Close()
+			return
+		}
+	}
+	for {
+		select {
+		case frame := <-p.frames:
+			if err := writeFrame(p.conn, frame); err != nil {
+				// The client catches up from the log when it reconnects
+				log.Warn("Relay failed to write to client", "node", p.node, "error", err)
+				p.conn.Close()
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// NewRelay creates a relay and loads its change log
+func NewRelay(cfg RelayConfig) (*Relay, error) {
+	if cfg.Secret == "" {
+		return nil, errors.New("a shared secret is required")
+	}
+	if cfg.Listen == "" {
+		cfg.Listen = DefaultRelayAddress
+	}
+
+	relay := &Relay{
+		cfg:     cfg,
+		stop:    make(chan struct{}),
+		lastRef: make(map[string]int64),
+		peers:   make(map[*relayPeer]bool),
+	}
+
+	if cfg.LogPath != "" {
+		if err := relay.loadLog(); err != nil {
+			return nil, fmt.Errorf("failed to load relay log: %w", err)
+		}
+	}
+
+	return relay, nil
+}
+
+// Start listens for clients
+func (r *Relay) Start() error {
+	listener, err := net.Listen("tcp", r.cfg.Listen)
+	if err != nil {
+		return &SocketError{Op: "listen", Err: err}
+	}
+	if r.cfg.TLS != nil {
+		listener = tls.NewListener(listener, r.cfg.TLS)
+	}
+	r.listener = listener
+
+	r.wg.Add(2)
+	go r.acceptLoop()
+	go r.compactLoop()
+
+	log.Info("Relay listening", "address", listener.Addr(), "tls", r.cfg.TLS != nil, "changes", len(r.entries))
+	return nil
+}
+
+// Addr returns the address the relay listens on
+func (r *Relay) Addr() net.Addr {
+	return r.listener.Addr()
+}
+
+// Stop disconnects all clients and closes the log
+func (r *Relay) Stop() error {
+	if r.closing.Swap(true) {
+		return nil
+	}
+
+	close(r.stop)
+	var err error
+	if r.listener != nil {
+		err = r.listener.Close()
+	}
+
+	r.mu.Lock()
+	for peer := range r.peers {
+		peer.conn.Close()
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+
+	if r.logFile != nil {
+		if closeErr := r.logFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (r *Relay) acceptLoop() {
+	defer r.wg.Done()
+
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			if r.closing.Load() {
+				return
+			}
+			log.Error("Relay accept failed", "error", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		r.wg.Add(1)
+		go r.serve(conn)
+	}
+}
+
+// serve runs a client session: handshake, catch-up and then live changes
+func (r *Relay) serve(conn net.Conn) {
+	defer r.wg.Done()
+	defer conn.Close()
+
+	reader := newFrameReader(conn)
+	node, since, err := r.handshake(conn, reader)
+	if err != nil {
+		log.Warn("Relay handshake failed", "remote", conn.RemoteAddr(), "error", err)
+		return
+	}
+
+	peer := &relayPeer{node: node, conn: conn, frames: make(chan relayFrame, relayQueueSize), done: make(chan struct{})}
+	backlog := r.register(peer, since)
+	defer r.unregister(peer)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		peer.write(backlog)
+	}()
+	log.Info("Relay client connected", "node", node, "remote", conn.RemoteAddr(), "since", since)
+
+	for {
+		frame, err := reader.read()
+		if err != nil {
+			if !IsSocketClosed(err) {
+				log.Warn("Relay read failed", "node", node, "error", err)
+			}
+			return
+		}
+
+		if frame.Kind != frameChange || frame.Change == nil {
+			continue
+		}
+		if err := r.publish(peer, frame.Ref, *frame.Change); err != nil {
+			log.Error("Relay failed to store change", "node", node, "error", err)
+			return
+		}
+	}
+}
+
+// handshake authenticates the client and returns its node ID and replay position
+func (r *Relay) handshake(conn net.Conn, reader *frameReader) (string, int64, error) {
+	_ = conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	nonce, err := randomHex(16)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := writeFrame(conn, relayFrame{Kind: frameChallenge, Nonce: nonce}); err != nil {
+		return "", 0, err
+	}
+
+	hello, err := reader.read()
+	if err != nil {
+		return "", 0, err
+	}
+	if hello.Kind != frameHello || hello.Node == "" || !verifyMAC(r.cfg.Secret, nonce, hello.Node, hello.MAC) {
+		_ = writeFrame(conn, relayFrame{Kind: frameReject, Error: "authentication failed"})
+		return "", 0, errors.New("authentication failed")
+	}
+
+	r.mu.Lock()
+	lastSeq := r.lastSeq
+	r.mu.Unlock()
+
+	since := hello.Since
+	if since > lastSeq {
+		// The relay lost its log, send everything it has
+		since = 0
+	}
+
+	welcome := relayFrame{Kind: frameWelcome, Seq: lastSeq, MAC: sign(r.cfg.Secret, hello.Nonce, "relay")}
+	if err := writeFrame(conn, welcome); err != nil {
+		return "", 0, err
+	}
+
+	return hello.Node, since, nil
+}
+
+// register adds the client to the live broadcast and returns the changes it missed, which
+// its writer sends before the queued ones
+func (r *Relay) register(peer *relayPeer, since int64) []relayFrame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var backlog []relayFrame
+	for _, entry := range r.entries {
+		if entry.Seq <= since || entry.Origin == peer.node {
+			continue
+		}
+		change := entry.Change
+		backlog = append(backlog, relayFrame{Kind: frameChange, Seq: entry.Seq, Change: &change})
+	}
+
+	r.peers[peer] = true
+	return backlog
+}
+
+func (r *Relay) unregister(peer *relayPeer) {
+	r.mu.Lock()
+	delete(r.peers, peer)
+	r.mu.Unlock()
+	close(peer.done)
+	log.Info("Relay client disconnected", "node", peer.node)
+}
+
+// publish stores a change and queues it for the other clients
+func (r *Relay) publish(sender *relayPeer, ref int64, change Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if ref <= r.lastRef[sender.node] {
+		// Resent after a reconnect, the client only missed the ack
+		r.queue(sender, relayFrame{Kind: frameAck, Ref: ref})
+		return nil
+	}
+
+	entry := relayEntry{Seq: r.lastSeq + 1, Origin: sender.node, Ref: ref, Change: change}
+	if err := r.appendLog(entry); err != nil {
+		return err
+	}
+	r.entries = append(r.entries, entry)
+	r.lastSeq = entry.Seq
+	r.lastRef[sender.node] = ref
+
+	for peer := range r.peers {
+		if peer != sender {
+			r.queue(peer, relayFrame{Kind: frameChange, Seq: entry.Seq, Change: &change})
+		}
+	}
+	r.queue(sender, relayFrame{Kind: frameAck, Ref: ref, Seq: entry.Seq})
+	return nil
+}
+
+// queue hands a frame to the writer of a client, a client that fell too far behind is
+// disconnected
+func (r *Relay) queue(peer *relayPeer, frame relayFrame) {
+	if !peer.queue(frame) {
+		log.Warn("Relay client fell behind, disconnecting", "node", peer.node)
+		peer.conn.Close()
+	}
+}
+
+// ===========================================================================
+// Change log
+// ===========================================================================
+
+// loadLog reads the change log and compacts it to the latest change of every entity
+func (r *Relay) loadLog() error {
+	if err := os.MkdirAll(filepath.Dir(r.cfg.LogPath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Open(r.cfg.LogPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var entries []relayEntry
+	if err == nil {
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), MaxRelayFrameSize)
+		for scanner.Scan() {
+			var entry relayEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				// A line cut short by a crash, everything before it is intact
+				log.Warn("Skipping damaged relay log entry", "error", err)
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	r.entries = compactLog(entries)
+	for _, entry := range entries {
+		r.lastSeq = max(r.lastSeq, entry.Seq)
+		r.lastRef[entry.Origin] = max(r.lastRef[entry.Origin], entry.Ref)
+	}
+
+	return r.rewriteLog()
+}
+
+// compactLoop compacts the change log now and then, so a relay that runs for months doesn't
+// keep every change it ever passed on
+func (r *Relay) compactLoop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(CompactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+		if err := r.compact(); err != nil {
+			log.Warn("Failed to compact relay log", "error", err)
+		}
+	}
+}
+
+// compact drops the changes that were superseded, in memory and in the log
+func (r *Relay) compact() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = compactLog(r.entries)
+	if r.logFile == nil {
+		return nil
+	}
+	old := r.logFile
+	if err := r.rewriteLog(); err != nil {
+		// Changes are still appended to the old log
+		r.logFile = old
+		return err
+	}
+	return old.Close()
+}
+
+// compactLog keeps the latest change of every entity, and the latest change of every client
+// so resent changes are still recognized
+func compactLog(entries []relayEntry) []relayEntry {
+	latest := make(map[string]int64)
+	newestRef := make(map[string]int64)
+	for _, entry := range entries {
+		latest[entry.Change.EntityKey()] = entry.Seq
+		if entry.Ref >= newestRef[entry.Origin] {
+			newestRef[entry.Origin] = entry.Ref
+		}
+	}
+
+	compacted := entries[:0:0]
+	for _, entry := range entries {
+		if latest[entry.Change.EntityKey()] == entry.Seq || newestRef[entry.Origin] == entry.Ref {
+			compacted = append(compacted, entry)
+		}
+	}
+	return compacted
+}
+
+func (r *Relay) rewriteLog() error {
+	tmp := r.cfg.LogPath + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range r.entries {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.cfg.LogPath); err != nil {
+		return err
+	}
+
+	r.logFile, err = os.OpenFile(r.cfg.LogPath, os.O_APPEND|os.O_WRONLY, 0600)
+	return err
+}
+
+func (r *Relay) appendLog(entry relayEntry) error {
+	if r.logFile == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := r.logFile.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return r.logFile.Sync()
+}
+
+// ===========================================================================
+// Framing and authentication
+// ===========================================================================
+
+type frameReader struct {
+	scanner *bufio.Scanner
+}
+
+func newFrameReader(conn net.Conn) *frameReader {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), MaxRelayFrameSize)
+	return &frameReader{scanner: scanner}
+}
+
+func (f *frameReader) read() (relayFrame, error) {
+	var frame relayFrame
+	if !f.scanner.Scan() {
+		err := f.scanner.Err()
+		if err == nil {
+			err = io.EOF
+		}
+		return frame, &SocketError{Op: "read", Err: err}
+	}
+	if err := json.Unmarshal(f.scanner.Bytes(), &frame); err != nil {
+		return frame, &SocketError{Op: "decode", Err: err}
+	}
+	return frame, nil
+}
+
+func writeFrame(conn net.Conn, frame relayFrame) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return &SocketError{Op: "encode", Err: err}
+	}
+	if len(data) >= MaxRelayFrameSize {
+		return &SocketError{Op: "size_check", Err: errors.New("frame exceeds maximum allowed size")}
+	}
+
+	if err := conn.SetWriteDeadline(time.Now().Add(WriteTimeout)); err != nil {
+		return &SocketError{Op: "set_deadline", Err: err}
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return &SocketError{Op: "write", Err: err}
+	}
+	return nil
+}
+
+// sign proves knowledge of the shared secret for a nonce chosen by the other side
+func sign(secret, nonce, node string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(nonce + "\n" + node))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifyMAC(secret, nonce, node, signature string) bool {
+	expected, err := hex.DecodeString(sign(secret, nonce, node))
+	if err != nil {
+		return false
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, actual)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
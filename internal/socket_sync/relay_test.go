@@ -0,0 +1,253 @@
+package socket_sync
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSecret = "correct horse battery staple"
+
+type recordingListener struct {
+	mu      sync.Mutex
+	changes []Notification
+}
+
+func (l *recordingListener) OnRemoteChange(notification Notification) {
+	l.mu.Lock()
+	l.changes = append(l.changes, notification)
+	l.mu.Unlock()
+}
+
+func (l *recordingListener) received() []Notification {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Notification(nil), l.changes...)
+}
+
+func startRelay(t *testing.T, listen, logPath string) *Relay {
+	t.Helper()
+
+	relay, err := NewRelay(RelayConfig{Listen: listen, Secret: testSecret, LogPath: logPath})
+	if err != nil {
+		t.Fatalf("NewRelay() error: %v", err)
+	}
+	if err := relay.Start(); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	t.Cleanup(func() { relay.Stop() })
+	return relay
+}
+
+func startRemote(t *testing.T, address, secret, statePath string) (*RemoteClient, *recordingListener) {
+	t.Helper()
+
+	listener := &recordingListener{}
+	client, err := NewRemoteClient(RemoteConfig{Address: address, Secret: secret, StatePath: statePath}, listener)
+	if err != nil {
+		t.Fatalf("NewRemoteClient() error: %v", err)
+	}
+	client.Start()
+	t.Cleanup(func() { client.Stop() })
+	return client, listener
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRelayPassesChangesBetweenClients(t *testing.T) {
+	dir := t.TempDir()
+	relay := startRelay(t, "127.0.0.1:0", filepath.Join(dir, "relay.log"))
+	address := relay.Addr().String()
+
+	laptop, laptopChanges := startRemote(t, address, testSecret, filepath.Join(dir, "laptop.json"))
+	desktop, desktopChanges := startRemote(t, address, testSecret, filepath.Join(dir, "desktop.json"))
+	waitFor(t, "both clients to connect", func() bool { return laptop.Connected() && desktop.Connected() })
+
+	change := NewNotification(TodoCreated, EntityTodo, 1, nil, &testTodo{ID: 1, Title: "Buy milk"})
+	change.UID = "todo-1"
+	if err := laptop.Publish(change); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+
+	waitFor(t, "the change to reach the desktop", func() bool { return len(desktopChanges.received()) == 1 })
+	waitFor(t, "the relay to acknowledge", func() bool { return laptop.Pending() == 0 })
+
+	received := desktopChanges.received()[0]
+	var todo testTodo
+	if err := received.Decode(&todo); err != nil || todo.Title != "Buy milk" || received.UID != "todo-1" {
+		t.Errorf("received %+v (%v), want the published todo", received, err)
+	}
+	if got := laptopChanges.received(); len(got) != 0 {
+		t.Errorf("sender received its own change back: %+v", got)
+	}
+}
+
+func TestRemoteQueuesChangesWhileOffline(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "relay.log")
+
+	relay := startRelay(t, "127.0.0.1:0", logPath)
+	address := relay.Addr().String()
+	if err := relay.Stop(); err != nil {
+		t.Fatalf("Stop() error: %v", err)
+	}
+
+	laptop, _ := startRemote(t, address, testSecret, filepath.Join(dir, "laptop.json"))
+	change := NewNotification(TodoUpdated, EntityTodo, 1, nil, &testTodo{ID: 1, Title: "Written offline"})
+	change.UID = "todo-1"
+	if err := laptop.Publish(change); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	if laptop.Pending() != 1 {
+		t.Fatalf("Pending() = %d, want the change queued", laptop.Pending())
+	}
+
+	// The desktop connects once the relay is back and catches up from its log
+	startRelay(t, address, logPath)
+	waitFor(t, "the queued change to be sent", func() bool { return laptop.Pending() == 0 })
+
+	desktop, desktopChanges := startRemote(t, address, testSecret, filepath.Join(dir, "desktop.json"))
+	waitFor(t, "the desktop to catch up", func() bool { return len(desktopChanges.received()) == 1 })
+	if !desktop.Fresh() {
+		t.Errorf("a client without state should be fresh")
+	}
+}
+
+func TestRelayRejectsWrongSecret(t *testing.T) {
+	dir := t.TempDir()
+	relay := startRelay(t, "127.0.0.1:0", "")
+	address := relay.Addr().String()
+
+	intruder, intruderChanges := startRemote(t, address, "guessed", filepath.Join(dir, "intruder.json"))
+	laptop, _ := startRemote(t, address, testSecret, filepath.Join(dir, "laptop.json"))
+	waitFor(t, "the laptop to connect", laptop.Connected)
+
+	change := NewNotification(TodoCreated, EntityTodo, 1, nil, &testTodo{ID: 1, Title: "Private"})
+	change.UID = "todo-1"
+	if err := laptop.Publish(change); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	waitFor(t, "the relay to acknowledge", func() bool { return laptop.Pending() == 0 })
+
+	time.Sleep(100 * time.Millisecond)
+	if intruder.Connected() || len(intruderChanges.received()) != 0 {
+		t.Errorf("client with the wrong secret was let in")
+	}
+}
+
+func TestCompactLogKeepsLatestChange(t *testing.T) {
+	entries := []relayEntry{
+		{Seq: 1, Origin: "a", Ref: 1, Change: Notification{Type: TodoCreated, Entity: EntityTodo, UID: "x"}},
+		{Seq: 2, Origin: "a", Ref: 2, Change: Notification{Type: TodoUpdated, Entity: EntityTodo, UID: "x"}},
+		{Seq: 3, Origin: "b", Ref: 1, Change: Notification{Type: TagCreated, Entity: EntityTag, UID: "x"}},
+	}
+
+	compacted := compactLog(entries)
+	if len(compacted) != 2 || compacted[0].Seq != 2 || compacted[1].Seq != 3 {
+		t.Errorf("compactLog() = %+v, want seq 2 and 3", compacted)
+	}
+}
+
+func TestRelayStalledClientDoesNotBlockOthers(t *testing.T) {
+	relay, err := NewRelay(RelayConfig{Secret: testSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing reads from the other end of the pipe, so the first write blocks
+	conn, _ := net.Pipe()
+	stalled := &relayPeer{node: "stalled", conn: conn, frames: make(chan relayFrame, relayQueueSize), done: make(chan struct{})}
+	backlog := relay.register(stalled, 0)
+	stopped := make(chan struct{})
+	go func() {
+		stalled.write(backlog)
+		close(stopped)
+	}()
+
+	sender := &relayPeer{node: "sender", frames: make(chan relayFrame, 2*relayQueueSize), done: make(chan struct{})}
+	relay.register(sender, 0)
+
+	published := make(chan error)
+	go func() {
+		for ref := int64(1); ref <= relayQueueSize+10; ref++ {
+			change := Notification{Type: TodoUpdated, Entity: EntityTodo, UID: "x"}
+			if err := relay.publish(sender, ref, change); err != nil {
+				published <- err
+				return
+			}
+		}
+		published <- nil
+	}()
+
+	select {
+	case err := <-published:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("publishing waited for the stalled client")
+	}
+	if len(sender.frames) != relayQueueSize+10 {
+		t.Errorf("sender has %d acks, want %d", len(sender.frames), relayQueueSize+10)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stalled client wasn't disconnected")
+	}
+}
+
+func TestRelayCompactsItsLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "relay.log")
+	relay, err := NewRelay(RelayConfig{Secret: testSecret, LogPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Stop()
+
+	conn, _ := net.Pipe()
+	sender := &relayPeer{node: "sender", conn: conn, frames: make(chan relayFrame, 10), done: make(chan struct{})}
+	relay.register(sender, 0)
+	changes := []Notification{
+		{Type: TodoCreated, Entity: EntityTodo, UID: "x"},
+		{Type: TodoUpdated, Entity: EntityTodo, UID: "x"},
+		{Type: TodoCreated, Entity: EntityTodo, UID: "y"},
+	}
+	for i, change := range changes {
+		if err := relay.publish(sender, int64(i+1), change); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := relay.compact(); err != nil {
+		t.Fatalf("compact() error: %v", err)
+	}
+	if len(relay.entries) != 2 {
+		t.Errorf("%d changes after compacting, want 2", len(relay.entries))
+	}
+	// The log is still appended to after it was rewritten
+	if err := relay.publish(sender, 4, Notification{Type: TodoDeleted, Entity: EntityTodo, UID: "y"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 3 {
+		t.Errorf("relay log holds %d changes, want 3", lines)
+	}
+}
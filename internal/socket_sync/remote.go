@@ -0,0 +1,403 @@
+package socket_sync
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	MinRelayReconnectDelay = 500 * time.Millisecond
+	MaxRelayReconnectDelay = 30 * time.Second
+)
+
+// RemoteListener receives changes made on other machines
+type RemoteListener interface {
+	OnRemoteChange(notification Notification)
+}
+
+// RemoteConfig configures the connection to a relay started with `todo serve`
+type RemoteConfig struct {
+	Address string
+	Secret  string
+	// TLS is used for the connection when set
+	TLS *tls.Config
+	// StatePath keeps the node ID, the replay position and changes that weren't sent yet
+	StatePath string
+}
+
+// remoteState is persisted so changes made offline survive a restart
+type remoteState struct {
+	Node    string        `json:"node"`
+	Cursor  int64         `json:"cursor"`
+	NextRef int64         `json:"next_ref"`
+	Outbox  []outboxEntry `json:"outbox"`
+}
+
+type outboxEntry struct {
+	Ref    int64        `json:"ref"`
+	Change Notification `json:"change"`
+}
+
+// RemoteClient replicates changes with other machines through a relay. Changes are queued
+// while the relay can't be reached and sent once the connection is back.
+type RemoteClient struct {
+	cfg      RemoteConfig
+	listener RemoteListener
+
+	stateMutex sync.Mutex
+	state      remoteState
+	fresh      bool
+
+	// sendMutex keeps queued and new changes in order while the outbox is flushed
+	sendMutex sync.Mutex
+	conn      net.Conn
+	ready     bool
+
+	connected atomic.Bool
+	stop      chan struct{}
+	stopOnce  sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewRemoteClient creates a client and loads its state
+func NewRemoteClient(cfg RemoteConfig, listener RemoteListener) (*RemoteClient, error) {
+	if _, _, err := net.SplitHostPort(cfg.Address); err != nil {
+		return nil, fmt.Errorf("invalid relay address %q: %w", cfg.Address, err)
+	}
+	if cfg.Secret == "" {
+		return nil, errors.New("a shared secret is required")
+	}
+
+	client := &RemoteClient{
+		cfg:      cfg,
+		listener: listener,
+		stop:     make(chan struct{}),
+	}
+	if err := client.loadState(); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Start connects to the relay in the background and keeps reconnecting
+func (c *RemoteClient) Start() {
+	c.wg.Add(1)
+	go c.run()
+}
+
+// Stop disconnects from the relay, queued changes are kept for the next start
+func (c *RemoteClient) Stop() error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+
+	c.sendMutex.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.sendMutex.Unlock()
+
+	c.wg.Wait()
+
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	return c.saveState()
+}
+
+// Fresh reports whether this database never synced before, so its existing todos have to
+// be published once
+func (c *RemoteClient) Fresh() bool {
+	return c.fresh
+}
+
+// NodeID identifies this database to the relay
+func (c *RemoteClient) NodeID() string {
+	return c.state.Node
+}
+
+// Connected reports whether the relay is currently reachable
+func (c *RemoteClient) Connected() bool {
+	return c.connected.Load()
+}
+
+// Pending returns the number of changes waiting for the relay
+func (c *RemoteClient) Pending() int {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	return len(c.state.Outbox)
+}
+
+// Publish queues a change for the relay and sends it right away when connected
+func (c *RemoteClient) Publish(change Notification) error {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	c.stateMutex.Lock()
+	c.state.NextRef++
+	entry := outboxEntry{Ref: c.state.NextRef, Change: change}
+	c.state.Outbox = append(c.state.Outbox, entry)
+	err := c.saveState()
+	c.stateMutex.Unlock()
+
+	if c.ready {
+		if sendErr := c.send(entry); sendErr != nil {
+			// The session notices the broken connection and the change is resent later
+			log.Warn("Failed to send change to relay", "error", sendErr)
+			c.conn.Close()
+		}
+	}
+
+	return err
+}
+
+// ===========================================================================
+// Session
+// ===========================================================================
+func (c *RemoteClient) run() {
+	defer c.wg.Done()
+
+	delay := MinRelayReconnectDelay
+	for {
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+
+		conn, err := c.dial()
+		if err == nil {
+			delay = MinRelayReconnectDelay
+			c.session(conn)
+		} else {
+			log.Debug("Relay not reachable", "address", c.cfg.Address, "error", err)
+		}
+
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, MaxRelayReconnectDelay)
+	}
+}
+
+func (c *RemoteClient) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: HandshakeTimeout, KeepAlive: 15 * time.Second}
+	if c.cfg.TLS != nil {
+		return tls.DialWithDialer(dialer, "tcp", c.cfg.Address, c.cfg.TLS)
+	}
+	return dialer.Dial("tcp", c.cfg.Address)
+}
+
+// session authenticates, sends the queued changes and then handles frames until the
+// connection drops
+func (c *RemoteClient) session(conn net.Conn) {
+	defer conn.Close()
+
+	reader := newFrameReader(conn)
+	if err := c.handshake(conn, reader); err != nil {
+		log.Warn("Relay handshake failed", "address", c.cfg.Address, "error", err)
+		return
+	}
+
+	if err := c.flush(conn); err != nil {
+		log.Warn("Failed to send queued changes to relay", "error", err)
+		return
+	}
+	c.connected.Store(true)
+	log.Info("Connected to relay", "address", c.cfg.Address, "node", c.state.Node)
+
+	defer func() {
+		c.sendMutex.Lock()
+		c.conn, c.ready = nil, false
+		c.sendMutex.Unlock()
+		c.connected.Store(false)
+		log.Info("Disconnected from relay", "address", c.cfg.Address)
+	}()
+
+	for {
+		frame, err := reader.read()
+		if err != nil {
+			return
+		}
+
+		switch frame.Kind {
+		case frameChange:
+			if frame.Change != nil {
+				c.listener.OnRemoteChange(*frame.Change)
+			}
+			c.advance(frame.Seq)
+		case frameAck:
+			c.acknowledge(frame.Ref, frame.Seq)
+		}
+	}
+}
+
+func (c *RemoteClient) handshake(conn net.Conn, reader *frameReader) error {
+	_ = conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	challenge, err := reader.read()
+	if err != nil {
+		return err
+	}
+	if challenge.Kind != frameChallenge || challenge.Nonce == "" {
+		return fmt.Errorf("unexpected %q frame", challenge.Kind)
+	}
+
+	nonce, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+
+	c.stateMutex.Lock()
+	hello := relayFrame{
+		Kind:  frameHello,
+		Node:  c.state.Node,
+		Nonce: nonce,
+		Since: c.state.Cursor,
+		MAC:   sign(c.cfg.Secret, challenge.Nonce, c.state.Node),
+	}
+	c.stateMutex.Unlock()
+	if err := writeFrame(conn, hello); err != nil {
+		return err
+	}
+
+	welcome, err := reader.read()
+	if err != nil {
+		return err
+	}
+	if welcome.Kind == frameReject {
+		return fmt.Errorf("rejected by relay: %s", welcome.Error)
+	}
+	if welcome.Kind != frameWelcome || !verifyMAC(c.cfg.Secret, nonce, "relay", welcome.MAC) {
+		return errors.New("relay could not prove it knows the shared secret")
+	}
+
+	c.stateMutex.Lock()
+	if c.state.Cursor > welcome.Seq {
+		// The relay lost its log and replays everything it has
+		c.state.Cursor = 0
+	}
+	c.stateMutex.Unlock()
+
+	return nil
+}
+
+// flush sends the outbox, new changes wait until it is done so the order is kept
+func (c *RemoteClient) flush(conn net.Conn) error {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	c.conn = conn
+	c.stateMutex.Lock()
+	outbox := append([]outboxEntry(nil), c.state.Outbox...)
+	c.stateMutex.Unlock()
+
+	for _, entry := range outbox {
+		if err := c.send(entry); err != nil {
+			c.conn = nil
+			return err
+		}
+	}
+	if len(outbox) > 0 {
+		log.Info("Sent changes made while offline", "count", len(outbox))
+	}
+
+	c.ready = true
+	return nil
+}
+
+func (c *RemoteClient) send(entry outboxEntry) error {
+	change := entry.Change
+	return writeFrame(c.conn, relayFrame{Kind: frameChange, Ref: entry.Ref, Change: &change})
+}
+
+// advance records that all changes up to seq have been applied
+func (c *RemoteClient) advance(seq int64) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	if seq <= c.state.Cursor {
+		return
+	}
+	c.state.Cursor = seq
+	if err := c.saveState(); err != nil {
+		log.Warn("Failed to save sync state", "error", err)
+	}
+}
+
+// acknowledge removes a change the relay stored from the outbox
+func (c *RemoteClient) acknowledge(ref, seq int64) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	outbox := c.state.Outbox[:0]
+	for _, entry := range c.state.Outbox {
+		if entry.Ref > ref {
+			outbox = append(outbox, entry)
+		}
+	}
+	c.state.Outbox = outbox
+	c.state.Cursor = max(c.state.Cursor, seq)
+
+	if err := c.saveState(); err != nil {
+		log.Warn("Failed to save sync state", "error", err)
+	}
+}
+
+// ===========================================================================
+// State
+// ===========================================================================
+func (c *RemoteClient) loadState() error {
+	data, err := os.ReadFile(c.cfg.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		node, err := randomHex(8)
+		if err != nil {
+			return err
+		}
+		c.state = remoteState{Node: node}
+		c.fresh = true
+		return c.saveState()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &c.state); err != nil {
+		return fmt.Errorf("failed to read sync state %s: %w", c.cfg.StatePath, err)
+	}
+	return nil
+}
+
+// saveState writes the state atomically, the caller holds stateMutex
+func (c *RemoteClient) saveState() error {
+	if c.cfg.StatePath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(c.state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.cfg.StatePath), 0755); err != nil {
+		return err
+	}
+
+	tmp := c.cfg.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.cfg.StatePath)
+}
@@ -0,0 +1,201 @@
+package socket_sync
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Tools such as editor plugins and status bars talk JSON-RPC 2.0 to the primary, on the same
+// socket and with the same newline framing as the notifications between instances. A message
+// with a "jsonrpc" member is a request, anything else a notification.
+
+const (
+	JSONRPCVersion = "2.0"
+	// MaxRPCMessageSize bounds requests and responses, a list of todos is far larger than
+	// the notifications between instances
+	MaxRPCMessageSize = 1 << 20
+)
+
+// Error codes, the first five are defined by JSON-RPC 2.0
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	RPCNotFound       = -32001
+	RPCConflict       = -32002
+)
+
+// Methods handled by the server itself, the others are passed to the RPCHandler
+const (
+	RPCSubscribe   = "subscribe"
+	RPCUnsubscribe = "unsubscribe"
+	// RPCChangeMethod is the notification subscribers receive for every change
+	RPCChangeMethod = "change"
+)
+
+// RPCRequest is a call from a tool. Requests without an ID are notifications and get no answer.
+type RPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// RPCResponse answers a request with either a result or an error
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is a typed error, Message is a stable key such as "error.todo_not_found"
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// RPCChange tells subscribers what changed, they ask for the details with `get`
+type RPCChange struct {
+	Type   NotificationType `json:"type"`
+	Entity Entity           `json:"entity,omitempty"`
+	ID     int64            `json:"id"`
+	UID    string           `json:"uid,omitempty"`
+	Seq    int64            `json:"seq,omitempty"`
+}
+
+// rpcNotification is a message to a subscriber that expects no answer
+type rpcNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// RPCHandler answers the requests of tools. Errors that are not an *RPCError are reported
+// as internal errors.
+type RPCHandler interface {
+	HandleRPC(method string, params json.RawMessage) (any, error)
+}
+
+// isRPC reports whether a message is a JSON-RPC request rather than a notification
+func isRPC(line []byte) bool {
+	var probe struct {
+		JSONRPC *string `json:"jsonrpc"`
+	}
+	return json.Unmarshal(line, &probe) == nil && probe.JSONRPC != nil
+}
+
+// handleRPC answers a request from a tool
+func (s *Server) handleRPC(clientID string, conn net.Conn, line []byte) {
+	s.clientsMutex.Lock()
+	if p, ok := s.clients[clientID]; ok {
+		p.rpc = true
+	}
+	s.clientsMutex.Unlock()
+
+	response := RPCResponse{JSONRPC: JSONRPCVersion, ID: json.RawMessage("null")}
+
+	var request RPCRequest
+	if err := json.Unmarshal(line, &request); err != nil {
+		response.Error = &RPCError{Code: RPCParseError, Message: err.Error()}
+	} else {
+		response.Result, response.Error = s.callRPC(clientID, request)
+		if request.ID == nil {
+			return
+		}
+		response.ID = request.ID
+	}
+
+	// Responses share the connection with the changes sent to subscribers
+	s.broadcastLock.Lock()
+	defer s.broadcastLock.Unlock()
+	if err := writeRPC(conn, response); err != nil {
+		log.Error("Failed to answer request", "id", clientID, "method", request.Method, "error", err)
+	}
+}
+
+func (s *Server) callRPC(clientID string, request RPCRequest) (any, *RPCError) {
+	if request.JSONRPC != JSONRPCVersion || request.Method == "" {
+		return nil, &RPCError{Code: RPCInvalidRequest, Message: "not a JSON-RPC 2.0 request"}
+	}
+
+	switch request.Method {
+	case RPCSubscribe, RPCUnsubscribe:
+		subscribed := request.Method == RPCSubscribe
+
+		// No change can slip in between the answer and the first change sent
+		s.broadcastLock.Lock()
+		defer s.broadcastLock.Unlock()
+		s.clientsMutex.Lock()
+		if p, ok := s.clients[clientID]; ok {
+			p.subscribed = subscribed
+		}
+		s.clientsMutex.Unlock()
+
+		return map[string]any{"subscribed": subscribed, "seq": s.head}, nil
+	}
+
+	if s.rpcHandler == nil {
+		return nil, &RPCError{Code: RPCMethodNotFound, Message: "method not found: " + request.Method}
+	}
+
+	result, err := s.rpcHandler.HandleRPC(request.Method, request.Params)
+	if err != nil {
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) {
+			return nil, rpcErr
+		}
+		return nil, &RPCError{Code: RPCInternalError, Message: err.Error()}
+	}
+
+	return result, nil
+}
+
+// sendChange forwards a change to a subscribed tool. The caller holds broadcastLock.
+func sendChange(conn net.Conn, notification Notification) error {
+	return writeRPC(conn, rpcNotification{
+		JSONRPC: JSONRPCVersion,
+		Method:  RPCChangeMethod,
+		Params: RPCChange{
+			Type:   notification.Type,
+			Entity: notification.Entity,
+			ID:     notification.ID,
+			UID:    notification.UID,
+			Seq:    notification.Seq,
+		},
+	})
+}
+
+// writeRPC writes a JSON-RPC message, which may be larger than a notification
+func writeRPC(conn net.Conn, message any) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(WriteTimeout)); err != nil {
+		return &SocketError{Op: "set_deadline", Err: err}
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return &SocketError{Op: "encode", Err: err}
+	}
+	data = append(data, '\n')
+
+	if len(data) > MaxRPCMessageSize {
+		return &SocketError{Op: "size_check", Err: errors.New("message exceeds maximum allowed size")}
+	}
+
+	if _, err := conn.Write(data); err != nil {
+		return &SocketError{Op: "write", Err: err}
+	}
+
+	return nil
+}
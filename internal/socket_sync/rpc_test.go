@@ -0,0 +1,170 @@
+package socket_sync
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type echoHandler struct{}
+
+func (echoHandler) HandleRPC(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "echo":
+		return params, nil
+	case "missing":
+		return nil, &RPCError{Code: RPCNotFound, Message: "error.todo_not_found"}
+	case "broken":
+		return nil, errors.New("error.update_failed")
+	}
+	return nil, &RPCError{Code: RPCMethodNotFound, Message: "method not found: " + method}
+}
+
+type rpcConn struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialRPC(t *testing.T, socketPath string) *rpcConn {
+	t.Helper()
+
+	conn, err := net.Dial(DefaultProtocol, socketPath)
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &rpcConn{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (c *rpcConn) send(line string) {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(line + "\n")); err != nil {
+		c.t.Fatalf("Write() error: %v", err)
+	}
+}
+
+func (c *rpcConn) receive() map[string]json.RawMessage {
+	c.t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := c.reader.ReadBytes('\n')
+	if err != nil {
+		c.t.Fatalf("ReadBytes() error: %v", err)
+	}
+	message := map[string]json.RawMessage{}
+	if err := json.Unmarshal(line, &message); err != nil {
+		c.t.Fatalf("Unmarshal(%s) error: %v", line, err)
+	}
+	return message
+}
+
+func startRPCServer(t *testing.T) (*Server, string) {
+	t.Helper()
+
+	server, socketPath := startServer(t, &memoryChangeLog{})
+	server.rpcHandler = echoHandler{}
+	return server, socketPath
+}
+
+func TestRPCRequests(t *testing.T) {
+	_, socketPath := startRPCServer(t)
+	conn := dialRPC(t, socketPath)
+
+	tests := []struct {
+		name    string
+		request string
+		want    map[string]string
+	}{
+		{
+			name:    "Result echoes the request ID",
+			request: `{"jsonrpc":"2.0","id":"a1","method":"echo","params":{"x":1}}`,
+			want:    map[string]string{"id": `"a1"`, "result": `{"x":1}`},
+		},
+		{
+			name:    "Typed errors keep their code",
+			request: `{"jsonrpc":"2.0","id":2,"method":"missing"}`,
+			want:    map[string]string{"id": "2", "error": `{"code":-32001,"message":"error.todo_not_found"}`},
+		},
+		{
+			name:    "Other errors are internal errors",
+			request: `{"jsonrpc":"2.0","id":3,"method":"broken"}`,
+			want:    map[string]string{"id": "3", "error": `{"code":-32603,"message":"error.update_failed"}`},
+		},
+		{
+			name:    "Unknown methods",
+			request: `{"jsonrpc":"2.0","id":4,"method":"nope"}`,
+			want:    map[string]string{"id": "4", "error": `{"code":-32601,"message":"method not found: nope"}`},
+		},
+		{
+			name:    "Wrong version",
+			request: `{"jsonrpc":"1.0","id":5,"method":"echo"}`,
+			want:    map[string]string{"id": "5", "error": `{"code":-32600,"message":"not a JSON-RPC 2.0 request"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn.send(tt.request)
+			got := conn.receive()
+			for field, want := range tt.want {
+				if string(got[field]) != want {
+					t.Errorf("%s = %s, want %s", field, got[field], want)
+				}
+			}
+		})
+	}
+
+	t.Run("Requests without an ID get no answer", func(t *testing.T) {
+		conn.send(`{"jsonrpc":"2.0","method":"echo"}`)
+		conn.send(`{"jsonrpc":"2.0","id":6,"method":"echo","params":[]}`)
+		if got := conn.receive(); string(got["id"]) != "6" {
+			t.Errorf("id = %s, want the answer to the second request", got["id"])
+		}
+	})
+}
+
+func TestRPCSubscribe(t *testing.T) {
+	server, socketPath := startRPCServer(t)
+
+	// An instance and a tool share the socket
+	_, instanceChanges := startClient(t, socketPath, 0)
+	waitForHello(t, server)
+	tool := dialRPC(t, socketPath)
+
+	tool.send(`{"jsonrpc":"2.0","id":1,"method":"subscribe"}`)
+	if got := tool.receive(); string(got["result"]) != `{"seq":0,"subscribed":true}` {
+		t.Fatalf("subscribe = %s", got["result"])
+	}
+
+	change := todoChange(TodoUpdated, 1)
+	if err := server.Broadcast(change); err != nil {
+		t.Fatalf("Broadcast() error: %v", err)
+	}
+
+	got := tool.receive()
+	if string(got["method"]) != `"change"` || string(got["id"]) != "" {
+		t.Fatalf("got %v, want a change notification", got)
+	}
+	var params RPCChange
+	if err := json.Unmarshal(got["params"], &params); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if params.Type != TodoUpdated || params.ID != 1 || params.Seq != 1 || !strings.HasPrefix(params.UID, "todo-") {
+		t.Errorf("params = %+v", params)
+	}
+
+	// The notification traffic between instances is unchanged
+	waitFor(t, "the instance to get the change", func() bool { return len(instanceChanges.received()) == 1 })
+	if received := instanceChanges.received()[0]; received.Type != TodoUpdated || received.Snapshot == nil {
+		t.Errorf("instance received %+v, want the full notification", received)
+	}
+
+	tool.send(`{"jsonrpc":"2.0","id":2,"method":"unsubscribe"}`)
+	if got := tool.receive(); string(got["result"]) != `{"seq":1,"subscribed":false}` {
+		t.Fatalf("unsubscribe = %s", got["result"])
+	}
+}
@@ -1,26 +1,75 @@
 package socket_sync
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
+
+	"github.com/martijnspitter/tui-todo/internal/version"
+)
+
+// HeartbeatInterval is how often the primary checks on the other instances
+const HeartbeatInterval = 10 * time.Second
+
+const (
+	// MaxBadFrames is how many malformed or oversized messages a client may send per
+	// BadFrameWindow, a client sending more is disconnected
+	MaxBadFrames   = 5
+	BadFrameWindow = time.Minute
 )
 
 // Server handles the socket server functionality for the primary app instance
 type Server struct {
 	socketPath      string
 	listener        net.Listener
-	clients         map[string]net.Conn
+	clients         map[string]*peer
 	clientsMutex    sync.RWMutex
 	shutdown        chan struct{}
 	shutdownWg      sync.WaitGroup
 	notifListener   NotificationListener
 	broadcastLock   sync.Mutex
 	heartbeatTicker *time.Ticker
+	startedAt       time.Time
+
+	// changeLog is optional, without it reconnecting clients miss what happened meanwhile.
+	// head is the Seq of the latest logged change, both are guarded by broadcastLock.
+	changeLog ChangeLog
+	head      int64
+
+	// rpcHandler answers the JSON-RPC requests of tools, see rpc.go
+	rpcHandler RPCHandler
+
+	// own is the presence of this instance, guarded by broadcastLock
+	own *Notification
+}
+
+// peer is a connected client
+type peer struct {
+	conn net.Conn
+	pid  int
+	// seq is the latest change the client confirmed to have seen
+	seq int64
+	// ready is set once the changes the client missed were replayed, broadcasts wait for it
+	ready bool
+	// rpc is set for tools speaking JSON-RPC, they only get changes after subscribing
+	rpc        bool
+	subscribed bool
+	// presence is the latest announcement of the client
+	presence *Notification
+	// handshake is what the client said about itself in its hello
+	handshake   Handshake
+	connectedAt time.Time
+	lastSeen    time.Time
+	// badFrames counts the malformed messages since badSince
+	badFrames int
+	badSince  time.Time
 }
 
 // NewServer creates a new socket server
@@ -43,7 +92,7 @@ func NewServer(socketPath string, listener NotificationListener) (*Server, error
 	// Create the server
 	server := &Server{
 		socketPath:    socketPath,
-		clients:       make(map[string]net.Conn),
+		clients:       make(map[string]*peer),
 		shutdown:      make(chan struct{}),
 		notifListener: listener,
 	}
@@ -60,16 +109,29 @@ func NewServer(socketPath string, listener NotificationListener) (*Server, error
 
 // Start begins accepting client connections
 func (s *Server) Start() error {
-	// Set permission on socket file to ensure all users can connect
-	if err := os.Chmod(s.socketPath, 0666); err != nil {
+	// Only our own user may connect, the peer credentials are checked as well
+	if err := os.Chmod(s.socketPath, 0600); err != nil {
 		return fmt.Errorf("failed to set socket permissions: %w", err)
 	}
 
+	s.startedAt = time.Now()
+	if s.changeLog != nil {
+		head, err := s.changeLog.Head()
+		if err != nil {
+			log.Warn("Failed to read change log, changes won't be replayed", "error", err)
+			s.changeLog = nil
+		} else {
+			s.head = head
+			s.shutdownWg.Add(1)
+			go s.compactLoop()
+		}
+	}
+
 	// Start the accept loop
 	s.shutdownWg.Add(1)
 	go s.acceptLoop()
 
-	s.heartbeatTicker = time.NewTicker(10 * time.Second)
+	s.heartbeatTicker = time.NewTicker(HeartbeatInterval)
 	s.shutdownWg.Add(1)
 	go s.heartbeatLoop()
 
@@ -93,6 +155,14 @@ func (s *Server) heartbeatLoop() {
 }
 
 func (s *Server) sendHeartbeat() {
+	heartbeat := Notification{
+		Type:      Heartbeat,
+		Timestamp: time.Now(),
+		ID:        0,
+	}
+	// Our own listener lets announcements that weren't repeated expire
+	s.notifListener.OnNotification(heartbeat)
+
 	// Check if we have any clients first
 	s.clientsMutex.RLock()
 	clientCount := len(s.clients)
@@ -102,16 +172,17 @@ func (s *Server) sendHeartbeat() {
 		return // No clients to send heartbeats to
 	}
 
-	heartbeat := Notification{
-		Type:      Heartbeat,
-		Timestamp: time.Now(),
-		ID:        0,
-	}
-
-	// Send heartbeat to all clients
-	if err := s.broadcastToOthers(heartbeat, ""); err != nil {
+	// Send heartbeat to all clients, our presence is repeated with it
+	s.broadcastLock.Lock()
+	defer s.broadcastLock.Unlock()
+	if err := s.sendToOthers(heartbeat, ""); err != nil {
 		log.Error("Failed to send heartbeat", "error", err)
 	}
+	if s.own != nil {
+		if err := s.sendToOthers(*s.own, ""); err != nil {
+			log.Error("Failed to send presence", "error", err)
+		}
+	}
 }
 
 // acceptLoop accepts new client connections
@@ -151,6 +222,13 @@ func (s *Server) acceptLoop() {
 				continue
 			}
 
+			// The socket may live in a directory other users can reach
+			if err := checkPeer(conn); err != nil {
+				log.Warn("Refused connection from another user", "error", err)
+				conn.Close()
+				continue
+			}
+
 			// Handle the new client connection
 			clientID := fmt.Sprintf("client-%d", time.Now().UnixNano())
 			log.Info("New client connected", "id", clientID)
@@ -163,18 +241,47 @@ func (s *Server) acceptLoop() {
 	}
 }
 
+// compactLoop compacts the change log now and then
+func (s *Server) compactLoop() {
+	defer s.shutdownWg.Done()
+
+	ticker := time.NewTicker(CompactInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.changeLog.Compact(); err != nil {
+			log.Warn("Failed to compact change log", "error", err)
+		}
+
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 // addClient adds a client to the clients map
 func (s *Server) addClient(id string, conn net.Conn) {
+	// Until it says otherwise, the client knows everything logged before it connected
+	s.broadcastLock.Lock()
+	defer s.broadcastLock.Unlock()
 	s.clientsMutex.Lock()
 	defer s.clientsMutex.Unlock()
-	s.clients[id] = conn
+	now := time.Now()
+	s.clients[id] = &peer{conn: conn, seq: s.head, connectedAt: now, lastSeen: now}
 }
 
-// removeClient removes a client from the clients map
+// removeClient removes a client from the clients map and tells the others it is gone
 func (s *Server) removeClient(id string) {
 	s.clientsMutex.Lock()
-	defer s.clientsMutex.Unlock()
+	p, ok := s.clients[id]
 	delete(s.clients, id)
+	s.clientsMutex.Unlock()
+
+	if ok && p.presence != nil {
+		s.announceGone(id, *p.presence)
+	}
 }
 
 // handleClient processes messages from a connected client
@@ -185,19 +292,28 @@ func (s *Server) handleClient(clientID string, conn net.Conn) {
 		s.shutdownWg.Done()
 	}()
 
+	reader := newMessageReader(conn)
+	first, err := s.greet(clientID, reader)
+	if err != nil {
+		var incompatible *IncompatibleError
+		if !IsSocketClosed(err) && !errors.As(err, &incompatible) {
+			log.Error("Failed to greet client", "id", clientID, "error", err)
+		}
+		return
+	}
+	if first != nil {
+		if err := s.handleLine(clientID, conn, first); err != nil && !(isBadFrame(err) && s.badFrame(clientID, err)) {
+			return
+		}
+	}
+
 	for {
 		select {
 		case <-s.shutdown:
 			return
 		default:
-			// Set read deadline
-			if err := conn.SetReadDeadline(time.Now().Add(120 * time.Second)); err != nil {
-				log.Error("Failed to set read deadline", "id", clientID, "error", err)
-				return
-			}
-
 			// Read a message from the client
-			notification, err := ReadMessage(conn)
+			line, err := reader.readLine(120 * time.Second)
 			if err != nil {
 				// Handle timeouts gracefully
 				if IsTimeout(err) {
@@ -207,39 +323,291 @@ func (s *Server) handleClient(clientID string, conn net.Conn) {
 				if IsSocketClosed(err) {
 					return
 				}
+				if isBadFrame(err) && s.badFrame(clientID, err) {
+					continue
+				}
 
 				log.Error("Error reading from client", "id", clientID, "error", err)
 				return
 			}
 
-			if notification.Type == Heartbeat {
-				continue
+			if err := s.handleLine(clientID, conn, line); err != nil && !(isBadFrame(err) && s.badFrame(clientID, err)) {
+				return
 			}
+		}
+	}
+}
 
-			// Notify our listener first
-			s.notifListener.OnNotification(notification)
+// handleLine dispatches a message from a client, which is either a notification or a
+// JSON-RPC request from a tool
+func (s *Server) handleLine(clientID string, conn net.Conn, line []byte) error {
+	if isRPC(line) {
+		s.handleRPC(clientID, conn, line)
+		return nil
+	}
 
-			// Broadcast to all other clients
-			if err := s.broadcastToOthers(notification, clientID); err != nil {
-				// Remove the failing client so that subsequent broadcasts don’t keep erroring
-				log.Error("Broadcast to others failed", "sender", clientID, "error", err)
-			}
+	notification, err := decodeMessage(line)
+	if err != nil {
+		return err
+	}
+	s.handleMessage(clientID, conn, notification)
+	return nil
+}
+
+// greet checks the handshake of a client and replays the changes it missed while it was
+// disconnected, after which it receives broadcasts. Clients that don't say hello or speak a
+// protocol we don't are refused. The first message of a tool is a JSON-RPC request, which is
+// returned to be handled as usual. Clients that don't tell their position get what was
+// logged while they were greeted.
+func (s *Server) greet(clientID string, reader *messageReader) ([]byte, error) {
+	line, err := reader.readLine(HelloTimeout)
+	if err != nil && !IsTimeout(err) {
+		return nil, err
+	}
+
+	var first Notification
+	hello := false
+	rpc := err == nil && isRPC(line)
+	if err == nil && !rpc {
+		first, err = decodeMessage(line)
+		if err != nil {
+			return nil, err
+		}
+		hello = first.Type == SyncHello
+	}
+
+	var handshake Handshake
+	if hello {
+		handshake = decodeHandshake(first)
+	}
+	if reason := handshake.check(); !rpc && reason != "" {
+		return nil, s.refuse(clientID, reader.conn, first.PID, handshake, reason)
+	}
+
+	// Holding the lock until the client is ready means no broadcast slips in between
+	s.broadcastLock.Lock()
+	defer s.broadcastLock.Unlock()
+
+	s.clientsMutex.RLock()
+	p, ok := s.clients[clientID]
+	s.clientsMutex.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+
+	// A client without a change log of its own says hello without a position
+	since := p.seq
+	if hello && first.Seq >= 0 {
+		since = first.Seq
+	}
+	if !rpc {
+		if err := WriteMessage(p.conn, newHandshakeNotification(SyncWelcome, localHandshake())); err != nil {
+			return nil, err
+		}
+		s.replay(p, clientID, since)
+		s.sendPresence(p, clientID)
+	}
+
+	s.clientsMutex.Lock()
+	p.ready = true
+	p.rpc = rpc
+	p.seq = s.head
+	if hello {
+		p.pid = first.PID
+		p.handshake = handshake
+	}
+	s.clientsMutex.Unlock()
+
+	if hello {
+		return nil, nil
+	}
+	return line, nil
+}
+
+// refuse tells a client we can't talk to it and why. Our own listener hears about it too,
+// so the user learns that an instance runs on its own.
+func (s *Server) refuse(clientID string, conn net.Conn, pid int, peer Handshake, reason string) error {
+	log.Warn("Refused incompatible instance", "id", clientID, "pid", pid, "version", peer.App, "protocol", peer.Protocol, "reason", reason)
+
+	refusal := localHandshake()
+	refusal.Reason = reason
+	if err := WriteMessage(conn, newHandshakeNotification(SyncRefused, refusal)); err != nil {
+		log.Debug("Failed to tell client it was refused", "id", clientID, "error", err)
+	}
+
+	peer.Reason = reason
+	s.notifListener.OnNotification(newHandshakeNotification(SyncRefused, peer))
+	return &IncompatibleError{Peer: peer, Reason: reason}
+}
+
+// badFrame counts a malformed or oversized message of a client and reports whether the
+// client may stay. Only the first one in a BadFrameWindow is logged.
+func (s *Server) badFrame(clientID string, err error) bool {
+	s.clientsMutex.Lock()
+	p, ok := s.clients[clientID]
+	if !ok {
+		s.clientsMutex.Unlock()
+		return false
+	}
+	now := time.Now()
+	if now.Sub(p.badSince) > BadFrameWindow {
+		p.badFrames, p.badSince = 0, now
+	}
+	p.badFrames++
+	count := p.badFrames
+	s.clientsMutex.Unlock()
+
+	if count > MaxBadFrames {
+		log.Warn("Disconnecting client that sends malformed messages", "id", clientID, "messages", count, "error", err)
+		return false
+	}
+	if count == 1 {
+		log.Warn("Skipped malformed message", "id", clientID, "error", err)
+	}
+	return true
+}
+
+// replay sends the logged changes after since to a client. When the log can't be read the
+// client is told to reload instead. The caller holds broadcastLock.
+func (s *Server) replay(p *peer, clientID string, since int64) {
+	if s.changeLog == nil || since >= s.head {
+		return
+	}
+
+	missed, err := s.changeLog.Since(since)
+	if err != nil {
+		log.Error("Failed to read change log, client will reload", "id", clientID, "error", err)
+		missed = []Notification{{Type: SyncReload, Timestamp: time.Now(), Seq: s.head}}
+	}
+
+	for _, notification := range missed {
+		if err := WriteMessage(p.conn, notification); err != nil {
+			log.Error("Failed to replay change", "id", clientID, "seq", notification.Seq, "error", err)
+			return
 		}
 	}
+	log.Info("Replayed missed changes", "id", clientID, "since", since, "changes", len(missed))
+}
+
+// handleMessage processes a single message from a client
+func (s *Server) handleMessage(clientID string, conn net.Conn, notification Notification) {
+	switch notification.Type {
+	case Heartbeat, SyncHello:
+		// Clients answer heartbeats with the latest change they have seen
+		s.touch(clientID, notification.Seq)
+	case SyncStatusRequest:
+		if err := s.sendStatus(clientID, conn); err != nil {
+			log.Error("Failed to send sync status", "id", clientID, "error", err)
+		}
+	case PresenceUpdate:
+		s.updatePresence(clientID, notification)
+	default:
+		if err := s.publish(&notification, clientID); err != nil {
+			// Remove the failing client so that subsequent broadcasts don’t keep erroring
+			log.Error("Broadcast to others failed", "sender", clientID, "error", err)
+		}
+		// The sender knows its own change
+		s.touch(clientID, notification.Seq)
+	}
+}
+
+// touch records that a client is alive and has seen the changes up to seq
+func (s *Server) touch(clientID string, seq int64) {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if p, ok := s.clients[clientID]; ok {
+		p.lastSeen = time.Now()
+		p.seq = max(p.seq, seq)
+	}
+}
+
+// sendStatus answers a SyncStatusRequest with the position of every instance but the asker
+func (s *Server) sendStatus(requesterID string, conn net.Conn) error {
+	s.broadcastLock.Lock()
+	defer s.broadcastLock.Unlock()
+
+	status := SyncStatus{
+		Head: s.head,
+		Peers: []PeerStatus{{
+			PID:         os.Getpid(),
+			App:         version.GetVersion(),
+			Primary:     true,
+			Seq:         s.head,
+			ConnectedAt: s.startedAt,
+			LastSeen:    time.Now(),
+		}},
+	}
+
+	s.clientsMutex.RLock()
+	for id, p := range s.clients {
+		if id == requesterID || p.rpc {
+			continue
+		}
+		status.Peers = append(status.Peers, PeerStatus{
+			PID:         p.pid,
+			App:         p.handshake.App,
+			Seq:         p.seq,
+			Lag:         max(s.head-p.seq, 0),
+			ConnectedAt: p.connectedAt,
+			LastSeen:    p.lastSeen,
+		})
+	}
+	s.clientsMutex.RUnlock()
+
+	sort.Slice(status.Peers[1:], func(i, j int) bool {
+		return status.Peers[i+1].ConnectedAt.Before(status.Peers[j+1].ConnectedAt)
+	})
+
+	snapshot, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	return WriteMessage(conn, Notification{Type: SyncStatusReply, Timestamp: time.Now(), Snapshot: snapshot})
 }
 
 // Broadcast sends a notification to all connected clients
 func (s *Server) Broadcast(notification Notification) error {
-	// First notify our own listener
-	s.notifListener.OnNotification(notification)
+	return s.publish(&notification, "")
+}
+
+// publish logs a change and sends it to all clients except the sender, then hands it to our
+// own listener. Logging and sending under one lock keeps the log in the order clients see.
+func (s *Server) publish(notification *Notification, senderID string) error {
+	s.broadcastLock.Lock()
+	s.record(notification)
+	err := s.sendToOthers(*notification, senderID)
+	s.broadcastLock.Unlock()
 
-	return s.broadcastToOthers(notification, "")
+	s.notifListener.OnNotification(*notification)
+
+	return err
+}
+
+// record appends a change to the change log and sets its Seq. The caller holds broadcastLock.
+func (s *Server) record(notification *Notification) {
+	if s.changeLog == nil {
+		return
+	}
+
+	if err := s.changeLog.Append(notification); err != nil {
+		log.Error("Failed to log change, reconnecting clients will miss it", "error", err)
+		return
+	}
+	s.head = notification.Seq
 }
 
 // broadcastToOthers sends a notification to all clients except the sender
 func (s *Server) broadcastToOthers(notification Notification, senderID string) error {
 	s.broadcastLock.Lock()
 	defer s.broadcastLock.Unlock()
+	return s.sendToOthers(notification, senderID)
+}
+
+// sendToOthers writes a notification to every ready client but the sender. The caller holds
+// broadcastLock.
+func (s *Server) sendToOthers(notification Notification, senderID string) error {
 	s.clientsMutex.RLock()
 	defer s.clientsMutex.RUnlock()
 
@@ -248,12 +616,24 @@ func (s *Server) broadcastToOthers(notification Notification, senderID string) e
 	}
 
 	var lastErr error
-	for id, conn := range s.clients {
-		if id == senderID {
-			continue // Skip the sender
+	for id, p := range s.clients {
+		if id == senderID || !p.ready {
+			continue // Skip the sender and clients that are still catching up
 		}
 
-		if err := WriteMessage(conn, notification); err != nil {
+		var err error
+		if p.rpc {
+			// Tools only hear about changes they subscribed to
+			if !p.subscribed || notification.Type == Heartbeat || notification.Type == PresenceUpdate {
+				continue
+			}
+			err = sendChange(p.conn, notification)
+		} else if notification.Type == PresenceUpdate && !p.handshake.Has(CapabilityPresence) {
+			continue
+		} else {
+			err = WriteMessage(p.conn, notification)
+		}
+		if err != nil {
 			if lastErr == nil {
 				lastErr = fmt.Errorf("failed to send message to client %s: %w", id, err)
 			}
@@ -261,13 +641,80 @@ func (s *Server) broadcastToOthers(notification Notification, senderID string) e
 			go func(c net.Conn, clientID string) {
 				c.Close()
 				s.removeClient(clientID)
-			}(conn, id)
+			}(p.conn, id)
 		}
 	}
 
 	return lastErr
 }
 
+// SetPresence announces the presence of this instance to the clients
+func (s *Server) SetPresence(notification Notification) {
+	s.broadcastLock.Lock()
+	defer s.broadcastLock.Unlock()
+	s.own = &notification
+	if err := s.sendToOthers(notification, ""); err != nil {
+		log.Debug("Failed to send presence", "error", err)
+	}
+}
+
+// updatePresence records the announcement of a client and relays it to the others
+func (s *Server) updatePresence(clientID string, notification Notification) {
+	if _, ok := decodePresence(notification); !ok {
+		return
+	}
+
+	s.clientsMutex.Lock()
+	if p, ok := s.clients[clientID]; ok {
+		p.presence = &notification
+		p.lastSeen = time.Now()
+	}
+	s.clientsMutex.Unlock()
+
+	if err := s.broadcastToOthers(notification, clientID); err != nil {
+		log.Debug("Failed to relay presence", "error", err)
+	}
+	s.notifListener.OnNotification(notification)
+}
+
+// sendPresence tells a client that just connected about the others. The caller holds
+// broadcastLock.
+func (s *Server) sendPresence(p *peer, clientID string) {
+	announcements := []Notification{}
+	if s.own != nil {
+		announcements = append(announcements, *s.own)
+	}
+	s.clientsMutex.RLock()
+	for id, other := range s.clients {
+		if id != clientID && other.presence != nil {
+			announcements = append(announcements, *other.presence)
+		}
+	}
+	s.clientsMutex.RUnlock()
+
+	for _, notification := range announcements {
+		if err := WriteMessage(p.conn, notification); err != nil {
+			log.Debug("Failed to send presence", "id", clientID, "error", err)
+			return
+		}
+	}
+}
+
+// announceGone tells the instances that a client disconnected, which releases its lock
+func (s *Server) announceGone(clientID string, last Notification) {
+	presence, ok := decodePresence(last)
+	if !ok {
+		return
+	}
+	presence.Gone = true
+	notification := newPresenceNotification(presence)
+
+	if err := s.broadcastToOthers(notification, clientID); err != nil {
+		log.Debug("Failed to announce disconnected instance", "error", err)
+	}
+	s.notifListener.OnNotification(notification)
+}
+
 // Stop gracefully shuts down the server
 func (s *Server) Stop() error {
 	// Signal all goroutines to shut down
@@ -287,11 +734,11 @@ func (s *Server) Stop() error {
 		s.clientsMutex.Lock()
 		defer s.clientsMutex.Unlock()
 
-		for id, conn := range s.clients {
+		for id, p := range s.clients {
 			log.Warn("Closing client connection", "id", id)
-			conn.Close()
+			p.conn.Close()
 		}
-		s.clients = make(map[string]net.Conn)
+		s.clients = make(map[string]*peer)
 	}()
 
 	// Wait for all goroutines to exit (with timeout)
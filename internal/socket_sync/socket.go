@@ -86,8 +86,8 @@ func WriteMessage(conn net.Conn, notification Notification) error {
 		return &SocketError{Op: "set_deadline", Err: err}
 	}
 
-	// Encode message to JSON
-	data, err := json.Marshal(notification)
+	// Encode message to JSON, dropping a payload that doesn't fit
+	data, err := notification.encode()
 	if err != nil {
 		return &SocketError{Op: "encode", Err: err}
 	}
@@ -114,17 +114,64 @@ func WriteMessage(conn net.Conn, notification Notification) error {
 
 // ReadMessage reads a notification message from the connection
 func ReadMessage(conn net.Conn) (Notification, error) {
-	_ = conn.SetReadDeadline(time.Now().Add(ReadTimeout))
-	var notification Notification
+	return newMessageReader(conn).read(ReadTimeout)
+}
 
-	// Create buffered reader
-	reader := bufio.NewReader(conn)
+// messageReader reads the messages of one connection. It keeps what was read past the end of
+// a message, so messages sent right after each other are not lost.
+type messageReader struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
 
-	// Read message up to newline
-	data, err := reader.ReadBytes('\n')
+func newMessageReader(conn net.Conn) *messageReader {
+	return &messageReader{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// read waits up to timeout for the next notification
+func (m *messageReader) read(timeout time.Duration) (Notification, error) {
+	line, err := m.readLine(timeout)
 	if err != nil {
-		return notification, &SocketError{Op: "read", Err: err}
+		return Notification{}, err
 	}
+	return decodeMessage(line)
+}
+
+// readLine waits up to timeout for the next message and returns it undecoded. A message
+// larger than MaxRPCMessageSize is skipped without keeping it in memory.
+func (m *messageReader) readLine(timeout time.Duration) ([]byte, error) {
+	_ = m.conn.SetReadDeadline(time.Now().Add(timeout))
+
+	// Read message up to newline
+	var data []byte
+	tooLarge := false
+	for {
+		chunk, err := m.reader.ReadSlice('\n')
+		if !tooLarge && len(data)+len(chunk) > MaxRPCMessageSize {
+			tooLarge, data = true, nil
+		}
+		if !tooLarge {
+			data = append(data, chunk...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, &SocketError{Op: "read", Err: err}
+		}
+		break
+	}
+
+	if tooLarge {
+		return nil, &SocketError{Op: "size_check", Err: errors.New("message too large")}
+	}
+
+	return data, nil
+}
+
+// decodeMessage decodes a notification read by readLine
+func decodeMessage(data []byte) (Notification, error) {
+	var notification Notification
 
 	if len(data) > MaxMessageSize {
 		return notification, &SocketError{Op: "size_check", Err: errors.New("message too large")}
@@ -162,6 +209,13 @@ func IsSocketClosed(err error) bool {
 		strings.Contains(err.Error(), "broken pipe")
 }
 
+// isBadFrame determines if an error is about a malformed or oversized message, after which
+// the connection can still be read
+func isBadFrame(err error) bool {
+	var socketErr *SocketError
+	return errors.As(err, &socketErr) && (socketErr.Op == "decode" || socketErr.Op == "size_check")
+}
+
 func IsTimeout(err error) bool {
 	if err == nil {
 		return false
@@ -181,6 +235,24 @@ func IsTimeout(err error) bool {
 	return false
 }
 
+var errPeerCredUnsupported = errors.New("peer credentials are not supported on this platform")
+
+// checkPeer verifies that the process on the other end of a connection runs as our user.
+// Where the platform can't tell, the permissions of the socket have to do.
+func checkPeer(conn net.Conn) error {
+	uid, err := peerUID(conn)
+	if errors.Is(err, errPeerCredUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return &SocketError{Op: "peer_credentials", Err: err}
+	}
+	if uid != os.Getuid() {
+		return &SocketError{Op: "peer_credentials", Err: fmt.Errorf("peer runs as uid %d, not %d", uid, os.Getuid())}
+	}
+	return nil
+}
+
 // ValidateSocketPath checks if the socket path is valid
 func ValidateSocketPath(path string) error {
 	// Check path length - Unix domain sockets have path length limitations
@@ -10,18 +10,35 @@ import (
 )
 
 var (
-	FocusedStyle = lipgloss.NewStyle().Foreground(theme.Mauve)
-	HoverStyle   = lipgloss.NewStyle().Foreground(theme.Yellow)
-	RowStyle     = lipgloss.NewStyle().Background(theme.BlackColor)
-	TextStyle    = lipgloss.NewStyle().Foreground(theme.TextColor)
-	SubtextStyle = lipgloss.NewStyle().Foreground(theme.SubtextColor)
-	EmptyStyle   = lipgloss.NewStyle().Foreground(theme.Green)
-	WarningStyle = lipgloss.NewStyle().Foreground(theme.ErrorColor)
-
 	BorderWidth = 1
 	Padding     = 1
 )
 
+// Styles are built on every call so they always use the active theme
+func FocusedStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(theme.Current().Accent)
+}
+
+func HoverStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(theme.Current().Highlight)
+}
+
+func TextStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(theme.Current().Text)
+}
+
+func SubtextStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(theme.Current().Subtext)
+}
+
+func EmptyStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(theme.Current().Positive)
+}
+
+func WarningStyle() lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(theme.Current().Error)
+}
+
 func GetStyledStatus(translatedStatus string, status models.Status, selected, omitNumber, hovered bool) string {
 	statusColor := status.Color()
 
@@ -31,7 +48,7 @@ func GetStyledStatus(translatedStatus string, status models.Status, selected, om
 func GetStyledTagWithIndicator(num int, text string, color lipgloss.Color, selected, omitNumber, hovered bool) string {
 	// Create indicator with number
 	indicator := lipgloss.NewStyle().
-		Foreground(theme.BlackColor).
+		Foreground(theme.Current().Inverse).
 		Background(color).
 		Padding(0, 1, 0, 0).
 		Bold(true).
@@ -43,19 +60,19 @@ func GetStyledTagWithIndicator(num int, text string, color lipgloss.Color, selec
 	var rightCapStyle lipgloss.Style
 	if hovered {
 		textStyle = lipgloss.NewStyle().
-			Foreground(theme.BlackColor).
-			Background(theme.Yellow).
+			Foreground(theme.Current().Inverse).
+			Background(theme.Current().Highlight).
 			Padding(0, 0)
 		leftCapStyle = lipgloss.NewStyle().
-			Foreground(theme.Yellow).
+			Foreground(theme.Current().Highlight).
 			Padding(0, 0)
 		rightCapStyle = lipgloss.NewStyle().
-			Foreground(theme.Yellow).
+			Foreground(theme.Current().Highlight).
 			Padding(0, 0).
 			MarginRight(2)
 	} else if selected {
 		textStyle = lipgloss.NewStyle().
-			Foreground(theme.BlackColor).
+			Foreground(theme.Current().Inverse).
 			Background(color).
 			Padding(0, 0)
 		leftCapStyle = lipgloss.NewStyle().
@@ -69,20 +86,26 @@ func GetStyledTagWithIndicator(num int, text string, color lipgloss.Color, selec
 	} else {
 		// Inactive tab
 		textStyle = lipgloss.NewStyle().
-			Foreground(theme.SubtextColor).
-			Background(theme.BackgroundColor).
+			Foreground(theme.Current().Subtext).
+			Background(theme.Current().Background).
 			Padding(0, 0)
 		leftCapStyle = lipgloss.NewStyle().
 			Foreground(color).
 			Padding(0, 0)
 		rightCapStyle = lipgloss.NewStyle().
-			Foreground(theme.BackgroundColor).
+			Foreground(theme.Current().Background).
 			Padding(0, 0).
 			MarginRight(2)
 	}
 
 	if omitNumber {
-		leftCapStyle.Foreground(theme.BackgroundColor)
+		leftCapStyle.Foreground(theme.Current().Background)
+	}
+
+	// Without colors the active and hovered tab are shown in reverse video
+	if theme.Current().Monochrome && (selected || hovered) {
+		textStyle = textStyle.Reverse(true)
+		indicator = lipgloss.NewStyle().Reverse(true).Bold(true).Padding(0, 1, 0, 0).Render(fmt.Sprintf("%d", num))
 	}
 
 	statusText := textStyle.Render(" " + text)
@@ -98,20 +121,21 @@ func GetStyledTagWithIndicator(num int, text string, color lipgloss.Color, selec
 }
 
 func GetStyledPriority(translatedP string, p models.Priority, selected, hovered bool) string {
-	bgColor := theme.BackgroundColor
-	textColor := theme.SubtextColor
+	bgColor := theme.Current().Background
+	textColor := theme.Current().Subtext
 	if selected {
 		bgColor = p.Color()
-		textColor = theme.BlackColor
+		textColor = theme.Current().Inverse
 	}
 	if hovered {
-		bgColor = theme.Yellow
+		bgColor = theme.Current().Highlight
 	}
 
 	// Text section (status name)
 	textStyle := lipgloss.NewStyle().
 		Foreground(textColor).
 		Background(bgColor).
+		Reverse(theme.Current().Monochrome && (selected || hovered)).
 		Width(8).
 		Align(lipgloss.Center).
 		MarginRight(1)
@@ -121,8 +145,8 @@ func GetStyledPriority(translatedP string, p models.Priority, selected, hovered
 
 func GetStyledUpdatedAt(text string) string {
 	textStyle := lipgloss.NewStyle().
-		Foreground(theme.Lavender).
-		Background(theme.BackgroundColor).
+		Foreground(theme.Current().Secondary).
+		Background(theme.Current().Background).
 		Padding(0, 1).
 		Align(lipgloss.Center).
 		MarginRight(1)
@@ -135,7 +159,7 @@ func GetStyledUpdatedAt(text string) string {
 func GetStyledDueDate(text string, priority models.Priority) string {
 	textStyle := lipgloss.NewStyle().
 		Foreground(priority.Color()).
-		Background(theme.BackgroundColor).
+		Background(theme.Current().Background).
 		Padding(0, 1).
 		Align(lipgloss.Center).
 		MarginRight(1)
@@ -147,8 +171,8 @@ func GetStyledDueDate(text string, priority models.Priority) string {
 
 func GetTimeSpend(text string) string {
 	textStyle := lipgloss.NewStyle().
-		Foreground(theme.Teal).
-		Background(theme.BackgroundColor).
+		Foreground(theme.Current().Tertiary).
+		Background(theme.Current().Background).
 		Padding(0, 1).
 		Align(lipgloss.Center)
 
@@ -159,8 +183,8 @@ func GetTimeSpend(text string) string {
 
 func GetStyledTag(tag string) string {
 	textStyle := lipgloss.NewStyle().
-		Foreground(theme.BlackColor).
-		Background(theme.Rosewater).
+		Foreground(theme.Current().Inverse).
+		Background(theme.Current().Tag).
 		Padding(0, 1).
 		Align(lipgloss.Center).
 		MarginRight(1)
@@ -171,8 +195,9 @@ func GetStyledTag(tag string) string {
 func GetSelectedBlock(selected bool) string {
 	if selected {
 		return lipgloss.NewStyle().
-			Foreground(theme.Yellow).
-			Background(theme.Yellow).
+			Foreground(theme.Current().Highlight).
+			Background(theme.Current().Highlight).
+			Reverse(theme.Current().Monochrome).
 			Padding(0, 1).
 			Align(lipgloss.Center).
 			MarginRight(1).
@@ -187,8 +212,13 @@ func GetSelectedBlock(selected bool) string {
 }
 
 func RenderMarkdown(md string) string {
+	style := glamour.WithAutoStyle()
+	if markdownStyle := theme.Current().MarkdownStyle; markdownStyle != theme.AutoMarkdownStyle {
+		style = glamour.WithStandardStyle(markdownStyle)
+	}
+
 	r, _ := glamour.NewTermRenderer(
-		glamour.WithAutoStyle(),
+		style,
 		glamour.WithWordWrap(80),
 	)
 
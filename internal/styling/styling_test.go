@@ -87,7 +87,7 @@ func TestGetStyledStatus(t *testing.T) {
 			}
 
 			// For hovered state, yellow should be used
-			if tt.hovered && !containsColor(result, theme.Yellow) {
+			if tt.hovered && !containsColor(result, theme.Current().Highlight) {
 				t.Error("Hovered status should use Yellow color")
 			}
 		})
@@ -108,7 +108,7 @@ func TestGetStyledTagWithIndicator(t *testing.T) {
 			name:       "Basic tag",
 			num:        1,
 			text:       "Tag",
-			color:      theme.Mauve,
+			color:      theme.Current().Accent,
 			selected:   false,
 			omitNumber: false,
 			hovered:    false,
@@ -117,7 +117,7 @@ func TestGetStyledTagWithIndicator(t *testing.T) {
 			name:       "Selected tag",
 			num:        2,
 			text:       "Selected",
-			color:      theme.Lavender,
+			color:      theme.Current().Secondary,
 			selected:   true,
 			omitNumber: false,
 			hovered:    false,
@@ -126,7 +126,7 @@ func TestGetStyledTagWithIndicator(t *testing.T) {
 			name:       "Hovered tag",
 			num:        3,
 			text:       "Hovered",
-			color:      theme.Rosewater,
+			color:      theme.Current().Tag,
 			selected:   false,
 			omitNumber: false,
 			hovered:    true,
@@ -135,7 +135,7 @@ func TestGetStyledTagWithIndicator(t *testing.T) {
 			name:       "Omitted number",
 			num:        4,
 			text:       "NoNumber",
-			color:      theme.Yellow,
+			color:      theme.Current().Highlight,
 			selected:   false,
 			omitNumber: true,
 			hovered:    false,
@@ -159,7 +159,7 @@ func TestGetStyledTagWithIndicator(t *testing.T) {
 			}
 
 			// For hovered state, yellow should be used
-			if tt.hovered && !containsColor(result, theme.Yellow) {
+			if tt.hovered && !containsColor(result, theme.Current().Highlight) {
 				t.Error("Hovered tag should use Yellow color")
 			}
 
@@ -226,7 +226,7 @@ func TestGetStyledPriority(t *testing.T) {
 			}
 
 			// For hovered state, yellow should be used
-			if tt.hovered && !containsColor(result, theme.Yellow) {
+			if tt.hovered && !containsColor(result, theme.Current().Highlight) {
 				t.Error("Hovered priority should use Yellow color")
 			}
 
@@ -274,7 +274,7 @@ func TestGetStyledUpdatedAt(t *testing.T) {
 			}
 
 			// Check that the Lavender color is used
-			if !containsColor(result, theme.Lavender) {
+			if !containsColor(result, theme.Current().Secondary) {
 				t.Error("UpdatedAt should use Lavender color")
 			}
 		})
@@ -365,7 +365,7 @@ func TestGetStyledTag(t *testing.T) {
 			}
 
 			// Check that the Rosewater color is used
-			if !containsColor(result, theme.Rosewater) {
+			if !containsColor(result, theme.Current().Tag) {
 				t.Error("Tag should use Rosewater color")
 			}
 		})
@@ -397,7 +397,7 @@ func TestGetSelectedBlock(t *testing.T) {
 			}
 
 			// For selected state, yellow should be used
-			if tt.selected && !containsColor(result, theme.Yellow) {
+			if tt.selected && !containsColor(result, theme.Current().Highlight) {
 				t.Error("Selected block should use Yellow color")
 			}
 		})
@@ -2,38 +2,184 @@ package theme
 
 import "github.com/charmbracelet/lipgloss"
 
-var (
-	OpenStatusColor    = lipgloss.Color("#f5e0dc")
-	DoingStatusColor   = lipgloss.Color("#89b4fa")
-	DoneStatusColor    = lipgloss.Color("#a6e3a1")
-	BlockedStatusColor = lipgloss.Color("#d20f39")
-
-	LowPriorityColor      = lipgloss.Color("#94e2d5")
-	MediumPriorityColor   = lipgloss.Color("#f0c6c6")
-	HighPriorityColor     = lipgloss.Color("#ef9f76")
-	MajorPriorityColor    = lipgloss.Color("#e78284")
-	CriticalPriorityColor = lipgloss.Color("#d20f39")
-
-	Mauve     = lipgloss.Color("#cba6f7")
-	Yellow    = lipgloss.Color("#f9e2af")
-	Lavender  = lipgloss.Color("#b4befe")
-	Rosewater = lipgloss.Color("#f2cdcd")
-	Teal      = lipgloss.Color("#8bd5ca")
-	Green     = lipgloss.Color("#a6da95")
-
-	ArchivedStatusColor = lipgloss.Color("#9399b2")
-
-	WhiteColor = lipgloss.Color("#fff")
-	BlackColor = lipgloss.Color("#11111b")
-
-	TextColor       = lipgloss.Color("#cdd6f4")
-	SubtextColor    = lipgloss.Color("#a6adc8")
-	HelpTextColor   = lipgloss.Color("#626262")
-	BackgroundColor = lipgloss.Color("#313244")
-	RowColor        = lipgloss.Color("#1e1e2e")
-
-	InfoColor    = lipgloss.Color("#186ddd")
-	WarningColor = lipgloss.Color("#ff7c03")
-	ErrorColor   = lipgloss.Color("#d13523")
-	SuccessColor = lipgloss.Color("#1b7e41")
+// Theme is a color palette for the whole interface. An empty color renders without color.
+type Theme struct {
+	Name string `toml:"-"`
+
+	OpenStatus    lipgloss.Color `toml:"open_status"`
+	DoingStatus   lipgloss.Color `toml:"doing_status"`
+	DoneStatus    lipgloss.Color `toml:"done_status"`
+	BlockedStatus lipgloss.Color `toml:"blocked_status"`
+	Archived      lipgloss.Color `toml:"archived"`
+
+	LowPriority      lipgloss.Color `toml:"low_priority"`
+	MediumPriority   lipgloss.Color `toml:"medium_priority"`
+	HighPriority     lipgloss.Color `toml:"high_priority"`
+	MajorPriority    lipgloss.Color `toml:"major_priority"`
+	CriticalPriority lipgloss.Color `toml:"critical_priority"`
+
+	// Accent is used for borders and focused fields, Highlight for the hovered item
+	Accent    lipgloss.Color `toml:"accent"`
+	Highlight lipgloss.Color `toml:"highlight"`
+	Secondary lipgloss.Color `toml:"secondary"`
+	Tertiary  lipgloss.Color `toml:"tertiary"`
+	Tag       lipgloss.Color `toml:"tag"`
+	Positive  lipgloss.Color `toml:"positive"`
+
+	Text       lipgloss.Color `toml:"text"`
+	Subtext    lipgloss.Color `toml:"subtext"`
+	HelpText   lipgloss.Color `toml:"help_text"`
+	Background lipgloss.Color `toml:"background"`
+	// Inverse is the text color on top of status, priority and highlight colors
+	Inverse   lipgloss.Color `toml:"inverse"`
+	ToastText lipgloss.Color `toml:"toast_text"`
+
+	Info    lipgloss.Color `toml:"info"`
+	Warning lipgloss.Color `toml:"warning"`
+	Error   lipgloss.Color `toml:"error"`
+	Success lipgloss.Color `toml:"success"`
+
+	// MarkdownStyle is the glamour style for release notes: auto, dark, light or notty
+	MarkdownStyle string `toml:"-"`
+	// Monochrome themes mark selection with reverse video instead of color
+	Monochrome bool `toml:"-"`
+}
+
+const (
+	DarkName         = "dark"
+	LightName        = "light"
+	HighContrastName = "high-contrast"
+	NoColorName      = "no-color"
+
+	// AutoMarkdownStyle lets glamour pick a style from the terminal background
+	AutoMarkdownStyle = "auto"
 )
+
+// Dark is the default Catppuccin Mocha inspired palette
+func Dark() Theme {
+	return Theme{
+		Name: DarkName,
+
+		OpenStatus:    lipgloss.Color("#f5e0dc"),
+		DoingStatus:   lipgloss.Color("#89b4fa"),
+		DoneStatus:    lipgloss.Color("#a6e3a1"),
+		BlockedStatus: lipgloss.Color("#d20f39"),
+		Archived:      lipgloss.Color("#9399b2"),
+
+		LowPriority:      lipgloss.Color("#94e2d5"),
+		MediumPriority:   lipgloss.Color("#f0c6c6"),
+		HighPriority:     lipgloss.Color("#ef9f76"),
+		MajorPriority:    lipgloss.Color("#e78284"),
+		CriticalPriority: lipgloss.Color("#d20f39"),
+
+		Accent:    lipgloss.Color("#cba6f7"),
+		Highlight: lipgloss.Color("#f9e2af"),
+		Secondary: lipgloss.Color("#b4befe"),
+		Tertiary:  lipgloss.Color("#8bd5ca"),
+		Tag:       lipgloss.Color("#f2cdcd"),
+		Positive:  lipgloss.Color("#a6da95"),
+
+		Text:       lipgloss.Color("#cdd6f4"),
+		Subtext:    lipgloss.Color("#a6adc8"),
+		HelpText:   lipgloss.Color("#626262"),
+		Background: lipgloss.Color("#313244"),
+		Inverse:    lipgloss.Color("#11111b"),
+		ToastText:  lipgloss.Color("#ffffff"),
+
+		Info:    lipgloss.Color("#186ddd"),
+		Warning: lipgloss.Color("#ff7c03"),
+		Error:   lipgloss.Color("#d13523"),
+		Success: lipgloss.Color("#1b7e41"),
+
+		MarkdownStyle: AutoMarkdownStyle,
+	}
+}
+
+// Light is a Catppuccin Latte inspired palette for light terminals
+func Light() Theme {
+	return Theme{
+		Name: LightName,
+
+		OpenStatus:    lipgloss.Color("#dc8a78"),
+		DoingStatus:   lipgloss.Color("#1e66f5"),
+		DoneStatus:    lipgloss.Color("#40a02b"),
+		BlockedStatus: lipgloss.Color("#d20f39"),
+		Archived:      lipgloss.Color("#8c8fa1"),
+
+		LowPriority:      lipgloss.Color("#179299"),
+		MediumPriority:   lipgloss.Color("#dd7878"),
+		HighPriority:     lipgloss.Color("#fe640b"),
+		MajorPriority:    lipgloss.Color("#e64553"),
+		CriticalPriority: lipgloss.Color("#d20f39"),
+
+		Accent:    lipgloss.Color("#8839ef"),
+		Highlight: lipgloss.Color("#df8e1d"),
+		Secondary: lipgloss.Color("#7287fd"),
+		Tertiary:  lipgloss.Color("#179299"),
+		Tag:       lipgloss.Color("#dd7878"),
+		Positive:  lipgloss.Color("#40a02b"),
+
+		Text:       lipgloss.Color("#4c4f69"),
+		Subtext:    lipgloss.Color("#6c6f85"),
+		HelpText:   lipgloss.Color("#9ca0b0"),
+		Background: lipgloss.Color("#ccd0da"),
+		Inverse:    lipgloss.Color("#eff1f5"),
+		ToastText:  lipgloss.Color("#ffffff"),
+
+		Info:    lipgloss.Color("#1e66f5"),
+		Warning: lipgloss.Color("#fe640b"),
+		Error:   lipgloss.Color("#d20f39"),
+		Success: lipgloss.Color("#40a02b"),
+
+		MarkdownStyle: "light",
+	}
+}
+
+// HighContrast uses the 16 ANSI colors on black, which every terminal renders legibly
+func HighContrast() Theme {
+	return Theme{
+		Name: HighContrastName,
+
+		OpenStatus:    lipgloss.Color("15"),
+		DoingStatus:   lipgloss.Color("14"),
+		DoneStatus:    lipgloss.Color("10"),
+		BlockedStatus: lipgloss.Color("9"),
+		Archived:      lipgloss.Color("7"),
+
+		LowPriority:      lipgloss.Color("14"),
+		MediumPriority:   lipgloss.Color("15"),
+		HighPriority:     lipgloss.Color("11"),
+		MajorPriority:    lipgloss.Color("13"),
+		CriticalPriority: lipgloss.Color("9"),
+
+		Accent:    lipgloss.Color("13"),
+		Highlight: lipgloss.Color("11"),
+		Secondary: lipgloss.Color("12"),
+		Tertiary:  lipgloss.Color("14"),
+		Tag:       lipgloss.Color("15"),
+		Positive:  lipgloss.Color("10"),
+
+		Text:       lipgloss.Color("15"),
+		Subtext:    lipgloss.Color("7"),
+		HelpText:   lipgloss.Color("7"),
+		Background: lipgloss.Color("0"),
+		Inverse:    lipgloss.Color("0"),
+		ToastText:  lipgloss.Color("0"),
+
+		Info:    lipgloss.Color("12"),
+		Warning: lipgloss.Color("11"),
+		Error:   lipgloss.Color("9"),
+		Success: lipgloss.Color("10"),
+
+		MarkdownStyle: "dark",
+	}
+}
+
+// NoColor renders text in the terminal's own colors, see https://no-color.org
+func NoColor() Theme {
+	return Theme{
+		Name:          NoColorName,
+		MarkdownStyle: "notty",
+		Monochrome:    true,
+	}
+}
@@ -0,0 +1,215 @@
+package theme
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	mu      sync.RWMutex
+	current = Dark()
+	themes  = map[string]Theme{
+		DarkName:         Dark(),
+		LightName:        Light(),
+		HighContrastName: HighContrast(),
+		NoColorName:      NoColor(),
+	}
+)
+
+// ===========================================================================
+// Active theme
+// ===========================================================================
+
+// Current returns the theme the interface is rendered with
+func Current() Theme {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Set makes t the active theme
+func Set(t Theme) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = t
+}
+
+// Use activates the registered theme with the given name
+func Use(name string) error {
+	t, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown theme %q", name)
+	}
+	Set(t)
+	return nil
+}
+
+// NoColorRequested reports whether the user asked for no colors through $NO_COLOR
+func NoColorRequested() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+// ===========================================================================
+// Registry
+// ===========================================================================
+
+// Lookup returns the built-in or custom theme with the given name
+func Lookup(name string) (Theme, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	t, ok := themes[name]
+	return t, ok
+}
+
+// Names returns the names of all themes, built-in themes first
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	builtIn := []string{DarkName, LightName, HighContrastName, NoColorName}
+	var custom []string
+	for name := range themes {
+		if !isBuiltIn(name) {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+
+	return append(builtIn, custom...)
+}
+
+// Register adds a custom theme, built-in themes can't be replaced
+func Register(t Theme) error {
+	if isBuiltIn(t.Name) {
+		return fmt.Errorf("theme %q: the name of a built-in theme can't be reused", t.Name)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	themes[t.Name] = t
+	return nil
+}
+
+func isBuiltIn(name string) bool {
+	switch name {
+	case DarkName, LightName, HighContrastName, NoColorName:
+		return true
+	}
+	return false
+}
+
+// ===========================================================================
+// Custom palettes
+// ===========================================================================
+
+// themeFile is the format of a custom palette, colors missing from it are taken from base
+type themeFile struct {
+	Base          string `toml:"base"`
+	MarkdownStyle string `toml:"markdown_style"`
+	Colors        Theme  `toml:"colors"`
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// LoadDir registers every *.toml palette in dir, named after the file. A missing directory is fine.
+func LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, path := range paths {
+		t, err := LoadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := Register(t); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// LoadFile reads a custom palette
+func LoadFile(path string) (Theme, error) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	// Read the base first so the palette can be decoded on top of it
+	var header themeFile
+	if _, err := toml.DecodeFile(path, &header); err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %w", path, err)
+	}
+	if header.Base == "" {
+		header.Base = DarkName
+	}
+	if !isBuiltIn(header.Base) {
+		return Theme{}, fmt.Errorf("theme %s: base %q is not a built-in theme", path, header.Base)
+	}
+
+	base, _ := Lookup(header.Base)
+	file := themeFile{Colors: base}
+	meta, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return Theme{}, fmt.Errorf("theme %s: unknown key %s", path, undecoded[0])
+	}
+
+	t := file.Colors
+	t.Name = name
+	if file.MarkdownStyle != "" {
+		t.MarkdownStyle = file.MarkdownStyle
+	}
+
+	if err := t.validate(); err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %w", path, err)
+	}
+
+	return t, nil
+}
+
+// validate checks that every color is empty, a hex color or an ANSI color number
+func (t Theme) validate() error {
+	var errs []error
+
+	value := reflect.ValueOf(t)
+	colorType := reflect.TypeOf(lipgloss.Color(""))
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Type != colorType {
+			continue
+		}
+
+		color := value.Field(i).String()
+		if color == "" || hexColor.MatchString(color) {
+			continue
+		}
+		if n, err := strconv.Atoi(color); err == nil && n >= 0 && n <= 255 {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s: %q is not a hex color or ANSI color number", field.Tag.Get("toml"), color))
+	}
+
+	switch t.MarkdownStyle {
+	case AutoMarkdownStyle, "dark", "light", "notty":
+	default:
+		errs = append(errs, fmt.Errorf("markdown_style: %q must be auto, dark, light or notty", t.MarkdownStyle))
+	}
+
+	return errors.Join(errs...)
+}
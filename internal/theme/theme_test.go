@@ -0,0 +1,132 @@
+package theme
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func writeTheme(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name+".toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuiltInThemesAreValid(t *testing.T) {
+	for _, name := range []string{DarkName, LightName, HighContrastName, NoColorName} {
+		th, ok := Lookup(name)
+		if !ok {
+			t.Fatalf("theme %s is not registered", name)
+		}
+		if th.Name != name {
+			t.Errorf("theme %s has name %q", name, th.Name)
+		}
+		if err := th.validate(); err != nil {
+			t.Errorf("theme %s: %v", name, err)
+		}
+	}
+}
+
+func TestNoColorTheme(t *testing.T) {
+	th := NoColor()
+	if !th.Monochrome {
+		t.Error("no-color theme should be monochrome")
+	}
+	if th.Accent != "" || th.Text != "" || th.OpenStatus != "" {
+		t.Error("no-color theme should not define colors")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeTheme(t, t.TempDir(), "solarized", `
+base = "light"
+markdown_style = "dark"
+
+[colors]
+accent = "#268bd2"
+text = "12"
+`)
+
+	th, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if th.Name != "solarized" {
+		t.Errorf("theme should be named after the file, got %q", th.Name)
+	}
+	if th.Accent != lipgloss.Color("#268bd2") || th.Text != lipgloss.Color("12") {
+		t.Errorf("colors from the file were not applied: %+v", th)
+	}
+	if th.Background != Light().Background {
+		t.Errorf("missing colors should come from the base theme, got %q", th.Background)
+	}
+	if th.MarkdownStyle != "dark" {
+		t.Errorf("unexpected markdown style %q", th.MarkdownStyle)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown base", `base = "sepia"`, `base "sepia"`},
+		{"invalid color", "[colors]\naccent = \"purple\"", "accent"},
+		{"ansi out of range", "[colors]\ntext = \"300\"", "text"},
+		{"unknown key", "[colors]\nborder = \"#fff\"", "border"},
+		{"invalid markdown style", `markdown_style = "fancy"`, "markdown_style"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFile(writeTheme(t, t.TempDir(), "broken", tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTheme(t, dir, "ocean", "[colors]\naccent = \"#0077be\"")
+
+	if err := LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	names := Names()
+	if !slices.Contains(names, "ocean") || names[0] != DarkName {
+		t.Errorf("unexpected theme names %v", names)
+	}
+
+	if err := Use("ocean"); err != nil {
+		t.Fatal(err)
+	}
+	defer Set(Dark())
+	if Current().Accent != lipgloss.Color("#0077be") {
+		t.Errorf("custom theme was not activated")
+	}
+}
+
+func TestLoadDirMissing(t *testing.T) {
+	if err := LoadDir(filepath.Join(t.TempDir(), "nope")); err != nil {
+		t.Errorf("a missing theme directory should not be an error: %v", err)
+	}
+}
+
+func TestRegisterBuiltInName(t *testing.T) {
+	th := Dark()
+	if err := Register(th); err == nil {
+		t.Error("expected an error when replacing a built-in theme")
+	}
+}
@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"slices"
+
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -50,9 +52,21 @@ func (m *AboutModal) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if key.Matches(
 			msg,
 			m.tuiService.KeyMap.Quit,
+			m.tuiService.KeyMap.Cancel,
 		) {
 			return m, func() tea.Msg { return modalCloseMsg{reload: false} }
 		}
+		if key.Matches(msg, m.tuiService.KeyMap.Theme) {
+			name := m.tuiService.CycleTheme()
+			return m, ShowDefaultToast(m.translator.Tf("toast.theme_changed", map[string]interface{}{"Theme": name}), InfoToast)
+		}
+		if key.Matches(msg, m.tuiService.KeyMap.Language) {
+			lang := m.cycleLanguage()
+			return m, ShowDefaultToast(m.translator.Tf("toast.language_changed", map[string]interface{}{
+				"Language": m.translator.LanguageName(lang),
+				"Code":     lang,
+			}), InfoToast)
+		}
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
@@ -68,14 +82,18 @@ func (m *AboutModal) View() string {
 		Border(lipgloss.RoundedBorder()).
 		Padding(1, 2).
 		Width((m.width / 3) * 2).
-		BorderForeground(theme.Mauve)
+		BorderForeground(theme.Current().Accent)
 
-	title := styling.FocusedStyle.Render(m.translator.T("about_title"))
-	subtitle := styling.TextStyle.Render(m.translator.T("about_subtitle"))
-	url := styling.TextStyle.Render(updateInfo.URL)
+	title := styling.FocusedStyle().Render(m.translator.T("about_title"))
+	subtitle := styling.TextStyle().Render(m.translator.T("about_subtitle"))
+	url := styling.TextStyle().Render(updateInfo.URL)
 	spacer := ""
-	notesTitle := styling.FocusedStyle.Render(m.translator.Tf("about_notes_title", map[string]interface{}{"Version": updateInfo.Version}))
+	notesTitle := styling.FocusedStyle().Render(m.translator.Tf("about_notes_title", map[string]interface{}{"Version": updateInfo.Version}))
 	notes := styling.RenderMarkdown(updateInfo.Notes)
+	themeName := styling.SubtextStyle().Render(m.translator.Tf("about_theme", map[string]interface{}{"Theme": theme.Current().Name}))
+	languageName := styling.SubtextStyle().Render(m.translator.Tf("about_language", map[string]interface{}{
+		"Language": m.translator.LanguageName(m.translator.GetCurrentLanguage()),
+	}))
 
 	help := m.help.View()
 	content := lipgloss.JoinVertical(
@@ -87,6 +105,9 @@ func (m *AboutModal) View() string {
 		spacer,
 		url,
 		spacer,
+		themeName,
+		languageName,
+		spacer,
 		notesTitle,
 		spacer,
 		notes,
@@ -102,3 +123,15 @@ func (m *AboutModal) View() string {
 		modalStyle.Render(content),
 	)
 }
+
+// ===========================================================================
+// Helpers
+// ===========================================================================
+
+// cycleLanguage switches the interface to the next language with a catalog
+func (m *AboutModal) cycleLanguage() string {
+	languages := m.translator.Languages()
+	next := languages[(slices.Index(languages, m.translator.GetCurrentLanguage())+1)%len(languages)]
+	m.translator.SetLanguage(next)
+	return next
+}
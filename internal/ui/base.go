@@ -24,7 +24,7 @@ func NewBaseModel(service *service.AppService, translationService *i18n.Translat
 }
 
 func (m *BaseModel) Init() tea.Cmd {
-	return tea.Batch(InitTodosCmd(), InitTagsCmd())
+	return tea.Batch(InitTodosCmd(), InitTagsCmd(), CheckBranchCmd(), DayChangeCmd())
 }
 
 func (m *BaseModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
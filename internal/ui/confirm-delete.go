@@ -25,17 +25,17 @@ type ConfirmDeleteModel struct {
 
 func NewConfirmDeleteModal(appService *service.AppService, tuiService *service.TuiService, translationService *i18n.TranslationService, entityID int64, deleteTag bool) *ConfirmDeleteModel {
 	normalStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(theme.SubtextColor)).
+		Foreground(theme.Current().Subtext).
 		Padding(0, 1)
 
 	focusedStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(theme.TextColor)).
-		Background(theme.SuccessColor).
+		Foreground(theme.Current().Text).
+		Background(theme.Current().Success).
 		Padding(0, 1)
 
 	cancelFocusedStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color(theme.TextColor)).
-		Background(theme.ErrorColor).
+		Foreground(theme.Current().Text).
+		Background(theme.Current().Error).
 		Padding(0, 1)
 
 	return &ConfirmDeleteModel{
@@ -108,7 +108,7 @@ func (m *ConfirmDeleteModel) View() string {
 		Border(lipgloss.RoundedBorder()).
 		Padding(1, 2).
 		Width(m.width / 2).
-		BorderForeground(theme.Mauve)
+		BorderForeground(theme.Current().Accent)
 
 	// Render buttons with appropriate styles
 	cancelView := m.cancelButton.View()
@@ -119,13 +119,13 @@ func (m *ConfirmDeleteModel) View() string {
 	}
 
 	title := styling.
-		FocusedStyle.
+		FocusedStyle().
 		Width(m.width / 2).
 		AlignHorizontal(lipgloss.Center).
 		MarginBottom(2).
 		Render(text)
 	buttons := styling.
-		FocusedStyle.
+		FocusedStyle().
 		Width(m.width/2).
 		AlignHorizontal(lipgloss.Center).
 		Render(cancelView, "  ", sendView)
@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/martijnspitter/tui-todo/internal/export"
+	"github.com/martijnspitter/tui-todo/internal/i18n"
+	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/service"
+)
+
+// ===========================================================================
+// Helpers
+// ===========================================================================
+func exportTitle(view service.ViewType, translator *i18n.TranslationService) string {
+	var name string
+	switch view {
+	case service.TodayPane:
+		name = translator.T("filter.today")
+	case service.OpenPane:
+		name = translator.T(models.Open.String())
+	case service.DoingPane:
+		name = translator.T(models.Doing.String())
+	case service.DonePane:
+		name = translator.T(models.Done.String())
+	case service.BlockedPane:
+		name = translator.T(models.Blocked.String())
+	default:
+		name = translator.T("filter.all")
+	}
+
+	return fmt.Sprintf("%s — %s", name, time.Now().Format("2006-01-02"))
+}
+
+// ===========================================================================
+// Messages
+// ===========================================================================
+type todosExportedMsg struct {
+	count int
+	path  string
+}
+
+// ===========================================================================
+// Commands
+// ===========================================================================
+func exportTodosCmd(todos []*models.Todo, view service.ViewType, format export.Format, translator *i18n.TranslationService) tea.Cmd {
+	return func() tea.Msg {
+		path, err := filepath.Abs(export.FileName(view.Name(), format, time.Now()))
+		if err != nil {
+			log.Error("Failed to resolve export path", "error", err)
+			return TodoErrorMsg{err: fmt.Errorf("error.export_failed")}
+		}
+
+		file, err := os.Create(path)
+		if err != nil {
+			log.Error("Failed to create export file", "error", err, "path", path)
+			return TodoErrorMsg{err: fmt.Errorf("error.export_failed")}
+		}
+		defer file.Close()
+
+		if err := export.Write(file, format, exportTitle(view, translator), todos, translator); err != nil {
+			log.Error("Failed to export todos", "error", err, "path", path)
+			return TodoErrorMsg{err: fmt.Errorf("error.export_failed")}
+		}
+
+		return todosExportedMsg{count: len(todos), path: path}
+	}
+}
@@ -67,7 +67,7 @@ func (m *FooterModel) View() string {
 	// Join everything
 	helpText := m.help.View()
 	if m.tuiService.ShowConfirmQuit {
-		helpText = lipgloss.NewStyle().Foreground(theme.HelpTextColor).Render("Really quit? (Press ctrl+c/esc again to quit)")
+		helpText = lipgloss.NewStyle().Foreground(theme.Current().HelpText).Render("Really quit? (Press ctrl+c/esc again to quit)")
 	}
 
 	statusBar := m.statusBar.View()
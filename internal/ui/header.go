@@ -47,7 +47,7 @@ func (m *HeaderModel) View() string {
 	var leftTabs []string
 
 	isTodaySelected := m.tuiService.CurrentView == service.TodayPane
-	todayTab := styling.GetStyledTagWithIndicator(1, m.translator.T("filter.today"), theme.Lavender, isTodaySelected, false, false)
+	todayTab := styling.GetStyledTagWithIndicator(1, m.translator.T("filter.today"), theme.Current().Secondary, isTodaySelected, false, false)
 	leftTabs = append(leftTabs, todayTab)
 
 	for status := models.Open; status <= models.Blocked; status++ {
@@ -60,10 +60,10 @@ func (m *HeaderModel) View() string {
 	leftContent := lipgloss.JoinHorizontal(lipgloss.Center, leftTabs...)
 
 	isAllSelected := m.tuiService.CurrentView == service.AllPane
-	allTab := styling.GetStyledTagWithIndicator(6, m.translator.T("filter.all"), theme.Rosewater, isAllSelected, false, false)
+	allTab := styling.GetStyledTagWithIndicator(6, m.translator.T("filter.all"), theme.Current().Tag, isAllSelected, false, false)
 
 	isTagsSelected := m.tuiService.CurrentView == service.TagsPane
-	tagsTab := styling.GetStyledTagWithIndicator(7, m.translator.T("filter.tags"), theme.Teal, isTagsSelected, false, false)
+	tagsTab := styling.GetStyledTagWithIndicator(7, m.translator.T("filter.tags"), theme.Current().Tertiary, isTagsSelected, false, false)
 
 	const minGap = 2
 	availableWidth := m.width - 2 // -2 for padding
@@ -57,7 +57,7 @@ func (m *HelpModel) getContextualKeyMap() keys.HelpKeyMap {
 	contextKeyMap := keys.NewHelpKeyMap(m.translator)
 
 	// Always show these keys regardless of context when not filtering
-	if !filterState.IsFilterActive && currentView != service.AddEditTodoModal && currentView != service.AddEditTagModal && currentView != service.AboutModal && currentView != service.TodayPane {
+	if !filterState.IsFilterActive && currentView != service.AddEditTodoModal && currentView != service.AddEditTagModal && currentView != service.AboutModal && currentView != service.WorkspaceModal && currentView != service.TodayPane {
 		contextKeyMap.AddBindingInShort(baseKeyMap.Help)
 		contextKeyMap.AddBindingInShort(baseKeyMap.Quit)
 	}
@@ -87,8 +87,11 @@ func (m *HelpModel) getContextualKeyMap() keys.HelpKeyMap {
 			contextKeyMap.AddBindingInFull(baseKeyMap.AdvanceStatus)
 			contextKeyMap.AddBindingInFull(baseKeyMap.BlockTodo)
 			contextKeyMap.AddBindingInFull(baseKeyMap.Archive)
+			contextKeyMap.AddBindingInFull(baseKeyMap.Export)
+			contextKeyMap.AddBindingInFull(baseKeyMap.ExportCSV)
 
 			contextKeyMap.AddBindingInFull(baseKeyMap.About)
+			contextKeyMap.AddBindingInFull(baseKeyMap.Workspace)
 		}
 
 		// Only show archived toggle in All filter mode
@@ -115,6 +118,7 @@ func (m *HelpModel) getContextualKeyMap() keys.HelpKeyMap {
 		contextKeyMap.AddBindingInFull(baseKeyMap.Delete)
 
 		contextKeyMap.AddBindingInFull(baseKeyMap.About)
+		contextKeyMap.AddBindingInFull(baseKeyMap.Workspace)
 	case service.AddEditTodoModal:
 		// Edit view shows edit-specific keys
 		contextKeyMap.AddBindingInShort(baseKeyMap.Cancel)
@@ -135,17 +139,28 @@ func (m *HelpModel) getContextualKeyMap() keys.HelpKeyMap {
 
 	case service.AboutModal:
 		contextKeyMap.AddBindingInShort(baseKeyMap.Cancel)
+		contextKeyMap.AddBindingInShort(baseKeyMap.Theme)
+		contextKeyMap.AddBindingInShort(baseKeyMap.Language)
+
+	case service.WorkspaceModal:
+		contextKeyMap.AddBindingInShort(baseKeyMap.Up)
+		contextKeyMap.AddBindingInShort(baseKeyMap.Down)
+		contextKeyMap.AddBindingInShort(baseKeyMap.Select)
+		contextKeyMap.AddBindingInShort(baseKeyMap.Cancel)
 
 	case service.TodayPane:
 		contextKeyMap.AddBindingInShort(baseKeyMap.Up)
 		contextKeyMap.AddBindingInShort(baseKeyMap.Down)
 		contextKeyMap.AddBindingInShort(baseKeyMap.PageUp)
 		contextKeyMap.AddBindingInShort(baseKeyMap.PageDown)
+		contextKeyMap.AddBindingInShort(baseKeyMap.Export)
 
 		contextKeyMap.AddBindingInFull(baseKeyMap.Up)
 		contextKeyMap.AddBindingInFull(baseKeyMap.Down)
 		contextKeyMap.AddBindingInFull(baseKeyMap.PageUp)
 		contextKeyMap.AddBindingInFull(baseKeyMap.PageDown)
+		contextKeyMap.AddBindingInFull(baseKeyMap.Export)
+		contextKeyMap.AddBindingInFull(baseKeyMap.ExportCSV)
 	}
 
 	return contextKeyMap
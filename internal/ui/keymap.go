@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/martijnspitter/tui-todo/internal/keys"
+)
+
+// ===========================================================================
+// Helpers
+// ===========================================================================
+
+// listKeyMap maps the configured navigation bindings onto a bubbles list
+func listKeyMap(k keys.KeyMap) list.KeyMap {
+	keyMap := list.DefaultKeyMap()
+	keyMap.CursorUp = k.Up
+	keyMap.CursorDown = k.Down
+	keyMap.GoToStart = k.Home
+	keyMap.GoToEnd = k.End
+	keyMap.NextPage = k.PageDown
+	keyMap.PrevPage = k.PageUp
+	keyMap.Filter = k.Filter
+	keyMap.ClearFilter = k.Cancel
+	keyMap.CancelWhileFiltering = k.Cancel
+	return keyMap
+}
+
+// viewportKeyMap maps the configured scroll bindings onto a bubbles viewport
+func viewportKeyMap(k keys.KeyMap) viewport.KeyMap {
+	keyMap := viewport.DefaultKeyMap()
+	keyMap.Up = k.Up
+	keyMap.Down = k.Down
+	keyMap.PageUp = k.PageUp
+	keyMap.PageDown = k.PageDown
+	return keyMap
+}
+
+// keyPress builds a key message that matches bindings containing k
+func keyPress(k string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -8,8 +9,11 @@ import (
 	"github.com/charmbracelet/lipgloss"
 
 	"github.com/martijnspitter/tui-todo/internal/i18n"
+	"github.com/martijnspitter/tui-todo/internal/keys"
 	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/repository"
 	"github.com/martijnspitter/tui-todo/internal/service"
+	"github.com/martijnspitter/tui-todo/internal/socket_sync"
 )
 
 type MainModel struct {
@@ -20,6 +24,7 @@ type MainModel struct {
 	height         int
 	quitting       bool
 	modalComponent tea.Model
+	keySequencer   *keys.Sequencer
 	footer         tea.Model
 	header         tea.Model
 	today          tea.Model
@@ -29,6 +34,7 @@ type MainModel struct {
 
 func NewMainModel(appService *service.AppService, translationService *i18n.TranslationService) *MainModel {
 	tuiService := service.NewTuiService()
+	tuiService.ApplyConfig(appService.GetConfig())
 
 	footer := NewFooterModel(appService, tuiService, translationService)
 	header := NewHeaderModel(tuiService, translationService)
@@ -38,14 +44,15 @@ func NewMainModel(appService *service.AppService, translationService *i18n.Trans
 
 	// Create model
 	m := &MainModel{
-		service:    appService,
-		tuiService: tuiService,
-		translator: translationService,
-		todos:      todos,
-		footer:     footer,
-		header:     header,
-		today:      today,
-		tags:       tags,
+		service:      appService,
+		tuiService:   tuiService,
+		translator:   translationService,
+		todos:        todos,
+		footer:       footer,
+		header:       header,
+		today:        today,
+		tags:         tags,
+		keySequencer: keys.NewSequencer(tuiService.KeyMap),
 	}
 
 	return m
@@ -56,6 +63,28 @@ func (m *MainModel) Init() tea.Cmd {
 }
 
 func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	defer m.announceEditing()
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m.update(msg)
+	}
+	if !m.tuiService.ShouldSequenceKeys() {
+		m.keySequencer.Reset()
+		return m.update(msg)
+	}
+
+	// Key sequences like "g g" arrive as a single key message once complete
+	var cmds []tea.Cmd
+	for _, pressed := range m.keySequencer.Feed(keyMsg) {
+		_, cmd := m.update(pressed)
+		cmds = append(cmds, cmd)
+	}
+
+	return m, tea.Batch(cmds...)
+}
+
+func (m *MainModel) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 	var cmd tea.Cmd
 
@@ -64,6 +93,14 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, m.loadTodosCmd()
 	case LoadTagsMsg:
 		return m, m.loadTagsCmd()
+	case RemoteChangeMsg:
+		if m.needsReload(msg.Change) {
+			// The edit modal still has to learn that its todo changed
+			if m.tuiService.ShouldShowModal() && m.modalComponent != nil {
+				m.modalComponent, cmd = m.modalComponent.Update(msg)
+			}
+			return m, tea.Batch(m.loadTodosCmd(), cmd)
+		}
 	case tea.KeyMsg:
 		if m.tuiService.ShouldShowModal() {
 			// Handle modal
@@ -85,7 +122,7 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.tuiService.RemoveNameFilter()
 				cmd := RemoveFilterCmd()
 				cmds = append(cmds, cmd)
-			} else if !m.tuiService.ShowConfirmQuit {
+			} else if m.tuiService.ConfirmQuit && !m.tuiService.ShowConfirmQuit {
 				m.tuiService.ToggleShowConfirmQuit()
 			} else {
 				m.quitting = true
@@ -113,6 +150,9 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case key.Matches(msg, m.tuiService.KeyMap.About):
 			return m, m.showAboutModalCmd()
+
+		case key.Matches(msg, m.tuiService.KeyMap.Workspace):
+			return m, m.showWorkspaceModalCmd()
 		}
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -156,8 +196,24 @@ func (m *MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.translator.T(fmt.Sprintf("toast.%s", msg.action)),
 			SuccessToast))
 
+	case todosExportedMsg:
+		cmds = append(cmds, ShowDefaultToast(
+			m.translator.Tn("toast.exported", msg.count, map[string]interface{}{"Path": msg.path}),
+			SuccessToast))
+
+	case IncompatiblePeerMsg:
+		key := "toast.sync_refused_peer"
+		if msg.Err.ByPeer {
+			key = "toast.sync_refused_by_primary"
+		}
+		peerVersion := msg.Err.Peer.App
+		if peerVersion == "" {
+			peerVersion = "?"
+		}
+		cmds = append(cmds, ShowDefaultToast(m.translator.Tf(key, map[string]interface{}{"Version": peerVersion}), ErrorToast))
+
 	case TodoErrorMsg:
-		cmds = append(cmds, ShowDefaultToast(m.translator.T(msg.Error()), ErrorToast))
+		cmds = append(cmds, ShowDefaultToast(m.errorText(msg.err), ErrorToast))
 
 	case modalCloseMsg:
 		m.tuiService.SwitchToListView()
@@ -250,6 +306,22 @@ func (m *MainModel) View() string {
 // ===========================================================================
 // Helpers
 // ===========================================================================
+// needsReload reports whether a remote change can't be patched into the active pane
+func (m *MainModel) needsReload(change service.Change) bool {
+	if !change.CanPatch() {
+		return true
+	}
+
+	// Todos list their tags by name, so renamed or deleted tags show up in every todo pane
+	pane := m.tuiService.ActivePane()
+	if change.IsTag() && pane != service.TagsPane {
+		return change.Type != socket_sync.TagCreated
+	}
+
+	// Adding a tag to a todo creates the tag when it doesn't exist yet
+	return !change.IsTag() && pane == service.TagsPane
+}
+
 func truncateString(s string, length int) string {
 	if length <= 0 {
 		return ""
@@ -267,7 +339,21 @@ type todoToggleArchived struct {
 	action string
 }
 type todosLoadedMsg struct {
-	todos []*models.Todo
+	todos    []*models.Todo
+	view     service.ViewType
+	archived bool
+	// more is set when the pane has todos after the loaded ones
+	more bool
+}
+
+// todosPageLoadedMsg carries the todos that follow the ones a pane has loaded
+type todosPageLoadedMsg struct {
+	todos    []*models.Todo
+	view     service.ViewType
+	archived bool
+	after    *repository.Cursor
+	more     bool
+	err      error
 }
 type tagsLoadedMsg struct {
 	tags []*models.Tag
@@ -293,6 +379,15 @@ func (e TodoErrorMsg) Error() string {
 	return e.err.Error()
 }
 
+// errorText translates an error for a toast, a vetoed change says which hook rejected it and why
+func (m *MainModel) errorText(err error) string {
+	var veto *service.HookVetoError
+	if errors.As(err, &veto) && veto.Reason() != "" {
+		return m.translator.Tf("error.hook_vetoed_reason", map[string]interface{}{"Hook": veto.Hook, "Reason": veto.Reason()})
+	}
+	return m.translator.T(err.Error())
+}
+
 type modalCloseMsg struct {
 	reload bool
 }
@@ -307,6 +402,19 @@ type UpdateCheckCompletedMsg struct {
 type LoadTodosMsg struct{}
 type LoadTagsMsg struct{}
 
+// RemoteChangeMsg carries a change made by another instance, which the panes patch in place
+type RemoteChangeMsg struct {
+	Change service.Change
+}
+
+// IncompatiblePeerMsg tells the interface that an instance couldn't join the sync
+type IncompatiblePeerMsg struct {
+	Err *socket_sync.IncompatibleError
+}
+
+// PresenceChangedMsg tells the interface that another instance came, went or opened another todo
+type PresenceChangedMsg struct{}
+
 type RemoveFilterMsg struct{}
 
 type ToggleShowAllHelpMsg struct{}
@@ -315,7 +423,21 @@ type ShowAllHelpToggledMsg struct{}
 // ===========================================================================
 // Commands
 // ===========================================================================
+// announceEditing tells the other instances which todo is open in the edit modal
+func (m *MainModel) announceEditing() {
+	var id int64
+	if modal, ok := m.modalComponent.(*TodoEditModal); ok && m.tuiService.CurrentView == service.AddEditTodoModal && modal.todo.ID > 0 {
+		id = modal.todo.ID
+	}
+	m.service.SetEditing(id)
+}
+
 func (m *MainModel) loadTodosCmd() tea.Cmd {
+	limit := service.PageSize
+	if todosModel, ok := m.todos.(*TodosModel); ok {
+		limit = todosModel.reloadLimit(m.tuiService.CurrentView, m.tuiService.FilterState.IncludeArchived)
+	}
+
 	return func() tea.Msg {
 		if m.tuiService.CurrentView == service.TodayPane {
 			return GetTodayDataMsg{}
@@ -325,16 +447,13 @@ func (m *MainModel) loadTodosCmd() tea.Cmd {
 			return LoadTagsMsg{}
 		}
 
-		todos, err := m.service.GetFilteredTodos(
-			m.tuiService.CurrentView,
-			m.tuiService.FilterState.IncludeArchived,
-		)
-
+		view, archived := m.tuiService.CurrentView, m.tuiService.FilterState.IncludeArchived
+		todos, more, err := m.service.GetFilteredTodosPage(view, archived, nil, limit)
 		if err != nil {
 			return TodoErrorMsg{err: err}
 		}
 
-		return todosLoadedMsg{todos: todos}
+		return todosLoadedMsg{todos: todos, view: view, archived: archived, more: more}
 	}
 }
 
@@ -387,6 +506,28 @@ func (m *MainModel) showAboutModalCmd() tea.Cmd {
 	}
 }
 
+func (m *MainModel) showWorkspaceModalCmd() tea.Cmd {
+	return func() tea.Msg {
+		workspaces, err := m.service.Workspaces()
+		if err != nil {
+			return TodoErrorMsg{err: err}
+		}
+
+		m.tuiService.SwitchToWorkspaceModalView()
+		modal := NewWorkspaceModal(
+			m.width,
+			m.height,
+			m.service,
+			m.tuiService,
+			m.translator,
+			workspaces,
+		)
+		return showModalMsg{
+			modal: modal,
+		}
+	}
+}
+
 func InitTodosCmd() tea.Cmd {
 	return func() tea.Msg {
 		return LoadTodosMsg{}
@@ -28,8 +28,8 @@ const cactus = `
 
 // EmptyNothingFoundView creates an empty state view for when no items are found in a search or filter
 func EmptyNothingFoundView(translator *i18n.TranslationService, width, height int) string {
-	emptyTitle := styling.EmptyStyle.Bold(true).Width(width).Align(lipgloss.Center).Render(translator.T("feedback.nothing_found"))
-	cactusText := styling.EmptyStyle.PaddingRight(2).Render(cactus)
+	emptyTitle := styling.EmptyStyle().Bold(true).Width(width).Align(lipgloss.Center).Render(translator.T("feedback.nothing_found"))
+	cactusText := styling.EmptyStyle().PaddingRight(2).Render(cactus)
 	emptyState := lipgloss.JoinVertical(lipgloss.Center, emptyTitle, cactusText)
 
 	return lipgloss.Place(
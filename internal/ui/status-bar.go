@@ -1,21 +1,32 @@
 package ui
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/martijnspitter/tui-todo/internal/gitlink"
 	"github.com/martijnspitter/tui-todo/internal/i18n"
+	"github.com/martijnspitter/tui-todo/internal/models"
 	"github.com/martijnspitter/tui-todo/internal/service"
 	"github.com/martijnspitter/tui-todo/internal/theme"
 )
 
+// BranchPollInterval is how often the status bar looks up the todo of the checked out branch
+const BranchPollInterval = 5 * time.Second
+
 type StatusBar struct {
 	service    *service.AppService
 	tuiService *service.TuiService
 	translator *i18n.TranslationService
 	width      int
 	height     int
+	// linkedTodo belongs to the git branch checked out in the working directory
+	linkedTodo *models.Todo
 }
 
 func NewStatusBar(service *service.AppService, tuiService *service.TuiService, translator *i18n.TranslationService) *StatusBar {
@@ -26,6 +37,36 @@ func NewStatusBar(service *service.AppService, tuiService *service.TuiService, t
 	}
 }
 
+// checkBranchMsg asks the status bar to look up the todo of the checked out branch
+type checkBranchMsg struct{}
+
+type branchMsg struct {
+	todo *models.Todo
+	// poll is false outside a git repository, where there is no branch to follow
+	poll bool
+}
+
+func CheckBranchCmd() tea.Cmd {
+	return func() tea.Msg {
+		return checkBranchMsg{}
+	}
+}
+
+func (m *StatusBar) lookupBranchCmd() tea.Cmd {
+	return func() tea.Msg {
+		dir, err := os.Getwd()
+		if err != nil {
+			return branchMsg{}
+		}
+		branch, err := gitlink.CurrentBranch(dir)
+		if errors.Is(err, gitlink.ErrNotRepository) {
+			return branchMsg{}
+		}
+		todo, _ := m.service.LinkedTodo(branch)
+		return branchMsg{todo: todo, poll: true}
+	}
+}
+
 func (m *StatusBar) Init() tea.Cmd {
 	return nil
 }
@@ -35,6 +76,15 @@ func (m *StatusBar) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+	case checkBranchMsg:
+		return m, m.lookupBranchCmd()
+	case branchMsg:
+		m.linkedTodo = msg.todo
+		if msg.poll {
+			return m, tea.Tick(BranchPollInterval, func(time.Time) tea.Msg {
+				return checkBranchMsg{}
+			})
+		}
 	}
 
 	return m, nil
@@ -47,18 +97,18 @@ func (m *StatusBar) View() string {
 
 	// Base style for the status bar
 	statusBarStyle := lipgloss.NewStyle().
-		Background(theme.BackgroundColor).
-		Foreground(theme.TextColor).
+		Background(theme.Current().Background).
+		Foreground(theme.Current().Text).
 		Width(m.width)
 
 	versionStyle := lipgloss.NewStyle().
-		Background(theme.BackgroundColor).
-		Foreground(theme.TextColor)
+		Background(theme.Current().Background).
+		Foreground(theme.Current().Text)
 
 	// Filter option style
 	filterOptionStyle := lipgloss.NewStyle().
-		Background(theme.BackgroundColor).
-		Foreground(theme.Lavender).
+		Background(theme.Current().Background).
+		Foreground(theme.Current().Secondary).
 		PaddingLeft(1).
 		PaddingRight(1)
 
@@ -87,6 +137,22 @@ func (m *StatusBar) View() string {
 		filterOptions = append(filterOptions, titleFilter)
 	}
 
+	// The default workspace is what there was before workspaces, it isn't worth the space
+	if ws := m.service.Workspace(); ws.Name != "" && !ws.IsDefault() {
+		content = filterOptionStyle.Foreground(theme.Current().Accent).Render("◆ " + ws.Label())
+	}
+
+	if m.linkedTodo != nil {
+		branch := fmt.Sprintf("⎇ TODO-%d %s", m.linkedTodo.ID, truncateString(m.linkedTodo.Title, 30))
+		content = lipgloss.JoinHorizontal(lipgloss.Center, content, filterOptionStyle.Foreground(m.linkedTodo.Status.Color()).Render(branch))
+	}
+
+	// Other instances on this machine, their open todos show up in the edit modal
+	if peers := m.service.Peers(); len(peers) > 0 {
+		windows := m.translator.Tf("presence.peers", map[string]interface{}{"Count": len(peers) + 1})
+		content = lipgloss.JoinHorizontal(lipgloss.Center, content, filterOptionStyle.Render("⧉ "+windows))
+	}
+
 	for i := 1; i < len(filterOptions); i++ {
 		content = lipgloss.JoinHorizontal(lipgloss.Center, content, filterOptions[i])
 	}
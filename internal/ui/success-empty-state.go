@@ -20,9 +20,9 @@ const trophy = `
      `
 
 func EmptySuccessStateView(translator *i18n.TranslationService, width, height int) string {
-	emptyTitle := styling.EmptyStyle.Bold(true).Width(width).Align(lipgloss.Center).Render(translator.T("feedback.no_todos"))
-	emptySubTitle := styling.EmptyStyle.Bold(true).Width(width).Align(lipgloss.Center).Render(translator.T("feedback.mission_accomplished"))
-	trophyText := styling.EmptyStyle.PaddingRight(2).Render(trophy)
+	emptyTitle := styling.EmptyStyle().Bold(true).Width(width).Align(lipgloss.Center).Render(translator.T("feedback.no_todos"))
+	emptySubTitle := styling.EmptyStyle().Bold(true).Width(width).Align(lipgloss.Center).Render(translator.T("feedback.mission_accomplished"))
+	trophyText := styling.EmptyStyle().PaddingRight(2).Render(trophy)
 	emptyState := lipgloss.JoinVertical(lipgloss.Center, emptyTitle, emptySubTitle, trophyText)
 
 	return lipgloss.Place(
@@ -88,10 +88,10 @@ func (m *TagEditModal) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.nameInput.Blur()
 				m.descInput.Focus()
 			}
-		case key.Matches(msg, m.tuiService.KeyMap.Quit):
+		case key.Matches(msg, m.tuiService.KeyMap.Quit, m.tuiService.KeyMap.Cancel):
 			// Close modal without saving
 			return m, func() tea.Msg { return modalCloseMsg{reload: false} }
-		case key.Matches(msg, m.tuiService.KeyMap.AdvanceStatus):
+		case key.Matches(msg, m.tuiService.KeyMap.Save):
 			return m, m.saveChangesCmd()
 		}
 	case tea.WindowSizeMsg:
@@ -116,20 +116,20 @@ func (m *TagEditModal) View() string {
 		Border(lipgloss.RoundedBorder()).
 		Padding(1, 2).
 		Width(m.width / 2).
-		BorderForeground(theme.Mauve)
+		BorderForeground(theme.Current().Accent)
 
 	title := m.translator.T("modal.new_tag")
 	if m.tag.ID >= 0 {
 		title = m.translator.Tf("modal.edit_tag", map[string]interface{}{"ID": m.tag.ID})
 	}
-	header := styling.TextStyle.Render(title)
+	header := styling.TextStyle().Render(title)
 
 	nameTitle := m.translator.T("field.name")
 	descTitle := m.translator.T("field.description")
 	if m.nameInputSelected {
-		nameTitle = styling.FocusedStyle.Render(nameTitle)
+		nameTitle = styling.FocusedStyle().Render(nameTitle)
 	} else {
-		descTitle = styling.FocusedStyle.Render(descTitle)
+		descTitle = styling.FocusedStyle().Render(descTitle)
 	}
 
 	content := fmt.Sprintf(
@@ -3,7 +3,6 @@ package ui
 import (
 	"fmt"
 	"io"
-	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
@@ -54,12 +53,12 @@ func (m TagModel) Render(w io.Writer, l list.Model, index int, listItem list.Ite
 	}
 
 	selected := styling.GetSelectedBlock(index == l.Index())
-	translatedUpdatedAt := m.translator.Tf("ui.updated", map[string]interface{}{"Time": i.tag.UpdatedAt.Format(time.Stamp)})
+	translatedUpdatedAt := m.translator.Tf("ui.updated", map[string]interface{}{"Time": m.tuiService.FormatDate(i.tag.UpdatedAt)})
 	updatedAt := styling.GetStyledUpdatedAt(translatedUpdatedAt)
 	requItemsWidth := lipgloss.Width(selected) + lipgloss.Width(updatedAt)
 	nameWidth, descriptionWidth := m.tuiService.DetermineMaxWidthsForTag(l.Width()-4, requItemsWidth)
-	name := styling.TextStyle.MarginRight(1).Width(nameWidth).Render(truncateString(i.Title(), nameWidth))
-	description := styling.SubtextStyle.Width(descriptionWidth).Render(truncateString(i.Description(), descriptionWidth))
+	name := styling.TextStyle().MarginRight(1).Width(nameWidth).Render(truncateString(i.Title(), nameWidth))
+	description := styling.SubtextStyle().Width(descriptionWidth).Render(truncateString(i.Description(), descriptionWidth))
 
 	leftContent := lipgloss.JoinHorizontal(lipgloss.Left, selected, name, description)
 	rightContent := lipgloss.JoinHorizontal(lipgloss.Right, updatedAt)
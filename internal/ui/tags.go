@@ -29,6 +29,7 @@ func NewTagsModel(service *service.AppService, tuiService *service.TuiService, t
 	tagList.SetShowHelp(false)
 	tagList.SetShowStatusBar(false)
 	tagList.SetFilteringEnabled(true)
+	tagList.KeyMap = listKeyMap(tuiService.KeyMap)
 
 	return &TagsModel{
 		service:    service,
@@ -75,12 +76,17 @@ func (m *TagsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 	case tagsLoadedMsg:
 		// Update the list with new tags
-		items := make([]list.Item, len(msg.tags))
-		for i, tag := range msg.tags {
-			items[i] = &TagItem{tag: tag}
+		cmds = append(cmds, m.setTags(msg.tags))
+	case RemoteChangeMsg:
+		if !msg.Change.IsTag() || m.tuiService.ActivePane() != service.TagsPane {
+			break
 		}
-		cmd := m.list.SetItems(items)
-		cmds = append(cmds, cmd)
+
+		tag, ok := msg.Change.Tag()
+		if !ok {
+			return m, InitTagsCmd()
+		}
+		cmds = append(cmds, m.setTags(service.PatchTags(m.allTags(), msg.Change.ID, tag)))
 	case tea.WindowSizeMsg:
 		headerHeight := 3 // Title + top border
 		footerHeight := 3 // Input + bottom padding
@@ -93,6 +99,10 @@ func (m *TagsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	m.list, cmd = m.list.Update(msg)
 	cmds = append(cmds, cmd)
 
+	if m.tuiService.CurrentView == service.TagsPane {
+		m.tuiService.ListFiltering = m.list.FilterState() == list.Filtering
+	}
+
 	return m, tea.Batch(cmds...)
 }
 
@@ -112,6 +122,24 @@ func (m *TagsModel) shouldAllowTagCrud() bool {
 	return m.list.SelectedItem() != nil && m.tuiService.CurrentView == service.TagsPane
 }
 
+func (m *TagsModel) allTags() []*models.Tag {
+	tags := make([]*models.Tag, 0, len(m.list.Items()))
+	for _, item := range m.list.Items() {
+		if tagItem, ok := item.(*TagItem); ok {
+			tags = append(tags, tagItem.tag)
+		}
+	}
+	return tags
+}
+
+func (m *TagsModel) setTags(tags []*models.Tag) tea.Cmd {
+	items := make([]list.Item, len(tags))
+	for i, tag := range tags {
+		items[i] = &TagItem{tag: tag}
+	}
+	return m.list.SetItems(items)
+}
+
 func (m *TagsModel) SetHeight(height int) {
 	m.height = height
 	m.list.SetHeight(height)
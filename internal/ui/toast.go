@@ -95,29 +95,29 @@ func (m *ToastModel) View() string {
 	switch m.Type {
 	case ErrorToast:
 		style = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFFFFF")).
-			Background(theme.ErrorColor).
+			Foreground(theme.Current().ToastText).
+			Background(theme.Current().Error).
 			Padding(1, 1).
 			Bold(true)
 
 	case WarningToast:
 		style = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#000000")).
-			Background(theme.WarningColor).
+			Foreground(theme.Current().Inverse).
+			Background(theme.Current().Warning).
 			Padding(1, 1).
 			Bold(true)
 
 	case SuccessToast:
 		style = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFFFFF")).
-			Background(theme.SuccessColor).
+			Foreground(theme.Current().ToastText).
+			Background(theme.Current().Success).
 			Padding(1, 1).
 			Bold(true)
 
 	default: // InfoToast
 		style = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFFFFF")).
-			Background(theme.InfoColor).
+			Foreground(theme.Current().ToastText).
+			Background(theme.Current().Info).
 			Padding(1, 1).
 			Bold(true)
 	}
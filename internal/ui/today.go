@@ -2,10 +2,13 @@ package ui
 
 import (
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/martijnspitter/tui-todo/internal/export"
 	"github.com/martijnspitter/tui-todo/internal/i18n"
 	"github.com/martijnspitter/tui-todo/internal/models"
 	"github.com/martijnspitter/tui-todo/internal/service"
@@ -60,12 +63,27 @@ func (m *TodayDashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
 	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.tuiService.CurrentView == service.TodayPane {
+			switch {
+			case key.Matches(msg, m.tuiService.KeyMap.Export):
+				return m, m.exportCmd(export.Markdown)
+			case key.Matches(msg, m.tuiService.KeyMap.ExportCSV):
+				return m, m.exportCmd(export.CSV)
+			}
+		}
 	case GetTodayDataMsg:
 		cmd = m.GetTodayDataCmd()
 		return m, cmd
 	case GetCompletionStats:
 		cmd = m.GetCompletionStatsCmd()
 		cmds = append(cmds, cmd)
+	case DayChangedMsg:
+		// Overdue, due today and coming up move on with the day
+		cmds = append(cmds, DayChangeCmd())
+		if m.tuiService.ActivePane() == service.TodayPane {
+			cmds = append(cmds, m.GetTodayDataCmd())
+		}
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height - 4
@@ -74,6 +92,7 @@ func (m *TodayDashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Initialize viewport when we first get a window size
 			m.viewport = viewport.New(m.width, m.height)
 			m.viewport.Style = lipgloss.NewStyle().Padding(0)
+			m.viewport.KeyMap = viewportKeyMap(m.tuiService.KeyMap)
 			m.ready = true
 		} else {
 			// Update viewport size
@@ -85,6 +104,19 @@ func (m *TodayDashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		contentStr := m.renderDashboard()
 		m.viewport.SetContent(contentStr)
 
+	case RemoteChangeMsg:
+		if msg.Change.IsTag() || m.tuiService.ActivePane() != service.TodayPane {
+			break
+		}
+
+		todo, ok := msg.Change.Todo(m.findTodo(msg.Change.ID))
+		if !ok {
+			return m, m.GetTodayDataCmd()
+		}
+		m.patchSections(msg.Change.ID, todo)
+		// The completion stats are counted over all sections, recount them
+		return m, m.GetCompletionStatsCmd()
+
 	case TodayDataUpdatedMsg:
 		// When data is updated, update the viewport content
 		if m.ready {
@@ -109,7 +141,7 @@ func (m *TodayDashboardModel) View() string {
 	// Show the viewport with scroll indicators if necessary
 	scrollIndicator := ""
 	if m.viewport.ScrollPercent() < 1.0 {
-		scrollIndicator = styling.SubtextStyle.Render("\n↓ Scroll for more")
+		scrollIndicator = styling.SubtextStyle().Render("\n↓ Scroll for more")
 	}
 
 	return m.viewport.View() + scrollIndicator
@@ -125,12 +157,12 @@ func (m *TodayDashboardModel) renderDashboard() string {
 	contentWidth := modalWidth - 6 // Account for padding
 	mainBox := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(theme.Mauve).
+		BorderForeground(theme.Current().Accent).
 		Padding(1, 2).
 		Width(modalWidth)
 
 	// Progress bar and overview
-	overviewTitle := styling.TextStyle.Bold(true).Render(m.translator.T("today_overview_title"))
+	overviewTitle := styling.TextStyle().Bold(true).Render(m.translator.T("today_overview_title"))
 	progressBar := m.renderProgressBar()
 
 	timeSpentText := m.translator.Tf("ui.t_time_spent", map[string]interface{}{"Time": m.formattedTimeSpent})
@@ -154,7 +186,7 @@ func (m *TodayDashboardModel) renderDashboard() string {
 	if m.allTodosEmpty() {
 		emptyBox := lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
-			BorderForeground(theme.Green).
+			BorderForeground(theme.Current().Positive).
 			Padding(1, 2).
 			Width(contentWidth).
 			Render(lipgloss.JoinVertical(
@@ -177,16 +209,16 @@ func (m *TodayDashboardModel) renderDashboard() string {
 	}
 
 	// High Priority Tasks Section
-	highPrioTitle := styling.TextStyle.
+	highPrioTitle := styling.TextStyle().
 		Bold(true).
-		Foreground(theme.ErrorColor).
+		Foreground(theme.Current().Error).
 		Render(m.translator.Tf("today_high_prio",
 			map[string]interface{}{"count": len(m.highPriorityTasks)}))
 
 	highPrioTasks := m.renderTasks(m.highPriorityTasks, contentWidth)
 	highPrioBox := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(theme.ErrorColor).
+		BorderForeground(theme.Current().Error).
 		Padding(1, 2).
 		Width(contentWidth).
 		Render(lipgloss.JoinVertical(
@@ -197,16 +229,16 @@ func (m *TodayDashboardModel) renderDashboard() string {
 		))
 
 	// Overdue Tasks Section
-	overdueTitle := styling.TextStyle.
+	overdueTitle := styling.TextStyle().
 		Bold(true).
-		Foreground(theme.WarningColor).
+		Foreground(theme.Current().Warning).
 		Render(m.translator.Tf("today_over_due",
 			map[string]interface{}{"count": len(m.overdueTasks)}))
 
 	overdueTasks := m.renderTasks(m.overdueTasks, contentWidth)
 	overdueBox := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(theme.WarningColor).
+		BorderForeground(theme.Current().Warning).
 		Padding(1, 2).
 		Width(contentWidth).
 		Render(lipgloss.JoinVertical(
@@ -217,16 +249,16 @@ func (m *TodayDashboardModel) renderDashboard() string {
 		))
 
 	// Due Today Tasks Section
-	dueTodayTitle := styling.TextStyle.
+	dueTodayTitle := styling.TextStyle().
 		Bold(true).
-		Foreground(theme.Yellow).
+		Foreground(theme.Current().Highlight).
 		Render(m.translator.Tf("today_due_today",
 			map[string]interface{}{"count": len(m.dueTodayTasks)}))
 
 	dueTodayTasks := m.renderTasks(m.dueTodayTasks, contentWidth)
 	dueTodayBox := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(theme.Yellow).
+		BorderForeground(theme.Current().Highlight).
 		Padding(1, 2).
 		Width(contentWidth).
 		Render(lipgloss.JoinVertical(
@@ -237,16 +269,16 @@ func (m *TodayDashboardModel) renderDashboard() string {
 		))
 
 	// In Progress Tasks Section
-	inProgressTitle := styling.TextStyle.
+	inProgressTitle := styling.TextStyle().
 		Bold(true).
-		Foreground(theme.DoingStatusColor).
+		Foreground(theme.Current().DoingStatus).
 		Render(m.translator.Tf("today_in_progress",
 			map[string]interface{}{"count": len(m.inProgressTasks)}))
 
 	inProgressTasks := m.renderTasks(m.inProgressTasks, contentWidth)
 	inProgressBox := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(theme.DoingStatusColor).
+		BorderForeground(theme.Current().DoingStatus).
 		Padding(1, 2).
 		Width(contentWidth).
 		Render(lipgloss.JoinVertical(
@@ -257,16 +289,16 @@ func (m *TodayDashboardModel) renderDashboard() string {
 		))
 
 	// Blocked Tasks Section
-	blockedTitle := styling.TextStyle.
+	blockedTitle := styling.TextStyle().
 		Bold(true).
-		Foreground(theme.BlockedStatusColor).
+		Foreground(theme.Current().BlockedStatus).
 		Render(m.translator.Tf("today_blocked",
 			map[string]interface{}{"count": len(m.blockedTasks)}))
 
 	blockedTasks := m.renderTasks(m.blockedTasks, contentWidth)
 	blockedBox := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(theme.BlockedStatusColor).
+		BorderForeground(theme.Current().BlockedStatus).
 		Padding(1, 2).
 		Width(contentWidth).
 		Render(lipgloss.JoinVertical(
@@ -277,16 +309,16 @@ func (m *TodayDashboardModel) renderDashboard() string {
 		))
 
 	// Coming Up Tasks Section
-	upcomingTitle := styling.TextStyle.
+	upcomingTitle := styling.TextStyle().
 		Bold(true).
-		Foreground(theme.InfoColor).
+		Foreground(theme.Current().Info).
 		Render(m.translator.Tf("today_coming_up",
 			map[string]interface{}{"count": len(m.upcomingTasks)}))
 
 	upcomingTasks := m.renderTasks(m.upcomingTasks, contentWidth)
 	upcomingBox := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(theme.InfoColor).
+		BorderForeground(theme.Current().Info).
 		Padding(1, 2).
 		Width(contentWidth).
 		Render(lipgloss.JoinVertical(
@@ -343,12 +375,12 @@ func (m *TodayDashboardModel) renderDashboard() string {
 // Helper method to render tasks in a section
 func (m *TodayDashboardModel) renderTasks(tasks []*models.Todo, width int) string {
 	if len(tasks) == 0 {
-		return styling.SubtextStyle.Render("No tasks")
+		return styling.SubtextStyle().Render("No tasks")
 	}
 
 	var renderedTasks []string
 	for _, task := range tasks {
-		taskTitle := styling.TextStyle.Render(task.Title)
+		taskTitle := styling.TextStyle().Render(task.Title)
 
 		// Add tags if present
 		var tagStr string
@@ -394,7 +426,7 @@ func (m *TodayDashboardModel) renderTasks(tasks []*models.Todo, width int) strin
 func (m *TodayDashboardModel) loadingView() string {
 	content := lipgloss.NewStyle().
 		Padding(1, 2).
-		Foreground(theme.InfoColor).
+		Foreground(theme.Current().Info).
 		Bold(true).
 		Render("Loading today's tasks...")
 
@@ -417,7 +449,7 @@ func (m *TodayDashboardModel) renderProgressBar() string {
 	if m.totalTasksCount > 0 {
 		percentage = m.completedTasksCount * 100 / m.totalTasksCount
 	}
-	completedStats := m.translator.Tf("today_completed",
+	completedStats := m.translator.Tn("today_completed", m.totalTasksCount,
 		map[string]interface{}{
 			"completed": m.completedTasksCount,
 			"total":     m.totalTasksCount,
@@ -445,12 +477,47 @@ func (m *TodayDashboardModel) allTodosEmpty() bool {
 // ===========================================================================
 // Messages
 // ===========================================================================
+// patchSections moves a changed todo into the sections it belongs to now, nil removes it
+func (m *TodayDashboardModel) patchSections(id int64, todo *models.Todo) {
+	sections := map[service.TodaySection]*[]*models.Todo{
+		service.HighPrioritySection: &m.highPriorityTasks,
+		service.DueTodaySection:     &m.dueTodayTasks,
+		service.InProgressSection:   &m.inProgressTasks,
+		service.BlockedSection:      &m.blockedTasks,
+		service.OverDueSection:      &m.overdueTasks,
+		service.ComingUpSection:     &m.upcomingTasks,
+	}
+
+	now := time.Now()
+	for section, todos := range sections {
+		belongs := todo != nil && m.service.InTodaySection(todo, section, now)
+		*todos = service.PatchTodos(*todos, id, todo, belongs)
+	}
+}
+
+// findTodo returns the dashboard's copy of a todo, which may be listed in several sections
+func (m *TodayDashboardModel) findTodo(id int64) *models.Todo {
+	for _, todos := range [][]*models.Todo{
+		m.highPriorityTasks, m.dueTodayTasks, m.inProgressTasks, m.blockedTasks, m.overdueTasks, m.upcomingTasks,
+	} {
+		for _, todo := range todos {
+			if todo.ID == id {
+				return todo
+			}
+		}
+	}
+	return nil
+}
+
 type GetTodayDataMsg struct{}
 
 type TodayDataUpdatedMsg struct{}
 
 type GetCompletionStats struct{}
 
+// DayChangedMsg is sent at midnight
+type DayChangedMsg struct{}
+
 // ===========================================================================
 // Commands
 // ===========================================================================
@@ -463,18 +530,34 @@ func (m *TodayDashboardModel) GetCompletionStatsCmd() tea.Cmd {
 
 func (m *TodayDashboardModel) GetTodayDataCmd() tea.Cmd {
 	return func() tea.Msg {
-		var err error
-		m.completedTasksCount, m.totalTasksCount, m.formattedTimeSpent = m.service.GetTodayCompletionStats()
-		m.highPriorityTasks, m.dueTodayTasks, m.inProgressTasks, m.blockedTasks, m.overdueTasks, m.upcomingTasks, err = m.service.GetTodosForToday()
-
+		data, err := m.service.GetTodayData()
 		if err != nil {
 			return TodoErrorMsg{err: err}
 		}
 
+		m.completedTasksCount, m.totalTasksCount, m.formattedTimeSpent = data.Completed, data.Total, data.TimeSpent
+		m.highPriorityTasks, m.dueTodayTasks, m.inProgressTasks = data.HighPrio, data.DueToday, data.InProgress
+		m.blockedTasks, m.overdueTasks, m.upcomingTasks = data.Blocked, data.OverDue, data.ComingUp
 		return TodayDataUpdatedMsg{}
 	}
 }
 
+// DayChangeCmd sends a DayChangedMsg at the next midnight
+func DayChangeCmd() tea.Cmd {
+	// A second late, so the new day has begun on the wall clock too
+	return tea.Tick(service.UntilTomorrow(time.Now())+time.Second, func(time.Time) tea.Msg {
+		return DayChangedMsg{}
+	})
+}
+
+func (m *TodayDashboardModel) exportCmd(format export.Format) tea.Cmd {
+	todos, err := m.service.GetTodosForExport(service.TodayPane, false, "")
+	if err != nil {
+		return func() tea.Msg { return TodoErrorMsg{err: err} }
+	}
+	return exportTodosCmd(todos, service.TodayPane, format, m.translator)
+}
+
 func (m *TodayDashboardModel) TodayDataUpdatedCmd() tea.Cmd {
 	return func() tea.Msg {
 		return TodayDataUpdatedMsg{}
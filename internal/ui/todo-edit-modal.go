@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -53,8 +54,28 @@ type TodoEditModal struct {
 	tuiService   *service.TuiService
 	translator   *i18n.TranslationService
 	help         tea.Model
+
+	// base is the todo as it was opened, a conflicting save is merged against it
+	base             models.Todo
+	conflict         *service.Conflict
+	fieldByField     bool
+	conflictCursor   int
+	useMine          map[service.TodoField]bool
+	changedElsewhere bool
+	deletedElsewhere bool
+	// overrideLock is set after saving a todo locked by another instance was refused once
+	overrideLock bool
 }
 
+// conflict dialog options
+const (
+	keepMineOption = iota
+	keepTheirsOption
+	fieldByFieldOption
+)
+
+var conflictOptions = []string{"conflict.keep_mine", "conflict.keep_theirs", "conflict.field_by_field"}
+
 func NewTodoEditModal(todo *models.Todo, width, height int, appService *service.AppService, tuiService *service.TuiService, translationService *i18n.TranslationService) *TodoEditModal {
 	help := NewHelpModel(appService, tuiService, translationService)
 
@@ -81,7 +102,11 @@ func NewTodoEditModal(todo *models.Todo, width, height int, appService *service.
 		dueDateInput.SetValue(todo.DueDate.Format("2006-01-02 15:04"))
 	}
 
+	base := *todo
+	base.Tags = slices.Clone(todo.Tags)
+
 	return &TodoEditModal{
+		base:         base,
 		todo:         todo,
 		titleInput:   ti,
 		descInput:    desc,
@@ -106,12 +131,36 @@ func (m *TodoEditModal) Init() tea.Cmd {
 func (m *TodoEditModal) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
+	switch msg := msg.(type) {
+	case todoConflictMsg:
+		m.conflict = msg.conflict
+		m.fieldByField = false
+		m.conflictCursor = keepMineOption
+		m.useMine = make(map[service.TodoField]bool)
+		return m, nil
+
+	case RemoteChangeMsg:
+		if m.todo.ID >= 0 && !msg.Change.IsTag() && msg.Change.ID == m.todo.ID {
+			if msg.Change.IsDeleted() {
+				m.deletedElsewhere = true
+			} else {
+				m.changedElsewhere = true
+			}
+		}
+		return m, nil
+	}
+
+	if m.conflict != nil {
+		return m.updateConflict(msg)
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch {
 		case key.Matches(
 			msg,
 			m.tuiService.KeyMap.Quit,
+			m.tuiService.KeyMap.Cancel,
 		):
 			// Close modal without saving
 			return m, func() tea.Msg { return modalCloseMsg{reload: false} }
@@ -148,7 +197,11 @@ func (m *TodoEditModal) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.status = models.Blocked
 			}
 
-		case key.Matches(msg, m.tuiService.KeyMap.AdvanceStatus):
+		case key.Matches(msg, m.tuiService.KeyMap.Save):
+			if _, locked := m.otherEditors(); locked && !m.overrideLock {
+				m.overrideLock = true
+				return m, ShowDefaultToast(m.translator.T("presence.save_again"), WarningToast)
+			}
 			return m, m.saveChangesCmd()
 		}
 
@@ -187,7 +240,11 @@ func (m *TodoEditModal) View() string {
 		Border(lipgloss.RoundedBorder()).
 		Padding(1, 2).
 		Width(m.width / 2).
-		BorderForeground(theme.Mauve)
+		BorderForeground(theme.Current().Accent)
+
+	if m.conflict != nil {
+		return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Render(m.conflictView()))
+	}
 
 	// Priority display
 	var priorityTabs []string
@@ -223,14 +280,14 @@ func (m *TodoEditModal) View() string {
 	// Title field
 	titleField := m.translator.T("field.title")
 	if m.editState == editingTitle {
-		titleField = styling.FocusedStyle.Render(titleField)
+		titleField = styling.FocusedStyle().Render(titleField)
 	}
 	title := fmt.Sprintf("%s\n%s", titleField, m.titleInput.View())
 
 	// Description field
 	descField := m.translator.T("field.description")
 	if m.editState == editingDescription {
-		descField = styling.FocusedStyle.Render(descField)
+		descField = styling.FocusedStyle().Render(descField)
 	}
 	m.descInput.SetWidth((m.width / 2) - 4)
 	description := fmt.Sprintf("%s\n%s", descField, m.descInput.View())
@@ -238,26 +295,26 @@ func (m *TodoEditModal) View() string {
 	// Tags field
 	tagsField := m.translator.T("field.tags")
 	if m.editState == editingTags {
-		tagsField = styling.FocusedStyle.Render(tagsField)
+		tagsField = styling.FocusedStyle().Render(tagsField)
 	}
 	tags := fmt.Sprintf("%s\n%s", tagsField, m.tagsInput.View())
 
 	// Priority header
 	priorityHeader := m.translator.T("field.priority")
 	if m.editState == editingPriorityLow || m.editState == editingPriorityMedium || m.editState == editingPriorityHigh {
-		priorityHeader = styling.FocusedStyle.Render(priorityHeader)
+		priorityHeader = styling.FocusedStyle().Render(priorityHeader)
 	}
 
 	// Status header
 	statusHeader := m.translator.T("field.status")
 	if m.editState == editingStatusOpen || m.editState == editingStatusDoing || m.editState == editingStatusDone || m.editState == editingStatusBlocked {
-		statusHeader = styling.FocusedStyle.Render(statusHeader)
+		statusHeader = styling.FocusedStyle().Render(statusHeader)
 	}
 
 	// Due Date field
 	dueDateField := m.translator.T("field.due_date")
 	if m.editState == editingDueDate {
-		dueDateField = styling.FocusedStyle.Render(dueDateField)
+		dueDateField = styling.FocusedStyle().Render(dueDateField)
 	}
 	dueDate := fmt.Sprintf("%s\n%s", dueDateField, m.dueDateInput.View())
 
@@ -265,7 +322,7 @@ func (m *TodoEditModal) View() string {
 	updatedAt := ""
 	if m.todo.ID >= 0 {
 		updatedAtHeader = m.translator.T("field.updated_at")
-		translatedUpdatedAt := m.translator.Tf("ui.updated", map[string]interface{}{"Time": m.todo.UpdatedAt.Format(time.Stamp)})
+		translatedUpdatedAt := m.translator.Tf("ui.updated", map[string]interface{}{"Time": m.tuiService.FormatDate(m.todo.UpdatedAt)})
 		updatedAtField := styling.GetStyledUpdatedAt(translatedUpdatedAt)
 		updatedAt = fmt.Sprintf("%s\n%s", updatedAtHeader, updatedAtField)
 	}
@@ -278,15 +335,31 @@ func (m *TodoEditModal) View() string {
 
 		remainder := m.width/2 - lipgloss.Width(text) - lipgloss.Width(timeSpend) + 8
 
-		header = lipgloss.JoinHorizontal(lipgloss.Left, styling.TextStyle.Width(remainder).Align(lipgloss.Left).Render(text), timeSpend)
+		header = lipgloss.JoinHorizontal(lipgloss.Left, styling.TextStyle().Width(remainder).Align(lipgloss.Left).Render(text), timeSpend)
 	}
 
 	help := m.help.View()
 
+	header = styling.TextStyle().Render(header)
+
+	// Warn that saving will conflict with a change made elsewhere
+	if m.deletedElsewhere {
+		header += "\n\n" + styling.WarningStyle().Render(m.translator.T("conflict.deleted_elsewhere"))
+	} else if m.changedElsewhere {
+		header += "\n\n" + styling.WarningStyle().Render(m.translator.T("conflict.changed_elsewhere"))
+	}
+	if where, locked := m.otherEditors(); where != "" {
+		warning := "presence.editing_elsewhere"
+		if locked {
+			warning = "presence.locked_elsewhere"
+		}
+		header += "\n\n" + styling.WarningStyle().Render(m.translator.Tf(warning, map[string]interface{}{"Where": where}))
+	}
+
 	// Combine all content
 	content := fmt.Sprintf(
 		"%s\n\n%s\n\n%s\n\n%s\n\n%s\n\n%s\n\n%s\n\n%s\n\n%s",
-		styling.TextStyle.Render(header),
+		header,
 		title,
 		description,
 		tags,
@@ -312,6 +385,25 @@ func (m *TodoEditModal) View() string {
 // ===========================================================================
 // Helpers
 // ===========================================================================
+// otherEditors describes the other instances that have this todo open, and whether one of
+// them locked it
+func (m *TodoEditModal) otherEditors() (where string, locked bool) {
+	if m.todo.ID <= 0 {
+		return "", false
+	}
+
+	var names []string
+	for _, peer := range m.appService.TodoEditors(m.todo.ID) {
+		name := peer.TTY
+		if name == "" {
+			name = fmt.Sprintf("PID %d", peer.PID)
+		}
+		names = append(names, name)
+		locked = locked || peer.Locked
+	}
+	return strings.Join(names, ", "), locked
+}
+
 func (m *TodoEditModal) goForward() {
 	switch m.editState {
 	case editingTitle:
@@ -365,6 +457,11 @@ func (m *TodoEditModal) goBack() {
 type GoBackMsg struct{}
 type GoForwardMsg struct{}
 
+// todoConflictMsg reports a save that conflicts with a change made elsewhere
+type todoConflictMsg struct {
+	conflict *service.Conflict
+}
+
 // ===========================================================================
 // Commands
 // ===========================================================================
@@ -393,6 +490,11 @@ func (m *TodoEditModal) saveChangesCmd() tea.Cmd {
 		tags := m.tagsInput.SelectedTags()
 
 		err := m.appService.SaveTodo(m.todo, tags)
+		if errors.Is(err, service.ErrTodoConflict) {
+			mine := *m.todo
+			mine.Tags = slices.Clone(tags)
+			return m.conflictMsg(&m.base, &mine)
+		}
 		if err != nil {
 			return TodoErrorMsg{err: err}
 		}
@@ -402,6 +504,30 @@ func (m *TodoEditModal) saveChangesCmd() tea.Cmd {
 	}
 }
 
+// saveResolvedCmd saves the outcome of the conflict dialog, which may conflict again
+func (m *TodoEditModal) saveResolvedCmd(todo *models.Todo) tea.Cmd {
+	base := m.conflict.Theirs
+	return func() tea.Msg {
+		err := m.appService.UpdateTodo(todo, todo.Tags)
+		if errors.Is(err, service.ErrTodoConflict) {
+			return m.conflictMsg(&base, todo)
+		}
+		if err != nil {
+			return TodoErrorMsg{err: err}
+		}
+		return modalCloseMsg{reload: true}
+	}
+}
+
+// conflictMsg loads the stored todo to merge mine with
+func (m *TodoEditModal) conflictMsg(base, mine *models.Todo) tea.Msg {
+	theirs, err := m.appService.GetTodo(mine.ID)
+	if err != nil {
+		return TodoErrorMsg{err: err}
+	}
+	return todoConflictMsg{conflict: service.NewConflict(base, mine, theirs)}
+}
+
 func GoToPreviousEditState() tea.Cmd {
 	return func() tea.Msg {
 		return GoBackMsg{}
@@ -414,6 +540,144 @@ func GoToNextEditState() tea.Cmd {
 	}
 }
 
+// ===========================================================================
+// Conflict
+// ===========================================================================
+func (m *TodoEditModal) updateConflict(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case tea.KeyMsg:
+		count := len(conflictOptions)
+		if m.fieldByField {
+			count = len(m.conflict.Conflicting())
+		}
+
+		switch {
+		case key.Matches(msg, m.tuiService.KeyMap.Cancel):
+			if m.fieldByField {
+				m.fieldByField = false
+				m.conflictCursor = fieldByFieldOption
+			} else {
+				// Back to the form, the edits are kept
+				m.conflict = nil
+			}
+
+		case key.Matches(msg, m.tuiService.KeyMap.Quit):
+			return m, func() tea.Msg { return modalCloseMsg{reload: true} }
+
+		case key.Matches(msg, m.tuiService.KeyMap.Up, m.tuiService.KeyMap.Prev):
+			if m.conflictCursor > 0 {
+				m.conflictCursor--
+			}
+
+		case key.Matches(msg, m.tuiService.KeyMap.Down, m.tuiService.KeyMap.Next):
+			if m.conflictCursor < count-1 {
+				m.conflictCursor++
+			}
+
+		case key.Matches(msg, m.tuiService.KeyMap.Select):
+			if m.fieldByField {
+				if m.conflictCursor < count {
+					field := m.conflict.Conflicting()[m.conflictCursor]
+					m.useMine[field] = !m.useMine[field]
+				}
+				return m, nil
+			}
+
+			switch m.conflictCursor {
+			case keepMineOption:
+				return m, m.saveResolvedCmd(m.conflict.KeepMine())
+			case keepTheirsOption:
+				return m, func() tea.Msg { return modalCloseMsg{reload: true} }
+			case fieldByFieldOption:
+				m.fieldByField = true
+				m.conflictCursor = 0
+			}
+
+		case key.Matches(msg, m.tuiService.KeyMap.Save):
+			if m.fieldByField {
+				return m, m.saveResolvedCmd(m.conflict.Merge(m.useMine))
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func (m *TodoEditModal) conflictView() string {
+	var sb strings.Builder
+	sb.WriteString(styling.TextStyle().Render(m.translator.Tf("modal.edit_todo", map[string]interface{}{"ID": m.todo.ID})))
+	sb.WriteString("\n\n")
+	sb.WriteString(styling.WarningStyle().Render(m.translator.T("conflict.title")))
+	sb.WriteString("\n\n")
+
+	if !m.fieldByField {
+		sb.WriteString(m.translator.T("conflict.prompt") + "\n\n")
+		for i, option := range conflictOptions {
+			line := "  " + m.translator.T(option)
+			if i == m.conflictCursor {
+				line = styling.FocusedStyle().Render("> " + m.translator.T(option))
+			}
+			sb.WriteString(line + "\n")
+		}
+		return sb.String()
+	}
+
+	fields := m.conflict.Conflicting()
+	if len(fields) == 0 {
+		sb.WriteString(m.translator.T("conflict.no_conflicting_fields") + "\n")
+	}
+	for i, field := range fields {
+		name := m.translator.T(field.String())
+		if i == m.conflictCursor {
+			name = styling.FocusedStyle().Render("> " + name)
+		} else {
+			name = "  " + name
+		}
+
+		mineMark, theirsMark := "( )", "(•)"
+		if m.useMine[field] {
+			mineMark, theirsMark = theirsMark, mineMark
+		}
+		mine := m.translator.Tf("conflict.mine", map[string]interface{}{"Value": m.fieldValue(&m.conflict.Mine, field)})
+		theirs := m.translator.Tf("conflict.theirs", map[string]interface{}{"Value": m.fieldValue(&m.conflict.Theirs, field)})
+
+		sb.WriteString(fmt.Sprintf("%s\n    %s %s\n    %s %s\n", name, mineMark, mine, theirsMark, theirs))
+	}
+
+	sb.WriteString("\n" + styling.SubtextStyle().Render(m.translator.T("conflict.field_by_field_help")))
+	return sb.String()
+}
+
+// fieldValue formats a field of a todo for the conflict dialog
+func (m *TodoEditModal) fieldValue(todo *models.Todo, field service.TodoField) string {
+	switch field {
+	case service.TitleField:
+		return todo.Title
+	case service.DescriptionField:
+		description, _, _ := strings.Cut(todo.Description, "\n")
+		if len(description) > 40 {
+			description = description[:40] + "…"
+		}
+		return description
+	case service.TagsField:
+		return strings.Join(todo.Tags, ", ")
+	case service.DueDateField:
+		if todo.DueDate == nil {
+			return "-"
+		}
+		return m.tuiService.FormatDate(*todo.DueDate)
+	case service.PriorityField:
+		return m.translator.T(todo.Priority.String())
+	case service.StatusField:
+		return m.translator.T(todo.Status.String())
+	}
+	return ""
+}
+
 // ===========================================================================
 // Tag Selector
 // ===========================================================================
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"io"
 	"strings"
-	"time"
 
 	"slices"
 
@@ -68,7 +67,7 @@ func (d TodoModel) Render(w io.Writer, m list.Model, index int, listItem list.It
 	// Add due date if present
 	dueDate := ""
 	if i.todo.DueDate != nil {
-		translatedDueDate := d.translator.Tf("ui.due", map[string]interface{}{"Time": i.todo.DueDate.Format(time.Stamp)})
+		translatedDueDate := d.translator.Tf("ui.due", map[string]interface{}{"Time": d.tuiService.FormatDate(*i.todo.DueDate)})
 		dueDate = styling.GetStyledDueDate(translatedDueDate, i.todo.Priority)
 
 	}
@@ -83,18 +82,18 @@ func (d TodoModel) Render(w io.Writer, m list.Model, index int, listItem list.It
 
 	titleWidth, descriptionWidth, leftWidth, remainderWidth := d.tuiService.DetermineMaxWidthsForTodo(width, requiredItemsWidth, lipgloss.Width(dueDate))
 
-	title := styling.TextStyle.MarginRight(1).Width(titleWidth).Render(truncateString(i.Title(), titleWidth))
+	title := styling.TextStyle().MarginRight(1).Width(titleWidth).Render(truncateString(i.Title(), titleWidth))
 
 	descStr := ""
 	if descriptionWidth > 50 {
-		descStr = styling.SubtextStyle.Width(descriptionWidth).Render(truncateString(i.Description(), descriptionWidth))
+		descStr = styling.SubtextStyle().Width(descriptionWidth).Render(truncateString(i.Description(), descriptionWidth))
 	} else {
 		descStr = ""
 	}
 
 	if leftWidth >= width {
 		widthAvailableForTitle := width - lipgloss.Width(selected) - 1
-		shortTitle := styling.TextStyle.MarginRight(1).Width(widthAvailableForTitle).Render(truncateString(i.Title(), widthAvailableForTitle))
+		shortTitle := styling.TextStyle().MarginRight(1).Width(widthAvailableForTitle).Render(truncateString(i.Title(), widthAvailableForTitle))
 		row := lipgloss.JoinHorizontal(lipgloss.Center, selected, shortTitle)
 		fmt.Fprint(w, row)
 		return
@@ -127,7 +126,7 @@ func (d TodoModel) Render(w io.Writer, m list.Model, index int, listItem list.It
 		priority int
 	}{dueDate, 1, 3})
 
-	translatedUpdatedAt := d.translator.Tf("ui.updated", map[string]interface{}{"Time": i.todo.UpdatedAt.Format(time.Stamp)})
+	translatedUpdatedAt := d.translator.Tf("ui.updated", map[string]interface{}{"Time": d.tuiService.FormatDate(i.todo.UpdatedAt)})
 	updatedAt := styling.GetStyledUpdatedAt(translatedUpdatedAt)
 	elementsToCheck = append(elementsToCheck, struct {
 		element  string
@@ -5,8 +5,10 @@ import (
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/martijnspitter/tui-todo/internal/export"
 	"github.com/martijnspitter/tui-todo/internal/i18n"
 	"github.com/martijnspitter/tui-todo/internal/models"
+	"github.com/martijnspitter/tui-todo/internal/repository"
 	"github.com/martijnspitter/tui-todo/internal/service"
 	"github.com/martijnspitter/tui-todo/internal/styling"
 )
@@ -18,6 +20,12 @@ type TodosModel struct {
 	list       list.Model
 	width      int
 	height     int
+
+	// The pane the list was loaded for, more is set when todos follow the loaded ones
+	view        service.ViewType
+	archived    bool
+	more        bool
+	loadingMore bool
 }
 
 func NewTodosModel(service *service.AppService, tuiService *service.TuiService, translator *i18n.TranslationService) *TodosModel {
@@ -29,6 +37,7 @@ func NewTodosModel(service *service.AppService, tuiService *service.TuiService,
 	todoList.SetShowHelp(false)
 	todoList.SetShowStatusBar(false)
 	todoList.SetFilteringEnabled(true)
+	todoList.KeyMap = listKeyMap(tuiService.KeyMap)
 
 	return &TodosModel{
 		service:    service,
@@ -55,10 +64,7 @@ func (m *TodosModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if !m.tuiService.FilterState.IsFilterActive {
 				m.tuiService.ActivateTagFilter()
 				m.list.ResetFilter()
-				filterKeyMsg := tea.KeyMsg{
-					Type:  tea.KeyRunes,
-					Runes: []rune{'/'},
-				}
+				filterKeyMsg := keyPress(m.tuiService.KeyMap.Filter.Keys()[0])
 				m.list, cmd = m.list.Update(filterKeyMsg)
 				return m, cmd
 			}
@@ -92,11 +98,18 @@ func (m *TodosModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				isCurrentlyBlocked := item.todo.Status == models.Blocked
 				return m, m.blockTodoCmd(item.todo.ID, isCurrentlyBlocked)
 			}
+		case key.Matches(msg, m.tuiService.KeyMap.Export):
+			if m.shouldAllowExport() {
+				return m, m.exportCmd(export.Markdown)
+			}
+		case key.Matches(msg, m.tuiService.KeyMap.ExportCSV):
+			if m.shouldAllowExport() {
+				return m, m.exportCmd(export.CSV)
+			}
 		case key.Matches(msg, m.tuiService.KeyMap.New):
 			if m.tuiService.CurrentView != service.TagsPane {
-				// Create new Todo
-				todo := &models.Todo{ID: -1}
-				return m, m.showEditModalCmd(todo)
+				// Create new Todo with the configured defaults
+				return m, m.showEditModalCmd(m.service.NewTodo())
 			}
 		}
 	case RemoveFilterMsg:
@@ -110,16 +123,48 @@ func (m *TodosModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		m.list.SetSize(msg.Width, m.height)
 	case todosLoadedMsg:
-		items := make([]list.Item, len(msg.todos))
-		for i, todo := range msg.todos {
-			items[i] = &TodoItem{todo: todo, tuiService: m.tuiService}
+		m.view, m.archived, m.more, m.loadingMore = msg.view, msg.archived, msg.more, false
+		cmds = append(cmds, m.setTodos(msg.todos))
+	case todosPageLoadedMsg:
+		if msg.view != m.view || msg.archived != m.archived || !m.loadingMore {
+			break
+		}
+		m.loadingMore = false
+		if msg.err != nil {
+			// Don't retry on every key press, the next reload tries again
+			m.more = false
+			return m, func() tea.Msg { return TodoErrorMsg{err: msg.err} }
+		}
+		if last := m.lastTodo(); last == nil || last.ID != msg.after.ID {
+			// The list was patched while the page loaded, load the page after the new end
+			break
+		}
+		m.more = msg.more
+		cmds = append(cmds, m.setTodos(append(m.allTodos(), msg.todos...)))
+	case RemoteChangeMsg:
+		pane := m.tuiService.ActivePane()
+		if msg.Change.IsTag() || pane == service.TodayPane || pane == service.TagsPane {
+			break
+		}
+
+		todo, ok := msg.Change.Todo(m.findTodo(msg.Change.ID))
+		if !ok {
+			return m, InitTodosCmd()
 		}
-		cmd := m.list.SetItems(items)
-		cmds = append(cmds, cmd)
+		belongs := todo != nil && service.InPane(todo, pane, m.tuiService.FilterState.IncludeArchived)
+		if belongs && m.more && !m.beforeEnd(todo) {
+			// It's listed after the loaded todos, a later page brings it
+			belongs = false
+		}
+		cmds = append(cmds, m.setTodos(service.PatchTodos(m.allTodos(), msg.Change.ID, todo, belongs)))
 	}
 
 	m.list, cmd = m.list.Update(msg)
-	cmds = append(cmds, cmd)
+	cmds = append(cmds, cmd, m.loadMoreCmd())
+
+	if m.tuiService.IsTodoView() {
+		m.tuiService.ListFiltering = m.list.FilterState() == list.Filtering
+	}
 
 	return m, tea.Batch(cmds...)
 }
@@ -144,6 +189,76 @@ func (m *TodosModel) shouldAllowTodoCrud() bool {
 	return m.list.SelectedItem() != nil && m.tuiService.CurrentView != service.TodayPane && m.tuiService.CurrentView != service.TagsPane
 }
 
+func (m *TodosModel) shouldAllowExport() bool {
+	return m.tuiService.IsTodoView() && m.list.FilterState() != list.Filtering
+}
+
+// visibleTodos returns the todos currently shown, honouring any active title or tag filter
+func (m *TodosModel) visibleTodos() []*models.Todo {
+	items := m.list.VisibleItems()
+	todos := make([]*models.Todo, 0, len(items))
+	for _, item := range items {
+		if todoItem, ok := item.(*TodoItem); ok {
+			todos = append(todos, todoItem.todo)
+		}
+	}
+	return todos
+}
+
+func (m *TodosModel) allTodos() []*models.Todo {
+	todos := make([]*models.Todo, 0, len(m.list.Items()))
+	for _, item := range m.list.Items() {
+		if todoItem, ok := item.(*TodoItem); ok {
+			todos = append(todos, todoItem.todo)
+		}
+	}
+	return todos
+}
+
+func (m *TodosModel) findTodo(id int64) *models.Todo {
+	for _, todo := range m.allTodos() {
+		if todo.ID == id {
+			return todo
+		}
+	}
+	return nil
+}
+
+// lastTodo returns the last loaded todo, the next page starts after it
+func (m *TodosModel) lastTodo() *models.Todo {
+	items := m.list.Items()
+	if len(items) == 0 {
+		return nil
+	}
+	if todoItem, ok := items[len(items)-1].(*TodoItem); ok {
+		return todoItem.todo
+	}
+	return nil
+}
+
+// beforeEnd reports whether the todo is listed before the last loaded todo
+func (m *TodosModel) beforeEnd(todo *models.Todo) bool {
+	last := m.lastTodo()
+	return last == nil || last.ID == todo.ID || repository.InListOrder(todo, last)
+}
+
+// reloadLimit returns how many todos a reload of the pane loads: as many as are loaded now
+// when the pane stays the same, so the selection stays where it is
+func (m *TodosModel) reloadLimit(view service.ViewType, archived bool) int {
+	if view != m.view || archived != m.archived {
+		return service.PageSize
+	}
+	return max(service.PageSize, len(m.list.Items()))
+}
+
+func (m *TodosModel) setTodos(todos []*models.Todo) tea.Cmd {
+	items := make([]list.Item, len(todos))
+	for i, todo := range todos {
+		items[i] = &TodoItem{todo: todo, tuiService: m.tuiService}
+	}
+	return m.list.SetItems(items)
+}
+
 func (m *TodosModel) SetHeight(height int) {
 	m.height = height
 	m.list.SetHeight(height)
@@ -163,6 +278,48 @@ func (m *TodosModel) advanceTodoStatusCmd(todoID int64) tea.Cmd {
 	}
 }
 
+// exportCmd exports the todos currently shown. Without a filter that is the whole pane, also
+// the todos that aren't loaded yet.
+func (m *TodosModel) exportCmd(format export.Format) tea.Cmd {
+	if !m.more || m.list.FilterState() != list.Unfiltered {
+		return exportTodosCmd(m.visibleTodos(), m.tuiService.CurrentView, format, m.translator)
+	}
+
+	view, archived := m.view, m.archived
+	return func() tea.Msg {
+		todos, err := m.service.GetFilteredTodos(view, archived)
+		if err != nil {
+			return TodoErrorMsg{err: err}
+		}
+		return exportTodosCmd(todos, view, format, m.translator)()
+	}
+}
+
+// loadMoreCmd loads the next page once the selection comes within a screen of the end of the
+// loaded todos. Filtering and exporting need every todo, then the rest of the pane is loaded.
+func (m *TodosModel) loadMoreCmd() tea.Cmd {
+	last := m.lastTodo()
+	if !m.more || m.loadingMore || last == nil || !m.tuiService.IsTodoView() {
+		return nil
+	}
+
+	limit := service.PageSize
+	if m.list.FilterState() == list.Unfiltered {
+		if m.list.Index() < len(m.list.Items())-m.list.Paginator.PerPage {
+			return nil
+		}
+	} else {
+		limit = 0
+	}
+
+	m.loadingMore = true
+	view, archived, after := m.view, m.archived, repository.CursorOf(last)
+	return func() tea.Msg {
+		todos, more, err := m.service.GetFilteredTodosPage(view, archived, after, limit)
+		return todosPageLoadedMsg{todos: todos, view: view, archived: archived, after: after, more: more, err: err}
+	}
+}
+
 func (m *TodosModel) showEditModalCmd(todo *models.Todo) tea.Cmd {
 	return func() tea.Msg {
 		m.tuiService.SwitchToEditTodoView()
@@ -55,10 +55,10 @@ func (m *UpdateModal) View() string {
 		Border(lipgloss.RoundedBorder()).
 		Padding(1, 2).
 		Width((m.width / 3) * 2).
-		BorderForeground(theme.Mauve)
+		BorderForeground(theme.Current().Accent)
 
-	title := styling.FocusedStyle.Render(m.translator.T("update_required"))
-	subtitle := styling.TextStyle.Render(m.translator.T("update_required_subtitle"))
+	title := styling.FocusedStyle().Render(m.translator.T("update_required"))
+	subtitle := styling.TextStyle().Render(m.translator.T("update_required_subtitle"))
 	spacer := ""
 
 	notes := styling.RenderMarkdown(updateInfo.Notes)
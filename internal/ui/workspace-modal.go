@@ -0,0 +1,117 @@
+package ui
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/martijnspitter/tui-todo/internal/i18n"
+	"github.com/martijnspitter/tui-todo/internal/service"
+	"github.com/martijnspitter/tui-todo/internal/styling"
+	"github.com/martijnspitter/tui-todo/internal/theme"
+	"github.com/martijnspitter/tui-todo/internal/workspace"
+)
+
+// WorkspaceModal lists the workspaces. Choosing another one quits the interface, which is
+// then reopened on the database of that workspace.
+type WorkspaceModal struct {
+	width      int
+	height     int
+	appService *service.AppService
+	tuiService *service.TuiService
+	translator *i18n.TranslationService
+	workspaces []workspace.Workspace
+	current    workspace.Workspace
+	cursor     int
+	help       tea.Model
+}
+
+func NewWorkspaceModal(width, height int, appService *service.AppService, tuiService *service.TuiService, translator *i18n.TranslationService, workspaces []workspace.Workspace) *WorkspaceModal {
+	current := appService.Workspace()
+	cursor := 0
+	for i, ws := range workspaces {
+		if ws.Dir == current.Dir {
+			cursor = i
+		}
+	}
+
+	return &WorkspaceModal{
+		width:      width,
+		height:     height,
+		appService: appService,
+		tuiService: tuiService,
+		translator: translator,
+		workspaces: workspaces,
+		current:    current,
+		cursor:     cursor,
+		help:       NewHelpModel(appService, tuiService, translator),
+	}
+}
+
+func (m *WorkspaceModal) Init() tea.Cmd {
+	return nil
+}
+
+func (m *WorkspaceModal) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.tuiService.KeyMap.Quit, m.tuiService.KeyMap.Cancel):
+			return m, CloseModalCmd(false)
+		case key.Matches(msg, m.tuiService.KeyMap.Up):
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case key.Matches(msg, m.tuiService.KeyMap.Down):
+			if m.cursor < len(m.workspaces)-1 {
+				m.cursor++
+			}
+		case key.Matches(msg, m.tuiService.KeyMap.Select):
+			selected := m.workspaces[m.cursor]
+			if selected.Dir == m.current.Dir {
+				return m, CloseModalCmd(false)
+			}
+			m.appService.RequestWorkspace(selected)
+			return m, tea.Quit
+		}
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+	}
+
+	return m, nil
+}
+
+func (m *WorkspaceModal) View() string {
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Padding(1, 2).
+		Width(m.width / 2).
+		BorderForeground(theme.Current().Accent)
+
+	rows := []string{
+		styling.FocusedStyle().Render(m.translator.T("workspace.title")),
+		"",
+	}
+	for i, ws := range m.workspaces {
+		label := ws.Label()
+		if ws.Dir == m.current.Dir {
+			label += " " + m.translator.T("workspace.current")
+		}
+
+		if i == m.cursor {
+			rows = append(rows, styling.FocusedStyle().Render("> "+label))
+		} else {
+			rows = append(rows, styling.TextStyle().Render("  "+label))
+		}
+		rows = append(rows, styling.SubtextStyle().Render("  "+ws.Dir))
+	}
+	rows = append(rows, "", m.help.View())
+
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modalStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)),
+	)
+}
@@ -0,0 +1,205 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// A workspace is a directory with its own database, sync socket, log and settings. The
+// default workspace is the data directory itself, so existing installations keep their todos.
+// Named workspaces live below it in workspaces/<name>, and a repository can carry its own in
+// a .todo directory that is found from the working directory like git finds .git.
+
+const (
+	// DefaultName is the workspace that lives in the data directory itself
+	DefaultName = "default"
+	// LocalDirName is the directory holding the workspace of a project
+	LocalDirName = ".todo"
+	// ConfigFileName holds the settings of a workspace, which override the config file
+	ConfigFileName = "config.toml"
+	// LocalName selects the workspace of the project in the working directory
+	LocalName = "."
+
+	namedDir = "workspaces"
+)
+
+// localGitignore keeps the database and socket out of the repository, the settings can be shared
+const localGitignore = "*\n!.gitignore\n!" + ConfigFileName + "\n"
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+var (
+	// ErrNotFound is returned for a named workspace that wasn't created
+	ErrNotFound = errors.New("workspace doesn't exist")
+	// ErrNoLocal is returned when no .todo directory is found from the working directory
+	ErrNoLocal = errors.New("no " + LocalDirName + " directory found")
+	// ErrExists is returned when creating a workspace that is already there
+	ErrExists = errors.New("workspace already exists")
+)
+
+type Workspace struct {
+	Name string
+	// Dir holds the database, socket and log of the workspace
+	Dir string
+	// Local is set for the .todo directory of a project, its name is that of the project
+	Local bool
+}
+
+// ConfigPath returns the settings file of the workspace. The default workspace has none,
+// the config file applies to it as is.
+func (w Workspace) ConfigPath() string {
+	if w.IsDefault() {
+		return ""
+	}
+	return filepath.Join(w.Dir, ConfigFileName)
+}
+
+func (w Workspace) IsDefault() bool {
+	return !w.Local && w.Name == DefaultName
+}
+
+// Label returns the name shown to the user, the project directory for a local workspace
+func (w Workspace) Label() string {
+	if w.Local {
+		return w.Name + " (" + LocalDirName + ")"
+	}
+	return w.Name
+}
+
+// ValidateName checks that name can be used for a named workspace
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%q is not a valid workspace name, use lowercase letters, digits, - and _", name)
+	}
+	return nil
+}
+
+// Default returns the workspace in the data directory root
+func Default(root string) Workspace {
+	return Workspace{Name: DefaultName, Dir: root}
+}
+
+// Named returns the workspace called name below root, which has to exist
+func Named(root, name string) (Workspace, error) {
+	if name == DefaultName {
+		return Default(root), nil
+	}
+	if err := ValidateName(name); err != nil {
+		return Workspace{}, err
+	}
+
+	ws := Workspace{Name: name, Dir: filepath.Join(root, namedDir, name)}
+	if info, err := os.Stat(ws.Dir); err != nil || !info.IsDir() {
+		return Workspace{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return ws, nil
+}
+
+// Find looks for a .todo directory in dir and its parents
+func Find(dir string) (Workspace, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Workspace{}, false
+	}
+
+	for {
+		candidate := filepath.Join(dir, LocalDirName)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return Workspace{Name: filepath.Base(dir), Dir: candidate, Local: true}, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Workspace{}, false
+		}
+		dir = parent
+	}
+}
+
+// Resolve returns the workspace to open. An explicit name wins, "." asks for the project in
+// cwd. Without a name the project in cwd is used when there is one, the default otherwise.
+func Resolve(root, name, cwd string) (Workspace, error) {
+	switch name {
+	case "":
+		if ws, ok := Find(cwd); ok {
+			return ws, nil
+		}
+		return Default(root), nil
+	case LocalName:
+		if ws, ok := Find(cwd); ok {
+			return ws, nil
+		}
+		return Workspace{}, ErrNoLocal
+	default:
+		return Named(root, name)
+	}
+}
+
+// List returns the default workspace, the named ones sorted by name and the project in cwd
+func List(root, cwd string) ([]Workspace, error) {
+	workspaces := []Workspace{Default(root)}
+
+	entries, err := os.ReadDir(filepath.Join(root, namedDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateName(entry.Name()) == nil && entry.Name() != DefaultName {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		workspaces = append(workspaces, Workspace{Name: name, Dir: filepath.Join(root, namedDir, name)})
+	}
+
+	if ws, ok := Find(cwd); ok {
+		workspaces = append(workspaces, ws)
+	}
+	return workspaces, nil
+}
+
+// Create makes a named workspace below root
+func Create(root, name string) (Workspace, error) {
+	if name == DefaultName {
+		return Workspace{}, fmt.Errorf("%w: %s", ErrExists, name)
+	}
+	if err := ValidateName(name); err != nil {
+		return Workspace{}, err
+	}
+
+	ws := Workspace{Name: name, Dir: filepath.Join(root, namedDir, name)}
+	if _, err := os.Stat(ws.Dir); err == nil {
+		return Workspace{}, fmt.Errorf("%w: %s", ErrExists, name)
+	}
+	if err := os.MkdirAll(ws.Dir, 0o755); err != nil {
+		return Workspace{}, err
+	}
+	return ws, nil
+}
+
+// Init makes a .todo workspace for the project in dir. Its .gitignore keeps everything but
+// the settings out of the repository.
+func Init(dir string) (Workspace, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	ws := Workspace{Name: filepath.Base(dir), Dir: filepath.Join(dir, LocalDirName), Local: true}
+	if _, err := os.Stat(ws.Dir); err == nil {
+		return Workspace{}, fmt.Errorf("%w: %s", ErrExists, ws.Dir)
+	}
+	if err := os.MkdirAll(ws.Dir, 0o755); err != nil {
+		return Workspace{}, err
+	}
+	if err := os.WriteFile(filepath.Join(ws.Dir, ".gitignore"), []byte(localGitignore), 0o644); err != nil {
+		return Workspace{}, err
+	}
+	return ws, nil
+}
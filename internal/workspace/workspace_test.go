@@ -0,0 +1,120 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	project := t.TempDir()
+	nested := filepath.Join(project, "src", "pkg")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Create(root, "work"); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+
+	// Without a .todo directory the default workspace is opened
+	ws, err := Resolve(root, "", nested)
+	if err != nil || !ws.IsDefault() || ws.Dir != root {
+		t.Errorf("Resolve() = %+v, %v, want the default workspace", ws, err)
+	}
+	if _, err := Resolve(root, LocalName, nested); !errors.Is(err, ErrNoLocal) {
+		t.Errorf("Resolve(.) without a project = %v, want ErrNoLocal", err)
+	}
+
+	local, err := Init(project)
+	if err != nil {
+		t.Fatalf("Init() error: %v", err)
+	}
+
+	// The project is found from any directory below it, like git finds .git
+	for _, name := range []string{"", LocalName} {
+		ws, err := Resolve(root, name, nested)
+		if err != nil || ws != local || ws.Name != filepath.Base(project) {
+			t.Errorf("Resolve(%q) = %+v, %v, want %+v", name, ws, err, local)
+		}
+	}
+
+	// A name wins over the project
+	ws, err = Resolve(root, "work", nested)
+	if err != nil || ws.Dir != filepath.Join(root, "workspaces", "work") || ws.ConfigPath() != filepath.Join(ws.Dir, ConfigFileName) {
+		t.Errorf("Resolve(work) = %+v, %v", ws, err)
+	}
+	if ws, err := Resolve(root, DefaultName, nested); err != nil || ws.Dir != root || ws.ConfigPath() != "" {
+		t.Errorf("Resolve(default) = %+v, %v", ws, err)
+	}
+	if _, err := Resolve(root, "personal", nested); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve() of a missing workspace = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	root := t.TempDir()
+
+	for _, name := range []string{"Work", "../up", "", "-x"} {
+		if _, err := Create(root, name); err == nil {
+			t.Errorf("Create(%q) succeeded", name)
+		}
+	}
+	if _, err := Create(root, DefaultName); !errors.Is(err, ErrExists) {
+		t.Errorf("Create(default) = %v, want ErrExists", err)
+	}
+	if _, err := Create(root, "side-project"); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if _, err := Create(root, "side-project"); !errors.Is(err, ErrExists) {
+		t.Errorf("second Create() = %v, want ErrExists", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	root := t.TempDir()
+	project := t.TempDir()
+	for _, name := range []string{"work", "personal"} {
+		if _, err := Create(root, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Init(project); err != nil {
+		t.Fatal(err)
+	}
+
+	workspaces, err := List(root, project)
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	var labels []string
+	for _, ws := range workspaces {
+		labels = append(labels, ws.Label())
+	}
+	want := []string{"default", "personal", "work", filepath.Base(project) + " (.todo)"}
+	if len(labels) != len(want) {
+		t.Fatalf("List() = %v, want %v", labels, want)
+	}
+	for i := range want {
+		if labels[i] != want[i] {
+			t.Errorf("List() = %v, want %v", labels, want)
+			break
+		}
+	}
+}
+
+func TestInitIgnoresData(t *testing.T) {
+	ws, err := Init(t.TempDir())
+	if err != nil {
+		t.Fatalf("Init() error: %v", err)
+	}
+	gitignore, err := os.ReadFile(filepath.Join(ws.Dir, ".gitignore"))
+	if err != nil || string(gitignore) != "*\n!.gitignore\n!config.toml\n" {
+		t.Errorf(".gitignore = %q, %v", gitignore, err)
+	}
+	if _, err := Init(filepath.Dir(ws.Dir)); !errors.Is(err, ErrExists) {
+		t.Errorf("second Init() = %v, want ErrExists", err)
+	}
+}